@@ -0,0 +1,570 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/blas"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Default iteration limit of QpActiveSet is ACTIVESET_MAXITERS times the
+// number of constraints.
+const ACTIVESET_MAXITERS = 10
+
+// Machine precision used by the active set method.
+const activeSetEps = 2.220446049250313e-16
+
+// State of the Goldfarb-Idnani method for
+//
+//     minimize    (1/2)*x'*P*x + q'*x
+//     subject to  np_k'*x + c0_k = 0   for equality constraints
+//                 np_k'*x + c0_k >= 0  for inequality constraints
+//
+// with P = L*L'. J = L^{-T}*Q where Q is orthogonal and the first iq
+// columns of J'*N equal the upper triangular R for the active normals N.
+type goldfarbIdnani struct {
+	n, m, p int
+	G, A    *matrix.FloatMatrix
+	h, b    []float64
+	J       []float64 // n x n, column major
+	R       []float64 // n x n, column major
+	Rnorm   float64
+	x0      []float64 // unconstrained minimizer
+	x       []float64
+	act     []int // active constraints; -k-1 for equality k
+	u       []float64
+	iq      int
+	// work space
+	np, d, z, r []float64
+}
+
+// Normal and constant of constraint k; h - G*x >= 0 and A*x - b = 0.
+func (gi *goldfarbIdnani) constraint(k int, np []float64) float64 {
+	if k < 0 {
+		e := -k - 1
+		for j := 0; j < gi.n; j++ {
+			np[j] = gi.A.GetAt(e, j)
+		}
+		return -gi.b[e]
+	}
+	for j := 0; j < gi.n; j++ {
+		np[j] = -gi.G.GetAt(k, j)
+	}
+	return gi.h[k]
+}
+
+// d = J'*np
+func (gi *goldfarbIdnani) computeD() {
+	n := gi.n
+	for i := 0; i < n; i++ {
+		s := 0.0
+		for k := 0; k < n; k++ {
+			s += gi.J[i*n+k] * gi.np[k]
+		}
+		gi.d[i] = s
+	}
+}
+
+// z = J2*d2 where J2 are the columns iq.. of J.
+func (gi *goldfarbIdnani) updateZ() {
+	n := gi.n
+	for k := 0; k < n; k++ {
+		s := 0.0
+		for j := gi.iq; j < n; j++ {
+			s += gi.J[j*n+k] * gi.d[j]
+		}
+		gi.z[k] = s
+	}
+}
+
+// r = R^{-1}*d1 for the active constraints.
+func (gi *goldfarbIdnani) updateR() {
+	n := gi.n
+	for i := gi.iq - 1; i >= 0; i-- {
+		s := gi.d[i]
+		for j := i + 1; j < gi.iq; j++ {
+			s -= gi.R[j*n+i] * gi.r[j]
+		}
+		gi.r[i] = s / gi.R[i*n+i]
+	}
+}
+
+// Adds the constraint with d = J'*np to the factorization. Returns false,
+// leaving the factorization valid for the old active set, if the normal
+// is linearly dependent on the active normals.
+func (gi *goldfarbIdnani) addConstraint() bool {
+	n := gi.n
+	d, J := gi.d, gi.J
+	for j := n - 1; j >= gi.iq+1; j-- {
+		cc, ss := d[j-1], d[j]
+		h := math.Hypot(cc, ss)
+		if h == 0.0 {
+			continue
+		}
+		d[j] = 0.0
+		ss /= h
+		cc /= h
+		if cc < 0.0 {
+			cc, ss = -cc, -ss
+			d[j-1] = -h
+		} else {
+			d[j-1] = h
+		}
+		xny := ss / (1.0 + cc)
+		for k := 0; k < n; k++ {
+			t1, t2 := J[(j-1)*n+k], J[j*n+k]
+			J[(j-1)*n+k] = t1*cc + t2*ss
+			J[j*n+k] = xny*(t1+J[(j-1)*n+k]) - t2
+		}
+	}
+	if math.Abs(d[gi.iq]) <= activeSetEps*gi.Rnorm {
+		return false
+	}
+	for i := 0; i <= gi.iq; i++ {
+		gi.R[gi.iq*n+i] = d[i]
+	}
+	gi.Rnorm = math.Max(gi.Rnorm, math.Abs(d[gi.iq]))
+	gi.iq++
+	return true
+}
+
+// Removes constraint l from the active set and updates the factorization.
+func (gi *goldfarbIdnani) deleteConstraint(l int) {
+	n := gi.n
+	R, J := gi.R, gi.J
+	qq := -1
+	for i := 0; i < gi.iq; i++ {
+		if gi.act[i] == l {
+			qq = i
+			break
+		}
+	}
+	if qq < 0 {
+		return
+	}
+	for i := qq; i < gi.iq-1; i++ {
+		gi.act[i] = gi.act[i+1]
+		gi.u[i] = gi.u[i+1]
+		copy(R[i*n:(i+1)*n], R[(i+1)*n:(i+2)*n])
+	}
+	gi.act[gi.iq-1] = gi.act[gi.iq]
+	gi.u[gi.iq-1] = gi.u[gi.iq]
+	gi.act[gi.iq] = 0
+	gi.u[gi.iq] = 0.0
+	for j := 0; j < gi.iq; j++ {
+		R[(gi.iq-1)*n+j] = 0.0
+	}
+	gi.iq--
+	for j := qq; j < gi.iq; j++ {
+		cc, ss := R[j*n+j], R[j*n+j+1]
+		h := math.Hypot(cc, ss)
+		if h == 0.0 {
+			continue
+		}
+		cc /= h
+		ss /= h
+		R[j*n+j+1] = 0.0
+		if cc < 0.0 {
+			R[j*n+j] = -h
+			cc, ss = -cc, -ss
+		} else {
+			R[j*n+j] = h
+		}
+		xny := ss / (1.0 + cc)
+		for k := j + 1; k < gi.iq; k++ {
+			t1, t2 := R[k*n+j], R[k*n+j+1]
+			R[k*n+j] = t1*cc + t2*ss
+			R[k*n+j+1] = xny*(t1+R[k*n+j]) - t2
+		}
+		for k := 0; k < n; k++ {
+			t1, t2 := J[j*n+k], J[(j+1)*n+k]
+			J[j*n+k] = t1*cc + t2*ss
+			J[(j+1)*n+k] = xny*(J[j*n+k]+t1) - t2
+		}
+	}
+}
+
+// Computes the minimizer x over the active set and the multipliers u:
+//
+//     R'*R*u = -c0 - N'*x0,  x = x0 + J1*R*u.
+//
+// Inequalities with negative multipliers are dropped, most negative
+// first, until the active set is dual feasible.
+func (gi *goldfarbIdnani) resolve() {
+	n := gi.n
+	for {
+		iq := gi.iq
+		// w = -c0 - N'*x0
+		w := make([]float64, iq)
+		for i := 0; i < iq; i++ {
+			c0 := gi.constraint(gi.act[i], gi.np)
+			w[i] = -c0
+			for k := 0; k < n; k++ {
+				w[i] -= gi.np[k] * gi.x0[k]
+			}
+		}
+		// R'*v = w, R*u = v
+		for i := 0; i < iq; i++ {
+			for j := 0; j < i; j++ {
+				w[i] -= gi.R[i*n+j] * w[j]
+			}
+			w[i] /= gi.R[i*n+i]
+		}
+		for i := iq - 1; i >= 0; i-- {
+			for j := i + 1; j < iq; j++ {
+				w[i] -= gi.R[j*n+i] * w[j]
+			}
+			w[i] /= gi.R[i*n+i]
+		}
+		l, umin := 0, 0.0
+		for i := 0; i < iq; i++ {
+			gi.u[i] = w[i]
+			if gi.act[i] >= 0 && w[i] < umin {
+				l, umin = gi.act[i], w[i]
+			}
+		}
+		if umin < 0.0 {
+			gi.deleteConstraint(l)
+			continue
+		}
+		// x = x0 + J1*(R*u)
+		copy(gi.x, gi.x0)
+		for j := 0; j < iq; j++ {
+			ru := 0.0
+			for k := j; k < iq; k++ {
+				ru += gi.R[k*n+j] * gi.u[k]
+			}
+			for k := 0; k < n; k++ {
+				gi.x[k] += gi.J[j*n+k] * ru
+			}
+		}
+		return
+	}
+}
+
+//    Solves the strictly convex quadratic program
+//
+//        minimize    (1/2)*x'*P*x + q'*x
+//        subject to  G*x <= h
+//                    A*x = b
+//
+//    with the dual active set method of Goldfarb and Idnani. P must be
+//    positive definite; it is factored once with lapack.Potrf and the
+//    factorization of the active constraints is updated with Givens
+//    rotations as constraints enter and leave the active set.
+//
+//    The method is intended for small dense problems solved repeatedly.
+//    working is an optional initial working set of rows of G, for example
+//    the active set returned by the previous call. Rows whose multipliers
+//    are negative on the working set, or that are linearly dependent on the
+//    other active rows, are dropped before the iteration starts.
+//
+//    Returns the solution and the indexes of the active rows of G at the
+//    solution. If the constraints are infeasible the status is
+//    PrimalInfeasible and an error is returned.
+//
+//    Only MaxIter of solopts is used; the default is ACTIVESET_MAXITERS
+//    times the number of constraints. solopts may be nil.
+//
+func QpActiveSet(P, q, G, h, A, b *matrix.FloatMatrix, working []int, solopts *SolverOptions) (sol *Solution, active []int, err error) {
+
+	start := time.Now()
+	var stats SolveStats
+	if q == nil || q.Cols() != 1 {
+		err = errors.New("'q' must a column matrix")
+		return
+	}
+	n := q.Rows()
+	if P == nil || !P.SizeMatch(n, n) {
+		err = errors.New(fmt.Sprintf("'P' must be matrix of size (%d,%d)", n, n))
+		return
+	}
+	if G == nil {
+		G = matrix.FloatZeros(0, n)
+	}
+	if h == nil {
+		h = matrix.FloatZeros(0, 1)
+	}
+	m := G.Rows()
+	if G.Cols() != n || !h.SizeMatch(m, 1) {
+		err = errors.New(fmt.Sprintf("'G' must have %d columns and 'h' size (%d,1)", n, m))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	p := A.Rows()
+	if A.Cols() != n || !b.SizeMatch(p, 1) {
+		err = errors.New(fmt.Sprintf("'A' must have %d columns and 'b' size (%d,1)", n, p))
+		return
+	}
+	for _, k := range working {
+		if k < 0 || k >= m {
+			err = errors.New(fmt.Sprintf("working set index %d out of range", k))
+			return
+		}
+	}
+	maxiter := ACTIVESET_MAXITERS * (m + p + 1)
+	if solopts != nil && solopts.MaxIter > 0 {
+		maxiter = solopts.MaxIter
+	}
+
+	gi := &goldfarbIdnani{n: n, m: m, p: p, G: G, A: A}
+	gi.h = h.FloatArray()
+	gi.b = b.FloatArray()
+	gi.act = make([]int, n+1)
+	gi.u = make([]float64, n+1)
+	gi.R = make([]float64, n*n)
+	gi.x = make([]float64, n)
+	gi.np = make([]float64, n)
+	gi.d = make([]float64, n)
+	gi.z = make([]float64, n)
+	gi.r = make([]float64, n)
+
+	// P = L*L', J = L^{-T}
+	t0 := time.Now()
+	L := P.Copy()
+	if err = lapack.Potrf(L); err != nil {
+		err = errors.New("'P' must be positive definite")
+		return
+	}
+	Jm := matrix.FloatIdentity(n)
+	blas.TrsmFloat(L, Jm, 1.0, la.OptTransA)
+	addSince(&stats.FactorTime, t0)
+	stats.Factorizations++
+	gi.J = Jm.FloatArray()
+
+	// x0 = -J*J'*q
+	jq := make([]float64, n)
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			jq[i] += gi.J[i*n+k] * q.GetIndex(k)
+		}
+	}
+	gi.x0 = make([]float64, n)
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			gi.x0[k] -= gi.J[i*n+k] * jq[i]
+		}
+	}
+
+	// equality constraints and the initial working set
+	for e := 0; e < p; e++ {
+		if gi.iq == n {
+			err = errors.New("equality constraints are linearly dependent")
+			return
+		}
+		gi.constraint(-e-1, gi.np)
+		gi.computeD()
+		gi.act[gi.iq] = -e - 1
+		if !gi.addConstraint() {
+			err = errors.New("equality constraints are linearly dependent")
+			return
+		}
+	}
+	inWorking := make([]bool, m)
+	for _, k := range working {
+		if inWorking[k] || gi.iq == n {
+			continue
+		}
+		gi.constraint(k, gi.np)
+		gi.computeD()
+		gi.act[gi.iq] = k
+		if gi.addConstraint() {
+			inWorking[k] = true
+		}
+	}
+	gi.resolve()
+	addSince(&stats.SetupTime, start)
+
+	s := make([]float64, m)
+	isActive := make([]bool, m)
+	excluded := make([]bool, m)
+	status := Unknown
+	iter := 0
+
+outer:
+	for iter < maxiter {
+		// slacks of the inequalities at x
+		for k := range isActive {
+			isActive[k] = false
+		}
+		for i := 0; i < gi.iq; i++ {
+			if gi.act[i] >= 0 {
+				isActive[gi.act[i]] = true
+			}
+		}
+		// most violated inequality not in the active set
+		ip, ss := -1, 0.0
+		for k := 0; k < m; k++ {
+			c0 := gi.constraint(k, gi.np)
+			s[k] = c0
+			for j := 0; j < n; j++ {
+				s[k] += gi.np[j] * gi.x[j]
+			}
+			if !isActive[k] && !excluded[k] && s[k] < ss {
+				ip, ss = k, s[k]
+			}
+		}
+		if ip < 0 || math.Abs(ss) <= float64(m)*activeSetEps*100.0*(1.0+math.Abs(gi.h[ip])) {
+			status = Optimal
+			break
+		}
+		c0 := gi.constraint(ip, gi.np)
+		gi.u[gi.iq] = 0.0
+		gi.act[gi.iq] = ip
+
+		for ; iter < maxiter; iter++ {
+			gi.computeD()
+			gi.updateZ()
+			gi.updateR()
+
+			// largest dual step keeping the active multipliers nonnegative
+			l, t1 := -1, math.Inf(1)
+			for k := 0; k < gi.iq; k++ {
+				if gi.act[k] >= 0 && gi.r[k] > 0.0 && gi.u[k]/gi.r[k] < t1 {
+					t1, l = gi.u[k]/gi.r[k], gi.act[k]
+				}
+			}
+			// full primal step to make constraint ip active
+			zz, znp := 0.0, 0.0
+			for k := 0; k < n; k++ {
+				zz += gi.z[k] * gi.z[k]
+				znp += gi.z[k] * gi.np[k]
+			}
+			t2 := math.Inf(1)
+			if math.Abs(zz) > activeSetEps {
+				t2 = -s[ip] / znp
+			}
+			t := math.Min(t1, t2)
+			if math.IsInf(t, 1) {
+				status = PrimalInfeasible
+				break outer
+			}
+			if math.IsInf(t2, 1) {
+				// dual step only
+				for k := 0; k < gi.iq; k++ {
+					gi.u[k] -= t * gi.r[k]
+				}
+				gi.u[gi.iq] += t
+				gi.deleteConstraint(l)
+				continue
+			}
+			for k := 0; k < n; k++ {
+				gi.x[k] += t * gi.z[k]
+			}
+			for k := 0; k < gi.iq; k++ {
+				gi.u[k] -= t * gi.r[k]
+			}
+			gi.u[gi.iq] += t
+			if t == t2 {
+				iter++
+				if gi.addConstraint() {
+					for k := range excluded {
+						excluded[k] = false
+					}
+				} else {
+					// numerically dependent; skip ip and restart from the
+					// minimizer over the current active set
+					excluded[ip] = true
+					gi.resolve()
+				}
+				continue outer
+			}
+			// partial step; drop the blocking constraint
+			gi.deleteConstraint(l)
+			s[ip] = c0
+			for k := 0; k < n; k++ {
+				s[ip] += gi.np[k] * gi.x[k]
+			}
+		}
+	}
+
+	sol = &Solution{Status: status, Iterations: iter, Stats: stats}
+	active = make([]int, 0, gi.iq)
+	x := matrix.FloatVector(gi.x)
+	z := matrix.FloatZeros(m, 1)
+	y := matrix.FloatZeros(p, 1)
+	for i := 0; i < gi.iq; i++ {
+		// P*x + q = N*u with normals -G_k' and A_e'
+		if k := gi.act[i]; k >= 0 {
+			z.SetIndex(k, gi.u[i])
+			active = append(active, k)
+		} else {
+			y.SetIndex(-k-1, -gi.u[i])
+		}
+	}
+	sortInts(active)
+	slack := matrix.FloatZeros(m, 1)
+	for k := 0; k < m; k++ {
+		gi.constraint(k, gi.np)
+		sk := gi.h[k]
+		for j := 0; j < n; j++ {
+			sk += gi.np[j] * gi.x[j]
+		}
+		slack.SetIndex(k, sk)
+	}
+	switch status {
+	case PrimalInfeasible:
+		err = errors.New("Primal infeasible")
+		sol.PrimalObjective = math.NaN()
+		sol.DualObjective = math.NaN()
+		return
+	case Unknown:
+		err = errors.New("Terminated (maximum iterations reached)")
+	}
+	// P is symmetric; like the factorization read only its lower triangle
+	f := 0.0
+	for j := 0; j < n; j++ {
+		pj := 0.0
+		for k := 0; k < n; k++ {
+			if k <= j {
+				pj += P.GetAt(j, k) * gi.x[k]
+			} else {
+				pj += P.GetAt(k, j) * gi.x[k]
+			}
+		}
+		f += gi.x[j] * (0.5*pj + q.GetIndex(j))
+	}
+	sol.X, sol.S, sol.Z, sol.Y = x, slack, z, y
+	sol.Result = FloatSetNew("x", "y", "s", "z")
+	sol.Result.Set("x", x)
+	sol.Result.Set("y", y)
+	sol.Result.Set("s", slack)
+	sol.Result.Set("z", z)
+	sol.PrimalObjective = f
+	sol.DualObjective = f
+	sol.Gap = vecDot(slack, z)
+	sol.PrimalSlack = minvec(slack.FloatArray())
+	sol.DualSlack = minvec(z.FloatArray())
+	sol.PrimalResidualCert = math.NaN()
+	sol.DualResidualCert = math.NaN()
+	sol.Stats.TotalTime = time.Since(start)
+	return
+}
+
+// Sorts a short list of ints in place.
+func sortInts(v []int) {
+	for k := 1; k < len(v); k++ {
+		for l := k; l > 0 && v[l] < v[l-1]; l-- {
+			v[l], v[l-1] = v[l-1], v[l]
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
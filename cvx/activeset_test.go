@@ -0,0 +1,173 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"strings"
+	"testing"
+)
+
+// minimize (1/2)*x'*P*x + q'*x subject to x >= 0, x0 + x1 + x2 <= 2 and
+// x0 + x2 = 1.5. The optimum is x = (1.1875, 0, 0.3125) with x1 >= 0 active.
+func makeActiveSetQp() (P, q, G, h, A, b *matrix.FloatMatrix) {
+	P = matrix.FloatMatrixStacked([][]float64{
+		[]float64{6.0, 2.0, 1.0},
+		[]float64{2.0, 5.0, 2.0},
+		[]float64{1.0, 2.0, 4.0}}, matrix.RowOrder)
+	q = matrix.FloatVector([]float64{-8.0, 3.0, -3.0})
+	G = matrix.FloatMatrixStacked([][]float64{
+		[]float64{-1.0, 0.0, 0.0},
+		[]float64{0.0, -1.0, 0.0},
+		[]float64{0.0, 0.0, -1.0},
+		[]float64{1.0, 1.0, 1.0}}, matrix.RowOrder)
+	h = matrix.FloatVector([]float64{0.0, 0.0, 0.0, 2.0})
+	A = matrix.FloatMatrixStacked([][]float64{[]float64{1.0, 0.0, 1.0}}, matrix.RowOrder)
+	b = matrix.FloatVector([]float64{1.5})
+	return
+}
+
+func TestActiveSetQp(t *testing.T) {
+	P, q, G, h, A, b := makeActiveSetQp()
+	ref, err := Qp(P, q, G, h, A, b, &SolverOptions{MaxIter: 30}, nil)
+	if err != nil {
+		t.Fatalf("Qp: %s\n", err)
+	}
+	sol, active, err := QpActiveSet(P, q, G, h, A, b, nil, nil)
+	if err != nil {
+		t.Fatalf("QpActiveSet: %s\n", err)
+	}
+	if sol.Status != Optimal {
+		t.Fatalf("status %v\n", sol.Status)
+	}
+	if e := maxDiff(sol.X, matrix.FloatVector([]float64{1.1875, 0.0, 0.3125})); e > 1e-12 {
+		t.Errorf("x = %v\n", sol.X.FloatArray())
+	}
+	for _, v := range []struct {
+		name string
+		x, y *matrix.FloatMatrix
+	}{{"x", sol.X, ref.X}, {"y", sol.Y, ref.Y}, {"z", sol.Z, ref.Z}} {
+		if e := maxDiff(v.x, v.y); e > 1e-6 {
+			t.Errorf("||%s - %s_qp|| = %.3e\n", v.name, v.name, e)
+		}
+	}
+	if math.Abs(sol.PrimalObjective-ref.PrimalObjective) > 1e-6 {
+		t.Errorf("objective %v, Qp %v\n", sol.PrimalObjective, ref.PrimalObjective)
+	}
+	// the active rows are those with zero slack
+	for k := 0; k < G.Rows(); k++ {
+		isActive := false
+		for _, i := range active {
+			isActive = isActive || i == k
+		}
+		if isActive != (sol.S.GetIndex(k) < 1e-9) {
+			t.Errorf("row %d: slack %v, active set %v\n", k, sol.S.GetIndex(k), active)
+		}
+	}
+}
+
+// A start from the returned working set needs no iterations; a working set
+// with a duplicate row drops the dependent row.
+func TestActiveSetWarmStart(t *testing.T) {
+	P, q, G, h, A, b := makeActiveSetQp()
+	cold, active, err := QpActiveSet(P, q, G, h, A, b, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) == 0 || cold.Iterations == 0 {
+		t.Fatalf("trivial test problem: active %v, %d iterations\n", active, cold.Iterations)
+	}
+	warm, active2, err := QpActiveSet(P, q, G, h, A, b, active, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warm.Iterations != 0 {
+		t.Errorf("warm start: %d iterations\n", warm.Iterations)
+	}
+	if e := maxDiff(warm.X, cold.X); e > 1e-12 || len(active2) != len(active) {
+		t.Errorf("warm start: ||x - x_cold|| = %.3e, active %v\n", e, active2)
+	}
+
+	Gd, _ := matrix.FloatMatrixCombined(matrix.StackDown, G, G.GetRow(active[0], nil))
+	hd, _ := matrix.FloatMatrixCombined(matrix.StackDown, h,
+		matrix.FloatVector([]float64{h.GetIndex(active[0])}))
+	working := append(append([]int(nil), active...), G.Rows())
+	dup, _, err := QpActiveSet(P, q, Gd, hd, A, b, working, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := maxDiff(dup.X, cold.X); e > 1e-12 {
+		t.Errorf("duplicate row: ||x - x_cold|| = %.3e\n", e)
+	}
+}
+
+// x0 <= -1 and x0 >= 0.
+func TestActiveSetInfeasible(t *testing.T) {
+	P, q, _, _, _, _ := makeActiveSetQp()
+	G := matrix.FloatMatrixStacked([][]float64{
+		[]float64{1.0, 0.0, 0.0},
+		[]float64{-1.0, 0.0, 0.0}}, matrix.RowOrder)
+	h := matrix.FloatVector([]float64{-1.0, 0.0})
+	sol, _, err := QpActiveSet(P, q, G, h, nil, nil, nil, nil)
+	if err == nil || sol == nil || sol.Status != PrimalInfeasible {
+		t.Errorf("infeasibility not detected: %v\n", err)
+	}
+}
+
+func TestActiveSetDependentEqualities(t *testing.T) {
+	P, q, G, h, _, _ := makeActiveSetQp()
+	A := matrix.FloatMatrixStacked([][]float64{
+		[]float64{1.0, 0.0, 1.0},
+		[]float64{2.0, 0.0, 2.0}}, matrix.RowOrder)
+	b := matrix.FloatVector([]float64{1.5, 3.0})
+	_, _, err := QpActiveSet(P, q, G, h, A, b, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "linearly dependent") {
+		t.Errorf("dependent equalities accepted: %v\n", err)
+	}
+
+	// more equalities than variables
+	A = matrix.FloatMatrixStacked([][]float64{
+		[]float64{1.0, 0.0, 0.0},
+		[]float64{0.0, 1.0, 0.0},
+		[]float64{0.0, 0.0, 1.0},
+		[]float64{1.0, 1.0, 1.0}}, matrix.RowOrder)
+	b = matrix.FloatVector([]float64{0.5, 0.5, 0.5, 1.5})
+	_, _, err = QpActiveSet(P, q, G, h, A, b, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "linearly dependent") {
+		t.Errorf("%d equalities in %d variables accepted: %v\n", A.Rows(), A.Cols(), err)
+	}
+}
+
+// Only the lower triangle of P is read, as in Qp.
+func TestActiveSetLowerP(t *testing.T) {
+	P, q, G, h, A, b := makeActiveSetQp()
+	ref, _, err := QpActiveSet(P, q, G, h, A, b, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	L := P.Copy()
+	for j := 1; j < 3; j++ {
+		for i := 0; i < j; i++ {
+			L.SetAt(i, j, 0.0)
+		}
+	}
+	sol, _, err := QpActiveSet(L, q, G, h, A, b, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := maxDiff(sol.X, ref.X); e > 1e-12 {
+		t.Errorf("||x - x_full|| = %.3e\n", e)
+	}
+	if math.Abs(sol.PrimalObjective-ref.PrimalObjective) > 1e-12 {
+		t.Errorf("objective %v, with full P %v\n", sol.PrimalObjective, ref.PrimalObjective)
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
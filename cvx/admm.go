@@ -0,0 +1,547 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/blas"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Default parameters of QpAdmm.
+const (
+	ADMM_MAXITERS       = 4000
+	ADMM_ABSTOL         = 1e-3
+	ADMM_RELTOL         = 1e-3
+	ADMM_INFTOL         = 1e-4
+	ADMM_RHO            = 0.1
+	ADMM_SIGMA          = 1e-6
+	ADMM_ALPHA          = 1.6
+	ADMM_ADAPT_INTERVAL = 25
+	// rho is refactored only if it changes by more than this factor
+	ADMM_ADAPT_TOL = 5.0
+	ADMM_RHO_MIN   = 1e-6
+	ADMM_RHO_MAX   = 1e6
+	// rho of the equality rows is scaled by this factor
+	ADMM_RHO_EQ = 1e3
+	// bounds of at least this magnitude are treated as infinite
+	ADMM_INFINITY = 1e20
+	// relative residual and iteration limit of the CG solves
+	ADMM_CG_TOL     = 1e-10
+	ADMM_CG_MAXITER = 500
+)
+
+// Parameters of QpAdmm. Zero values select the defaults.
+type AdmmOptions struct {
+	// Initial step size parameter; ADMM_RHO if zero.
+	Rho float64
+	// Regularization of the x-update; ADMM_SIGMA if zero.
+	Sigma float64
+	// Relaxation parameter in (0,2); ADMM_ALPHA if zero.
+	Alpha float64
+	// Absolute and relative tolerances of the residuals.
+	AbsTol float64
+	RelTol float64
+	// Tolerance of the infeasibility certificates.
+	InfTol  float64
+	MaxIter int
+	// Iterations between updates of rho; ADMM_ADAPT_INTERVAL if zero, no
+	// updates if negative.
+	AdaptInterval int
+	// Relative residual and iteration limit of the CG solves of the
+	// reduced equations; ADMM_CG_TOL and ADMM_CG_MAXITER if zero.
+	CGTol        float64
+	CGMaxIter    int
+	ShowProgress bool
+}
+
+// Certificate of infeasibility of QpAdmm. It certifies the problem form
+// l <= A*x <= u of QpAdmm, not the G, h, A, b form of Certificate.
+type AdmmCertificate struct {
+	Status StatusCode
+	// PrimalInfeasible: A'*Y = 0 and u'*max(Y,0) + l'*min(Y,0) < 0.
+	Y *matrix.FloatMatrix
+	// DualInfeasible: P*X = 0, q'*X < 0 and A*X in the recession cone
+	// of [l, u].
+	X *matrix.FloatMatrix
+}
+
+type admmSolver struct {
+	n, m  int
+	P, A  *matrix.FloatMatrix
+	l, u  []float64
+	rho   []float64
+	sigma float64
+	// diagonal of the reduced matrix, the preconditioner of CG
+	diag      []float64
+	cgTol     float64
+	cgMaxIter int
+	// work vectors of CG and of the products with the reduced matrix
+	r, w, p, Kp *matrix.FloatMatrix
+	tm          *matrix.FloatMatrix
+	stats       SolveStats
+}
+
+// v := A*x
+func (s *admmSolver) mulA(x, v *matrix.FloatMatrix) {
+	if s.m > 0 {
+		blas.GemvFloat(s.A, x, v, 1.0, 0.0)
+	}
+}
+
+// v := A'*y
+func (s *admmSolver) mulAt(y, v *matrix.FloatMatrix) {
+	if s.m > 0 {
+		blas.GemvFloat(s.A, y, v, 1.0, 0.0, la.OptTrans)
+	} else {
+		blas.ScalFloat(v, 0.0)
+	}
+}
+
+// v := P*x
+func (s *admmSolver) mulP(x, v *matrix.FloatMatrix) {
+	if s.P != nil {
+		blas.SymvFloat(s.P, x, v, 1.0, 0.0)
+	} else {
+		blas.ScalFloat(v, 0.0)
+	}
+}
+
+// v := (P + sigma*I + A'*diag(rho)*A)*x
+func (s *admmSolver) mult(x, v *matrix.FloatMatrix) {
+	s.mulP(x, v)
+	blas.AxpyFloat(x, v, s.sigma)
+	if s.m > 0 {
+		s.mulA(x, s.tm)
+		ta := s.tm.FloatArray()
+		for i := range ta {
+			ta[i] *= s.rho[i]
+		}
+		blas.GemvFloat(s.A, s.tm, v, 1.0, 1.0, la.OptTrans)
+	}
+}
+
+// Sets the diagonal of P + sigma*I + A'*diag(rho)*A after a change of rho.
+func (s *admmSolver) setPreconditioner() {
+	defer addSince(&s.stats.FactorTime, time.Now())
+	s.stats.Factorizations++
+	for j := 0; j < s.n; j++ {
+		d := s.sigma
+		if s.P != nil {
+			d += s.P.GetAt(j, j)
+		}
+		for i := 0; i < s.m; i++ {
+			a := s.A.GetAt(i, j)
+			d += s.rho[i] * a * a
+		}
+		s.diag[j] = d
+	}
+}
+
+// Solves (P + sigma*I + A'*diag(rho)*A)*x = b by preconditioned conjugate
+// gradients, starting from the value of x.
+func (s *admmSolver) solveReduced(b, x *matrix.FloatMatrix) {
+	defer addSince(&s.stats.SolveTime, time.Now())
+	s.stats.Solves++
+	tol := s.cgTol * blas.Nrm2Float(b)
+	r, w, p, Kp := s.r, s.w, s.p, s.Kp
+	ra, wa, pa := r.FloatArray(), w.FloatArray(), p.FloatArray()
+
+	// r = b - K*x, w = M^{-1}*r, p = w
+	s.mult(x, Kp)
+	blas.Copy(b, r)
+	blas.AxpyFloat(Kp, r, -1.0)
+	for j := range wa {
+		wa[j] = ra[j] / s.diag[j]
+		pa[j] = wa[j]
+	}
+	rw := blas.DotFloat(r, w)
+	for k := 0; k < s.cgMaxIter && blas.Nrm2Float(r) > tol; k++ {
+		s.mult(p, Kp)
+		alpha := rw / blas.DotFloat(p, Kp)
+		blas.AxpyFloat(p, x, alpha)
+		blas.AxpyFloat(Kp, r, -alpha)
+		for j := range wa {
+			wa[j] = ra[j] / s.diag[j]
+		}
+		rwn := blas.DotFloat(r, w)
+		beta := rwn / rw
+		rw = rwn
+		for j := range pa {
+			pa[j] = wa[j] + beta*pa[j]
+		}
+	}
+}
+
+// Sets rho_i from rho by the type of row i.
+func (s *admmSolver) setRho(rho float64) {
+	for i := 0; i < s.m; i++ {
+		switch {
+		case s.l[i] <= -ADMM_INFINITY && s.u[i] >= ADMM_INFINITY:
+			s.rho[i] = ADMM_RHO_MIN
+		case s.u[i]-s.l[i] < 1e-4*ADMM_RHO_MIN:
+			s.rho[i] = ADMM_RHO_EQ * rho
+		default:
+			s.rho[i] = rho
+		}
+	}
+}
+
+func (s *admmSolver) project(i int, v float64) float64 {
+	return math.Min(math.Max(v, s.l[i]), s.u[i])
+}
+
+//    Solves the quadratic program
+//
+//        minimize    (1/2)*x'*P*x + q'*x
+//        subject to  l <= A*x <= u
+//
+//    with the alternating direction method of multipliers in the form of
+//    OSQP (Stellato et al., 2020). Equality constraints have l[i] = u[i];
+//    bounds of magnitude ADMM_INFINITY or more, including math.Inf, are
+//    treated as absent. Only the lower triangular part of P is referenced
+//    and P may be nil for a linear program.
+//
+//    Each iteration solves the reduced equations
+//
+//        (P + sigma*I + A'*diag(rho)*A) * x = sigma*x - q + A'*(rho.*z - y)
+//
+//    of the quasi-definite KKT system by conjugate gradients with the
+//    diagonal preconditioner, warm started from the previous x. CG uses
+//    only products with P, A and A'; no matrix of order n or n+m is
+//    formed or factored, and the work space is O(n+m) beyond the data.
+//    rho is adapted every AdaptInterval iterations from the ratio of the
+//    scaled primal and dual residuals; a change of rho by more than
+//    ADMM_ADAPT_TOL rebuilds the preconditioner, which is counted in
+//    Stats.Factorizations.
+//
+//    The iteration stops when
+//
+//        ||A*x - z||_inf        <= abstol + reltol*max(||A*x||_inf,
+//                                                  ||z||_inf)
+//        ||P*x + q + A'*y||_inf <= abstol + reltol*max(||P*x||_inf,
+//                                                  ||A'*y||_inf, ||q||_inf)
+//
+//    The returned Solution has X = x, Y = y, the multipliers of
+//    l <= A*x <= u (positive at an active upper bound, negative at an
+//    active lower bound), and S = z, the projection of A*x onto [l, u].
+//    Result has the same vectors with keys "x", "y" and "z".
+//
+//    If the successive differences of y or x prove primal or dual
+//    infeasibility the status is PrimalInfeasible or DualInfeasible and
+//    sol.AdmmCertificate holds the normalized difference; sol.Certificate
+//    is nil.
+//
+//    initvals may provide a warm start with keys "x", "y" and "z". Missing
+//    vectors are zero, z defaults to the projection of A*x.
+//
+func QpAdmm(P, q, A, l, u *matrix.FloatMatrix, admmopts *AdmmOptions, initvals *FloatMatrixSet) (sol *Solution, err error) {
+
+	start := time.Now()
+	if q == nil || q.Cols() != 1 {
+		err = errors.New("'q' must a column matrix")
+		return
+	}
+	n := q.Rows()
+	if P != nil && !P.SizeMatch(n, n) {
+		err = errors.New(fmt.Sprintf("'P' must be matrix of size (%d,%d)", n, n))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	m := A.Rows()
+	if A.Cols() != n {
+		err = errors.New(fmt.Sprintf("'A' must have %d columns", n))
+		return
+	}
+	if l == nil || !l.SizeMatch(m, 1) || u == nil || !u.SizeMatch(m, 1) {
+		err = errors.New(fmt.Sprintf("'l' and 'u' must be matrices of size (%d,1)", m))
+		return
+	}
+	for i := 0; i < m; i++ {
+		if l.GetIndex(i) > u.GetIndex(i) || math.IsNaN(l.GetIndex(i)) || math.IsNaN(u.GetIndex(i)) {
+			err = errors.New(fmt.Sprintf("inconsistent bounds on row %d", i))
+			return
+		}
+	}
+
+	opts := AdmmOptions{}
+	if admmopts != nil {
+		opts = *admmopts
+	}
+	if opts.Rho <= 0.0 {
+		opts.Rho = ADMM_RHO
+	}
+	if opts.Sigma <= 0.0 {
+		opts.Sigma = ADMM_SIGMA
+	}
+	if opts.Alpha <= 0.0 || opts.Alpha >= 2.0 {
+		opts.Alpha = ADMM_ALPHA
+	}
+	if opts.AbsTol <= 0.0 {
+		opts.AbsTol = ADMM_ABSTOL
+	}
+	if opts.RelTol <= 0.0 {
+		opts.RelTol = ADMM_RELTOL
+	}
+	if opts.InfTol <= 0.0 {
+		opts.InfTol = ADMM_INFTOL
+	}
+	if opts.MaxIter <= 0 {
+		opts.MaxIter = ADMM_MAXITERS
+	}
+	if opts.AdaptInterval == 0 {
+		opts.AdaptInterval = ADMM_ADAPT_INTERVAL
+	}
+	if opts.CGTol <= 0.0 {
+		opts.CGTol = ADMM_CG_TOL
+	}
+	if opts.CGMaxIter <= 0 {
+		opts.CGMaxIter = ADMM_CG_MAXITER
+	}
+
+	s := &admmSolver{n: n, m: m, P: P, A: A, sigma: opts.Sigma}
+	s.l = make([]float64, m)
+	s.u = make([]float64, m)
+	for i := 0; i < m; i++ {
+		s.l[i] = l.GetIndex(i)
+		s.u[i] = u.GetIndex(i)
+		if s.l[i] <= -ADMM_INFINITY {
+			s.l[i] = math.Inf(-1)
+		}
+		if s.u[i] >= ADMM_INFINITY {
+			s.u[i] = math.Inf(1)
+		}
+	}
+	s.rho = make([]float64, m)
+	s.diag = make([]float64, n)
+	s.cgTol, s.cgMaxIter = opts.CGTol, opts.CGMaxIter
+	s.r = matrix.FloatZeros(n, 1)
+	s.w = matrix.FloatZeros(n, 1)
+	s.p = matrix.FloatZeros(n, 1)
+	s.Kp = matrix.FloatZeros(n, 1)
+	s.tm = matrix.FloatZeros(m, 1)
+	rho := opts.Rho
+	s.setRho(rho)
+	s.setPreconditioner()
+
+	x := matrix.FloatZeros(n, 1)
+	y := matrix.FloatZeros(m, 1)
+	z := matrix.FloatZeros(m, 1)
+	if initvals != nil {
+		if v := initvals.At("x"); len(v) > 0 && v[0] != nil {
+			blas.Copy(v[0], x)
+		}
+		if v := initvals.At("y"); len(v) > 0 && v[0] != nil {
+			blas.Copy(v[0], y)
+		}
+	}
+	Ax := matrix.FloatZeros(m, 1)
+	Px := matrix.FloatZeros(n, 1)
+	Aty := matrix.FloatZeros(n, 1)
+	s.mulA(x, Ax)
+	if v := initvals; v != nil && len(v.At("z")) > 0 && v.At("z")[0] != nil {
+		blas.Copy(v.At("z")[0], z)
+	} else {
+		for i := 0; i < m; i++ {
+			z.SetIndex(i, s.project(i, Ax.GetIndex(i)))
+		}
+	}
+
+	xa, za, ya := x.FloatArray(), z.FloatArray(), y.FloatArray()
+	qa := q.FloatArray()
+	rhs := matrix.FloatZeros(n, 1)
+	ra := rhs.FloatArray()
+	xt := matrix.FloatZeros(n, 1)
+	zt := matrix.FloatZeros(m, 1)
+	xta, zta := xt.FloatArray(), zt.FloatArray()
+	dx := matrix.FloatZeros(n, 1)
+	dy := matrix.FloatZeros(m, 1)
+	dxa, dya := dx.FloatArray(), dy.FloatArray()
+	tmpn := matrix.FloatZeros(n, 1)
+	tmpm := matrix.FloatZeros(m, 1)
+	qnrm := normInf(q)
+
+	sol = &Solution{Status: Unknown}
+	var rprim, rdual float64
+	iter := 0
+	if opts.ShowProgress {
+		fmt.Printf("% 6s% 14s% 10s% 10s% 10s\n", "iter", "objective", "pres", "dres", "rho")
+	}
+	addSince(&s.stats.SetupTime, start)
+	for iter = 0; iter < opts.MaxIter; iter++ {
+		// x~ from the reduced equations, z~ = A*x~
+		ta := s.tm.FloatArray()
+		for i := 0; i < m; i++ {
+			ta[i] = s.rho[i]*za[i] - ya[i]
+		}
+		s.mulAt(s.tm, rhs)
+		for j := 0; j < n; j++ {
+			ra[j] += s.sigma*xa[j] - qa[j]
+		}
+		blas.Copy(x, xt)
+		s.solveReduced(rhs, xt)
+		s.mulA(xt, zt)
+		// relaxation and updates of x, z and y
+		alpha := opts.Alpha
+		for j := 0; j < n; j++ {
+			xn := alpha*xta[j] + (1.0-alpha)*xa[j]
+			dxa[j] = xn - xa[j]
+			xa[j] = xn
+		}
+		for i := 0; i < m; i++ {
+			zr := alpha*zta[i] + (1.0-alpha)*za[i]
+			zn := s.project(i, zr+ya[i]/s.rho[i])
+			yn := ya[i] + s.rho[i]*(zr-zn)
+			dya[i] = yn - ya[i]
+			ya[i] = yn
+			za[i] = zn
+		}
+
+		// residuals
+		s.mulA(x, Ax)
+		s.mulP(x, Px)
+		s.mulAt(y, Aty)
+		rprim = 0.0
+		for i := 0; i < m; i++ {
+			rprim = math.Max(rprim, math.Abs(Ax.GetIndex(i)-za[i]))
+		}
+		rdual = 0.0
+		for j := 0; j < n; j++ {
+			rdual = math.Max(rdual, math.Abs(Px.GetIndex(j)+qa[j]+Aty.GetIndex(j)))
+		}
+		pscale := math.Max(normInf(Ax), normInf(z))
+		dscale := math.Max(math.Max(normInf(Px), normInf(Aty)), qnrm)
+		if opts.ShowProgress {
+			fmt.Printf("% 6d% 14.6e% 10.2e% 10.2e% 10.2e\n", iter, admmObjective(P, q, x, Px), rprim, rdual, rho)
+		}
+		if rprim <= opts.AbsTol+opts.RelTol*pscale &&
+			rdual <= opts.AbsTol+opts.RelTol*dscale {
+			sol.Status = Optimal
+			break
+		}
+
+		// primal infeasibility: A'*dy = 0, u'*max(dy,0) + l'*min(dy,0) < 0
+		if dynrm := normInf(dy); dynrm > 0.0 {
+			s.mulAt(dy, tmpn)
+			if normInf(tmpn) <= opts.InfTol*dynrm {
+				if v, ok := s.supportValue(dya, opts.InfTol*dynrm); ok && v < -opts.InfTol*dynrm {
+					sol.Status = PrimalInfeasible
+					blas.ScalFloat(dy, 1.0/dynrm)
+					sol.AdmmCertificate = &AdmmCertificate{Status: PrimalInfeasible, Y: dy}
+					break
+				}
+			}
+		}
+		// dual infeasibility: P*dx = 0, q'*dx < 0, A*dx in recession cone
+		if dxnrm := normInf(dx); dxnrm > 0.0 {
+			eps := opts.InfTol * dxnrm
+			s.mulP(dx, tmpn)
+			s.mulA(dx, tmpm)
+			if normInf(tmpn) <= eps && blas.DotFloat(q, dx) < -eps && s.recession(tmpm.FloatArray(), eps) {
+				sol.Status = DualInfeasible
+				blas.ScalFloat(dx, 1.0/dxnrm)
+				sol.AdmmCertificate = &AdmmCertificate{Status: DualInfeasible, X: dx}
+				break
+			}
+		}
+
+		// adapt rho to balance the scaled residuals
+		if opts.AdaptInterval > 0 && m > 0 && (iter+1)%opts.AdaptInterval == 0 {
+			pr := rprim / math.Max(pscale, 1e-10)
+			dr := rdual / math.Max(dscale, 1e-10)
+			rnew := rho * math.Sqrt(pr/math.Max(dr, 1e-10))
+			rnew = math.Min(math.Max(rnew, ADMM_RHO_MIN), ADMM_RHO_MAX)
+			if rnew > ADMM_ADAPT_TOL*rho || rnew < rho/ADMM_ADAPT_TOL {
+				rho = rnew
+				s.setRho(rho)
+				s.setPreconditioner()
+			}
+		}
+	}
+
+	sol.Iterations = iter
+	sol.Stats = s.stats
+	sol.Stats.TotalTime = time.Since(start)
+	sol.PrimalInfeasibility = rprim
+	sol.DualInfeasibility = rdual
+	switch sol.Status {
+	case PrimalInfeasible:
+		err = errors.New("Primal infeasible")
+		sol.PrimalObjective = math.NaN()
+		sol.DualObjective = math.NaN()
+	case DualInfeasible:
+		err = errors.New("Dual infeasible")
+		sol.PrimalObjective = math.NaN()
+		sol.DualObjective = math.NaN()
+	default:
+		if sol.Status != Optimal {
+			err = errors.New("Terminated (maximum iterations reached)")
+		}
+		sol.PrimalObjective = admmObjective(P, q, x, Px)
+		sol.DualObjective = math.NaN()
+		sol.Gap = math.NaN()
+		sol.RelativeGap = math.NaN()
+	}
+	sol.X, sol.Y, sol.S = x, y, z
+	sol.Result = FloatSetNew("x", "y", "z")
+	sol.Result.Set("x", x)
+	sol.Result.Set("y", y)
+	sol.Result.Set("z", z)
+	return
+}
+
+// Returns u'*max(y,0) + l'*min(y,0). Components of y within eps of zero are
+// ignored; ok is false if another component multiplies an infinite bound.
+func (s *admmSolver) supportValue(y []float64, eps float64) (v float64, ok bool) {
+	for i, yi := range y {
+		switch {
+		case yi > eps:
+			if math.IsInf(s.u[i], 1) {
+				return 0.0, false
+			}
+			v += s.u[i] * yi
+		case yi < -eps:
+			if math.IsInf(s.l[i], -1) {
+				return 0.0, false
+			}
+			v += s.l[i] * yi
+		}
+	}
+	return v, true
+}
+
+// Reports whether v is within eps of the recession cone of [l, u].
+func (s *admmSolver) recession(v []float64, eps float64) bool {
+	for i, vi := range v {
+		if !math.IsInf(s.u[i], 1) && vi > eps {
+			return false
+		}
+		if !math.IsInf(s.l[i], -1) && vi < -eps {
+			return false
+		}
+	}
+	return true
+}
+
+// (1/2)*x'*P*x + q'*x with Px = P*x.
+func admmObjective(P, q, x, Px *matrix.FloatMatrix) float64 {
+	f := blas.DotFloat(q, x)
+	if P != nil {
+		f += 0.5 * blas.DotFloat(x, Px)
+	}
+	return f
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
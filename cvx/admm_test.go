@@ -0,0 +1,179 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// The problem of makeActiveSetQp in the form l <= A*x <= u.
+func makeAdmmQp() (P, q, A, l, u *matrix.FloatMatrix) {
+	P, q, _, _, _, _ = makeActiveSetQp()
+	inf := math.Inf(1)
+	A = matrix.FloatMatrixStacked([][]float64{
+		[]float64{1.0, 0.0, 1.0},
+		[]float64{1.0, 0.0, 0.0},
+		[]float64{0.0, 1.0, 0.0},
+		[]float64{0.0, 0.0, 1.0},
+		[]float64{1.0, 1.0, 1.0}}, matrix.RowOrder)
+	l = matrix.FloatVector([]float64{1.5, 0.0, 0.0, 0.0, -inf})
+	u = matrix.FloatVector([]float64{1.5, inf, inf, inf, 2.0})
+	return
+}
+
+func TestAdmmConvergence(t *testing.T) {
+	P, q, A, l, u := makeAdmmQp()
+	opts := &AdmmOptions{AbsTol: 1e-8, RelTol: 1e-8}
+	sol, err := QpAdmm(P, q, A, l, u, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sol.Status != Optimal {
+		t.Fatalf("status %v\n", sol.Status)
+	}
+	if e := maxDiff(sol.X, matrix.FloatVector([]float64{1.1875, 0.0, 0.3125})); e > 1e-6 {
+		t.Errorf("x = %v\n", sol.X.FloatArray())
+	}
+	// y of the bound x1 >= 0 is minus the multiplier z1 of QpActiveSet
+	Pa, qa, Ga, ha, Aa, ba := makeActiveSetQp()
+	ref, _, err := QpActiveSet(Pa, qa, Ga, ha, Aa, ba, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := math.Abs(sol.Y.GetIndex(2) + ref.Z.GetIndex(1)); e > 1e-5 {
+		t.Errorf("y = %v, z_activeset = %v\n", sol.Y.FloatArray(), ref.Z.FloatArray())
+	}
+	if math.Abs(sol.PrimalObjective-ref.PrimalObjective) > 1e-6 {
+		t.Errorf("objective %v, expected %v\n", sol.PrimalObjective, ref.PrimalObjective)
+	}
+}
+
+// A box constrained least squares problem with 40 variables and 60 rows
+// agrees with Qp.
+func TestAdmmLarger(t *testing.T) {
+	n, m := 40, 60
+	rnd := rand.New(rand.NewSource(3))
+	F := matrix.FloatZeros(m, n)
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			if rnd.Float64() < 0.2 {
+				F.SetAt(i, j, rnd.NormFloat64())
+			}
+		}
+	}
+	g := matrix.FloatZeros(m, 1)
+	for i := 0; i < m; i++ {
+		g.SetIndex(i, rnd.NormFloat64())
+	}
+	// minimize (1/2)*||F*x - g||^2 subject to -0.2 <= x <= 0.2
+	P := F.Transpose().Times(F)
+	q := F.Transpose().Times(g).Scale(-1.0)
+	l := matrix.FloatWithValue(n, 1, -0.2)
+	u := matrix.FloatWithValue(n, 1, 0.2)
+	sol, err := QpAdmm(P, q, matrix.FloatIdentity(n), l, u, &AdmmOptions{AbsTol: 1e-7, RelTol: 1e-7}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	G, _ := matrix.FloatMatrixCombined(matrix.StackDown, matrix.FloatIdentity(n), matrix.FloatDiagonal(n, -1.0))
+	h := matrix.FloatWithValue(2*n, 1, 0.2)
+	ref, err := Qp(P, q, G, h, nil, nil, &SolverOptions{MaxIter: 30}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := maxDiff(sol.X, ref.X); e > 1e-4 {
+		t.Errorf("||x - x_qp|| = %.3e\n", e)
+	}
+	// one CG solve per iteration, the last one included
+	if sol.Stats.Solves != sol.Iterations+1 {
+		t.Errorf("%d CG solves in %d iterations\n", sol.Stats.Solves, sol.Iterations)
+	}
+}
+
+// A poor initial rho is corrected by refactorizations.
+func TestAdmmAdaptiveRho(t *testing.T) {
+	P, q, A, l, u := makeAdmmQp()
+	opts := &AdmmOptions{Rho: 1e-5, MaxIter: 2000}
+	adapt, err := QpAdmm(P, q, A, l, u, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adapt.Stats.Factorizations < 2 {
+		t.Errorf("rho not adapted: %d factorizations\n", adapt.Stats.Factorizations)
+	}
+	opts.AdaptInterval = -1
+	fixed, _ := QpAdmm(P, q, A, l, u, opts, nil)
+	if fixed.Stats.Factorizations != 1 {
+		t.Errorf("fixed rho: %d factorizations\n", fixed.Stats.Factorizations)
+	}
+	if adapt.Iterations >= fixed.Iterations {
+		t.Errorf("%d iterations with adaptive rho, %d with fixed\n", adapt.Iterations, fixed.Iterations)
+	}
+}
+
+// A start from a solution converges in a few iterations.
+func TestAdmmWarmStart(t *testing.T) {
+	P, q, A, l, u := makeAdmmQp()
+	cold, err := QpAdmm(P, q, A, l, u, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initvals := FloatSetNew("x", "y", "z")
+	initvals.Set("x", cold.X)
+	initvals.Set("y", cold.Y)
+	initvals.Set("z", cold.S)
+	warm, err := QpAdmm(P, q, A, l, u, nil, initvals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warm.Iterations > cold.Iterations/2 {
+		t.Errorf("warm start: %d iterations, cold %d\n", warm.Iterations, cold.Iterations)
+	}
+	if e := maxDiff(warm.X, cold.X); e > 1e-3 {
+		t.Errorf("warm start: ||x - x_cold|| = %.3e\n", e)
+	}
+}
+
+// x0 >= 1 and x0 <= 0.
+func TestAdmmPrimalInfeasible(t *testing.T) {
+	P, q, _, _, _ := makeAdmmQp()
+	A := matrix.FloatMatrixStacked([][]float64{
+		[]float64{1.0, 0.0, 0.0},
+		[]float64{1.0, 0.0, 0.0}}, matrix.RowOrder)
+	l := matrix.FloatVector([]float64{1.0, math.Inf(-1)})
+	u := matrix.FloatVector([]float64{math.Inf(1), 0.0})
+	sol, err := QpAdmm(P, q, A, l, u, nil, nil)
+	if err == nil || sol.Status != PrimalInfeasible || sol.AdmmCertificate == nil || sol.Certificate != nil {
+		t.Fatalf("infeasibility not detected: %v\n", err)
+	}
+	// A'*y = 0 and u'*max(y,0) + l'*min(y,0) < 0
+	y := sol.AdmmCertificate.Y.FloatArray()
+	if math.Abs(y[0]+y[1]) > 1e-3 || !(y[0] < 0.0 && y[1] > 0.0) {
+		t.Errorf("certificate y = %v\n", y)
+	}
+}
+
+// minimize -x0 subject to x0 >= 0.
+func TestAdmmDualInfeasible(t *testing.T) {
+	q := matrix.FloatVector([]float64{-1.0})
+	A := matrix.FloatVector([]float64{1.0})
+	l := matrix.FloatVector([]float64{0.0})
+	u := matrix.FloatVector([]float64{math.Inf(1)})
+	sol, err := QpAdmm(nil, q, A, l, u, nil, nil)
+	if err == nil || sol.Status != DualInfeasible || sol.AdmmCertificate == nil || sol.Certificate != nil {
+		t.Fatalf("unboundedness not detected: %v\n", err)
+	}
+	if x := sol.AdmmCertificate.X.GetIndex(0); math.Abs(x-1.0) > 1e-6 {
+		t.Errorf("certificate x = %v\n", x)
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
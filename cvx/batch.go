@@ -0,0 +1,144 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+)
+
+// Concurrency
+//
+// The solvers of this package may be called concurrently from any number
+// of goroutines. The package level state (the table of KKT solvers and the
+// option values of package linalg) is never written after initialization,
+// all work space of a solve, including the Scaling and KKT factors, belongs
+// to that solve. The input matrices, SolverOptions and starting points are
+// only read, so they may be shared by concurrent solves; the returned
+// solutions are not shared.
+//
+// Concurrent solves also call the BLAS and LAPACK library linked to package
+// linalg concurrently. This is safe only if that library is thread safe,
+// as the reference implementations and OpenBLAS built with thread support
+// are. Some builds are not: older LAPACK releases initialize the machine
+// constants of dlamch in SAVE variables on the first call, and OpenBLAS
+// built without USE_THREAD or USE_LOCKING shares its buffers between
+// callers. With such a library call the solvers from one goroutine at a
+// time, for example with SolveBatch(problems, 1), and leave
+// SolverOptions.Workers at one.
+
+// Problem of SolveBatch.
+type Problem interface {
+	// Solves the problem; the options of a problem may be nil for the
+	// defaults.
+	Solve() (*Solution, error)
+}
+
+// Linear program of Lp.
+type LpProblem struct {
+	C, G, H, A, B          *matrix.FloatMatrix
+	Options                *SolverOptions
+	PrimalStart, DualStart *FloatMatrixSet
+}
+
+// Quadratic program of Qp.
+type QpProblem struct {
+	P, Q, G, H, A, B *matrix.FloatMatrix
+	Options          *SolverOptions
+	InitVals         *FloatMatrixSet
+}
+
+// Second order cone program of Socp.
+type SocpProblem struct {
+	C, Gl, Hl, A, B        *matrix.FloatMatrix
+	Ghq                    *FloatMatrixSet
+	Options                *SolverOptions
+	PrimalStart, DualStart *FloatMatrixSet
+}
+
+// Semidefinite program of Sdp.
+type SdpProblem struct {
+	C, Gl, Hl, A, B        *matrix.FloatMatrix
+	Ghs                    *FloatMatrixSet
+	Options                *SolverOptions
+	PrimalStart, DualStart *FloatMatrixSet
+}
+
+// Cone linear program of ConeLp.
+type ConeLpProblem struct {
+	C, G, H, A, B          *matrix.FloatMatrix
+	Dims                   *DimensionSet
+	Options                *SolverOptions
+	PrimalStart, DualStart *FloatMatrixSet
+}
+
+// Returns solopts, or the default options if solopts is nil.
+func optionsOrDefault(solopts *SolverOptions) *SolverOptions {
+	if solopts == nil {
+		return &SolverOptions{MaxIter: MAXITERS, AbsTol: ABSTOL, RelTol: RELTOL,
+			FeasTol: FEASTOL}
+	}
+	return solopts
+}
+
+func (p *LpProblem) Solve() (*Solution, error) {
+	return Lp(p.C, p.G, p.H, p.A, p.B, optionsOrDefault(p.Options), p.PrimalStart, p.DualStart)
+}
+
+func (p *QpProblem) Solve() (*Solution, error) {
+	return Qp(p.P, p.Q, p.G, p.H, p.A, p.B, optionsOrDefault(p.Options), p.InitVals)
+}
+
+func (p *SocpProblem) Solve() (*Solution, error) {
+	return Socp(p.C, p.Gl, p.Hl, p.A, p.B, p.Ghq, optionsOrDefault(p.Options), p.PrimalStart, p.DualStart)
+}
+
+func (p *SdpProblem) Solve() (*Solution, error) {
+	return Sdp(p.C, p.Gl, p.Hl, p.A, p.B, p.Ghs, optionsOrDefault(p.Options), p.PrimalStart, p.DualStart)
+}
+
+func (p *ConeLpProblem) Solve() (*Solution, error) {
+	return ConeLp(p.C, p.G, p.H, p.A, p.B, p.Dims, optionsOrDefault(p.Options), p.PrimalStart, p.DualStart)
+}
+
+// Solves independent problems on at most workers goroutines, one problem
+// at a time per goroutine. Zero or one worker solves the problems in order
+// in the calling goroutine. Returns the solutions and the errors in the
+// order of problems; a failing problem does not stop the others, and a
+// panic in a solver is returned as the error of its problem.
+//
+// Parallelism inside a solve (SolverOptions.Workers) multiplies with the
+// batch workers; for many small problems one worker per solve is best.
+func SolveBatch(problems []Problem, workers int) (sols []*Solution, errs []error) {
+	sols = make([]*Solution, len(problems))
+	errs = make([]error, len(problems))
+	forBlocks(len(problems), workers, func(k, w int) error {
+		sols[k], errs[k] = solveRecover(k, problems[k])
+		return nil
+	})
+	return
+}
+
+// Solves problem k, converting a panic to an error.
+func solveRecover(k int, p Problem) (sol *Solution, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sol = nil
+			err = errors.New(fmt.Sprintf("problem %d: %v", k, r))
+		}
+	}()
+	if p == nil {
+		err = errors.New(fmt.Sprintf("problem %d: nil problem", k))
+		return
+	}
+	return p.Solve()
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,118 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"fmt"
+	"testing"
+)
+
+// The problems of examples/testlp.go, testqp.go, testsocp.go, testsdp.go
+// and testconelp.go.
+func makeBatchProblems() []Problem {
+	lp := &LpProblem{
+		C: matrix.FloatVector([]float64{-4.0, -5.0}),
+		G: matrix.FloatMatrixStacked([][]float64{
+			[]float64{2.0, 1.0, -1.0, 0.0},
+			[]float64{1.0, 2.0, 0.0, -1.0}}, matrix.ColumnOrder),
+		H: matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})}
+
+	S := matrix.FloatMatrixStacked([][]float64{
+		[]float64{4e-2, 6e-3, -4e-3, 0.0},
+		[]float64{6e-3, 1e-2, 0.0, 0.0},
+		[]float64{-4e-3, 0.0, 2.5e-3, 0.0},
+		[]float64{0.0, 0.0, 0.0, 0.0}})
+	qp := &QpProblem{P: S,
+		Q: matrix.FloatVector([]float64{-.12, -.10, -.07, -.03}),
+		G: matrix.FloatDiagonal(4, -1.0),
+		H: matrix.FloatZeros(4, 1),
+		A: matrix.FloatWithValue(1, 4, 1.0),
+		B: matrix.FloatNew(1, 1, []float64{1.0})}
+
+	Ghq := FloatSetNew("Gq", "hq")
+	Ghq.Append("Gq", matrix.FloatMatrixStacked([][]float64{
+		[]float64{12., 13., 12.},
+		[]float64{6., -3., -12.},
+		[]float64{-5., -5., 6.}}, matrix.ColumnOrder),
+		matrix.FloatMatrixStacked([][]float64{
+			[]float64{3., 3., -1., 1.},
+			[]float64{-6., -6., -9., 19.},
+			[]float64{10., -2., -2., -3.}}, matrix.ColumnOrder))
+	Ghq.Append("hq", matrix.FloatVector([]float64{-12.0, -3.0, -2.0}),
+		matrix.FloatVector([]float64{27.0, 0.0, 3.0, -42.0}))
+	socp := &SocpProblem{C: matrix.FloatVector([]float64{-2.0, 1.0, 5.0}), Ghq: Ghq}
+
+	Ghs := FloatSetNew("Gs", "hs")
+	Ghs.Append("Gs", matrix.FloatMatrixStacked([][]float64{
+		[]float64{-7., -11., -11., 3.},
+		[]float64{7., -18., -18., 8.},
+		[]float64{-2., -8., -8., 1.}}, matrix.ColumnOrder),
+		matrix.FloatMatrixStacked([][]float64{
+			[]float64{-21., -11., 0., -11., 10., 8., 0., 8., 5.},
+			[]float64{0., 10., 16., 10., -10., -10., 16., -10., 3.},
+			[]float64{-5., 2., -17., 2., -6., 8., -17., -7., 6.}}, matrix.ColumnOrder))
+	Ghs.Append("hs", matrix.FloatMatrixStacked([][]float64{
+		[]float64{33., -9.},
+		[]float64{-9., 26.}}, matrix.ColumnOrder),
+		matrix.FloatMatrixStacked([][]float64{
+			[]float64{14., 9., 40.},
+			[]float64{9., 91., 10.},
+			[]float64{40., 10., 15.}}, matrix.ColumnOrder))
+	sdp := &SdpProblem{C: matrix.FloatVector([]float64{1.0, -1.0, 1.0}), Ghs: Ghs}
+
+	c, G, h, dims := makeConeLp()
+	conelp := &ConeLpProblem{C: c, G: G, H: h, Dims: dims}
+
+	return []Problem{lp, qp, socp, sdp, conelp}
+}
+
+// Concurrent solves of problems that share their data give the results of
+// the sequential solves. Equal results do not prove the absence of data
+// races; run this test with go test -race to check the shared data.
+func TestSolveBatch(t *testing.T) {
+	base := makeBatchProblems()
+	ref := make([]*Solution, len(base))
+	for k, p := range base {
+		sol, err := p.Solve()
+		if err != nil {
+			t.Fatalf("problem %d: %s\n", k, err)
+		}
+		ref[k] = sol
+	}
+	problems := make([]Problem, 0)
+	for i := 0; i < 20; i++ {
+		problems = append(problems, base...)
+	}
+	// an invalid problem fails alone
+	problems = append(problems, &LpProblem{C: matrix.FloatVector([]float64{1.0})}, nil)
+
+	sols, errs := SolveBatch(problems, 8)
+	if len(sols) != len(problems) || len(errs) != len(problems) {
+		t.Fatalf("%d solutions and %d errors for %d problems\n", len(sols), len(errs), len(problems))
+	}
+	for k := range problems[:len(problems)-2] {
+		if errs[k] != nil {
+			t.Fatalf("problem %d: %s\n", k, errs[k])
+		}
+		r := ref[k%len(base)]
+		checkEqual(t, fmt.Sprintf("x%d", k), sols[k].X, r.X)
+		checkEqual(t, fmt.Sprintf("z%d", k), sols[k].Z, r.Z)
+		if sols[k].Iterations != r.Iterations {
+			t.Errorf("problem %d: %d iterations, %d sequentially\n", k, sols[k].Iterations, r.Iterations)
+		}
+	}
+	for _, k := range []int{len(problems) - 2, len(problems) - 1} {
+		if errs[k] == nil || sols[k] != nil {
+			t.Errorf("problem %d: invalid problem solved\n", k)
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
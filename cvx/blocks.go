@@ -0,0 +1,428 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Second order cone constraint G*x + sq = H, sq >= 0, of SolveSocp.
+// G has n columns and H is a column vector with G.Rows() elements.
+type SocpBlock struct {
+	G, H *matrix.FloatMatrix
+}
+
+// Linear matrix inequality mat(G*x) + ss = H, ss >= 0, of SolveSdp.
+// H is a symmetric m by m matrix and G has m*m rows and n columns.
+type SdpBlock struct {
+	G, H *matrix.FloatMatrix
+}
+
+// Initial point of SolveSocp. The primal start is used if X is set and
+// the dual start if Zl or Zq is set. Sl and Zl may be nil if there are no
+// linear inequalities.
+type SocpStart struct {
+	X, Sl *matrix.FloatMatrix
+	Sq    []*matrix.FloatMatrix
+	Y, Zl *matrix.FloatMatrix
+	Zq    []*matrix.FloatMatrix
+}
+
+// Initial point of SolveSdp. The primal start is used if X is set and
+// the dual start if Zl or Zs is set. Ss and Zs are symmetric matrices.
+type SdpStart struct {
+	X, Sl *matrix.FloatMatrix
+	Ss    []*matrix.FloatMatrix
+	Y, Zl *matrix.FloatMatrix
+	Zs    []*matrix.FloatMatrix
+}
+
+// Solution blocks of SolveSocp. The slack and dual variables are nil if
+// the solver did not return a solution.
+type SocpResult struct {
+	X, Y, Sl, Zl *matrix.FloatMatrix
+	Sq, Zq       []*matrix.FloatMatrix
+}
+
+// Solution blocks of SolveSdp. The slack and dual variables are nil if
+// the solver did not return a solution.
+type SdpResult struct {
+	X, Y, Sl, Zl *matrix.FloatMatrix
+	Ss, Zs       []*matrix.FloatMatrix
+}
+
+// Returns SOCP blocks from the "Gq" and "hq" sets of Ghq.
+func SocpBlocksFromSet(Ghq *FloatMatrixSet) ([]SocpBlock, error) {
+	if Ghq == nil {
+		return nil, nil
+	}
+	Gq, hq := Ghq.At("Gq"), Ghq.At("hq")
+	if len(Gq) != len(hq) {
+		return nil, errors.New(fmt.Sprintf("'hq' must be a list of %d matrices", len(Gq)))
+	}
+	blocks := make([]SocpBlock, len(Gq))
+	for k := range Gq {
+		blocks[k] = SocpBlock{Gq[k], hq[k]}
+	}
+	return blocks, nil
+}
+
+// Returns the blocks as a set with entries "Gq" and "hq".
+func SocpBlockSet(blocks []SocpBlock) *FloatMatrixSet {
+	ms := FloatSetNew("Gq", "hq")
+	for _, b := range blocks {
+		ms.Append("Gq", b.G)
+		ms.Append("hq", b.H)
+	}
+	return ms
+}
+
+// Returns SDP blocks from the "Gs" and "hs" sets of Ghs.
+func SdpBlocksFromSet(Ghs *FloatMatrixSet) ([]SdpBlock, error) {
+	if Ghs == nil {
+		return nil, nil
+	}
+	Gs, hs := Ghs.At("Gs"), Ghs.At("hs")
+	if len(Gs) != len(hs) {
+		return nil, errors.New(fmt.Sprintf("'hs' must be a list of %d matrices", len(Gs)))
+	}
+	blocks := make([]SdpBlock, len(Gs))
+	for k := range Gs {
+		blocks[k] = SdpBlock{Gs[k], hs[k]}
+	}
+	return blocks, nil
+}
+
+// Returns the blocks as a set with entries "Gs" and "hs".
+func SdpBlockSet(blocks []SdpBlock) *FloatMatrixSet {
+	ms := FloatSetNew("Gs", "hs")
+	for _, b := range blocks {
+		ms.Append("Gs", b.G)
+		ms.Append("hs", b.H)
+	}
+	return ms
+}
+
+// Returns the start point from the primal and dual start sets of Socp. The
+// primal set has the entries "x", "s" (linear part) and "sl" (cone parts),
+// the dual set "y", "z" (linear part) and "zl" (cone parts).
+func SocpStartFromSets(primalstart, dualstart *FloatMatrixSet) *SocpStart {
+	st := &SocpStart{}
+	if primalstart != nil {
+		st.X = firstOf(primalstart, "x")
+		st.Sl = firstOf(primalstart, "s")
+		st.Sq = primalstart.At("sl")
+	}
+	if dualstart != nil {
+		st.Y = firstOf(dualstart, "y")
+		st.Zl = firstOf(dualstart, "z")
+		st.Zq = dualstart.At("zl")
+	}
+	return st
+}
+
+// Returns the start point as primal and dual start sets of Socp.
+func (st *SocpStart) Sets() (primalstart, dualstart *FloatMatrixSet) {
+	if st == nil {
+		return
+	}
+	if st.X != nil {
+		primalstart = FloatSetNew("x", "s", "sl")
+		primalstart.Set("x", st.X)
+		if st.Sl != nil {
+			primalstart.Set("s", st.Sl)
+		}
+		primalstart.Set("sl", st.Sq...)
+	}
+	if st.Zl != nil || st.Zq != nil {
+		dualstart = FloatSetNew("y", "z", "zl")
+		if st.Y != nil {
+			dualstart.Set("y", st.Y)
+		}
+		if st.Zl != nil {
+			dualstart.Set("z", st.Zl)
+		}
+		dualstart.Set("zl", st.Zq...)
+	}
+	return
+}
+
+// Returns the start point from the primal and dual start sets of Sdp. The
+// primal set has the entries "x", "s" (linear part) and "ss" (matrix
+// parts), the dual set "y", "z" (linear part) and "zs" (matrix parts).
+func SdpStartFromSets(primalstart, dualstart *FloatMatrixSet) *SdpStart {
+	st := &SdpStart{}
+	if primalstart != nil {
+		st.X = firstOf(primalstart, "x")
+		st.Sl = firstOf(primalstart, "s")
+		st.Ss = primalstart.At("ss")
+	}
+	if dualstart != nil {
+		st.Y = firstOf(dualstart, "y")
+		st.Zl = firstOf(dualstart, "z")
+		st.Zs = dualstart.At("zs")
+	}
+	return st
+}
+
+// Returns the start point as primal and dual start sets of Sdp.
+func (st *SdpStart) Sets() (primalstart, dualstart *FloatMatrixSet) {
+	if st == nil {
+		return
+	}
+	if st.X != nil {
+		primalstart = FloatSetNew("x", "s", "ss")
+		primalstart.Set("x", st.X)
+		if st.Sl != nil {
+			primalstart.Set("s", st.Sl)
+		}
+		primalstart.Set("ss", st.Ss...)
+	}
+	if st.Zl != nil || st.Zs != nil {
+		dualstart = FloatSetNew("y", "z", "zs")
+		if st.Y != nil {
+			dualstart.Set("y", st.Y)
+		}
+		if st.Zl != nil {
+			dualstart.Set("z", st.Zl)
+		}
+		dualstart.Set("zs", st.Zs...)
+	}
+	return
+}
+
+// Returns the result as a set with entries "x", "y", "sl", "sq", "zl" and
+// "zq".
+func (r *SocpResult) FloatMatrixSet() *FloatMatrixSet {
+	ms := FloatSetNew("x", "y", "sl", "sq", "zl", "zq")
+	ms.Set("x", r.X)
+	ms.Set("y", r.Y)
+	ms.Set("sl", r.Sl)
+	ms.Set("sq", r.Sq...)
+	ms.Set("zl", r.Zl)
+	ms.Set("zq", r.Zq...)
+	return ms
+}
+
+// Returns the result as a set with entries "x", "y", "sl", "ss", "zl" and
+// "zs".
+func (r *SdpResult) FloatMatrixSet() *FloatMatrixSet {
+	ms := FloatSetNew("x", "y", "sl", "ss", "zl", "zs")
+	ms.Set("x", r.X)
+	ms.Set("y", r.Y)
+	ms.Set("sl", r.Sl)
+	ms.Set("ss", r.Ss...)
+	ms.Set("zl", r.Zl)
+	ms.Set("zs", r.Zs...)
+	return ms
+}
+
+// Checks the SOCP blocks for n variables and returns the cone sizes.
+func checkSocpBlocks(blocks []SocpBlock, n int) (mq []int, err error) {
+	mq = make([]int, len(blocks))
+	for k, b := range blocks {
+		if b.G == nil {
+			err = errors.New(fmt.Sprintf("SOCP block %d: 'G' is missing", k))
+			return
+		}
+		if b.G.Cols() != n {
+			err = errors.New(fmt.Sprintf("SOCP block %d: 'G' has %d columns, expected %d",
+				k, b.G.Cols(), n))
+			return
+		}
+		if b.G.Rows() == 0 {
+			err = errors.New(fmt.Sprintf("SOCP block %d: the number of rows of 'G' is zero", k))
+			return
+		}
+		if e := checkVector(b.H, "H", b.G.Rows(), false); e != nil {
+			err = errors.New(fmt.Sprintf("SOCP block %d: %s", k, e))
+			return
+		}
+		mq[k] = b.G.Rows()
+	}
+	return
+}
+
+// Checks the SDP blocks for n variables and returns the matrix orders.
+func checkSdpBlocks(blocks []SdpBlock, n int) (ms []int, err error) {
+	ms = make([]int, len(blocks))
+	for k, b := range blocks {
+		if b.G == nil {
+			err = errors.New(fmt.Sprintf("SDP block %d: 'G' is missing", k))
+			return
+		}
+		if b.G.Cols() != n {
+			err = errors.New(fmt.Sprintf("SDP block %d: 'G' has %d columns, expected %d",
+				k, b.G.Cols(), n))
+			return
+		}
+		m := int(math.Sqrt(float64(b.G.Rows())) + 0.5)
+		if m == 0 || b.G.Rows() != m*m {
+			err = errors.New(fmt.Sprintf(
+				"SDP block %d: the number of rows of 'G' is not a nonzero square", k))
+			return
+		}
+		if e := checkMatrix(b.H, "H", m, m); e != nil {
+			err = errors.New(fmt.Sprintf("SDP block %d: %s", k, e))
+			return
+		}
+		ms[k] = m
+	}
+	return
+}
+
+// Checks that v is a m by n matrix.
+func checkMatrix(v *matrix.FloatMatrix, name string, m, n int) error {
+	if v == nil {
+		return errors.New(fmt.Sprintf("'%s' is missing", name))
+	}
+	if ! v.SizeMatch(m, n) {
+		return errors.New(fmt.Sprintf("'%s' has size (%d,%d), expected (%d,%d)",
+			name, v.Rows(), v.Cols(), m, n))
+	}
+	return nil
+}
+
+// Returns the column vector of the elements of parts stacked in order.
+func stackVectors(parts ...*matrix.FloatMatrix) *matrix.FloatMatrix {
+	n := 0
+	for _, p := range parts {
+		n += p.NumElements()
+	}
+	v := make([]float64, 0, n)
+	for _, p := range parts {
+		v = append(v, p.FloatArray()...)
+	}
+	return matrix.FloatVector(v)
+}
+
+// Checks the start point of SolveSocp and returns it in the ConeLp format.
+func (st *SocpStart) coneStart(n, ml, p int, mq []int) (pstart, dstart *FloatMatrixSet, err error) {
+	if st == nil {
+		return
+	}
+	if st.X != nil {
+		if err = checkVector(st.X, "x", n, false); err != nil {
+			return
+		}
+		if err = checkVector(st.Sl, "sl", ml, false); err != nil {
+			return
+		}
+		if len(st.Sq) != len(mq) {
+			err = errors.New(fmt.Sprintf("'sq' must be a list of %d vectors", len(mq)))
+			return
+		}
+		parts := make([]*matrix.FloatMatrix, 0, len(mq)+1)
+		if ml > 0 {
+			parts = append(parts, st.Sl)
+		}
+		for k, m := range mq {
+			if err = checkVector(st.Sq[k], fmt.Sprintf("sq[%d]", k), m, false); err != nil {
+				return
+			}
+			parts = append(parts, st.Sq[k])
+		}
+		pstart = FloatSetNew("x", "s")
+		pstart.Set("x", st.X)
+		pstart.Set("s", stackVectors(parts...))
+	}
+	if st.Zl != nil || st.Zq != nil {
+		if err = checkVector(st.Y, "y", p, false); err != nil {
+			return
+		}
+		if err = checkVector(st.Zl, "zl", ml, false); err != nil {
+			return
+		}
+		if len(st.Zq) != len(mq) {
+			err = errors.New(fmt.Sprintf("'zq' must be a list of %d vectors", len(mq)))
+			return
+		}
+		parts := make([]*matrix.FloatMatrix, 0, len(mq)+1)
+		if ml > 0 {
+			parts = append(parts, st.Zl)
+		}
+		for k, m := range mq {
+			if err = checkVector(st.Zq[k], fmt.Sprintf("zq[%d]", k), m, false); err != nil {
+				return
+			}
+			parts = append(parts, st.Zq[k])
+		}
+		dstart = FloatSetNew("y", "z")
+		if st.Y != nil {
+			dstart.Set("y", st.Y)
+		}
+		dstart.Set("z", stackVectors(parts...))
+	}
+	return
+}
+
+// Checks the start point of SolveSdp and returns it in the ConeLp format.
+func (st *SdpStart) coneStart(n, ml, p int, ms []int) (pstart, dstart *FloatMatrixSet, err error) {
+	if st == nil {
+		return
+	}
+	if st.X != nil {
+		if err = checkVector(st.X, "x", n, false); err != nil {
+			return
+		}
+		if err = checkVector(st.Sl, "sl", ml, false); err != nil {
+			return
+		}
+		if len(st.Ss) != len(ms) {
+			err = errors.New(fmt.Sprintf("'ss' must be a list of %d matrices", len(ms)))
+			return
+		}
+		parts := make([]*matrix.FloatMatrix, 0, len(ms)+1)
+		if ml > 0 {
+			parts = append(parts, st.Sl)
+		}
+		for k, m := range ms {
+			if err = checkMatrix(st.Ss[k], fmt.Sprintf("ss[%d]", k), m, m); err != nil {
+				return
+			}
+			parts = append(parts, st.Ss[k])
+		}
+		pstart = FloatSetNew("x", "s")
+		pstart.Set("x", st.X)
+		pstart.Set("s", stackVectors(parts...))
+	}
+	if st.Zl != nil || st.Zs != nil {
+		if err = checkVector(st.Y, "y", p, false); err != nil {
+			return
+		}
+		if err = checkVector(st.Zl, "zl", ml, false); err != nil {
+			return
+		}
+		if len(st.Zs) != len(ms) {
+			err = errors.New(fmt.Sprintf("'zs' must be a list of %d matrices", len(ms)))
+			return
+		}
+		parts := make([]*matrix.FloatMatrix, 0, len(ms)+1)
+		if ml > 0 {
+			parts = append(parts, st.Zl)
+		}
+		for k, m := range ms {
+			if err = checkMatrix(st.Zs[k], fmt.Sprintf("zs[%d]", k), m, m); err != nil {
+				return
+			}
+			parts = append(parts, st.Zs[k])
+		}
+		dstart = FloatSetNew("y", "z")
+		if st.Y != nil {
+			dstart.Set("y", st.Y)
+		}
+		dstart.Set("z", stackVectors(parts...))
+	}
+	return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
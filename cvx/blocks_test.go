@@ -0,0 +1,146 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"testing"
+)
+
+// The SOCP of examples/testsocp.go.
+func makeTestSocp() (c *matrix.FloatMatrix, Ghq *FloatMatrixSet) {
+	g0 := matrix.FloatMatrixStacked([][]float64{
+		[]float64{12., 13., 12.},
+		[]float64{6., -3., -12.},
+		[]float64{-5., -5., 6.}}, matrix.ColumnOrder)
+	g1 := matrix.FloatMatrixStacked([][]float64{
+		[]float64{3., 3., -1., 1.},
+		[]float64{-6., -6., -9., 19.},
+		[]float64{10., -2., -2., -3.}}, matrix.ColumnOrder)
+	Ghq = FloatSetNew("Gq", "hq")
+	Ghq.Append("Gq", g0, g1)
+	Ghq.Append("hq", matrix.FloatVector([]float64{-12.0, -3.0, -2.0}),
+		matrix.FloatVector([]float64{27.0, 0.0, 3.0, -42.0}))
+	c = matrix.FloatVector([]float64{-2.0, 1.0, 5.0})
+	return
+}
+
+// Returns the vector (1, 0, ..., 0) of length m, the identity of the
+// second order cone.
+func socIdentity(m int) *matrix.FloatMatrix {
+	e := matrix.FloatZeros(m, 1)
+	e.SetIndex(0, 1.0)
+	return e
+}
+
+// A dual start without a primal start is read from the dual start set.
+// Socp and Sdp used to read the cone parts of the dual start from
+// primalstart.At("zl"), which failed with a nil primal start.
+func TestSocpSdpDualStart(t *testing.T) {
+	c, Ghq := makeTestSocp()
+	ref, err := Socp(c, nil, nil, nil, nil, Ghq, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatalf("Socp: %s\n", err)
+	}
+	dualstart := FloatSetNew("y", "z", "zl")
+	dualstart.Append("zl", socIdentity(3), socIdentity(4))
+	// cone parts in the primal start set must not be taken for the dual
+	primalstart := FloatSetNew("x", "s", "sl", "zl")
+	primalstart.Append("zl", socIdentity(2))
+	st := SocpStartFromSets(primalstart, dualstart)
+	if st.X != nil || len(st.Zq) != 2 || st.Zq[1].Rows() != 4 {
+		t.Errorf("SocpStartFromSets: x %v, %d cone parts\n", st.X, len(st.Zq))
+	}
+
+	sol, err := Socp(c, nil, nil, nil, nil, Ghq, &SolverOptions{MaxIter: 30}, nil, dualstart)
+	if err != nil {
+		t.Fatalf("Socp with dual start: %s\n", err)
+	}
+	if e := maxDiff(sol.Result.At("x")[0], ref.Result.At("x")[0]); e > 1e-6 {
+		t.Errorf("Socp with dual start: x differs by %.3e\n", e)
+	}
+
+	cs, Ghs := makeTestSdp()
+	sref, err := Sdp(cs, nil, nil, nil, nil, Ghs, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatalf("Sdp: %s\n", err)
+	}
+	sdual := FloatSetNew("y", "z", "zs")
+	sdual.Append("zs", matrix.FloatIdentity(2), matrix.FloatIdentity(3))
+	ssol, err := Sdp(cs, nil, nil, nil, nil, Ghs, &SolverOptions{MaxIter: 30}, nil, sdual)
+	if err != nil {
+		t.Fatalf("Sdp with dual start: %s\n", err)
+	}
+	if e := maxDiff(ssol.Result.At("x")[0], sref.Result.At("x")[0]); e > 1e-6 {
+		t.Errorf("Sdp with dual start: x differs by %.3e\n", e)
+	}
+}
+
+// Start points of the wrong size or with the wrong number of cone parts
+// are rejected before the solve.
+func TestStartValidation(t *testing.T) {
+	x2 := matrix.FloatZeros(2, 1)
+	x3 := matrix.FloatZeros(3, 1)
+	if err := (&StartPoint{X: x2, S: x3, Z: x3}).Validate(2, 3, 0); err != nil {
+		t.Errorf("valid start point: %s\n", err)
+	}
+	for _, sp := range []*StartPoint{{X: x3}, {S: x2}, {Y: x2}, {Z: matrix.FloatZeros(1, 3)}} {
+		if err := sp.Validate(2, 3, 0); err == nil {
+			t.Errorf("invalid start point %v accepted\n", *sp)
+		}
+	}
+	sp := StartPointFromSets(nil, FloatSetNew("x"), (&StartPoint{X: x2, S: x3}).PrimalSet())
+	if sp.X != x2 || sp.S != x3 || sp.DualSet() != nil {
+		t.Errorf("StartPointFromSets: %v\n", *sp)
+	}
+
+	c, G, h, dims := makeConeLp()
+	cdim := G.Rows()
+	primalstart := FloatSetNew("x", "s")
+	primalstart.Set("x", matrix.FloatZeros(3, 1))
+	if err := checkConeLpStart(primalstart, nil, 3, cdim, 0); err == nil {
+		t.Errorf("primal start without s accepted\n")
+	}
+	primalstart.Set("s", matrix.FloatZeros(cdim-1, 1))
+	if _, err := ConeLp(c, G, h, nil, nil, dims, &SolverOptions{MaxIter: 30}, primalstart, nil); err == nil {
+		t.Errorf("ConeLp: primal start s of size %d accepted\n", cdim-1)
+	}
+
+	cq, Ghq := makeTestSocp()
+	blocks, err := SocpBlocksFromSet(Ghq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []*SocpStart{
+		{Zq: []*matrix.FloatMatrix{socIdentity(3)}},
+		{Zq: []*matrix.FloatMatrix{socIdentity(3), socIdentity(3)}},
+		{X: x3, Sq: []*matrix.FloatMatrix{socIdentity(3), socIdentity(4)}, Sl: x2},
+		{X: x2, Sq: []*matrix.FloatMatrix{socIdentity(3), socIdentity(4)}},
+	} {
+		if _, _, err := SolveSocp(cq, nil, nil, nil, nil, blocks, nil, st); err == nil {
+			t.Errorf("invalid SOCP start %v accepted\n", *st)
+		}
+	}
+
+	cs, Ghs := makeTestSdp()
+	sblocks, err := SdpBlocksFromSet(Ghs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []*SdpStart{
+		{Zs: []*matrix.FloatMatrix{matrix.FloatIdentity(2)}},
+		{Zs: []*matrix.FloatMatrix{matrix.FloatIdentity(2), matrix.FloatZeros(3, 2)}},
+	} {
+		if _, _, err := SolveSdp(cs, nil, nil, nil, nil, sblocks, nil, st); err == nil {
+			t.Errorf("invalid SDP start %v accepted\n", *st)
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
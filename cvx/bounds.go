@@ -0,0 +1,473 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Variable bounds Lower <= x <= Upper and ranged rows RLower <= R*x <= RUpper
+// of LpBounded and QpBounded. Nil vectors and infinite elements denote
+// missing bounds. Equal lower and upper bounds should be given as equality
+// constraints instead.
+type Bounds struct {
+	Lower, Upper   *matrix.FloatMatrix
+	R              *matrix.FloatMatrix
+	RLower, RUpper *matrix.FloatMatrix
+}
+
+// The constraint operator [G; -I_l; I_u; -R_l; R_u] of the bounded problem,
+// where I_l, I_u, R_l, R_u are the rows of I and R with finite bounds. A row
+// of R with both bounds finite appears in R_l and R_u, and has two slack
+// components, but the operator multiplies it only once and it enters the
+// KKT matrix as a single rank-one term.
+type boundedOp struct {
+	n, mG  int
+	G, R   *matrix.FloatMatrix
+	il, iu []int
+	rl, ru []int
+	// dense rows of G and R, copied once
+	grows, rrows [][]float64
+	// R*x or the coefficients of R'*x in apply
+	rw []float64
+}
+
+// Returns indexes of the elements of v that are finite, or for nil v the
+// empty list. Elements equal to inf are skipped.
+func finiteIndexes(v *matrix.FloatMatrix, inf float64) []int {
+	ind := make([]int, 0)
+	if v == nil {
+		return ind
+	}
+	for k, a := range v.FloatArray() {
+		if a != inf {
+			ind = append(ind, k)
+		}
+	}
+	return ind
+}
+
+// Checks the bounds for n variables and returns the operator for G.
+func newBoundedOp(G *matrix.FloatMatrix, bounds *Bounds, n int) (op *boundedOp, err error) {
+	op = &boundedOp{n: n, mG: G.Rows(), G: G, grows: denseRows(G)}
+	if bounds == nil {
+		op.il, op.iu, op.rl, op.ru = []int{}, []int{}, []int{}, []int{}
+		return
+	}
+	if err = checkVector(bounds.Lower, "lb", n, true); err != nil {
+		return
+	}
+	if err = checkVector(bounds.Upper, "ub", n, true); err != nil {
+		return
+	}
+	op.R = bounds.R
+	mr := 0
+	if op.R != nil {
+		if op.R.Cols() != n {
+			err = errors.New(fmt.Sprintf("'R' must be matrix with %d columns", n))
+			return
+		}
+		mr = op.R.Rows()
+	}
+	if err = checkVector(bounds.RLower, "rl", mr, true); err != nil {
+		return
+	}
+	if err = checkVector(bounds.RUpper, "ru", mr, true); err != nil {
+		return
+	}
+	for _, v := range []*matrix.FloatMatrix{bounds.Lower, bounds.Upper, bounds.RLower, bounds.RUpper} {
+		if v != nil && matrixNaN(v) {
+			err = errors.New("bounds must not contain NaN")
+			return
+		}
+	}
+	for k := 0; k < n && bounds.Lower != nil && bounds.Upper != nil; k++ {
+		if bounds.Lower.GetIndex(k) > bounds.Upper.GetIndex(k) {
+			err = errors.New(fmt.Sprintf("lower bound of x[%d] exceeds the upper bound", k))
+			return
+		}
+	}
+	for k := 0; k < mr && bounds.RLower != nil && bounds.RUpper != nil; k++ {
+		if bounds.RLower.GetIndex(k) > bounds.RUpper.GetIndex(k) {
+			err = errors.New(fmt.Sprintf("lower bound of row %d exceeds the upper bound", k))
+			return
+		}
+	}
+	op.il = finiteIndexes(bounds.Lower, math.Inf(-1))
+	op.iu = finiteIndexes(bounds.Upper, math.Inf(1))
+	op.rl = finiteIndexes(bounds.RLower, math.Inf(-1))
+	op.ru = finiteIndexes(bounds.RUpper, math.Inf(1))
+	if op.R != nil {
+		op.rrows = denseRows(op.R)
+	}
+	op.rw = make([]float64, mr)
+	return
+}
+
+// Returns the rows of M as slices.
+func denseRows(M *matrix.FloatMatrix) [][]float64 {
+	rows := make([][]float64, M.Rows())
+	for i := range rows {
+		rows[i] = M.GetRowArray(i, nil)
+	}
+	return rows
+}
+
+// Number of rows of the operator.
+func (op *boundedOp) rows() int {
+	return op.mG + len(op.il) + len(op.iu) + len(op.rl) + len(op.ru)
+}
+
+// Returns the right hand side [h; -lb_l; ub_u; -rl_l; ru_u].
+func (op *boundedOp) rhs(h *matrix.FloatMatrix, bounds *Bounds) *matrix.FloatMatrix {
+	v := make([]float64, 0, op.rows())
+	v = append(v, h.FloatArray()...)
+	for _, k := range op.il {
+		v = append(v, -bounds.Lower.GetIndex(k))
+	}
+	for _, k := range op.iu {
+		v = append(v, bounds.Upper.GetIndex(k))
+	}
+	for _, k := range op.rl {
+		v = append(v, -bounds.RLower.GetIndex(k))
+	}
+	for _, k := range op.ru {
+		v = append(v, bounds.RUpper.GetIndex(k))
+	}
+	return matrix.FloatVector(v)
+}
+
+// y := alpha*G*x + beta*y or y := alpha*G'*x + beta*y.
+func (op *boundedOp) apply(x, y *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error {
+	trans := la.GetIntOpt("trans", int(la.PNoTrans), opts...)
+	xa, ya := x.FloatArray(), y.FloatArray()
+	// offsets of the I_u, R_l and R_u rows
+	ou := op.mG + len(op.il)
+	orl := ou + len(op.iu)
+	oru := orl + len(op.rl)
+	if trans == int(la.PNoTrans) {
+		for i, g := range op.grows {
+			ya[i] = alpha*dotArray(g, xa) + beta*ya[i]
+		}
+		for k, j := range op.il {
+			ya[op.mG+k] = -alpha*xa[j] + beta*ya[op.mG+k]
+		}
+		for k, j := range op.iu {
+			ya[ou+k] = alpha*xa[j] + beta*ya[ou+k]
+		}
+		for i, r := range op.rrows {
+			op.rw[i] = alpha * dotArray(r, xa)
+		}
+		for k, i := range op.rl {
+			ya[orl+k] = -op.rw[i] + beta*ya[orl+k]
+		}
+		for k, i := range op.ru {
+			ya[oru+k] = op.rw[i] + beta*ya[oru+k]
+		}
+		return nil
+	}
+	for j := range ya[:op.n] {
+		ya[j] *= beta
+	}
+	for i, g := range op.grows {
+		a := alpha * xa[i]
+		for j, gj := range g {
+			ya[j] += a * gj
+		}
+	}
+	for k, j := range op.il {
+		ya[j] -= alpha * xa[op.mG+k]
+	}
+	for k, j := range op.iu {
+		ya[j] += alpha * xa[ou+k]
+	}
+	// both bounds of a ranged row combine to one coefficient of its row
+	for i := range op.rw {
+		op.rw[i] = 0.0
+	}
+	for k, i := range op.rl {
+		op.rw[i] -= xa[orl+k]
+	}
+	for k, i := range op.ru {
+		op.rw[i] += xa[oru+k]
+	}
+	for i, r := range op.rrows {
+		if op.rw[i] == 0.0 {
+			continue
+		}
+		a := alpha * op.rw[i]
+		for j, rj := range r {
+			ya[j] += a * rj
+		}
+	}
+	return nil
+}
+
+// K := K + w*g*g' in the lower triangle of the leading block of K.
+func addRankOne(K *matrix.FloatMatrix, g []float64, w float64) {
+	for j := range g {
+		if g[j] == 0.0 {
+			continue
+		}
+		for i := j; i < len(g); i++ {
+			K.SetAt(i, j, K.GetAt(i, j)+w*g[i]*g[j])
+		}
+	}
+}
+
+//    Solution of the KKT equations with the operator of the bounded problem
+//    and the 'l' cone only. The third block row is eliminated,
+//
+//        [ H + G'*W^{-2}*G   A' ] [ ux ]   [ bx + G'*W^{-2}*bz ]
+//        [ A                 0  ] [ uy ] = [ by                ],
+//
+//        W*uz = W^{-1}*(G*ux - bz).
+//
+//    The variable bound rows only add to the diagonal of G'*W^{-2}*G and
+//    the two bounds of a ranged row add one rank-one term.
+//
+func (op *boundedOp) kktFactor(A *matrix.FloatMatrix) kktFactor {
+	p, n := A.Rows(), op.n
+	ldK := n + p
+	K := matrix.FloatZeros(ldK, ldK)
+	ipiv := make([]int32, ldK)
+	u := matrix.FloatZeros(ldK, 1)
+	t := matrix.FloatZeros(op.rows(), 1)
+	wr := make([]float64, len(op.rrows))
+	ou := op.mG + len(op.il)
+	orl := ou + len(op.iu)
+	oru := orl + len(op.rl)
+
+	factor := func(W *Scaling, H, Df *matrix.FloatMatrix) (kktFunc, error) {
+		K.Scale(0.0)
+		// lower triangle of H and A
+		for j := 0; j < n; j++ {
+			for i := j; H != nil && i < n; i++ {
+				K.SetAt(i, j, H.GetAt(i, j))
+			}
+			for i := 0; i < p; i++ {
+				K.SetAt(n+i, j, A.GetAt(i, j))
+			}
+		}
+		di := W.Di.FloatArray()
+		for i, g := range op.grows {
+			addRankOne(K, g, di[i]*di[i])
+		}
+		for k, j := range op.il {
+			K.SetAt(j, j, K.GetAt(j, j)+di[op.mG+k]*di[op.mG+k])
+		}
+		for k, j := range op.iu {
+			K.SetAt(j, j, K.GetAt(j, j)+di[ou+k]*di[ou+k])
+		}
+		for i := range wr {
+			wr[i] = 0.0
+		}
+		for k, i := range op.rl {
+			wr[i] += di[orl+k] * di[orl+k]
+		}
+		for k, i := range op.ru {
+			wr[i] += di[oru+k] * di[oru+k]
+		}
+		for i, r := range op.rrows {
+			if wr[i] != 0.0 {
+				addRankOne(K, r, wr[i])
+			}
+		}
+		if err := lapack.Sytrf(K, ipiv); err != nil {
+			return nil, err
+		}
+
+		solve := func(x, y, z *matrix.FloatMatrix) (err error) {
+			// t = W^{-2}*bz
+			ta, za := t.FloatArray(), z.FloatArray()
+			for k := range ta {
+				ta[k] = di[k] * di[k] * za[k]
+			}
+			copy(u.FloatArray()[:n], x.FloatArray())
+			copy(u.FloatArray()[n:], y.FloatArray())
+			// u[:n] += G'*t
+			if err = op.apply(t, u, 1.0, 1.0, la.OptTrans); err != nil {
+				return
+			}
+			if err = lapack.Sytrs(K, u, ipiv); err != nil {
+				return
+			}
+			copy(x.FloatArray(), u.FloatArray()[:n])
+			copy(y.FloatArray(), u.FloatArray()[n:])
+			// z := W^{-1}*(G*ux - bz)
+			if err = op.apply(x, z, 1.0, -1.0); err != nil {
+				return
+			}
+			for k := range za {
+				za[k] *= di[k]
+			}
+			return
+		}
+		return solve, nil
+	}
+	return factor
+}
+
+// Splits the slack and dual variables of the bounded problem to the G
+// rows and the bound duals of sol.
+func (op *boundedOp) unpack(sol *Solution) {
+	if sol == nil || sol.Z == nil || sol.S == nil {
+		return
+	}
+	za := sol.Z.FloatArray()
+	sol.S = matrix.FloatVector(sol.S.FloatArray()[:op.mG])
+	sol.Z = matrix.FloatVector(za[:op.mG])
+	if sol.Result != nil {
+		sol.Result.Set("s", sol.S)
+		sol.Result.Set("z", sol.Z)
+	}
+	mr := 0
+	if op.R != nil {
+		mr = op.R.Rows()
+	}
+	sol.ZLower = matrix.FloatZeros(op.n, 1)
+	sol.ZUpper = matrix.FloatZeros(op.n, 1)
+	sol.ZRangeLower = matrix.FloatZeros(mr, 1)
+	sol.ZRangeUpper = matrix.FloatZeros(mr, 1)
+	ind := op.mG
+	for _, k := range op.il {
+		sol.ZLower.SetIndex(k, za[ind])
+		ind++
+	}
+	for _, k := range op.iu {
+		sol.ZUpper.SetIndex(k, za[ind])
+		ind++
+	}
+	for _, k := range op.rl {
+		sol.ZRangeLower.SetIndex(k, za[ind])
+		ind++
+	}
+	for _, k := range op.ru {
+		sol.ZRangeUpper.SetIndex(k, za[ind])
+		ind++
+	}
+}
+
+//    Solves a pair of primal and dual LPs with variable bounds and ranged
+//    rows
+//
+//        minimize    c'*x
+//        subject to  G*x <= h
+//                    A*x = b
+//                    lb <= x <= ub
+//                    rl <= R*x <= ru.
+//
+//    The bounds are given in bounds, infinite elements are allowed. The
+//    bound rows are not added to G; they are handled in the KKT solver as
+//    diagonal terms. A ranged row with both bounds finite has two slack
+//    and dual components but is multiplied and factored once. The dual variables of the bounds are returned in
+//    sol.ZLower, sol.ZUpper, sol.ZRangeLower and sol.ZRangeUpper, and
+//    sol.S and sol.Z hold the slack and dual variables of G*x <= h.
+//
+//    G, h, A and b may be nil.
+//
+func LpBounded(c, G, h, A, b *matrix.FloatMatrix, bounds *Bounds, solopts *SolverOptions) (sol *Solution, err error) {
+	if c == nil || c.Cols() != 1 {
+		err = errors.New("'c' must a column matrix")
+		return
+	}
+	n := c.Rows()
+	G, h, A, b, err = checkBoundedArgs(G, h, A, b, n)
+	if err != nil {
+		return
+	}
+	op, err := newBoundedOp(G, bounds, n)
+	if err != nil {
+		return
+	}
+	dims := DSetNew("l", "q", "s")
+	dims.Set("l", []int{op.rows()})
+	ops := &coneOps{G: op.apply, kkt: op.kktFactor(A)}
+	sol, err = coneLp(c, nil, op.rhs(h, bounds), A, b, dims, solopts, nil, nil, ops)
+	op.unpack(sol)
+	return
+}
+
+//    Solves a quadratic program with variable bounds and ranged rows
+//
+//        minimize    (1/2)*x'*P*x + q'*x
+//        subject to  G*x <= h
+//                    A*x = b
+//                    lb <= x <= ub
+//                    rl <= R*x <= ru.
+//
+//    P is stored in the lower triangle. The bounds and the returned
+//    solution are as in LpBounded.
+//
+func QpBounded(P, q, G, h, A, b *matrix.FloatMatrix, bounds *Bounds, solopts *SolverOptions) (sol *Solution, err error) {
+	if P == nil || P.Rows() != P.Cols() {
+		err = errors.New("'P' must a non-nil square matrix")
+		return
+	}
+	n := P.Rows()
+	if q == nil || ! q.SizeMatch(n, 1) {
+		err = errors.New(fmt.Sprintf("'q' must be matrix of size (%d,1)", n))
+		return
+	}
+	G, h, A, b, err = checkBoundedArgs(G, h, A, b, n)
+	if err != nil {
+		return
+	}
+	op, err := newBoundedOp(G, bounds, n)
+	if err != nil {
+		return
+	}
+	dims := DSetNew("l", "q", "s")
+	dims.Set("l", []int{op.rows()})
+	ops := &coneOps{G: op.apply, kkt: op.kktFactor(A)}
+	sol, err = coneQp(P, q, nil, op.rhs(h, bounds), A, b, dims, solopts, nil, ops)
+	op.unpack(sol)
+	return
+}
+
+// Checks G, h, A and b of n variables and sets the defaults for nil
+// arguments.
+func checkBoundedArgs(G, h, A, b *matrix.FloatMatrix, n int) (Gd, hd, Ad, bd *matrix.FloatMatrix, err error) {
+	if G == nil {
+		G = matrix.FloatZeros(0, n)
+	}
+	if G.Cols() != n {
+		err = errors.New(fmt.Sprintf("'G' must be matrix with %d columns", n))
+		return
+	}
+	if h == nil {
+		h = matrix.FloatZeros(G.Rows(), 1)
+	}
+	if ! h.SizeMatch(G.Rows(), 1) {
+		err = errors.New(fmt.Sprintf("'h' must be matrix of size (%d,1)", G.Rows()))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if A.Cols() != n {
+		err = errors.New(fmt.Sprintf("'A' must be matrix with %d columns", n))
+		return
+	}
+	if b == nil {
+		b = matrix.FloatZeros(A.Rows(), 1)
+	}
+	if ! b.SizeMatch(A.Rows(), 1) {
+		err = errors.New(fmt.Sprintf("'b' must be matrix of size (%d,1)", A.Rows()))
+		return
+	}
+	return G, h, A, b, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,130 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"testing"
+)
+
+// Checks the bound duals of sol with tolerance 1e-5.
+func checkBoundDuals(t *testing.T, name string, sol *Solution, zl, zu, zrl, zru []float64) {
+	for _, v := range []struct {
+		name     string
+		z        *matrix.FloatMatrix
+		expected []float64
+	}{{"ZLower", sol.ZLower, zl}, {"ZUpper", sol.ZUpper, zu},
+		{"ZRangeLower", sol.ZRangeLower, zrl}, {"ZRangeUpper", sol.ZRangeUpper, zru}} {
+		if v.z == nil || maxDiff(v.z, matrix.FloatVector(v.expected)) > 1e-5 {
+			t.Errorf("%s: %s = %v, expected %v\n", name, v.name, v.z, v.expected)
+		}
+	}
+}
+
+// minimize -x0 - 2*x1 subject to x0 + x1 <= 5, 0 <= x <= (3, 2) and
+// -1 <= x0 - x1 <= 0.5. At the optimum x = (2.5, 2) the upper bound of x1
+// and the upper bound of the ranged row are active with duals 3 and 1.
+func TestLpBounded(t *testing.T) {
+	c := matrix.FloatVector([]float64{-1.0, -2.0})
+	G := matrix.FloatMatrixStacked([][]float64{[]float64{1.0, 1.0}}, matrix.RowOrder)
+	h := matrix.FloatVector([]float64{5.0})
+	bounds := &Bounds{
+		Lower:  matrix.FloatVector([]float64{0.0, 0.0}),
+		Upper:  matrix.FloatVector([]float64{3.0, 2.0}),
+		R:      matrix.FloatMatrixStacked([][]float64{[]float64{1.0, -1.0}}, matrix.RowOrder),
+		RLower: matrix.FloatVector([]float64{-1.0}),
+		RUpper: matrix.FloatVector([]float64{0.5})}
+	sol, err := LpBounded(c, G, h, nil, nil, bounds, &SolverOptions{MaxIter: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sol.Status != Optimal {
+		t.Fatalf("status %v\n", sol.Status)
+	}
+	if e := maxDiff(sol.X, matrix.FloatVector([]float64{2.5, 2.0})); e > 1e-6 {
+		t.Errorf("x = %v\n", sol.X.FloatArray())
+	}
+	if math.Abs(sol.PrimalObjective+6.5) > 1e-6 {
+		t.Errorf("objective %v, expected -6.5\n", sol.PrimalObjective)
+	}
+	if sol.S.Rows() != 1 || sol.Z.Rows() != 1 || math.Abs(sol.S.GetIndex(0)-0.5) > 1e-6 ||
+		math.Abs(sol.Z.GetIndex(0)) > 1e-6 {
+		t.Errorf("s = %v, z = %v\n", sol.S.FloatArray(), sol.Z.FloatArray())
+	}
+	checkBoundDuals(t, "lp", sol, []float64{0.0, 0.0}, []float64{0.0, 3.0}, []float64{0.0}, []float64{1.0})
+
+	// the same problem with the bounds as rows of G
+	Gf := matrix.FloatMatrixStacked([][]float64{
+		[]float64{1.0, 1.0},
+		[]float64{-1.0, 0.0},
+		[]float64{0.0, -1.0},
+		[]float64{1.0, 0.0},
+		[]float64{0.0, 1.0},
+		[]float64{-1.0, 1.0},
+		[]float64{1.0, -1.0}}, matrix.RowOrder)
+	hf := matrix.FloatVector([]float64{5.0, 0.0, 0.0, 3.0, 2.0, 1.0, 0.5})
+	ref, err := Lp(c, Gf, hf, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := maxDiff(sol.X, ref.X); e > 1e-6 {
+		t.Errorf("x = %v, Lp %v\n", sol.X.FloatArray(), ref.X.FloatArray())
+	}
+}
+
+// minimize (1/2)*||x - (3, -1)||^2 subject to 0 <= x <= 2 and
+// 2.5 <= x0 + x1 <= 4. At the optimum x = (2, 0.5) the upper bound of x0
+// and the lower bound of the ranged row are active with duals 2.5 and 1.5.
+func TestQpBounded(t *testing.T) {
+	P := matrix.FloatDiagonal(2, 1.0)
+	q := matrix.FloatVector([]float64{-3.0, 1.0})
+	bounds := &Bounds{
+		Lower:  matrix.FloatVector([]float64{0.0, 0.0}),
+		Upper:  matrix.FloatVector([]float64{2.0, 2.0}),
+		R:      matrix.FloatMatrixStacked([][]float64{[]float64{1.0, 1.0}}, matrix.RowOrder),
+		RLower: matrix.FloatVector([]float64{2.5}),
+		RUpper: matrix.FloatVector([]float64{4.0})}
+	sol, err := QpBounded(P, q, nil, nil, nil, nil, bounds, &SolverOptions{MaxIter: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sol.Status != Optimal {
+		t.Fatalf("status %v\n", sol.Status)
+	}
+	if e := maxDiff(sol.X, matrix.FloatVector([]float64{2.0, 0.5})); e > 1e-6 {
+		t.Errorf("x = %v\n", sol.X.FloatArray())
+	}
+	if math.Abs(sol.PrimalObjective+3.375) > 1e-6 {
+		t.Errorf("objective %v, expected -3.375\n", sol.PrimalObjective)
+	}
+	checkBoundDuals(t, "qp", sol, []float64{0.0, 0.0}, []float64{2.5, 0.0}, []float64{1.5}, []float64{0.0})
+
+	// infinite bounds are missing bounds
+	inf := math.Inf(1)
+	bounds.Upper = matrix.FloatVector([]float64{inf, inf})
+	bounds.RUpper = matrix.FloatVector([]float64{inf})
+	sol, err = QpBounded(P, q, nil, nil, nil, nil, bounds, &SolverOptions{MaxIter: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := maxDiff(sol.X, matrix.FloatVector([]float64{3.0, 0.0})); e > 1e-6 {
+		t.Errorf("no upper bounds: x = %v\n", sol.X.FloatArray())
+	}
+	checkBoundDuals(t, "qp, no upper bounds", sol, []float64{0.0, 1.0}, []float64{0.0, 0.0},
+		[]float64{0.0}, []float64{0.0})
+
+	bounds.Lower = matrix.FloatVector([]float64{1.0, 3.0})
+	bounds.Upper = matrix.FloatVector([]float64{2.0, 2.0})
+	if _, err = QpBounded(P, q, nil, nil, nil, nil, bounds, nil); err == nil {
+		t.Errorf("inconsistent bounds accepted\n")
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,167 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Certificate of infeasibility of the cone program
+//
+//     minimize    c'*x
+//     subject to  G*x + s = h
+//                 A*x = b
+//                 s >= 0
+//
+// If Status is PrimalInfeasible, Y and Z form a Farkas certificate
+//
+//     G'*z + A'*y = 0,  h'*z + b'*y = -1,  z >= 0
+//
+// that proves the primal problem has no feasible point. X and S are nil.
+//
+// If Status is DualInfeasible, X and S form a certificate
+//
+//     G*x + s = 0,  A*x = 0,  c'*x = -1,  s >= 0
+//
+// that proves the dual problem is infeasible; if the primal is feasible
+// it is unbounded below along x. Y and Z are nil.
+//
+// The 's' components are stored unpacked with both triangles filled in.
+type Certificate struct {
+	Status StatusCode
+	X      *matrix.FloatMatrix
+	S      *matrix.FloatMatrix
+	Y      *matrix.FloatMatrix
+	Z      *matrix.FloatMatrix
+}
+
+//    Checks the certificate against the problem data. Returns the largest
+//    violation of the certificate conditions measured in infinity norm,
+//    and an error if it exceeds tol. Conic membership is measured by
+//    min {t >= 0 | u + t*e >= 0} with e the identity of the cone.
+//
+//    Arguments c, A and b may be nil if they do not enter the certificate
+//    (c for primal infeasibility, A and b if there are no equality
+//    constraints). If dims is nil, G*x <= h is a componentwise inequality.
+//
+func (cert *Certificate) Verify(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, tol float64) (res float64, err error) {
+
+	res = math.Inf(1)
+	if G == nil {
+		err = errors.New("'G' must be a dense matrix")
+		return
+	}
+	m, n := G.Size()
+	if dims == nil {
+		dims = DSetNew("l", "q", "s")
+		dims.Set("l", []int{m})
+	}
+	if cdim := dims.Sum("l", "q") + dims.SumSquared("s"); cdim != m {
+		err = errors.New(fmt.Sprintf("'G' must have %d rows", cdim))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	p := A.Rows()
+	if A.Cols() != n || !b.SizeMatch(p, 1) {
+		err = errors.New(fmt.Sprintf("'A' must have %d columns and 'b' size (%d,1)", n, p))
+		return
+	}
+
+	switch cert.Status {
+	case PrimalInfeasible:
+		z, y := cert.Z, cert.Y
+		if y == nil {
+			y = matrix.FloatZeros(p, 1)
+		}
+		if h == nil || !h.SizeMatch(m, 1) {
+			err = errors.New(fmt.Sprintf("'h' must be a dense matrix of size (%d,1)", m))
+			return
+		}
+		if z == nil || !z.SizeMatch(m, 1) || !y.SizeMatch(p, 1) {
+			err = errors.New("certificate does not match problem dimensions")
+			return
+		}
+		// G'*z + A'*y
+		res = 0.0
+		for j := 0; j < n; j++ {
+			r := 0.0
+			for i := 0; i < m; i++ {
+				r += G.GetAt(i, j) * z.GetIndex(i)
+			}
+			for i := 0; i < p; i++ {
+				r += A.GetAt(i, j) * y.GetIndex(i)
+			}
+			res = math.Max(res, math.Abs(r))
+		}
+		// h'*z + b'*y + 1
+		r := 1.0 + vecDot(h, z) + vecDot(b, y)
+		res = math.Max(res, math.Abs(r))
+		res = math.Max(res, coneViolation(z, dims))
+
+	case DualInfeasible:
+		x, s := cert.X, cert.S
+		if c == nil || !c.SizeMatch(n, 1) {
+			err = errors.New(fmt.Sprintf("'c' must be a dense matrix of size (%d,1)", n))
+			return
+		}
+		if x == nil || s == nil || !x.SizeMatch(n, 1) || !s.SizeMatch(m, 1) {
+			err = errors.New("certificate does not match problem dimensions")
+			return
+		}
+		res = 0.0
+		// G*x + s
+		for i := 0; i < m; i++ {
+			r := s.GetIndex(i)
+			for j := 0; j < n; j++ {
+				r += G.GetAt(i, j) * x.GetIndex(j)
+			}
+			res = math.Max(res, math.Abs(r))
+		}
+		// A*x
+		for i := 0; i < p; i++ {
+			r := 0.0
+			for j := 0; j < n; j++ {
+				r += A.GetAt(i, j) * x.GetIndex(j)
+			}
+			res = math.Max(res, math.Abs(r))
+		}
+		// c'*x + 1
+		res = math.Max(res, math.Abs(1.0+vecDot(c, x)))
+		res = math.Max(res, coneViolation(s, dims))
+
+	default:
+		err = errors.New("certificate status must be PrimalInfeasible or DualInfeasible")
+		return
+	}
+
+	if res > tol {
+		err = errors.New(fmt.Sprintf("certificate violated by %.3e (tolerance %.3e)", res, tol))
+	}
+	return
+}
+
+// Returns max(0, min {t | u + t*e >= 0}), the distance of u from the cone
+// measured along the identity e.
+func coneViolation(u *matrix.FloatMatrix, dims *DimensionSet) float64 {
+	t, err := MaxStep(u, dims, 0, nil)
+	if err != nil {
+		return math.Inf(1)
+	}
+	return math.Max(t, 0.0)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,106 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"testing"
+)
+
+// minimize x0 subject to x0 >= 1, x0 <= 0. The certificate is z = (1, 1).
+func makePrimalInfeasibleLp() (c, G, h *matrix.FloatMatrix) {
+	c = matrix.FloatVector([]float64{1.0})
+	G = matrix.FloatVector([]float64{-1.0, 1.0})
+	h = matrix.FloatVector([]float64{-1.0, 0.0})
+	return
+}
+
+// minimize -x0 subject to x0 >= 0. The certificate is x = 1, s = 1.
+func makeDualInfeasibleLp() (c, G, h *matrix.FloatMatrix) {
+	c = matrix.FloatVector([]float64{-1.0})
+	G = matrix.FloatVector([]float64{-1.0})
+	h = matrix.FloatVector([]float64{0.0})
+	return
+}
+
+func TestCertificatePrimalInfeasible(t *testing.T) {
+	c, G, h := makePrimalInfeasibleLp()
+	sol, err := Lp(c, G, h, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err == nil || sol == nil || sol.Status != PrimalInfeasible || sol.Certificate == nil {
+		t.Fatalf("infeasibility not detected: %v\n", err)
+	}
+	cert := sol.Certificate
+	if cert.X != nil || cert.S != nil {
+		t.Errorf("primal infeasibility certificate with x or s\n")
+	}
+	if res, err := cert.Verify(nil, G, h, nil, nil, nil, 1e-7); err != nil {
+		t.Errorf("Verify: %s (residual %.3e)\n", err, res)
+	}
+	if e := maxDiff(cert.Z, matrix.FloatVector([]float64{1.0, 1.0})); e > 1e-6 {
+		t.Errorf("z = %v, expected [1 1]\n", cert.Z.FloatArray())
+	}
+}
+
+func TestCertificateDualInfeasible(t *testing.T) {
+	c, G, h := makeDualInfeasibleLp()
+	sol, err := Lp(c, G, h, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err == nil || sol == nil || sol.Status != DualInfeasible || sol.Certificate == nil {
+		t.Fatalf("unboundedness not detected: %v\n", err)
+	}
+	cert := sol.Certificate
+	if cert.Y != nil || cert.Z != nil {
+		t.Errorf("dual infeasibility certificate with y or z\n")
+	}
+	if res, err := cert.Verify(c, G, h, nil, nil, nil, 1e-7); err != nil {
+		t.Errorf("Verify: %s (residual %.3e)\n", err, res)
+	}
+	if e := maxDiff(cert.X, matrix.FloatVector([]float64{1.0})); e > 1e-6 {
+		t.Errorf("x = %v, expected [1]\n", cert.X.FloatArray())
+	}
+	if e := maxDiff(cert.S, matrix.FloatVector([]float64{1.0})); e > 1e-6 {
+		t.Errorf("s = %v, expected [1]\n", cert.S.FloatArray())
+	}
+}
+
+// Each corruption of a valid certificate is rejected.
+func TestCertificateCorrupted(t *testing.T) {
+	c, G, h := makePrimalInfeasibleLp()
+	cd, Gd, hd := makeDualInfeasibleLp()
+	for _, tc := range []struct {
+		name    string
+		cert    *Certificate
+		c, G, h *matrix.FloatMatrix
+	}{
+		// G'*z != 0
+		{"unbalanced z", &Certificate{Status: PrimalInfeasible,
+			Z: matrix.FloatVector([]float64{1.0, 1.5})}, c, G, h},
+		// h'*z = 1
+		{"wrong sign", &Certificate{Status: PrimalInfeasible,
+			Z: matrix.FloatVector([]float64{-1.0, -1.0})}, c, G, h},
+		// z not in the cone
+		{"negative z", &Certificate{Status: PrimalInfeasible,
+			Z: matrix.FloatVector([]float64{-1.0, 1.0})}, c, G, h},
+		// G*x + s != 0
+		{"unbalanced s", &Certificate{Status: DualInfeasible,
+			X: matrix.FloatVector([]float64{1.0}), S: matrix.FloatVector([]float64{0.5})}, cd, Gd, hd},
+		// s not in the cone
+		{"negative s", &Certificate{Status: DualInfeasible,
+			X: matrix.FloatVector([]float64{-1.0}), S: matrix.FloatVector([]float64{-1.0})}, cd, Gd, hd},
+		{"wrong size", &Certificate{Status: PrimalInfeasible,
+			Z: matrix.FloatVector([]float64{1.0})}, c, G, h},
+		{"wrong status", &Certificate{Status: Optimal,
+			Z: matrix.FloatVector([]float64{1.0, 1.0})}, c, G, h},
+	} {
+		if res, err := tc.cert.Verify(tc.c, tc.G, tc.h, nil, nil, nil, 1e-7); err == nil {
+			t.Errorf("%s: accepted with residual %.3e\n", tc.name, res)
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
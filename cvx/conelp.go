@@ -15,6 +15,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"time"
 )
 
 
@@ -23,6 +24,11 @@ type fClosure struct {
 	wx2, wy2, ws2, wz2 *matrix.FloatMatrix
 	// these are singleton matrices
 	wtau, wkappa, wtau2, wkappa2 *matrix.FloatMatrix
+	// previous solution in adaptive refinement
+	px, py, ps, pz, ptau, pkappa *matrix.FloatMatrix
+	// the solution, right hand side, residual and previous solution of
+	// adaptive refinement as the lists of refineAdaptive
+	u, b, r, p []*matrix.FloatMatrix
 }
 
 func checkConeLpDimensions(dims *DimensionSet) error {
@@ -67,16 +73,30 @@ func checkConeLpDimensions(dims *DimensionSet) error {
 //    The next M cones are positive semidefinite cones of order ms[0], ...,
 //    ms[M-1] >= 0.  
 //
+//    If the problem is found to be primal or dual infeasible, the error is
+//    set and sol.Certificate holds the normalized certificate vectors.
+//    See Certificate.
+//
 func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *SolverOptions, primalstart, dualstart *FloatMatrixSet) (sol *Solution, err error) {
+	return coneLp(c, G, h, A, b, dims, solopts, primalstart, dualstart, nil)
+}
+
+// ConeLp with the operator G and the KKT solver given by ops if it is not
+// nil. Then G is not referenced and equilibration is not done.
+func coneLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *SolverOptions, primalstart, dualstart *FloatMatrixSet, ops *coneOps) (sol *Solution, err error) {
 
 	err = nil
 	const EXPON = 3
 	const STEP = 0.99
 
-	sol = &Solution{Unknown,
-		nil, nil, nil, nil, nil, 
-		0.0, 0.0, 0.0, 0.0, 0.0,
-		0.0, 0.0, 0.0, 0.0, 0.0, 0}
+	if solopts.Equilibrate && ops == nil {
+		return coneLpEquilibrated(c, G, h, A, b, dims, solopts, primalstart, dualstart)
+	}
+
+	sol = &Solution{Status: Unknown}
+	stats := &sol.Stats
+	start := time.Now()
+	defer addSince(&stats.TotalTime, start)
 
 	//var primalstart *FloatMatrixSet = nil
 	//var dualstart *FloatMatrixSet = nil
@@ -93,6 +113,10 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	feasTolerance := FEASTOL
 	absTolerance := ABSTOL
 	relTolerance := RELTOL
+	maxIter := MAXITERS
+	if solopts.MaxIter > 0 {
+		maxIter = solopts.MaxIter
+	}
 	if solopts.FeasTol > 0.0 {
 		feasTolerance = solopts.FeasTol
 	}
@@ -102,6 +126,8 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	if solopts.RelTol > 0.0 {
 		relTolerance = solopts.RelTol
 	}
+	// goroutines for the per-cone work of the scaling and cone operations
+	workers := &la.IOpt{"workers", solopts.Workers}
 
 	solvername := solopts.KKTSolverName
 	if len(solvername) == 0 {
@@ -128,6 +154,8 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	if err = checkConeLpDimensions(dims); err != nil {
 		return 
 	}
+	// workspace of the steps to the boundary and the products in S
+	cw := newConeWork(dims, 0, solopts.Workers)
 
 	cdim := dims.Sum("l", "q") + dims.SumSquared("s")
 	cdim_diag := dims.Sum("l", "q", "s")
@@ -151,13 +179,16 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		inds = append(inds, inds[len(inds)-1]+k*k)
 	}
 
-	if G != nil && !G.SizeMatch(cdim, c.Rows()) {
+	if ops == nil && G != nil && !G.SizeMatch(cdim, c.Rows()) {
 		estr := fmt.Sprintf("'G' must be of size (%d,%d)", cdim, c.Rows())
 		err = errors.New(estr)
 		return 
 	}
 	Gf := func(x, y *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error{
-		return sgemv(G, x, y, alpha, beta, dims, opts...)
+		return Sgemv(G, x, y, alpha, beta, dims, opts...)
+	}
+	if ops != nil {
+		Gf = ops.G
 	}
 
 	// Check A and set defaults if it is nil
@@ -174,6 +205,9 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	Af := func(x, y *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error {
 		return blas.GemvFloat(A, x, y, alpha, beta, opts...)
 	}
+	// the option of the transposed products, made once so that the calls
+	// through the function values do not allocate
+	optTrans := []la.Option{la.OptTrans}
 
 	// Check b and set defaults if it is nil
 	if b == nil {
@@ -189,6 +223,9 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		err = errors.New(estr)
 		return 
 	}
+	if err = checkConeLpStart(primalstart, dualstart, c.Rows(), cdim, b.Rows()); err != nil {
+		return
+	}
 
     // kktsolver(W) returns a routine for solving 3x3 block KKT system 
     //
@@ -197,17 +234,37 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
     //     [ G   0   -W'       ] [ uz ]   [ bz ]
 	var factor kktFactor
 	var kktsolver kktFactor = nil
-	if kktfunc, ok := solvers[solvername]; ok {
+	var cg *kktCgSolver = nil
+	if ops != nil {
+		factor = ops.kkt
+		kktsolver = func(W *Scaling, H, Df *matrix.FloatMatrix) (kktFunc, error) {
+			return factor(W, nil, nil)
+		}
+	} else if solvername == "cg" {
+		if cg, err = createCgSolver(G, dims, A, 0, solopts); err != nil {
+			return
+		}
+		kktsolver = func(W *Scaling, H, Df *matrix.FloatMatrix) (kktFunc, error) {
+			return cg.factor(W, nil, nil)
+		}
+	} else if kktfunc, ok := solvers[solvername]; ok {
 		// kkt function returns us problem spesific factor function.
 		factor, err = kktfunc(G, dims, A, 0)
 		// solver is 
-		kktsolver = func(W *FloatMatrixSet, H, Df *matrix.FloatMatrix) (kktFunc, error) {
+		kktsolver = func(W *Scaling, H, Df *matrix.FloatMatrix) (kktFunc, error) {
 			return factor(W, nil, nil)
 		}
 	} else {
 		err = errors.New(fmt.Sprintf("solver '%s' not known", solvername))
 		return
 	}
+	kktfactor := kktsolver
+	timer := stats.kktTimer()
+	kktsolver = func(W *Scaling, H, Df *matrix.FloatMatrix) (kktFunc, error) {
+		t0 := time.Now()
+		f, err := kktfactor(W, H, Df)
+		return timer.timed(t0, f, err)
+	}
 
 	// res() evaluates residual in 5x5 block KKT system
 	//
@@ -222,15 +279,15 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	wz3 := matrix.FloatZeros(cdim, 1)
 
 	// 
-	res := func(ux, uy, uz, utau , us, ukappa, vx, vy, vz, vtau, vs, vkappa *matrix.FloatMatrix, W *FloatMatrixSet, dg float64, lmbda *matrix.FloatMatrix) (err error) {
+	res := func(ux, uy, uz, utau , us, ukappa, vx, vy, vz, vtau, vs, vkappa *matrix.FloatMatrix, W *Scaling, dg float64, lmbda *matrix.FloatMatrix) (err error) {
 
 		err = nil
 		// vx := vx - A'*uy - G'*W^{-1}*uz - c*utau/dg
-		Af(uy, vx, -1.0, 1.0, la.OptTrans)
+		Af(uy, vx, -1.0, 1.0, optTrans...)
 		//fmt.Printf("post-Af vx=\n%v\n", vx)
 		blas.Copy(uz, wz3)
-		scale(wz3, W, false, true)
-		Gf(wz3, vx, -1.0, 1.0, la.OptTrans)
+		Scale(wz3, W, false, true)
+		Gf(wz3, vx, -1.0, 1.0, optTrans...)
 		blas.AxpyFloat(c, vx, -utau.Float()/dg)
 
 		// vy := vy + A*ux - b*utau/dg
@@ -241,18 +298,18 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		Gf(ux, vz, 1.0, 1.0)
 		blas.AxpyFloat(h, vz, -utau.Float()/dg)
 		blas.Copy(us, ws3)
-		scale(ws3, W, true, false)
+		Scale(ws3, W, true, false)
 		blas.AxpyFloat(ws3, vz, 1.0)
 		
 		// vtau := vtau + c'*ux + b'*uy + h'*W^{-1}*uz + dg*ukappa
 		var vtauplus float64 = dg*ukappa.Float() + blas.DotFloat(c, ux) +
-			blas.DotFloat(b, uy) + sdot(h, wz3, dims, 0) 
+			blas.DotFloat(b, uy) + Sdot(h, wz3, dims, 0) 
 		vtau.SetValue(vtau.Float()+vtauplus)
 
 		// vs := vs + lmbda o (uz + us)
 		blas.Copy(us, ws3)
 		blas.AxpyFloat(uz, ws3, 1.0)
-		sprod(ws3, lmbda, dims, 0, &la.SOpt{"diag", "D"})
+		cw.sprod(ws3, lmbda, true)
 		blas.AxpyFloat(ws3, vs, 1.0)
 
 		// vkappa += vkappa + lmbdag * (utau + ukappa)
@@ -264,7 +321,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 
 	resx0 := math.Max(1.0, math.Sqrt(blas.DotFloat(c,c)))
 	resy0 := math.Max(1.0, math.Sqrt(blas.DotFloat(b,b)))
-	resz0 := math.Max(1.0, snrm2(h, dims, 0))
+	resz0 := math.Max(1.0, Snrm2(h, dims, 0))
 
 	// select initial points
 
@@ -284,7 +341,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	dkappa := matrix.FloatValue(0.0)
 	dtau := matrix.FloatValue(0.0)
 
-	var W *FloatMatrixSet
+	var W *Scaling
 	var f kktFunc
 	if primalstart == nil || dualstart == nil {
 		// Factor
@@ -293,24 +350,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		//     [ A   0   0  ].
 		//     [ G   0  -I  ]
 		//
-		W = FloatSetNew("d", "di", "v", "beta", "r", "rti")
-		dd := dims.At("l")[0]
-		mat := matrix.FloatOnes(dd, 1)
-		W.Set("d", mat)
-		mat = matrix.FloatOnes(dd, 1)
-		W.Set("di", mat)
-		dq := len(dims.At("q"))
-		W.Set("beta", matrix.FloatOnes(dq, 1))
-
-		for _, n := range dims.At("q")  {
-			vm := matrix.FloatZeros(n, 1)
-			vm.SetIndex(0, 1.0)
-			W.Append("v", vm)
-		}
-		for _, n := range dims.At("s") {
-			W.Append("r", matrix.FloatIdentity(n))
-			W.Append("rti", matrix.FloatIdentity(n))
-		}
+		W = NewScaling(dims, 0)
 		f, err = kktsolver(W, nil, nil)
 		if err != nil {
 			fmt.Printf("kktsolver error: %s\n", err)
@@ -343,7 +383,10 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	}
 
 	// ts = min{ t | s + t*e >= 0 }
-	ts,_ := maxStep(s, dims, 0, nil)
+	ts, err := cw.maxStep(s, nil)
+	if err != nil {
+		return
+	}
 	if ts >= 0 && primalstart != nil {
 		err = errors.New("initial s is not positive")
 		return 
@@ -367,21 +410,24 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			return
 		}
 	} else {
-		if len(dualstart.At("y")) > 0 {
+		if firstOf(dualstart, "y") != nil {
 			blas.Copy(dualstart.At("y")[0], y)
 		}
 		blas.Copy(dualstart.At("z")[0], z)
 	}
 
 	// ts = min{ t | z + t*e >= 0 }
-	tz,_ := maxStep(z, dims, 0, nil)
+	tz, err := cw.maxStep(z, nil)
+	if err != nil {
+		return
+	}
 	if tz >= 0 && dualstart != nil {
 		err = errors.New("initial z is not positive")
 		return 
 	}
 
-	nrms := snrm2(s, dims, 0)
-	nrmz := snrm2(z, dims, 0)
+	nrms := Snrm2(s, dims, 0)
+	nrmz := Snrm2(z, dims, 0)
 
 	gap := 0.0
 	pcost := 0.0
@@ -389,9 +435,9 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	relgap := 0.0
 
 	if primalstart == nil && dualstart == nil {
-		gap = sdot(s, z, dims, 0)
+		gap = Sdot(s, z, dims, 0)
 		pcost = blas.DotFloat(c, x)
-		dcost = -blas.DotFloat(b, y) - sdot(h, z, dims, 0)
+		dcost = -blas.DotFloat(b, y) - Sdot(h, z, dims, 0)
 		if pcost < 0.0 {
 			relgap = gap / -pcost
 		} else if dcost > 0.0 {
@@ -405,15 +451,15 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 
 			ind := dims.At("l")[0] + dims.Sum("q")
 			for _, m := range dims.At("s") {
-				symm(s, m, ind)
-				symm(z, m, ind)
+				Symm(s, m, ind)
+				Symm(z, m, ind)
 				ind += m*m
 			}
 
 			// rx = A'*y + G'*z + c
 			rx := c.Copy()
-			Af(y, rx, 1.0, 1.0, la.OptTrans)
-			Gf(z, rx, 1.0, 1.0, la.OptTrans)
+			Af(y, rx, 1.0, 1.0, optTrans...)
+			Gf(z, rx, 1.0, 1.0, optTrans...)
 			resx := math.Sqrt(blas.Dot(rx, rx).Float())
 			// ry = b - A*x 
 			ry := b.Copy()
@@ -424,13 +470,13 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			Gf(x, rz, 1.0, 0.0)
 			blas.AxpyFloat(s, rz, 1.0)
 			blas.AxpyFloat(h, rz, -1.0)
-			resz := snrm2(rz, dims, 0)
+			resz := Snrm2(rz, dims, 0)
 
 			pres := math.Max(resy/resy0, resz/resz0)
 			dres := resx/resx0
 			cx := blas.Dot(c, x).Float()
 			by := blas.Dot(b, y).Float()
-			hz := sdot(h, z, dims, 0)
+			hz := Sdot(h, z, dims, 0)
 
 			sol.X = x; sol.Y = y; sol.S = s; sol.Z = z
 			sol.Result = FloatSetNew("x", "y", "s", "x")
@@ -529,22 +575,33 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 	sigz := matrix.FloatZeros(dims.Sum("s"), 1)
 	lmbda := matrix.FloatZeros(cdim_diag+1, 1)
 	lmbdasq := matrix.FloatZeros(cdim_diag+1, 1)
+	// unit entries of e in the 'l' and 'q' blocks and in all blocks
+	ielq := identityIndexes(dims, false)
+	ie := identityIndexes(dims, true)
 
-	gap = sdot(s, z, dims, 0)
+	gap = Sdot(s, z, dims, 0)
 
 	var x1, y1, z1 *matrix.FloatMatrix
 	var dg, dgi float64
 	var th *matrix.FloatMatrix
 	var WS fClosure
+	// adaptive refinement and the statistics of the current iteration
+	adaptive := solopts.AdaptiveRefinement
+	refineTol, maxRefine := refinementLimits(solopts)
+	var kktres float64
+	var refsteps int
 	var f3 kktFunc
 
 	//fmt.Printf("preloop x=\n%v\n", x.ConvertToString())
 	//fmt.Printf("preloop z=\n%v\n", z.ConvertToString())
 	//fmt.Printf("preloop s=\n%v\n", s.ConvertToString())
-	for iter := 0; iter < solopts.MaxIter+1; iter++ {
+	// sized for all iterations so that the appends do not allocate
+	sol.IterStats = make([]IterationStats, 0, maxIter+1)
+	addSince(&stats.SetupTime, start)
+	for iter := 0; iter < maxIter+1; iter++ {
 		// hrx = -A'*y - G'*z 
-		Af(y, hrx, -1.0, 0.0, la.OptTrans)
-		Gf(z, hrx, -1.0, 1.0, la.OptTrans)
+		Af(y, hrx, -1.0, 0.0, optTrans...)
+		Gf(z, hrx, -1.0, 1.0, optTrans...)
 		hresx := math.Sqrt( blas.DotFloat(hrx, hrx) ) 
 
 		// rx = hrx - c*tau 
@@ -566,19 +623,19 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		// hrz = s + G*x  
 		Gf(x, hrz, 1.0, 0.0)
 		blas.AxpyFloat(s, hrz, 1.0)
-		hresz := snrm2(hrz, dims, 0) 
+		hresz := Snrm2(hrz, dims, 0) 
 
 		// rz = hrz - h*tau 
 		//    = s + G*x - h*tau
 		blas.ScalFloat(rz, 0.0)
 		blas.AxpyFloat(hrz, rz, 1.0)
 		blas.AxpyFloat(h, rz, -tau.Float())
-		resz := snrm2(rz, dims, 0) / tau.Float()
+		resz := Snrm2(rz, dims, 0) / tau.Float()
 
 		// rt = kappa + c'*x + b'*y + h'*z '
 		cx := blas.DotFloat(c, x)
 		by := blas.DotFloat(b, y)
-		hz := sdot(h, z, dims, 0)
+		hz := Sdot(h, z, dims, 0)
 		rt := kappa.Float() + cx + by + hz 
 
 		// Statistics for stopping criteria
@@ -618,7 +675,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 
 		if (pres <= feasTolerance && dres <= feasTolerance &&
 			(gap <= absTolerance || (!math.IsNaN(relgap) && relgap <= relTolerance))) ||
-			iter == solopts.MaxIter {
+			iter == maxIter {
 			// done
 			blas.ScalFloat(x, 1.0/tau.Float())
 			blas.ScalFloat(y, 1.0/tau.Float())
@@ -626,13 +683,13 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			blas.ScalFloat(z, 1.0/tau.Float())
 			ind := dims.Sum("l", "q")
 			for _, m := range dims.At("s") {
-				symm(s, m, ind)
-				symm(z, m, ind)
+				Symm(s, m, ind)
+				Symm(z, m, ind)
 				ind += m*m
 			}
-			ts, _ = maxStep(s, dims, 0, nil)
-			tz, _ = maxStep(z, dims, 0, nil)
-			if iter == solopts.MaxIter {
+			ts, _ = cw.maxStep(s, nil)
+			tz, _ = cw.maxStep(z, nil)
+			if iter == maxIter {
 				// MaxIterations exceeded
 				if solopts.ShowProgress {
 					fmt.Printf("No solution. Max iterations exceeded\n")
@@ -692,11 +749,12 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			sol.X = nil; sol.Y = nil; sol.S = nil; sol.Z = nil
 			ind := dims.Sum("l", "q")
 			for _, m := range dims.At("s") {
-				symm(z, m, ind)
+				Symm(z, m, ind)
 				ind += m*m
 			}
-			tz, _ = maxStep(z, dims, 0, nil)
+			tz, _ = cw.maxStep(z, nil)
 			sol.Status = PrimalInfeasible
+			sol.Certificate = &Certificate{Status: PrimalInfeasible, Y: y, Z: z}
 			sol.Result = FloatSetNew("x", "y", "s", "x")
 			sol.Result.Append("x", nil)
 			sol.Result.Append("y", nil)
@@ -719,17 +777,18 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			if solopts.ShowProgress {
 				fmt.Printf("Dual infeasible.\n")
 			}
-			err = errors.New("Primal infeasible")
+			err = errors.New("Dual infeasible")
 			blas.ScalFloat(x, 1.0/(-cx))
 			blas.ScalFloat(s, 1.0/(-cx))
 			sol.X = nil; sol.Y = nil; sol.S = nil; sol.Z = nil
 			ind := dims.Sum("l", "q")
 			for _, m := range dims.At("s") {
-				symm(s, m, ind)
+				Symm(s, m, ind)
 				ind += m*m
 			}
-			ts, _ = maxStep(s, dims, 0, nil)
-			sol.Status = PrimalInfeasible
+			ts, _ = cw.maxStep(s, nil)
+			sol.Status = DualInfeasible
+			sol.Certificate = &Certificate{Status: DualInfeasible, X: x, S: s}
 			sol.Result = FloatSetNew("x", "y", "s", "x")
 			sol.Result.Append("x", nil)
 			sol.Result.Append("y", nil)
@@ -754,7 +813,9 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		//     W * z = W^{-T} * s = lambda
 		//     dg * tau = 1/dg * kappa = lambdag.
 		if iter == 0 {
-			W, err = computeScaling(s, z, lmbda, dims, 0)
+			t0 := time.Now()
+			W, err = ComputeScaling(s, z, lmbda, dims, 0, workers)
+			addSince(&stats.ScalingTime, t0)
 
 			//     dg = sqrt( kappa / tau )
 			//     dgi = sqrt( tau / kappa )
@@ -767,7 +828,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			lmbda.SetIndex(-1, math.Sqrt(float64(tau.Float()*kappa.Float())))
 		}
 		// lmbdasq := lmbda o lmbda 
-		ssqr(lmbdasq, lmbda, dims, 0)
+		Ssqr(lmbdasq, lmbda, dims, 0)
 		lmbdasq.SetIndex(-1, lmbda.GetIndex(-1)*lmbda.GetIndex(-1))
 
 		// f3(x, y, z) solves    
@@ -785,6 +846,10 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		//     [-A   0   0     ]*[ y1        ] = -dgi * [ b ].
 		//     [-G   0   W'*W  ] [ W^{-1}*z1 ]          [ h ]
 
+		if cg != nil {
+			// CG tolerance follows the duality gap
+			cg.setGap(gap, cdim_diag+1)
+		}
 		f3, err = kktsolver(W, nil, nil)
 		if err != nil {
 			fmt.Printf("kktsolver error=%v\n", err)
@@ -817,12 +882,12 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 				blas.ScalFloat(z, t_)
 				ind := dims.Sum("l", "q")
 				for _, m := range dims.At("s") {
-					symm(s, m, ind)
-					symm(z, m, ind)
+					Symm(s, m, ind)
+					Symm(z, m, ind)
 					ind += m*m
 				}
-				ts,_ = maxStep(s, dims, 0, nil)
-				tz,_ = maxStep(z, dims, 0, nil)
+				ts,_ = cw.maxStep(s, nil)
+				tz,_ = cw.maxStep(z, nil)
 				err = errors.New("Terminated (singular KKT matrix).")
 				sol.X = x; sol.Y = y; sol.S = s; sol.Z = z
 				sol.Result = FloatSetNew("x", "y", "s", "x")
@@ -862,7 +927,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		}
 
 		blas.Copy(h, th)
-		scale(th, W, true, true)
+		Scale(th, W, true, true)
 
 		f6_no_ir := func(x, y, z, tau, s, kappa *matrix.FloatMatrix) (err error) {
             // Solve 
@@ -891,12 +956,12 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			blas.ScalFloat(y, -1.0)
 
             // s := -lmbda o\ s = -lmbda o\ bs
-			err = sinv(s, lmbda, dims, 0)
+			err = Sinv(s, lmbda, dims, 0)
 			blas.ScalFloat(s, -1.0)
 
             // z := -(z + W'*s) = -bz + W'*(lambda o\ bs)
 			blas.Copy(s, ws3)
-			err = scale(ws3, W, true, false)
+			err = Scale(ws3, W, true, false)
 			blas.AxpyFloat(ws3, z, 1.0)
 			blas.ScalFloat(z, -1.0)
 
@@ -921,11 +986,11 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 
             //tau[0] = dgi * ( tau[0] + xdot(c,x) + ydot(b,y) + 
             //    misc.sdot(th, z, dims) ) / (1.0 + misc.sdot(z1, z1, dims))
-			//tau_ = tau_ + blas.DotFloat(c, x) + blas.DotFloat(b, y) + sdot(th, z, dims, 0)
+			//tau_ = tau_ + blas.DotFloat(c, x) + blas.DotFloat(b, y) + Sdot(th, z, dims, 0)
 			tau_ += blas.DotFloat(c, x)
 			tau_ += blas.DotFloat(b, y)
-			tau_ += sdot(th, z, dims, 0)
-			tau_ = dgi * tau_ / (1.0 + sdot(z1, z1, dims, 0))
+			tau_ += Sdot(th, z, dims, 0)
+			tau_ = dgi * tau_ / (1.0 + Sdot(z1, z1, dims, 0))
 			tau.SetValue(tau_)
 			blas.AxpyFloat(x1, x, tau_)
 			blas.AxpyFloat(y1, y, tau_)
@@ -942,7 +1007,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		// and ~ 12 is the limit. We wrap them to a structure.
 
 		if iter == 0 {
-			if refinement > 0 || solopts.Debug {
+			if refinement > 0 || adaptive || solopts.Debug {
 				WS.wx = c.Copy()
 				WS.wy = b.Copy()
 				WS.wz = matrix.FloatZeros(cdim, 1)
@@ -950,7 +1015,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 				WS.wtau = matrix.FloatValue(0.0)
 				WS.wkappa = matrix.FloatValue(0.0)
 			}
-			if refinement > 0 {
+			if refinement > 0 || adaptive {
 				WS.wx2 = c.Copy()
 				WS.wy2 = b.Copy()
 				WS.wz2 = matrix.FloatZeros(cdim, 1)
@@ -958,11 +1023,23 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 				WS.wtau2 = matrix.FloatValue(0.0)
 				WS.wkappa2 = matrix.FloatValue(0.0)
 			}
+			if adaptive {
+				WS.px = c.Copy()
+				WS.py = b.Copy()
+				WS.pz = matrix.FloatZeros(cdim, 1)
+				WS.ps = matrix.FloatZeros(cdim, 1)
+				WS.ptau = matrix.FloatValue(0.0)
+				WS.pkappa = matrix.FloatValue(0.0)
+				WS.u = make([]*matrix.FloatMatrix, 6)
+				WS.b = []*matrix.FloatMatrix{WS.wx, WS.wy, WS.wz, WS.wtau, WS.ws, WS.wkappa}
+				WS.r = []*matrix.FloatMatrix{WS.wx2, WS.wy2, WS.wz2, WS.wtau2, WS.ws2, WS.wkappa2}
+				WS.p = []*matrix.FloatMatrix{WS.px, WS.py, WS.pz, WS.ptau, WS.ps, WS.pkappa}
+			}
 		}
 
 		f6 := func(x, y, z, tau, s, kappa *matrix.FloatMatrix) error {
 			var err error =  nil
-			if refinement > 0 || solopts.Debug {
+			if refinement > 0 || adaptive || solopts.Debug {
 				blas.Copy(x, WS.wx)
 				blas.Copy(y, WS.wy)
 				blas.Copy(z, WS.wz)
@@ -971,7 +1048,22 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 				WS.wkappa.SetValue(kappa.Float())
 			}
 			err = f6_no_ir(x, y, z, tau, s, kappa)
-			for i := 0; i < refinement; i++ {
+			if adaptive && err == nil {
+				var steps int
+				WS.u[0], WS.u[1], WS.u[2] = x, y, z
+				WS.u[3], WS.u[4], WS.u[5] = tau, s, kappa
+				kktres, steps, err = refineAdaptive(WS.u, WS.b, WS.r, WS.p,
+					refineTol, maxRefine,
+					func(u, r []*matrix.FloatMatrix) error {
+						return res(u[0], u[1], u[2], u[3], u[4], u[5],
+							r[0], r[1], r[2], r[3], r[4], r[5], W, dg, lmbda)
+					},
+					func(r []*matrix.FloatMatrix) error {
+						return f6_no_ir(r[0], r[1], r[2], r[3], r[4], r[5])
+					})
+				refsteps += steps
+			}
+			for i := 0; i < refinement && ! adaptive; i++ {
 				blas.Copy(WS.wx, WS.wx2)
 				blas.Copy(WS.wy, WS.wy2)
 				blas.Copy(WS.wz, WS.wz2)
@@ -980,6 +1072,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 				WS.wkappa2.SetValue(WS.wkappa.Float())
 				err = res(x, y, z, tau, s, kappa, WS.wx2, WS.wy2, WS.wz2, WS.wtau2, WS.ws2, WS.wkappa2, W, dg, lmbda)
 				err = f6_no_ir(WS.wx2, WS.wy2, WS.wz2, WS.wtau2, WS.ws2, WS.wkappa2)
+				refsteps++
 				blas.AxpyFloat(WS.wx2, x, 1.0)
 				blas.AxpyFloat(WS.wy2, y, 1.0)
 				blas.AxpyFloat(WS.wz2, z, 1.0)
@@ -994,10 +1087,11 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			return err
 		}
 
-		var nrm float64 = blas.Nrm2(lmbda).Float()
+		var nrm float64 = blas.Nrm2Float(lmbda)
         mu := math.Pow(nrm, 2.0) / (1.0 + float64(cdim_diag))
         sigma := 0.0
 		var step, tt, tk float64
+		kktres, refsteps = 0.0, 0
 
 		for i := 0; i < 2; i++ {
             // Solve
@@ -1034,14 +1128,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 
 			if i == 1 {
 				blas.AxpyFloat(ws3, ds, 1.0)
-				ind = dims.Sum("l", "q")
-				is := make([]int, 0) 
-				is = append(is, matrix.MakeIndexSet(0, dims.At("l")[0], 1)...)
-				is = append(is, indq[:len(indq)-1]...)
-				for _, m := range dims.At("s") {
-					is = append(is, matrix.MakeIndexSet(ind, ind+m*m, m+1)...)
-				}
-				for _, k := range is {
+				for _, k := range ie {
 					ds.SetIndex(k, ds.GetIndex(k) - sigma*mu)
 				}
 				
@@ -1064,7 +1151,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			// Save ds o dz and dkappa * dtau for Mehrotra correction
 			if i == 0 {
 				blas.Copy(ds, ws3)
-				sprod(ws3, dz, dims, 0)
+				cw.sprod(ws3, dz, false)
 				wkappa3.SetValue(dtau.Float() * dkappa.Float())
 			}
 
@@ -1074,15 +1161,23 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
             // blocks in ds, dz.  The eigenvectors Qs, Qz are stored in 
             // dsk, dzk.  The eigenvalues are stored in sigs, sigz. 
 			var ts, tz float64
+			t0 := time.Now()
 
 			scale2(lmbda, ds, dims, 0, false)
 			scale2(lmbda, dz, dims, 0, false)
 			if i == 0 {
-				ts,_ = maxStep(ds, dims, 0, nil)
-				tz,_ = maxStep(dz, dims, 0, nil)
+				ts, err = cw.maxStep(ds, nil)
+				if err == nil {
+					tz, err = cw.maxStep(dz, nil)
+				}
 			} else {
-				ts,_ = maxStep(ds, dims, 0, sigs)
-				tz,_ = maxStep(dz, dims, 0, sigz)
+				ts, err = cw.maxStep(ds, sigs)
+				if err == nil {
+					tz, err = cw.maxStep(dz, sigz)
+				}
+			}
+			if err != nil {
+				return
 			}
 			dt_ := dtau.Float()
 			dk_ := dkappa.Float()
@@ -1098,12 +1193,23 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 					step = math.Min(1.0, STEP/t)
 				}
 			}
+			addSince(&stats.StepTime, t0)
 			if i == 0 {
 				// sigma = (1 - step)^3 
 				sigma = (1.0-step)*(1.0-step)*(1.0-step)
 				//sigma = math.Pow((1.0 - step), EXPON)
+				stats.AffineStep = step
+			} else {
+				stats.Step = step
 			}
 		}
+		istats := IterationStats{KKTResidual: kktres, Refinement: refsteps}
+		if cg != nil {
+			istats.CGIterations = cg.iters
+			istats.CGUnconverged = cg.unconverged
+		}
+		sol.IterStats = append(sol.IterStats, istats)
+		stats.Refinement += refsteps
 		//fmt.Printf("** tau = %.17f, kappa = %.17f\n", tau.Float(), kappa.Float())
 		//fmt.Printf("** step = %.17f, sigma = %.17f\n", step, sigma)
 
@@ -1122,10 +1228,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 		blas.ScalFloat(ds, step, &la.IOpt{"n", dims.Sum("l", "q")})
 		blas.ScalFloat(dz, step, &la.IOpt{"n", dims.Sum("l", "q")})
 
-		is := make([]int, 0)
-		is = append(is, matrix.MakeIndexSet(0, dims.At("l")[0], 1)...)
-		is = append(is, indq[:len(indq)-1]...)
-		for _, k := range is {
+		for _, k := range ielq {
 			ds.SetIndex(k, 1.0+ds.GetIndex(k))
 			dz.SetIndex(k, 1.0+dz.GetIndex(k))
 		}
@@ -1168,7 +1271,9 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			ind3 += m
 		}
 		
-		err = updateScaling(W, lmbda, ds, dz)
+		t0 := time.Now()
+		err = UpdateScaling(W, lmbda, ds, dz)
+		addSince(&stats.ScalingTime, t0)
 
         // For kappa, tau block: 
         //
@@ -1194,7 +1299,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			ind += m
 			ind2 += m*m
 		}
-		scale(s, W, true, false)
+		Scale(s, W, true, false)
 		
 		ind = dims.Sum("l", "q")
 		ind2 = ind
@@ -1206,7 +1311,7 @@ func ConeLp(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *Solv
 			ind += m
 			ind2 += m*m
 		}
-		scale(z, W, false, true)
+		Scale(z, W, false, true)
 		
 		kappa.SetValue(lmbda.GetIndex(-1)/dgi)
 		tau.SetValue(lmbda.GetIndex(-1)*dgi)
@@ -14,6 +14,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"time"
 )
 
 func checkConeQpDimensions(dims *DimensionSet) error {
@@ -60,24 +61,34 @@ func checkConeQpDimensions(dims *DimensionSet) error {
 //    ms[M-1] >= 0.  
 //
 func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *SolverOptions, initvals *FloatMatrixSet) (sol *Solution, err error) {
+	return coneQp(P, q, G, h, A, b, dims, solopts, initvals, nil)
+}
+
+// ConeQp with the operator G and the KKT solver given by ops if it is not
+// nil. Then G is not referenced.
+func coneQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *SolverOptions, initvals *FloatMatrixSet, ops *coneOps) (sol *Solution, err error) {
 
 
 	err = nil
 	EXPON := 3
 	STEP := 0.99
 
-	sol = &Solution{Unknown,
-		nil, nil, nil, nil, nil,
-		0.0, 0.0, 0.0, 0.0, 0.0,
-		0.0, 0.0, 0.0, 0.0, 0.0, 0}
+	sol = &Solution{Status: Unknown}
+	stats := &sol.Stats
+	start := time.Now()
+	defer addSince(&stats.TotalTime, start)
 
-	var kktsolver func(*FloatMatrixSet)(kktFunc, error) = nil
+	var kktsolver func(*Scaling)(kktFunc, error) = nil
 	var refinement int
 	var correction bool = true
 
 	feasTolerance := FEASTOL
 	absTolerance := ABSTOL
 	relTolerance := RELTOL
+	maxIter := MAXITERS
+	if solopts.MaxIter > 0 {
+		maxIter = solopts.MaxIter
+	}
 	if solopts.FeasTol > 0.0 {
 		feasTolerance = solopts.FeasTol
 	}
@@ -87,6 +98,16 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 	if solopts.RelTol > 0.0 {
 		relTolerance = solopts.RelTol
 	}
+	// goroutines for the per-cone work of the scaling and cone operations
+	workers := &la.IOpt{"workers", solopts.Workers}
+	if solopts.Refinement > 0 {
+		refinement = solopts.Refinement
+	}
+	// adaptive refinement and the statistics of the current iteration
+	adaptive := solopts.AdaptiveRefinement
+	refineTol, maxRefine := refinementLimits(solopts)
+	var kktres float64
+	var refsteps int
 
 	solvername := solopts.KKTSolverName
 	if len(solvername) == 0 {
@@ -128,6 +149,8 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 	if err != nil {
 		return
 	}
+	// workspace of the steps to the boundary and the products in S
+	cw := newConeWork(dims, 0, solopts.Workers)
 
 	cdim := dims.Sum("l", "q") + dims.SumSquared("s")
 	//cdim_pckd := dims.Sum("l", "q") + dims.SumPacked("s")
@@ -152,13 +175,16 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		inds = append(inds, inds[len(inds)-1]+k*k)
 	}
 
-	if G != nil && !G.SizeMatch(cdim, q.Rows()) {
+	if ops == nil && G != nil && !G.SizeMatch(cdim, q.Rows()) {
 		estr := fmt.Sprintf("'G' must be of size (%d,%d)", cdim, q.Rows())
 		err = errors.New(estr)
 		return 
 	}
 	fG := func(x, y *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error{
-		return sgemv(G, x, y, alpha, beta, dims, opts...)
+		return Sgemv(G, x, y, alpha, beta, dims, opts...)
+	}
+	if ops != nil {
+		fG = ops.G
 	}
 
 	// Check A and set defaults if it is nil
@@ -175,6 +201,9 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 	fA := func(x, y *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error {
 		return blas.GemvFloat(A, x, y, alpha, beta, opts...)
 	}
+	// the option of the transposed products, made once so that the calls
+	// through the function values do not allocate
+	optTrans := []la.Option{la.OptTrans}
 
 	// Check b and set defaults if it is nil
 	if b == nil {
@@ -190,6 +219,9 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		err = errors.New(estr)
 		return 
 	}
+	if err = StartPointFromSets(initvals).Validate(q.Rows(), cdim, b.Rows()); err != nil {
+		return
+	}
 
     // kktsolver(W) returns a routine for solving 3x3 block KKT system 
     //
@@ -197,7 +229,20 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
     //     [ A   0   0         ] [ uy ] = [ by ].
     //     [ G   0   -W'       ] [ uz ]   [ bz ]
 	var factor kktFactor
-	if kkt, ok := solvers[solvername]; ok {
+	var cg *kktCgSolver = nil
+	if ops != nil {
+		factor = ops.kkt
+		kktsolver = func(W *Scaling) (kktFunc, error) {
+			return factor(W, P, nil)
+		}
+	} else if solvername == "cg" {
+		if cg, err = createCgSolver(G, dims, A, 0, solopts); err != nil {
+			return
+		}
+		kktsolver = func(W *Scaling) (kktFunc, error) {
+			return cg.factor(W, P, nil)
+		}
+	} else if kkt, ok := solvers[solvername]; ok {
 		if b.Rows() > q.Rows()  {
 			err = errors.New("1: Rank(A) < p or Rank[G; A] < n")
 			return
@@ -212,19 +257,26 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			fmt.Printf("error on factoring: %s\n", err)
 		}
 		// solver is 
-		kktsolver = func(W *FloatMatrixSet) (kktFunc, error) {
+		kktsolver = func(W *Scaling) (kktFunc, error) {
 			return factor(W, P, nil)
 		}
 	} else {
 		err = errors.New(fmt.Sprintf("solver '%s' not known", solvername))
 		return
 	}
+	kktfactor := kktsolver
+	timer := stats.kktTimer()
+	kktsolver = func(W *Scaling) (kktFunc, error) {
+		t0 := time.Now()
+		f, err := kktfactor(W)
+		return timer.timed(t0, f, err)
+	}
 
 	ws3 := matrix.FloatZeros(cdim, 1)
 	wz3 := matrix.FloatZeros(cdim, 1)
 
 	// 
-	res := func(ux, uy, uz, us, vx, vy, vz, vs *matrix.FloatMatrix, W *FloatMatrixSet, lmbda *matrix.FloatMatrix) (err error) {
+	res := func(ux, uy, uz, us, vx, vy, vz, vs *matrix.FloatMatrix, W *Scaling, lmbda *matrix.FloatMatrix) (err error) {
         // Evaluates residual in Newton equations:
         // 
         //      [ vx ]    [ vx ]   [ 0     ]   [ P  A'  G' ]   [ ux        ]
@@ -235,35 +287,35 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 
         // vx := vx - P*ux - A'*uy - G'*W^{-1}*uz
 		fP(ux, vx, -1.0, 1.0)
-		fA(uy, vx, -1.0, 1.0, la.OptTrans)
+		fA(uy, vx, -1.0, 1.0, optTrans...)
 		blas.Copy(uz, wz3)
-		scale(wz3, W, true, false)
-		fG(wz3, vx, -1.0, 1.0, la.OptTrans)
+		Scale(wz3, W, true, false)
+		fG(wz3, vx, -1.0, 1.0, optTrans...)
         // vy := vy - A*ux
         fA(ux, vy, -1.0, 1.0)
 
         // vz := vz - G*ux - W'*us
         fG(ux, vz, -1.0, 1.0)
         blas.Copy(us, ws3)
-        scale(ws3, W, true, false)
+        Scale(ws3, W, true, false)
         blas.AxpyFloat(ws3, vz, -1.0)
  
         // vs := vs - lmbda o (uz + us)
         blas.Copy(us, ws3)
         blas.AxpyFloat(uz, ws3, 1.0)
-        sprod(ws3, lmbda, dims, 0, la.OptDiag)
+        cw.sprod(ws3, lmbda, true)
         blas.AxpyFloat(ws3, vs, -1.0)
 		return 
 	}
 
 	resx0 := math.Max(1.0, math.Sqrt(blas.Dot(q,q).Float()))
 	resy0 := math.Max(1.0, math.Sqrt(blas.Dot(b,b).Float()))
-	resz0 := math.Max(1.0, snrm2(h, dims, 0))
+	resz0 := math.Max(1.0, Snrm2(h, dims, 0))
 	//fmt.Printf("resx0: %.17f, resy0: %.17f, resz0: %.17f\n", resx0, resy0, resz0)
 
 	var x, y, z, s, dx, dy, ds, dz, rx, ry, rz *matrix.FloatMatrix
 	var lmbda, lmbdasq, sigs, sigz *matrix.FloatMatrix
-	var W *FloatMatrixSet
+	var W *Scaling
 	var f, f3 kktFunc
 	var resx, resy, resz, step, sigma, mu, eta float64
 	var gap, pcost, dcost, relgap, pres, dres, f0 float64
@@ -275,9 +327,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		//     [       ] [   ] = [    ].
 		//     [ A  0  ] [ y ]   [  b ]
 		//
-		Wtmp := FloatSetNew("d", "di", "beta", "v", "r", "rti")
-		Wtmp.Set("d", matrix.FloatZeros(0, 1))
-		Wtmp.Set("di", matrix.FloatZeros(0, 1))
+		Wtmp := &Scaling{D: matrix.FloatZeros(0, 1), Di: matrix.FloatZeros(0, 1)}
 		f3, err = kktsolver(Wtmp)
 		if err != nil {
 			s := fmt.Sprintf("kkt error: %s", err)
@@ -293,7 +343,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		rx = q.Copy()
 		fP(x, rx, 1.0, 1.0)
 		pcost = 0.5 *( blas.DotFloat(x, rx) + blas.DotFloat(x, q))
-		fA(y, rx, 1.0, 1.0, la.OptTrans)
+		fA(y, rx, 1.0, 1.0, optTrans...)
 		dres = math.Sqrt(blas.DotFloat(rx, rx)/resx0)
 		
 		ry = b.Copy()
@@ -305,11 +355,13 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			relgap = math.NaN()
 		}
 
+		sol.X = x; sol.Y = y
+		sol.S = matrix.FloatZeros(0,1); sol.Z = matrix.FloatZeros(0,1)
 		sol.Result = FloatSetNew("x", "y", "s", "z")
-		sol.Result.Set("x", x)
-		sol.Result.Set("y", y)
-		sol.Result.Set("s", matrix.FloatZeros(0,1))
-		sol.Result.Set("z", matrix.FloatZeros(0,1))
+		sol.Result.Set("x", sol.X)
+		sol.Result.Set("y", sol.Y)
+		sol.Result.Set("s", sol.S)
+		sol.Result.Set("z", sol.Z)
 		sol.Status = Optimal
 		sol.Gap = 0.0; sol.RelativeGap = relgap
 		sol.PrimalObjective = pcost
@@ -334,20 +386,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		//     [ A   0   0  ].
 		//     [ G   0  -I  ]
 		//
-		W = FloatSetNew("d", "di", "v", "beta", "r", "rti")
-		W.Set("d", matrix.FloatOnes(dims.At("l")[0], 1))
-		W.Set("di", matrix.FloatOnes(dims.At("l")[0], 1))
-		W.Set("beta", matrix.FloatOnes(len(dims.At("q")), 1))
-
-		for _, n := range dims.At("q")  {
-			vm := matrix.FloatZeros(n, 1)
-			vm.SetIndex(0, 1.0)
-			W.Append("v", vm)
-		}
-		for _, n := range dims.At("s") {
-			W.Append("r", matrix.FloatIdentity(n))
-			W.Append("rti", matrix.FloatIdentity(n))
-		}
+		W = NewScaling(dims, 0)
 		f, err = kktsolver(W)
 		if err != nil {
 			s := fmt.Sprintf("kkt error: %s", err)
@@ -372,8 +411,10 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		s = z.Copy()
 		blas.ScalFloat(s, -1.0)
 
-		nrms = snrm2(s, dims, 0)
-		ts,_ = maxStep(s, dims, 0, nil)
+		nrms = Snrm2(s, dims, 0)
+		if ts, err = cw.maxStep(s, nil); err != nil {
+			return
+		}
 		if ts >= -1e-8 * math.Max(nrms, 1.0) {
 			// a = 1.0 + ts  
 			a := 1.0 + ts
@@ -393,8 +434,10 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			}
 		}
 
-		nrmz = snrm2(z, dims, 0)
-		tz,_ = maxStep(z, dims, 0, nil)
+		nrmz = Snrm2(z, dims, 0)
+		if tz, err = cw.maxStep(z, nil); err != nil {
+			return
+		}
 		if tz >= -1e-8 * math.Max(nrmz, 1.0) {
 			a := 1.0 + tz
 			is := make([]int, 0)
@@ -411,14 +454,14 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		}
 
 	} else {
-		ix := initvals.At("x")[0]
+		ix := firstOf(initvals, "x")
 		if ix != nil {
 			blas.Copy(ix, x)
 		} else {
 			blas.ScalFloat(x, 0.0)
 		}
 
-		is := initvals.At("s")[0]
+		is := firstOf(initvals, "s")
 		if is != nil {
 			blas.Copy(is, s)
 		} else {
@@ -435,14 +478,14 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			}
 		}
 		
-		iy := initvals.At("y")[0]
+		iy := firstOf(initvals, "y")
 		if iy != nil {
 			blas.Copy(iy, y)
 		} else {
 			blas.ScalFloat(y, 0.0)
 		}
 
-		iz := initvals.At("z")[0]
+		iz := firstOf(initvals, "z")
 		if iz != nil {
 			blas.Copy(iz, z)
 		} else {
@@ -471,18 +514,24 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 	lmbdasq = matrix.FloatZeros(cdim_diag, 1)
 	sigs = matrix.FloatZeros(dims.Sum("s"), 1)
 	sigz = matrix.FloatZeros(dims.Sum("s"), 1)
+	// unit entries of e in the 'l' and 'q' blocks and in all blocks
+	ielq := identityIndexes(dims, false)
+	ie := identityIndexes(dims, true)
 
 	var WS fClosure
 
-	gap = sdot(s, z, dims, 0)
-	for iter := 0; iter < solopts.MaxIter+1; iter++ {
+	gap = Sdot(s, z, dims, 0)
+	// sized for all iterations so that the appends do not allocate
+	sol.IterStats = make([]IterationStats, 0, maxIter+1)
+	addSince(&stats.SetupTime, start)
+	for iter := 0; iter < maxIter+1; iter++ {
 
         // f0 = (1/2)*x'*P*x + q'*x + r and  rx = P*x + q + A'*y + G'*z.
         blas.Copy(q, rx)
         fP(x, rx, 1.0, 1.0)
         f0 = 0.5 * (blas.DotFloat(x, rx) + blas.DotFloat(x, q))
-        fA(y, rx, 1.0, 1.0, la.OptTrans)
-        fG(z, rx, 1.0, 1.0, la.OptTrans)
+        fA(y, rx, 1.0, 1.0, optTrans...)
+        fG(z, rx, 1.0, 1.0, optTrans...)
         resx = math.Sqrt(blas.DotFloat(rx, rx))
            
         // ry = A*x - b
@@ -494,7 +543,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
         blas.Copy(s, rz)
         blas.AxpyFloat(h, rz, -1.0)
         fG(x, rz, 1.0, 1.0)
-        resz = snrm2(rz, dims, 0)
+        resz = Snrm2(rz, dims, 0)
 		//fmt.Printf("resx: %.17f, resy: %.17f, resz: %.17f\n", resx, resy, resz)
 
         // Statistics for stopping criteria.
@@ -504,7 +553,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
         //       = (1/2)*x'*P*x + q'*x + y'*(A*x-b) + z'*(G*x-h+s) - z'*s
         //       = (1/2)*x'*P*x + q'*x + y'*ry + z'*rz - gap
         pcost = f0
-        dcost = f0 + blas.DotFloat(y, ry) + sdot(z, rz, dims, 0) - gap
+        dcost = f0 + blas.DotFloat(y, ry) + Sdot(z, rz, dims, 0) - gap
         if pcost < 0.0 {
             relgap = gap / -pcost
         } else if dcost > 0.0 {
@@ -529,17 +578,17 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		
 		if pres <= feasTolerance && dres <= feasTolerance &&
 			( gap <= absTolerance || (!math.IsNaN(relgap) && relgap <= relTolerance)) ||
-			iter == solopts.MaxIter {
+			iter == maxIter {
 
 			ind := dims.Sum("l", "q")
 			for _, m := range dims.At("s") {
-				symm(s, m, ind)
-				symm(z, m, ind)
+				Symm(s, m, ind)
+				Symm(z, m, ind)
 				ind += m*m
 			}
-			ts,_ = maxStep(s, dims, 0, nil)
-			tz,_ = maxStep(z, dims, 0, nil)
-			if iter == solopts.MaxIter {
+			ts,_ = cw.maxStep(s, nil)
+			tz,_ = cw.maxStep(z, nil)
+			if iter == maxIter {
 				// terminated on max iterations.
 				sol.Status = Unknown
 				err = errors.New("Terminated (maximum iterations reached)")
@@ -549,6 +598,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			// optimal solution found
 			//fmt.Print("Optimal solution.\n")
 			err = nil
+			sol.X = x; sol.Y = y; sol.S = s; sol.Z = z
 			sol.Result = FloatSetNew("x", "y", "s", "z")
 			sol.Result.Set("x", x)
 			sol.Result.Set("y", y)
@@ -574,10 +624,16 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
         // 
         // lmbdasq = lambda o lambda.
 		if iter == 0 {
-			W, err = computeScaling(s, z, lmbda, dims, 0)
+			t0 := time.Now()
+			W, err = ComputeScaling(s, z, lmbda, dims, 0, workers)
+			addSince(&stats.ScalingTime, t0)
 		}
-		ssqr(lmbdasq, lmbda, dims, 0)
+		Ssqr(lmbdasq, lmbda, dims, 0)
 
+		if cg != nil {
+			// CG tolerance follows the duality gap
+			cg.setGap(gap, cdim_diag)
+		}
 		f3, err = kktsolver(W)
 		if err != nil {
 			if iter == 0 {
@@ -587,15 +643,16 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			} else {
 				ind := dims.Sum("l", "q")
 				for _, m := range dims.At("s") {
-					symm(s, m, ind)
-					symm(z, m, ind)
+					Symm(s, m, ind)
+					Symm(z, m, ind)
 					ind += m*m
 				}
-				ts,_ = maxStep(s, dims, 0, nil)
-				tz,_ = maxStep(z, dims, 0, nil)
+				ts,_ = cw.maxStep(s, nil)
+				tz,_ = cw.maxStep(z, nil)
 				// terminated (singular KKT matrix)
 				fmt.Printf("Terminated (singular KKT matrix).\n")
 				err = errors.New("Terminated (singular KKT matrix).")
+				sol.X = x; sol.Y = y; sol.S = s; sol.Z = z
 				sol.Result = FloatSetNew("x", "y", "s", "z")
 				sol.Result.Set("x", x)
 				sol.Result.Set("y", y)
@@ -638,12 +695,12 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
             
             // s := lmbda o\ s 
             //    = lmbda o\ bs
-			sinv(s, lmbda, dims, 0)
+			Sinv(s, lmbda, dims, 0)
 
             // z := z - W'*s 
             //    = bz - W'*(lambda o\ bs)
 			blas.Copy(s, ws3)
-			scale(ws3, W, true, false)
+			Scale(ws3, W, true, false)
 			blas.AxpyFloat(ws3, z, -1.0)
 
 			err := f3(x, y, z)
@@ -658,36 +715,60 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		}
 
 		if iter == 0 {
-			if refinement > 0 || solopts.Debug {
+			if refinement > 0 || adaptive || solopts.Debug {
 				WS.wx = q.Copy()
 				WS.wy = y.Copy()
 				WS.ws = matrix.FloatZeros(cdim, 1)
 				WS.wz = matrix.FloatZeros(cdim, 1)
 			}
-			if refinement > 0 {
+			if refinement > 0 || adaptive {
 				WS.wx2 = q.Copy()
 				WS.wy2 = y.Copy()
 				WS.ws2 = matrix.FloatZeros(cdim, 1)
 				WS.wz2 = matrix.FloatZeros(cdim, 1)
 			}
+			if adaptive {
+				WS.px = q.Copy()
+				WS.py = y.Copy()
+				WS.ps = matrix.FloatZeros(cdim, 1)
+				WS.pz = matrix.FloatZeros(cdim, 1)
+				WS.u = make([]*matrix.FloatMatrix, 4)
+				WS.b = []*matrix.FloatMatrix{WS.wx, WS.wy, WS.wz, WS.ws}
+				WS.r = []*matrix.FloatMatrix{WS.wx2, WS.wy2, WS.wz2, WS.ws2}
+				WS.p = []*matrix.FloatMatrix{WS.px, WS.py, WS.pz, WS.ps}
+			}
 		}
 
 		f4 := func(x, y, z, s *matrix.FloatMatrix)(err error) {
 			err = nil
-			if refinement > 0 || solopts.Debug {
+			if refinement > 0 || adaptive || solopts.Debug {
 				blas.Copy(x, WS.wx)
 				blas.Copy(y, WS.wy)
 				blas.Copy(z, WS.wz)
 				blas.Copy(s, WS.ws)
 			}
 			err = f4_no_ir(x, y, z, s)
-			for i := 0; i < refinement; i++ {
+			if adaptive && err == nil {
+				var steps int
+				WS.u[0], WS.u[1], WS.u[2], WS.u[3] = x, y, z, s
+				kktres, steps, err = refineAdaptive(WS.u, WS.b, WS.r, WS.p,
+					refineTol, maxRefine,
+					func(u, r []*matrix.FloatMatrix) error {
+						return res(u[0], u[1], u[2], u[3], r[0], r[1], r[2], r[3], W, lmbda)
+					},
+					func(r []*matrix.FloatMatrix) error {
+						return f4_no_ir(r[0], r[1], r[2], r[3])
+					})
+				refsteps += steps
+			}
+			for i := 0; i < refinement && ! adaptive; i++ {
 				blas.Copy(WS.wx, WS.wx2)
 				blas.Copy(WS.wy, WS.wy2)
 				blas.Copy(WS.wz, WS.wz2)
 				blas.Copy(WS.ws, WS.ws2)
 				res(x, y, z, s, WS.wx2, WS.wy2, WS.wz2, WS.ws2, W, lmbda)
 				f4_no_ir(WS.wx2, WS.wy2, WS.wz2, WS.ws2)
+				refsteps++
 				blas.AxpyFloat(WS.wx2, x, 1.0)
 				blas.AxpyFloat(WS.wy2, y, 1.0)
 				blas.AxpyFloat(WS.wz2, z, 1.0)
@@ -699,6 +780,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 		//var mu, sigma, eta float64
 		mu = gap / float64(dims.Sum("l", "s") +  len(dims.At("q")))
 		sigma, eta = 0.0, 0.0
+		kktres, refsteps = 0.0, 0
 
 		for i := 0; i < 2; i++ {
             // Solve
@@ -720,20 +802,17 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 				blas.AxpyFloat(ws3, ds, -1.0)
 			}
 			blas.AxpyFloat(lmbdasq, ds, -1.0, &la.IOpt{"n", dims.Sum("l", "q")})
-			ind := dims.At("l")[0]
-			ds.Add(sigma*mu, matrix.MakeIndexSet(0, ind, 1)...) 
-			for _, m := range dims.At("q") {
-				ds.SetIndex(ind, sigma*mu+ds.GetIndex(ind))
-				ind += m
-			}
+			ind := dims.Sum("l", "q")
 			ind2 := ind
 			for _, m := range dims.At("s") {
 				blas.AxpyFloat(lmbdasq, ds, -1.0, &la.IOpt{"n", m}, &la.IOpt{"incy", m+1},
 					&la.IOpt{"offsetx", ind2}, &la.IOpt{"offsety", ind})
-				ds.Add(sigma*mu, matrix.MakeIndexSet(ind, ind+m*m, m+1)...)
 				ind += m*m
 				ind2 += m
 			}
+			for _, k := range ie {
+				ds.SetIndex(k, sigma*mu+ds.GetIndex(k))
+			}
 
 			// (dx, dy, dz) := -(1 - eta) * (rx, ry, rz)
 			blas.ScalFloat(dx, 0.0)
@@ -757,20 +836,20 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 				} else {
 					ind = dims.Sum("l", "q")
 					for _, m := range dims.At("s") {
-						symm(s, m, ind)
-						symm(z, m, ind)
+						Symm(s, m, ind)
+						Symm(z, m, ind)
 						ind += m*m
 					}
-					ts,_ = maxStep(s, dims, 0, nil)
-					tz,_ = maxStep(z, dims, 0, nil)
+					ts,_ = cw.maxStep(s, nil)
+					tz,_ = cw.maxStep(z, nil)
 					return
 				}
 			}
 
-			dsdz := sdot(ds, dz, dims, 0)
+			dsdz := Sdot(ds, dz, dims, 0)
 			if correction && i == 0 {
 				blas.Copy(ds, ws3)
-				sprod(ws3, dz, dims, 0)
+				cw.sprod(ws3, dz, false)
 			}
 
             // Maximum step to boundary.
@@ -778,14 +857,22 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
             // If i is 1, also compute eigenvalue decomposition of the 's' 
             // blocks in ds, dz.  The eigenvectors Qs, Qz are stored in 
             // dsk, dzk.  The eigenvalues are stored in sigs, sigz. 
+			t0 := time.Now()
 			scale2(lmbda, ds, dims, 0, false)
 			scale2(lmbda, dz, dims, 0, false)
 			if i == 0 {
-				ts,_ = maxStep(ds, dims, 0, nil)
-				tz,_ = maxStep(dz, dims, 0, nil)
+				ts, err = cw.maxStep(ds, nil)
+				if err == nil {
+					tz, err = cw.maxStep(dz, nil)
+				}
 			} else {
-				ts,_ = maxStep(ds, dims, 0, sigs)
-				tz,_ = maxStep(dz, dims, 0, sigz)
+				ts, err = cw.maxStep(ds, sigs)
+				if err == nil {
+					tz, err = cw.maxStep(dz, sigz)
+				}
+			}
+			if err != nil {
+				return
 			}
 			t := maxvec([]float64{0.0, ts, tz})
 			//fmt.Printf("== t=%.17f from %v\n", t, []float64{ts, tz})
@@ -798,14 +885,25 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 					step = math.Min(1.0, STEP/t)
 				}
 			}
+			addSince(&stats.StepTime, t0)
 			if i == 0 {
 				m := math.Max(0.0, 1.0 - step + dsdz/gap * (step*step))
 				sigma = math.Pow(math.Min(1.0, m), float64(EXPON))
 				eta = 0.0
+				stats.AffineStep = step
+			} else {
+				stats.Step = step
 			}
 			//fmt.Printf("== step=%.17f sigma=%.17f dsdz=%.17f\n", step, sigma, dsdz)
 
 		}
+		istats := IterationStats{KKTResidual: kktres, Refinement: refsteps}
+		if cg != nil {
+			istats.CGIterations = cg.iters
+			istats.CGUnconverged = cg.unconverged
+		}
+		sol.IterStats = append(sol.IterStats, istats)
+		stats.Refinement += refsteps
 
 		blas.AxpyFloat(dx, x, step)
 		blas.AxpyFloat(dy, y, step)
@@ -824,14 +922,9 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
         // dz := e + step*dz for nonlinear, 'l' and 'q' blocks.
 		blas.ScalFloat(ds, step, &la.IOpt{"n", dims.Sum("l", "q")})
 		blas.ScalFloat(dz, step, &la.IOpt{"n", dims.Sum("l", "q")})
-		ind := dims.At("l")[0]
-		is := matrix.MakeIndexSet(0, ind, 1)
-		ds.Add(1.0, is...)
-		dz.Add(1.0, is...)
-		for _, m := range dims.At("q") {
-			ds.SetIndex(ind, 1.0+ds.GetIndex(ind))
-			dz.SetIndex(ind, 1.0+dz.GetIndex(ind))
-			ind += m
+		for _, k := range ielq {
+			ds.SetIndex(k, 1.0+ds.GetIndex(k))
+			dz.SetIndex(k, 1.0+dz.GetIndex(k))
 		}
 
         // ds := H(lambda)^{-1/2} * ds and dz := H(lambda)^{-1/2} * dz.
@@ -873,11 +966,13 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			ind3 += m
 		}
 		
-		err = updateScaling(W, lmbda, ds, dz)
+		t0 := time.Now()
+		err = UpdateScaling(W, lmbda, ds, dz)
+		addSince(&stats.ScalingTime, t0)
 
         // Unscale s, z, tau, kappa (unscaled variables are used only to 
         // compute feasibility residuals).
-		ind = dims.Sum("l", "q")
+		ind := dims.Sum("l", "q")
 		ind2 = ind
 		blas.Copy(lmbda, s, &la.IOpt{"n", ind})
 		for _, m := range dims.At("s") {
@@ -887,7 +982,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			ind += m
 			ind2 += m*m
 		}
-		scale(s, W, true, false)
+		Scale(s, W, true, false)
 		
 		ind = dims.Sum("l", "q")
 		ind2 = ind
@@ -899,7 +994,7 @@ func ConeQp(P, q, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *S
 			ind += m
 			ind2 += m*m
 		}
-		scale(z, W, false, true)
+		Scale(z, W, false, true)
 
 		gap = blas.DotFloat(lmbda, lmbda)
 		//fmt.Printf("== gap = %.17f\n", gap)
@@ -0,0 +1,218 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Minimum distance of the warm start s and z from the cone boundary relative
+// to the square root of the previous duality measure.
+const WARMSTART_CENTERING = 0.1
+
+//    Reusable solver for a sequence of ConeQp problems
+//
+//        minimize    (1/2)*x'*P*x + q'*x
+//        subject to  G*x + s = h
+//                    A*x = b
+//                    s >= 0
+//
+//    with fixed P, G, A and dims and varying q, h and b. The KKT solver and
+//    its workspace are allocated once. Each solve starts from the previous
+//    iterate after a centering step that moves s and z into the interior of
+//    the cone.
+//
+type ConeQpSolver struct {
+	P, G, A *matrix.FloatMatrix
+	dims    *DimensionSet
+	opts    SolverOptions
+	ops     *coneOps
+	// previous iterate, valid if warm is set
+	x, s, y, z *matrix.FloatMatrix
+	warm       bool
+}
+
+// Creates a solver for P, G, A and dims. G, A and dims may be nil as in
+// ConeQp. The matrices are copied.
+func NewConeQpSolver(P, G, A *matrix.FloatMatrix, dims *DimensionSet, solopts *SolverOptions) (S *ConeQpSolver, err error) {
+	if P == nil || P.Rows() != P.Cols() {
+		err = errors.New("'P' must a non-nil square matrix")
+		return
+	}
+	n := P.Rows()
+	if dims == nil {
+		m := 0
+		if G != nil {
+			m = G.Rows()
+		}
+		dims = DSetNew("l", "q", "s")
+		dims.Set("l", []int{m})
+	}
+	if err = checkConeQpDimensions(dims); err != nil {
+		return
+	}
+	cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+	if G == nil {
+		G = matrix.FloatZeros(cdim, n)
+	}
+	if ! G.SizeMatch(cdim, n) {
+		err = errors.New(fmt.Sprintf("'G' must be of size (%d,%d)", cdim, n))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if A.Cols() != n {
+		err = errors.New(fmt.Sprintf("'A' must have %d columns", n))
+		return
+	}
+	S = &ConeQpSolver{P: P.Copy(), G: G.Copy(), A: A.Copy(), dims: dims}
+	if solopts != nil {
+		S.opts = *solopts
+	}
+	solvername := S.opts.KKTSolverName
+	if len(solvername) == 0 {
+		if len(dims.At("q")) > 0 || len(dims.At("s")) > 0 {
+			solvername = "qr"
+		} else {
+			solvername = "chol2"
+		}
+	}
+	kkt, ok := solvers[solvername]
+	if ! ok {
+		err = errors.New(fmt.Sprintf("solver '%s' not known", solvername))
+		return
+	}
+	factor, err := kkt(S.G, dims, S.A, 0)
+	if err != nil {
+		return
+	}
+	Gs := S.G
+	S.ops = &coneOps{
+		G: func(x, y *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error {
+			return Sgemv(Gs, x, y, alpha, beta, dims, opts...)
+		},
+		kkt: factor}
+	S.x = matrix.FloatZeros(n, 1)
+	S.s = matrix.FloatZeros(cdim, 1)
+	S.y = matrix.FloatZeros(A.Rows(), 1)
+	S.z = matrix.FloatZeros(cdim, 1)
+	return
+}
+
+// Replaces the values of P with those of P2 of the same size.
+func (S *ConeQpSolver) UpdateP(P2 *matrix.FloatMatrix) error {
+	if P2 == nil || ! P2.SizeMatch(S.P.Size()) {
+		return errors.New(fmt.Sprintf("'P' must be of size (%d,%d)", S.P.Rows(), S.P.Cols()))
+	}
+	copy(S.P.FloatArray(), P2.FloatArray())
+	return nil
+}
+
+// Replaces the values of G with those of G2 of the same size.
+func (S *ConeQpSolver) UpdateG(G2 *matrix.FloatMatrix) error {
+	if G2 == nil || ! G2.SizeMatch(S.G.Size()) {
+		return errors.New(fmt.Sprintf("'G' must be of size (%d,%d)", S.G.Rows(), S.G.Cols()))
+	}
+	copy(S.G.FloatArray(), G2.FloatArray())
+	return nil
+}
+
+// Discards the previous iterate; the next solve starts cold.
+func (S *ConeQpSolver) Reset() {
+	S.warm = false
+}
+
+// Solves the problem for q, h and b. h and b may be nil if G and A have no
+// rows. The solution vectors are not shared with the solver.
+func (S *ConeQpSolver) Solve(q, h, b *matrix.FloatMatrix) (sol *Solution, err error) {
+	n, cdim, p := S.x.Rows(), S.s.Rows(), S.y.Rows()
+	if q == nil || ! q.SizeMatch(n, 1) {
+		err = errors.New(fmt.Sprintf("'q' must be matrix of size (%d,1)", n))
+		return
+	}
+	if h == nil {
+		h = matrix.FloatZeros(0, 1)
+	}
+	if ! h.SizeMatch(cdim, 1) {
+		err = errors.New(fmt.Sprintf("'h' must be matrix of size (%d,1)", cdim))
+		return
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	if ! b.SizeMatch(p, 1) {
+		err = errors.New(fmt.Sprintf("'b' must be matrix of size (%d,1)", p))
+		return
+	}
+	var initvals *FloatMatrixSet
+	if S.warm && cdim > 0 {
+		if err = centerStart(S.s, S.z, S.dims); err == nil {
+			initvals = (&StartPoint{X: S.x, S: S.s, Y: S.y, Z: S.z}).InitVals()
+		}
+	}
+	sol, err = coneQp(S.P, q, S.G, h, S.A, b, S.dims, &S.opts, initvals, S.ops)
+	S.warm = false
+	if err != nil || sol == nil {
+		return
+	}
+	if sol.X != nil && sol.S != nil && sol.Y != nil && sol.Z != nil {
+		copy(S.x.FloatArray(), sol.X.FloatArray())
+		copy(S.s.FloatArray(), sol.S.FloatArray())
+		copy(S.y.FloatArray(), sol.Y.FloatArray())
+		copy(S.z.FloatArray(), sol.Z.FloatArray())
+		S.warm = true
+	}
+	return
+}
+
+// Adds a times the identity element e of the cone to x.
+func addIdentity(x *matrix.FloatMatrix, dims *DimensionSet, a float64) {
+	xa := x.FloatArray()
+	ind := dims.Sum("l")
+	for k := 0; k < ind; k++ {
+		xa[k] += a
+	}
+	for _, m := range dims.At("q") {
+		xa[ind] += a
+		ind += m
+	}
+	for _, m := range dims.At("s") {
+		for k := 0; k < m; k++ {
+			xa[ind+k*(m+1)] += a
+		}
+		ind += m*m
+	}
+}
+
+// Centering step of the warm start. Shifts s and z along the identity of
+// the cone so that their smallest eigenvalues are at least
+// WARMSTART_CENTERING*sqrt(mu), mu = s'*z / (number of cone eigenvalues).
+func centerStart(s, z *matrix.FloatMatrix, dims *DimensionSet) error {
+	degree := dims.Sum("l", "s") + len(dims.At("q"))
+	mu := math.Max(Sdot(s, z, dims, 0)/float64(degree), 0.0)
+	delta := WARMSTART_CENTERING * math.Max(math.Sqrt(mu), 1e-8)
+	for _, v := range []*matrix.FloatMatrix{s, z} {
+		t, err := MaxStep(v, dims, 0, nil)
+		if err != nil {
+			return err
+		}
+		// smallest eigenvalue of v is -t
+		if -t < delta {
+			addIdentity(v, dims, t+delta)
+		}
+	}
+	return nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,99 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"testing"
+)
+
+// The cone QP of examples/testconeqp.go: least squares ||A*x - b||^2 with
+// x >= 0 and ||x||_2 <= 1.
+func makeConeQp() (P, q, G, h *matrix.FloatMatrix, dims *DimensionSet) {
+	A := matrix.FloatMatrixStacked([][]float64{
+		[]float64{0.3, -0.4, -0.2, -0.4, 1.3},
+		[]float64{0.6, 1.2, -1.7, 0.3, -0.3},
+		[]float64{-0.3, 0.0, 0.6, -1.2, -2.0}}, matrix.ColumnOrder)
+	b := matrix.FloatVector([]float64{1.5, 0.0, -1.2, -0.7, 0.0})
+	n := A.Cols()
+	h = matrix.FloatZeros(2*n+1, 1)
+	h.SetIndex(n, 1.0)
+	G, _ = matrix.FloatMatrixCombined(matrix.StackDown, matrix.FloatDiagonal(n, -1.0),
+		matrix.FloatZeros(1, n), matrix.FloatIdentity(n))
+	P = A.Transpose().Times(A)
+	q = A.Transpose().Times(b).Scale(-1.0)
+	dims = DSetNew("l", "q", "s")
+	dims.Set("l", []int{n})
+	dims.Set("q", []int{n + 1})
+	return
+}
+
+// Warm started solves after changes of q, h and b and after UpdateP give
+// the solutions of a fresh ConeQp.
+func TestConeQpSolver(t *testing.T) {
+	P, q, G, h, A, b := makeActiveSetQp()
+	Pc, qc, Gc, hc, dims := makeConeQp()
+
+	q2 := q.Copy()
+	q2.SetIndex(0, -7.0)
+	q2.SetIndex(1, 2.0)
+	h2 := h.Copy()
+	h2.SetIndex(3, 2.5)
+	b2 := matrix.FloatVector([]float64{1.0})
+	qc2 := qc.Copy().Scale(0.5)
+	hc2 := hc.Copy()
+	hc2.SetIndex(5, 0.5)
+
+	type data struct {
+		P, q, h, b *matrix.FloatMatrix
+	}
+	for _, tc := range []struct {
+		name     string
+		G, A     *matrix.FloatMatrix
+		dims     *DimensionSet
+		sequence []data
+	}{
+		{"qp", G, A, nil, []data{{P, q, h, b}, {P, q2, h, b}, {P, q2, h2, b}, {P, q2, h2, b2},
+			{matrix.FloatDiagonal(3, 4.0), q2, h2, b2}}},
+		{"coneqp", Gc, nil, dims, []data{{Pc, qc, hc, nil}, {Pc, qc2, hc, nil}, {Pc, qc2, hc2, nil},
+			{Pc.Copy().Scale(2.0), qc2, hc2, nil}}},
+	} {
+		S, err := NewConeQpSolver(tc.sequence[0].P, tc.G, tc.A, tc.dims, &SolverOptions{MaxIter: 30})
+		if err != nil {
+			t.Fatalf("%s: %s\n", tc.name, err)
+		}
+		for k, d := range tc.sequence {
+			if err = S.UpdateP(d.P); err != nil {
+				t.Fatalf("%s: UpdateP: %s\n", tc.name, err)
+			}
+			sol, err := S.Solve(d.q, d.h, d.b)
+			if err != nil {
+				t.Fatalf("%s[%d]: Solve: %s\n", tc.name, k, err)
+			}
+			ref, err := ConeQp(d.P, d.q, tc.G, d.h, tc.A, d.b, tc.dims, &SolverOptions{MaxIter: 30}, nil)
+			if err != nil {
+				t.Fatalf("%s[%d]: ConeQp: %s\n", tc.name, k, err)
+			}
+			if sol.Status != Optimal {
+				t.Errorf("%s[%d]: status %v\n", tc.name, k, sol.Status)
+			}
+			for _, v := range []struct {
+				name string
+				x, y *matrix.FloatMatrix
+				tol  float64
+			}{{"x", sol.X, ref.X, 1e-6}, {"y", sol.Y, ref.Y, 1e-5}, {"z", sol.Z, ref.Z, 1e-5}} {
+				if e := maxDiff(v.x, v.y); e > v.tol {
+					t.Errorf("%s[%d]: %s differs from ConeQp by %.3e\n", tc.name, k, v.name, e)
+				}
+			}
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,162 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Euclidean projection of x[offset:offset+n] onto the nonnegative orthant.
+func ProjectNonnegative(x *matrix.FloatMatrix, offset, n int) error {
+	if offset < 0 || n < 0 || offset+n > x.NumElements() {
+		return errors.New("ProjectNonnegative: index out of range")
+	}
+	xa := x.FloatArray()[offset : offset+n]
+	for k := range xa {
+		xa[k] = math.Max(xa[k], 0.0)
+	}
+	return nil
+}
+
+// Euclidean projection of x[offset:offset+m] = (t, u) onto the second order
+// cone {(t, u) | ||u||_2 <= t}.
+func ProjectSOC(x *matrix.FloatMatrix, offset, m int) error {
+	if offset < 0 || m < 0 || offset+m > x.NumElements() {
+		return errors.New("ProjectSOC: index out of range")
+	}
+	if m == 0 {
+		return nil
+	}
+	xa := x.FloatArray()[offset : offset+m]
+	t := xa[0]
+	nrm := 0.0
+	for _, v := range xa[1:] {
+		nrm += v * v
+	}
+	nrm = math.Sqrt(nrm)
+	switch {
+	case nrm <= t:
+		// in the cone
+	case nrm <= -t:
+		// in the polar cone
+		for k := range xa {
+			xa[k] = 0.0
+		}
+	default:
+		a := 0.5 * (t + nrm)
+		xa[0] = a
+		for k := 1; k < m; k++ {
+			xa[k] *= a / nrm
+		}
+	}
+	return nil
+}
+
+// Euclidean projection of the m by m symmetric matrix stored in column major
+// order in x[offset:offset+m*m] onto the positive semidefinite cone. Only the
+// lower triangle is referenced; on return the block is fully symmetric.
+func ProjectPSD(x *matrix.FloatMatrix, offset, m int) error {
+	if offset < 0 || m < 0 || offset+m*m > x.NumElements() {
+		return errors.New("ProjectPSD: index out of range")
+	}
+	if m == 0 {
+		return nil
+	}
+	xa := x.FloatArray()[offset : offset+m*m]
+	A := matrix.FloatZeros(m, m)
+	for j := 0; j < m; j++ {
+		for i := j; i < m; i++ {
+			A.SetAt(i, j, xa[j*m+i])
+		}
+	}
+	w := matrix.FloatZeros(m, 1)
+	Z := matrix.FloatZeros(m, m)
+	if err := lapack.SyevrFloat(A, w, Z, 0.0, nil, nil, la.OptJobZValue); err != nil {
+		return err
+	}
+	// X = sum_k max(w_k, 0)*z_k*z_k'
+	for k := range xa {
+		xa[k] = 0.0
+	}
+	za := Z.FloatArray()
+	for k := 0; k < m; k++ {
+		wk := w.GetIndex(k)
+		if wk <= 0.0 {
+			continue
+		}
+		zk := za[k*m : (k+1)*m]
+		for j := 0; j < m; j++ {
+			for i := j; i < m; i++ {
+				xa[j*m+i] += wk * zk[i] * zk[j]
+			}
+		}
+	}
+	for j := 0; j < m; j++ {
+		for i := j + 1; i < m; i++ {
+			xa[i*m+j] = xa[j*m+i]
+		}
+	}
+	return nil
+}
+
+//    Projects x onto the cone
+//
+//        C = R_+^l x Q_{q[0]} x ... x S_+^{s[0]} x ...
+//
+//    described by dims, in the layout of the s and z variables of ConeLp
+//    and ConeQp: the 'l' block first, then the 'q' blocks and the 's'
+//    blocks stored as unpacked m by m matrices in column major order.
+//    The projection is Euclidean, blockwise and in place. Only the lower
+//    triangles of the 's' blocks are referenced.
+//
+func ProjectCone(x *matrix.FloatMatrix, dims *DimensionSet) error {
+	if dims == nil {
+		return ProjectNonnegative(x, 0, x.NumElements())
+	}
+	if cdim := dims.Sum("l", "q") + dims.SumSquared("s"); x.NumElements() != cdim {
+		return errors.New(fmt.Sprintf("'x' must have %d elements", cdim))
+	}
+	ind := dims.Sum("l")
+	if err := ProjectNonnegative(x, 0, ind); err != nil {
+		return err
+	}
+	for _, m := range dims.At("q") {
+		if err := ProjectSOC(x, ind, m); err != nil {
+			return err
+		}
+		ind += m
+	}
+	for _, m := range dims.At("s") {
+		if err := ProjectPSD(x, ind, m); err != nil {
+			return err
+		}
+		ind += m * m
+	}
+	return nil
+}
+
+//    Projects x onto the dual cone C* = {z | z'*x >= 0 for all x in C} of
+//    the cone described by dims. The nonnegative orthant, the second order
+//    cone and the PSD cone with the trace inner product are self-dual, so
+//    C* = C and this is ProjectCone. It is provided for methods written in
+//    terms of the dual cone.
+//
+//    By the Moreau decomposition x = P_C(x) - P_C*(-x), the projection onto
+//    the polar cone -C* is x - P_C(x).
+//
+func ProjectDualCone(z *matrix.FloatMatrix, dims *DimensionSet) error {
+	return ProjectCone(z, dims)
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
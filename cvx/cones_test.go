@@ -0,0 +1,97 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestProjectKnown(t *testing.T) {
+	x := matrix.FloatVector([]float64{-1.0, 2.0})
+	ProjectNonnegative(x, 0, 2)
+	if e := maxDiff(x, matrix.FloatVector([]float64{0.0, 2.0})); e > 0.0 {
+		t.Errorf("l: %v\n", x.FloatArray())
+	}
+	// (0, 3, 4) projects to (5/2)*(1, 3/5, 4/5); (-5, 3, 4) is in the polar cone
+	x = matrix.FloatVector([]float64{0.0, 3.0, 4.0, -5.0, 3.0, 4.0})
+	ProjectSOC(x, 0, 3)
+	ProjectSOC(x, 3, 3)
+	if e := maxDiff(x, matrix.FloatVector([]float64{2.5, 1.5, 2.0, 0.0, 0.0, 0.0})); e > 1e-14 {
+		t.Errorf("q: %v\n", x.FloatArray())
+	}
+	// [1 2; 2 1] has eigenvalues 3 and -1, the projection is (3/2)*[1 1; 1 1]
+	x = matrix.FloatVector([]float64{1.0, 2.0, 0.0, 1.0})
+	if err := ProjectPSD(x, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if e := maxDiff(x, matrix.FloatVector([]float64{1.5, 1.5, 1.5, 1.5})); e > 1e-14 {
+		t.Errorf("s: %v\n", x.FloatArray())
+	}
+}
+
+// For random x and the cone C of makeDSet: P_C(x) is in C, P_C(P_C(x)) =
+// P_C(x), and the Moreau decomposition x = P_C(x) - P_C*(-x) holds with
+// P_C(x) orthogonal to P_C*(-x).
+func TestProjectCone(t *testing.T) {
+	dims := makeDSet()
+	cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+	rnd := rand.New(rand.NewSource(7))
+	for trial := 0; trial < 20; trial++ {
+		x := matrix.FloatZeros(cdim, 1)
+		for k := 0; k < cdim; k++ {
+			x.SetIndex(k, rnd.NormFloat64())
+		}
+		// symmetric 's' blocks
+		ind := dims.Sum("l", "q")
+		for _, m := range dims.At("s") {
+			Symm(x, m, ind)
+			ind += m * m
+		}
+
+		p := x.Copy()
+		if err := ProjectCone(p, dims); err != nil {
+			t.Fatal(err)
+		}
+		if v := coneViolation(p, dims); v > 1e-12 {
+			t.Errorf("trial %d: P_C(x) outside the cone by %.3e\n", trial, v)
+		}
+		pp := p.Copy()
+		ProjectCone(pp, dims)
+		if e := maxDiff(pp, p); e > 1e-12 {
+			t.Errorf("trial %d: not idempotent, %.3e\n", trial, e)
+		}
+
+		r := x.Copy()
+		r.Scale(-1.0)
+		if err := ProjectDualCone(r, dims); err != nil {
+			t.Fatal(err)
+		}
+		// x = p - r
+		d := 0.0
+		xa, pa, ra := x.FloatArray(), p.FloatArray(), r.FloatArray()
+		for k := range xa {
+			d = math.Max(d, math.Abs(xa[k]-pa[k]+ra[k]))
+		}
+		if d > 1e-12 {
+			t.Errorf("trial %d: x - P_C(x) + P_C*(-x) = %.3e\n", trial, d)
+		}
+		if ip := vecDot(p, r); math.Abs(ip) > 1e-12 {
+			t.Errorf("trial %d: <P_C(x), P_C*(-x)> = %.3e\n", trial, ip)
+		}
+	}
+
+	if err := ProjectCone(matrix.FloatZeros(cdim-1, 1), dims); err == nil {
+		t.Errorf("size mismatch accepted\n")
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -15,6 +15,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"time"
 )
 
 // ConvexProg is an interface that handles the following functions.
@@ -90,14 +91,18 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 
 	var refinement int
 
-	sol = &Solution{Unknown,
-		nil, nil, nil, nil, nil, 
-		0.0, 0.0, 0.0, 0.0, 0.0,
-		0.0, 0.0, 0.0, 0.0, 0.0, 0}
+	sol = &Solution{Status: Unknown}
+	stats := &sol.Stats
+	start := time.Now()
+	defer addSince(&stats.TotalTime, start)
 
 	feasTolerance := FEASTOL
 	absTolerance := ABSTOL
 	relTolerance := RELTOL
+	maxIter := MAXITERS
+	if solopts.MaxIter > 0 {
+		maxIter = solopts.MaxIter
+	}
 	if solopts.FeasTol > 0.0 {
 		feasTolerance = solopts.FeasTol
 	}
@@ -107,6 +112,8 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 	if solopts.RelTol > 0.0 {
 		relTolerance = solopts.RelTol
 	}
+	// goroutines for the per-cone work of the scaling and cone operations
+	workers := &la.IOpt{"workers", solopts.Workers}
 	if solopts.Refinement > 0 {
 		refinement = solopts.Refinement
 	} else {
@@ -153,6 +160,8 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 	if err = checkConeLpDimensions(dims); err != nil {
 		return 
 	}
+	// workspace of the steps to the boundary and the products in S
+	cw := newConeWork(dims, mnl, solopts.Workers)
 
 	cdim := dims.Sum("l", "q") + dims.SumSquared("s")
 	cdim_diag := dims.Sum("l", "q", "s")
@@ -173,7 +182,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 	}
 
 	fG := func(x, y *matrix.FloatMatrix, alpha, beta float64, trans la.Option) error{
-		return sgemv(G, x, y, alpha, beta, dims, trans)
+		return Sgemv(G, x, y, alpha, beta, dims, trans)
 	}
 
 
@@ -210,7 +219,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 		// kkt function returns us problem spesific factor function.
 		factor, err = kktfunc(G, dims, A, mnl)
 		// solver is 
-		kktsolver = func(W *FloatMatrixSet, x, z *matrix.FloatMatrix) (kktFunc, error) {
+		kktsolver = func(W *Scaling, x, z *matrix.FloatMatrix) (kktFunc, error) {
 			_, Df, H, err := F.F2(x, z)
 			if err != nil { return nil, err }
 			return factor(W, H, Df)
@@ -219,6 +228,13 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 		err = errors.New(fmt.Sprintf("solver '%s' not known", solvername))
 		return
 	}
+	kktfactor := kktsolver
+	timer := stats.kktTimer()
+	kktsolver = func(W *Scaling, x, z *matrix.FloatMatrix) (kktFunc, error) {
+		t0 := time.Now()
+		f, err := kktfactor(W, x, z)
+		return timer.timed(t0, f, err)
+	}
 
 	//var x, y, z, s *matrix.FloatMatrix
 	//var dx, dy, dz, ds *matrix.FloatMatrix
@@ -284,19 +300,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 	ds0 := matrix.FloatZeros(mnl+cdim, 1)
 	ds20 := matrix.FloatZeros(mnl+cdim, 1)
 	
-	W0 := FloatSetNew("d", "di", "dnl", "dnli", "v", "r", "rti", "beta")
-	W0.Set("dnl", matrix.FloatZeros(mnl, 1))
-	W0.Set("dnli", matrix.FloatZeros(mnl, 1))
-	W0.Set("d", matrix.FloatZeros(dims.At("l")[0], 1))
-	W0.Set("di", matrix.FloatZeros(dims.At("l")[0], 1))
-	W0.Set("beta", matrix.FloatZeros(len(dims.At("q")), 1))
-	for _, n := range dims.At("q") {
-		W0.Append("v", matrix.FloatZeros(n, 1))
-	}
-	for _, n := range dims.At("s") {
-		W0.Append("r", matrix.FloatZeros(n, n))
-		W0.Append("rti", matrix.FloatZeros(n, n))
-	}
+	W0 := NewScaling(dims, mnl)
 	lmbda0 := matrix.FloatZeros(mnl+dims.Sum("l", "q", "s"), 1)
 	lmbdasq0 := matrix.FloatZeros(mnl+dims.Sum("l", "q", "s"), 1)
 
@@ -309,7 +313,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 	var resx0, /*resy0, reszl0,*/ resznl0, /*pcost0, dcost0,*/ dres0, pres0 float64
 	var dsdz, dsdz0, step, step0, dphi, dphi0, sigma0, /*mu0,*/ eta0 float64
 	var newresx, newresznl, newgap, newphi float64
-	var W *FloatMatrixSet
+	var W *Scaling
 	var f3 kktFunc
 	
 	// Declare fDf and fH here, they bind to Df and H as they are already declared.
@@ -322,7 +326,8 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 	}
 
 	relaxed_iters := 0
-	for iters := 0; iters <= solopts.MaxIter+1; iters++ {
+	addSince(&stats.SetupTime, start)
+	for iters := 0; iters <= maxIter+1; iters++ {
 
 		if refinement != 0 || solopts.Debug {
 			f, Df, H, err = F.F2(x, matrix.FloatVector(z.FloatArray()[:mnl]))
@@ -346,7 +351,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 			}
 		}
 
-		gap = sdot(s, z, dims, mnl)
+		gap = Sdot(s, z, dims, mnl)
 
 		// these are helpers, copies of parts of z,s
 		z_mnl := matrix.FloatVector(z.FloatArray()[:mnl])
@@ -370,7 +375,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
         blas.Copy(s_mnl2, rzl)
         blas.AxpyFloat(h, rzl, -1.0)
         fG(x, rzl, 1.0, 1.0, la.OptNoTrans)
-        reszl = snrm2(rzl, dims, 0)
+        reszl = Snrm2(rzl, dims, 0)
 
 		//fmt.Printf("%d: resx=%.9f, resznl=%.9f, reszl=%.9f\n", iters, resx, resznl, reszl)
 
@@ -382,7 +387,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
         //       = c'*x + y'*ry + znl'*rznl + zl'*rzl - gap
 		pcost = blas.DotFloat(c, x)
 		dcost = pcost + blas.DotFloat(y, ry) + blas.DotFloat(z_mnl, rznl)
-		dcost += sdot(z_mnl2, rzl, dims, 0) - gap
+		dcost += Sdot(z_mnl2, rzl, dims, 0) - gap
 		
 		if pcost < 0.0 {
 			relgap = gap / -pcost
@@ -420,9 +425,9 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 		// Stopping criteria
 		if ( pres <= feasTolerance && dres <= feasTolerance &&
 			( gap <= absTolerance || (!math.IsNaN(relgap) && relgap <= relTolerance))) ||
-			iters == solopts.MaxIter {
+			iters == maxIter {
 
-			if iters == solopts.MaxIter {
+			if iters == maxIter {
 				s := "Terminated (maximum number of iterations reached)"
 				if solopts.ShowProgress {
 					fmt.Printf(s + "\n")
@@ -457,9 +462,11 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
         //
         // lmbdasq = lambda o lambda 
         if iters == 0 {
-            W, _ = computeScaling(s, z, lmbda, dims, mnl)
+            t0 := time.Now()
+            W, _ = ComputeScaling(s, z, lmbda, dims, mnl, workers)
+            addSince(&stats.ScalingTime, t0)
 		}
-        ssqr(lmbdasq, lmbda, dims, mnl)
+        Ssqr(lmbdasq, lmbda, dims, mnl)
 
         // f3(x, y, z) solves
         //
@@ -482,18 +489,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 				// the last saved state and require a standard line search. 
 				phi, gap = phi0, gap0
 				mu = gap / float64(mnl + dims.Sum("l", "s") + len(dims.At("q")))
-				blas.Copy(W0.At("dnl")[0],  W.At("dnl")[0])
-				blas.Copy(W0.At("dnli")[0], W.At("dnli")[0])
-				blas.Copy(W0.At("d")[0],    W.At("d")[0])
-				blas.Copy(W0.At("di")[0],   W.At("di")[0])
-				blas.Copy(W0.At("beta")[0], W.At("beta")[0])
-				for k, _ := range dims.At("q") {
-					blas.Copy(W0.At("v")[k], W.At("v")[k])
-				}
-				for k, _ := range dims.At("s") {
-					blas.Copy(W0.At("r")[k], W.At("r")[k])
-					blas.Copy(W0.At("rti")[k], W.At("rti")[k])
-				}
+				W0.CopyTo(W)
 				blas.Copy(x0, x)
 				blas.Copy(y0, y)
 				blas.Copy(s0, s)
@@ -528,12 +524,12 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 				sl := matrix.FloatVector(s.FloatArray()[mnl:])
 				ind := dims.Sum("l", "q")
 				for _, m := range dims.At("s") {
-					symm(sl, m, ind)
-					symm(zl, m, ind)
+					Symm(sl, m, ind)
+					Symm(zl, m, ind)
 					ind += m*m
 				}
-				ts, _ = maxStep(s, dims, mnl, nil)
-				tz, _ = maxStep(z, dims, mnl, nil)
+				ts, _ = cw.maxStep(s, nil)
+				tz, _ = cw.maxStep(z, nil)
 
 				err = errors.New(msg)
 				sol.Status = Unknown
@@ -584,12 +580,12 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 			err = nil
             // s := lmbda o\ s 
             //    = lmbda o\ bs
-            sinv(s, lmbda, dims, mnl)
+            Sinv(s, lmbda, dims, mnl)
 
             // z := z - W'*s 
             //    = bz - W' * (lambda o\ bs)
             blas.Copy(s, ws3)
-            scale(ws3, W, true, false)
+            Scale(ws3, W, true, false)
             blas.AxpyFloat(ws3, z, -1.0)
 
             // Solve for ux, uy, uz
@@ -620,7 +616,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
             fH(ux, vx, -1.0, 1.0)
             fA(uy, vx, -1.0, 1.0, la.OptTrans) 
             blas.Copy(uz, wz3)
-            scale(wz3, W, false, true)
+            Scale(wz3, W, false, true)
 			wz3_nl := matrix.FloatVector(wz3.FloatArray()[:mnl])
 			wz3_l := matrix.FloatVector(wz3.FloatArray()[mnl:])
             fDf(wz3_nl, vx, -1.0, 1.0, la.OptTrans)
@@ -635,13 +631,13 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
             fG(ux, wz2l, 1.0, 0.0, la.OptNoTrans)
             blas.AxpyFloat(wz2l, vz, -1.0, &la.IOpt{"offsety", mnl})
             blas.Copy(us, ws3) 
-            scale(ws3, W, true, false)
+            Scale(ws3, W, true, false)
             blas.AxpyFloat(ws3, vz, -1.0)
 
             // vs -= lmbda o (uz + us)
             blas.Copy(us, ws3)
             blas.AxpyFloat(uz, ws3, 1.0)
-            sprod(ws3, lmbda, dims, mnl, &la.SOpt{"diag", "D"})
+            cw.sprod(ws3, lmbda, true)
             blas.AxpyFloat(ws3, vs, -1.0)
 			return 
 		}
@@ -679,6 +675,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 				blas.Copy(ws, ws2)
 				res(x, y, z, s, wx2, wy2, wz2, ws2)
 				err = f4_no_ir(wx2, wy2, wz2, ws2)
+				stats.Refinement++
 				blas.AxpyFloat(wx2, x, 1.0)
 				blas.AxpyFloat(wy2, y, 1.0)
 				blas.AxpyFloat(wz2, z, 1.0)
@@ -749,12 +746,12 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 				sl := matrix.FloatVector(s.FloatArray()[mnl:])
 				ind := dims.Sum("l", "q")
 				for _, m := range dims.At("s") {
-					symm(sl, m, ind)
-					symm(zl, m, ind)
+					Symm(sl, m, ind)
+					Symm(zl, m, ind)
 					ind += m*m
 				}
-				ts, _ = maxStep(s, dims, mnl, nil)
-				tz, _ = maxStep(z, dims, mnl, nil)
+				ts, _ = cw.maxStep(s, nil)
+				tz, _ = cw.maxStep(z, nil)
 
 				err = errors.New(msg)
 				sol.Status = Unknown
@@ -781,11 +778,11 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 
             // Inner product ds'*dz and unscaled steps are needed in the 
             // line search.
-            dsdz = sdot(ds, dz, dims, mnl)
+            dsdz = Sdot(ds, dz, dims, mnl)
             blas.Copy(dz, dz2)
-            scale(dz2, W, false, true)
+            Scale(dz2, W, false, true)
             blas.Copy(ds, ds2)
-            scale(ds2, W, true, false)
+            Scale(ds2, W, true, false)
 
             // Maximum steps to boundary. 
             // 
@@ -793,10 +790,15 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
             // ds, dz.  The eigenvectors Qs, Qz are stored in ds, dz.
             // The eigenvalues are stored in sigs, sigz.
 
+            t0 := time.Now()
             scale2(lmbda, ds, dims, mnl, false)
-            ts, _ = maxStep(ds, dims, mnl, sigs)
+            if ts, err = cw.maxStep(ds, sigs); err != nil {
+                return
+            }
             scale2(lmbda, dz, dims, mnl, false)
-            tz, _ = maxStep(dz, dims, mnl, sigz)
+            if tz, err = cw.maxStep(dz, sigz); err != nil {
+                return
+            }
             t := maxvec([]float64{0.0, ts, tz})
             if t == 0 {
                 step = 1.0
@@ -917,18 +919,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
                             phi0, dphi0, gap0 = phi, dphi, gap
                             step0 = step
 								
-							blas.Copy(W.At("dnl")[0],  W0.At("dnl")[0])
-							blas.Copy(W.At("dnli")[0], W0.At("dnli")[0])
-							blas.Copy(W.At("d")[0],    W0.At("d")[0])
-							blas.Copy(W.At("di")[0],   W0.At("di")[0])
-							blas.Copy(W.At("beta")[0], W0.At("beta")[0])
-							for k, _ := range dims.At("q") {
-								blas.Copy(W.At("v")[k], W0.At("v")[k])
-							}
-							for k, _ := range dims.At("s") {
-								blas.Copy(W.At("r")[k],   W0.At("r")[k])
-								blas.Copy(W.At("rti")[k], W0.At("rti")[k])
-							}
+							W.CopyTo(W0)
 							blas.Copy(x, x0)
 							blas.Copy(y, y0)
 							blas.Copy(dx, dx0)
@@ -975,18 +966,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
                             // Resume last saved line search 
                             phi, dphi, gap = phi0, dphi0, gap0
                             step = step0
-							blas.Copy(W0.At("dnl")[0],  W.At("dnl")[0])
-							blas.Copy(W0.At("dnli")[0], W.At("dnli")[0])
-							blas.Copy(W0.At("d")[0],    W.At("d")[0])
-							blas.Copy(W0.At("di")[0],   W.At("di")[0])
-							blas.Copy(W0.At("beta")[0], W.At("beta")[0])
-							for k, _ := range dims.At("q") {
-								blas.Copy(W0.At("v")[k], W.At("v")[k])
-							}
-							for k, _ := range dims.At("s") {
-								blas.Copy(W0.At("r")[k],   W.At("r")[k])
-								blas.Copy(W0.At("rti")[k], W.At("rti")[k])
-							}
+							W0.CopyTo(W)
 							blas.Copy(x, x0)
 							blas.Copy(y, y0)
 							blas.Copy(dx, dx0)
@@ -1015,6 +995,12 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 					}
 				}
 			} // end of line search
+			addSince(&stats.StepTime, t0)
+			if i == 0 {
+				stats.AffineStep = step
+			} else {
+				stats.Step = step
+			}
 
 			//fmt.Printf("eol ds=\n%v\n", ds.ToString("%.7f"))
 			//fmt.Printf("eol dz=\n%v\n", dz.ToString("%.7f"))
@@ -1087,7 +1073,9 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 			ind3 += m
 		}
 		
-		err = updateScaling(W, lmbda, ds, dz)
+		t0 := time.Now()
+		err = UpdateScaling(W, lmbda, ds, dz)
+		addSince(&stats.ScalingTime, t0)
 
         // Unscale s, z, tau, kappa (unscaled variables are used only to 
         // compute feasibility residuals).
@@ -1101,7 +1089,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 			ind += m
 			ind2 += m*m
 		}
-		scale(s, W, true, false)
+		Scale(s, W, true, false)
 		
 		ind = mnl + dims.Sum("l", "q")
 		ind2 = ind
@@ -1113,7 +1101,7 @@ func Cpl(F ConvexProg, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, so
 			ind += m
 			ind2 += m*m
 		}
-		scale(z, W, false, true)
+		Scale(z, W, false, true)
 
 		gap = blas.DotFloat(lmbda, lmbda)
 
@@ -0,0 +1,183 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+//    Computes an optimal basic solution of
+//
+//        minimize    c'*x
+//        subject to  G*x + s = h
+//                    A*x = b
+//                    s >= 0
+//
+//    from an optimal solution sol returned by Lp.
+//
+//    Crossover starts from the basis of the slack and logical variables with
+//    the variables x fixed at their interior point values.
+//
+//      - Primal push: each x_j is moved towards zero keeping the other
+//        variables feasible. It either reaches zero and stays nonbasic or a
+//        basic variable reaches its bound and x_j replaces it in the basis.
+//
+//      - Dual push: basic slacks of active inequalities (s_i <= tol*(1+|h_i|)
+//        with z_i > tol) and the basic logicals of the equality rows are
+//        pivoted out of the basis with the dual ratio test.
+//
+//    The basis is then cleaned up with the primal simplex method, which
+//    normally takes only a few iterations. Arguments are as for Simplex; if
+//    tol is not positive SENSITIVITY_TOL is used.
+//
+//    Returns the basic solution and the status of each variable and
+//    constraint in the final basis. The basis can be used to warm start
+//    Simplex.
+//
+func Crossover(c, G, h, A, b *matrix.FloatMatrix, sol *Solution, solopts *SolverOptions, tol float64) (vsol *Solution, basis *Basis, err error) {
+
+	if sol == nil || sol.Status != Optimal || sol.X == nil {
+		err = errors.New("crossover requires an optimal solution")
+		return
+	}
+	if c == nil || c.Cols() != 1 {
+		err = errors.New("'c' must a column matrix")
+		return
+	}
+	n := c.Rows()
+	if G == nil {
+		G = matrix.FloatZeros(0, n)
+	}
+	if h == nil {
+		h = matrix.FloatZeros(0, 1)
+	}
+	m := G.Rows()
+	if G.Cols() != n || !h.SizeMatch(m, 1) {
+		err = errors.New(fmt.Sprintf("'G' must have %d columns and 'h' size (%d,1)", n, m))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	p := A.Rows()
+	if A.Cols() != n || !b.SizeMatch(p, 1) {
+		err = errors.New(fmt.Sprintf("'A' must have %d columns and 'b' size (%d,1)", n, p))
+		return
+	}
+	if !sol.X.SizeMatch(n, 1) || sol.Z == nil || !sol.Z.SizeMatch(m, 1) {
+		err = errors.New("solution does not match problem dimensions")
+		return
+	}
+	if solopts == nil {
+		solopts = &SolverOptions{}
+	}
+	if tol <= 0.0 {
+		tol = SENSITIVITY_TOL
+	}
+
+	lp := newSimplexLp(c, G, h, A, b)
+	lp.maxiter = SIMPLEX_MAXITERS
+	if solopts.MaxIter > 0 {
+		lp.maxiter = solopts.MaxIter
+	}
+	lp.show = solopts.ShowProgress
+
+	lp.slackBasis()
+	lp.super = make([]bool, lp.cols)
+	for j := 0; j < n; j++ {
+		lp.val[j] = sol.X.GetIndex(j)
+		lp.super[j] = lp.val[j] != 0.0
+	}
+	if err = lp.refactor(); err != nil {
+		return
+	}
+	if err = lp.computeBasic(); err != nil {
+		return
+	}
+
+	// primal push
+	alpha := make([]float64, lp.rows)
+	for j := 0; j < n; j++ {
+		if !lp.super[j] {
+			continue
+		}
+		lp.column(j, alpha)
+		if err = lp.ftran(alpha); err != nil {
+			return
+		}
+		dir := -math.Copysign(1.0, lp.val[j])
+		t, r, bound := lp.ratioTest(alpha, dir, math.Abs(lp.val[j]))
+		lp.val[j] += dir * t
+		for k, jb := range lp.head {
+			lp.val[jb] -= t * dir * alpha[k]
+		}
+		lp.super[j] = false
+		lp.iter++
+		if r < 0 {
+			lp.val[j] = 0.0
+			continue
+		}
+		leave := lp.head[r]
+		lp.val[leave] = bound
+		if bound == lp.lo[leave] {
+			lp.status[leave] = AtLower
+		} else {
+			lp.status[leave] = AtUpper
+		}
+		if err = lp.pivot(r, j, alpha); err != nil {
+			return
+		}
+	}
+	lp.super = nil
+
+	// dual push
+	for k := 0; k < lp.rows; k++ {
+		j := lp.head[k]
+		if j < n {
+			continue
+		}
+		if i := j - n; i < m {
+			if lp.val[j] > tol*(1.0+math.Abs(h.GetIndex(i))) || sol.Z.GetIndex(i) <= tol {
+				continue
+			}
+		}
+		// head[k] stays at position k after the pivot
+		if _, err = lp.dualStep(k, lp.lo[j]); err != nil {
+			return
+		}
+		lp.iter++
+	}
+
+	// clean up
+	if err = lp.computeBasic(); err != nil {
+		return
+	}
+	status, err := lp.primal()
+	if status == Optimal {
+		err = lp.pivotFree()
+	}
+	var serr error
+	vsol, serr = lp.solution(status)
+	basis = lp.basis()
+	if err == nil {
+		err = serr
+	}
+	if err == nil && status != Optimal {
+		err = errors.New("crossover did not find an optimal basis")
+	}
+	return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,89 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"testing"
+)
+
+// Checks that the nonbasic variables and slacks of basis define the point x
+// of the two variable problem G*x <= h.
+func checkVertex(t *testing.T, name string, G, h, x *matrix.FloatMatrix, basis *Basis) {
+	rows := make([][]float64, 0)
+	rhs := make([]float64, 0)
+	for j, st := range basis.Variables {
+		if st != Basic {
+			r := []float64{0.0, 0.0}
+			r[j] = 1.0
+			rows = append(rows, r)
+			rhs = append(rhs, 0.0)
+		}
+	}
+	for i, st := range basis.Inequalities {
+		if st != Basic {
+			rows = append(rows, []float64{G.GetAt(i, 0), G.GetAt(i, 1)})
+			rhs = append(rhs, h.GetIndex(i))
+		}
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%s: %d nonbasic variables for 2 unknowns\n", name, len(rows))
+	}
+	det := rows[0][0]*rows[1][1] - rows[0][1]*rows[1][0]
+	if math.Abs(det) < 1e-12 {
+		t.Fatalf("%s: singular basis\n", name)
+	}
+	x0 := (rhs[0]*rows[1][1] - rows[0][1]*rhs[1]) / det
+	x1 := (rows[0][0]*rhs[1] - rhs[0]*rows[1][0]) / det
+	if e := maxDiff(x, matrix.FloatVector([]float64{x0, x1})); e > 1e-10 {
+		t.Errorf("%s: x = %v, vertex of the basis (%v, %v)\n", name, x.FloatArray(), x0, x1)
+	}
+}
+
+func TestCrossover(t *testing.T) {
+	c, G, h := makeTestLp()
+	// minimize -x0 - x1 subject to x0 + x1 <= 1, x >= 0 has an optimal
+	// edge and the interior point solution is its midpoint.
+	c2 := matrix.FloatVector([]float64{-1.0, -1.0})
+	G2 := matrix.FloatMatrixStacked([][]float64{
+		[]float64{1.0, 1.0},
+		[]float64{-1.0, 0.0},
+		[]float64{0.0, -1.0}}, matrix.RowOrder)
+	h2 := matrix.FloatVector([]float64{1.0, 0.0, 0.0})
+
+	for _, tc := range []struct {
+		name    string
+		c, G, h *matrix.FloatMatrix
+	}{{"testlp", c, G, h}, {"edge", c2, G2, h2}} {
+		sol, err := Lp(tc.c, tc.G, tc.h, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: Lp: %s\n", tc.name, err)
+		}
+		vsol, basis, err := Crossover(tc.c, tc.G, tc.h, nil, nil, sol, nil, 0.0)
+		if err != nil {
+			t.Fatalf("%s: Crossover: %s\n", tc.name, err)
+		}
+		if vsol.Status != Optimal {
+			t.Fatalf("%s: status %v\n", tc.name, vsol.Status)
+		}
+		if math.Abs(vsol.PrimalObjective-sol.PrimalObjective) > 1e-6 {
+			t.Errorf("%s: objective %v, interior point %v\n", tc.name, vsol.PrimalObjective, sol.PrimalObjective)
+		}
+		checkVertex(t, tc.name, tc.G, tc.h, vsol.X, basis)
+		// nonbasic slacks are zero
+		for i, st := range basis.Inequalities {
+			if st != Basic && math.Abs(vsol.S.GetIndex(i)) > 1e-10 {
+				t.Errorf("%s: nonbasic slack %d = %v\n", tc.name, i, vsol.S.GetIndex(i))
+			}
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
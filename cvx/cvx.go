@@ -9,6 +9,7 @@
 package cvx
 
 import (
+	la "github.com/hrautila/go.opt/linalg"
 	"github.com/hrautila/go.opt/matrix"
 	"errors"
 )
@@ -17,12 +18,22 @@ import (
 type kktFunc func(x, y, z *matrix.FloatMatrix) error
 
 // kktFactor produces solver function
-type kktFactor func(*FloatMatrixSet, *matrix.FloatMatrix, *matrix.FloatMatrix)(kktFunc, error)
+type kktFactor func(*Scaling, *matrix.FloatMatrix, *matrix.FloatMatrix)(kktFunc, error)
 
 // kktSolver creates problem spesific factor
 type kktSolver func(*matrix.FloatMatrix, *DimensionSet, *matrix.FloatMatrix, int) (kktFactor, error)
 
-func kktNullFactor(W *FloatMatrixSet, H, Df *matrix.FloatMatrix) (kktFunc, error) {
+// Matrix-free operator y := alpha*M*x + beta*y, or y := alpha*M'*x + beta*y
+// with the option trans.
+type mvFunc func(x, y *matrix.FloatMatrix, alpha, beta float64, opts ...la.Option) error
+
+// Problem specific operator G and KKT solver of coneLp and coneQp.
+type coneOps struct {
+	G   mvFunc
+	kkt kktFactor
+}
+
+func kktNullFactor(W *Scaling, H, Df *matrix.FloatMatrix) (kktFunc, error) {
 	nullsolver := func(x, y, z *matrix.FloatMatrix) error {
 		return errors.New("Null KTT Solver does not solve anything.")
 	}
@@ -40,7 +51,8 @@ var solvers solverMap = solverMap{
 	"ldl2": kktLdl,
 	"qr": kktLdl,
 	"chol": kktLdl,
-	"chol2": kktLdl}
+	"chol2": kktLdl,
+	"cg": kktCg}
 
 
 type StatusCode int
@@ -66,20 +78,66 @@ type Solution struct {
 	DualInfeasibility float64
 	PrimalSlack float64
 	DualSlack float64
+	// Residuals of the infeasibility certificates; see Certificate.
 	PrimalResidualCert float64
 	DualResidualCert float64
 	Iterations int
+	// Certificate of primal or dual infeasibility if Status is
+	// PrimalInfeasible or DualInfeasible, nil otherwise.
+	Certificate *Certificate
+	// Certificate of infeasibility of QpAdmm, which sets it in place of
+	// Certificate; nil otherwise.
+	AdmmCertificate *AdmmCertificate
+	// Dual variables of the variable bounds and of the ranged rows of
+	// LpBounded and QpBounded, zero for infinite bounds; nil otherwise.
+	ZLower *matrix.FloatMatrix
+	ZUpper *matrix.FloatMatrix
+	ZRangeLower *matrix.FloatMatrix
+	ZRangeUpper *matrix.FloatMatrix
+	// Statistics of the iterations of ConeLp and ConeQp that solved KKT
+	// systems, in order.
+	IterStats []IterationStats
+	// Wall times and work counts of the solve.
+	Stats SolveStats
 }
 
 type SolverOptions struct {
 	AbsTol float64
 	RelTol float64
 	FeasTol float64
+	// Maximum number of iterations; MAXITERS if zero.
 	MaxIter int
 	ShowProgress bool
 	Debug bool
+	// Fixed number of iterative refinement steps per KKT solve. If zero,
+	// ConeLp refines once when there are 'q' or 's' cones and Cpl always
+	// refines once; ConeQp (and Qp) do not refine. ConeQp used to ignore
+	// a nonzero value; it now applies it like ConeLp.
 	Refinement int
 	KKTSolverName string
+	// Equilibrate G and A by Ruiz scaling before solving (ConeLp, Lp,
+	// Socp and Sdp). The returned solution is in the original scaling.
+	Equilibrate bool
+	// Maximum number of equilibration passes; EQUILIBRATE_ITERS if zero.
+	EquilibrateIter int
+	// Number of goroutines for the per-cone work on the 'q' and 's' blocks
+	// in ComputeScaling, UpdateScaling, Scale, Sprod and MaxStep. Zero or
+	// one is sequential; the iterates do not depend on the value.
+	Workers int
+	// Refine the KKT solutions adaptively: correct until the relative
+	// residual is below RefinementTol or stops decreasing, at most
+	// MaxRefinement steps per solve (ConeLp and ConeQp). Replaces the
+	// fixed number of steps of Refinement.
+	AdaptiveRefinement bool
+	// REFINEMENT_TOL and MAX_REFINEMENT if zero.
+	RefinementTol float64
+	MaxRefinement int
+	// Preconditioner of KKT solver "cg": "diag" (default) or "ichol",
+	// and the maximum number of CG iterations per solve, CG_MAXITER if
+	// zero. A solve stopped at CGMaxIter is counted in
+	// IterationStats.CGUnconverged.
+	CGPreconditioner string
+	CGMaxIter int
 }
 
 const (
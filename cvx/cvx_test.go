@@ -9,7 +9,6 @@
 package cvx
 
 import (
-	"github.com/hrautila/go.opt/matrix"
 	"fmt"
 	"testing"
 )
@@ -29,55 +28,24 @@ func makeDSet() *DimensionSet {
 	return dims
 }
 
-func makeMatrixSet(dims *DimensionSet) *FloatMatrixSet {
-	W := FloatSetNew("d", "di", "v", "beta", "r", "rti")
-	dd := dims.At("l")[0]
-	W.Set("d", matrix.FloatOnes(dd, 1))
-	W.Set("di", matrix.FloatOnes(dd, 1))
-	dq := len(dims.At("q"))
-	W.Set("beta", matrix.FloatOnes(dq, 1))
-
-	for _, n := range dims.At("q")  {
-		vm := matrix.FloatZeros(n, 1)
-		vm.SetIndex(0, 1.0)
-		W.Append("v", vm)
-	}
-	for _, n := range dims.At("s") {
-		W.Append("r", matrix.FloatIdentity(n))
-		W.Append("rti", matrix.FloatIdentity(n))
-	}
-	return W
-}
-
 func TestDSet(t *testing.T) {
 	dims := makeDSet()
 	cdim, cdim_packd, cdim_diag := calcDims(dims)
 	fmt.Printf("cdim = %d\ncdim_packd = %d\ncdim_diag = %d\n", cdim, cdim_packd, cdim_diag)
 }
 
-func TestMatrixSet(t *testing.T) {
+func TestScaling(t *testing.T) {
 	dims := makeDSet()
-	W := makeMatrixSet(dims)
-	for k, m := range W.At("d") {
-		fmt.Printf("d[%d]:\n%v\n", k, m)
+	W := NewScaling(dims, 0)
+	W2 := W.Copy()
+	W2.Beta[0] = 2.0
+	if W.Beta[0] != 1.0 {
+		t.Errorf("Copy shares Beta with the original\n")
 	}
-	for k, m := range W.At("di") {
-		fmt.Printf("di[%d]:\n%v\n", k, m)
+	W2.CopyTo(W)
+	if W.Beta[0] != 2.0 {
+		t.Errorf("CopyTo did not copy Beta\n")
 	}
-	for k, m := range W.At("beta") {
-		fmt.Printf("beta[%d]:\n%v\n", k, m)
-	}
-	for k, m := range W.At("v") {
-		fmt.Printf("v[%d]:\n%v\n", k, m)
-	}
-	for k, m := range W.At("r") {
-		fmt.Printf("r[%d]:\n%v\n", k, m)
-	}
-	for k, m := range W.At("rti") {
-		fmt.Printf("rti[%d]:\n%v\n", k, m)
-	}
-
-		
 }
 
 func TestCompile(t *testing.T) {
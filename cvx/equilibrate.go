@@ -0,0 +1,287 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+)
+
+const (
+	// Default number of Ruiz equilibration passes.
+	EQUILIBRATE_ITERS = 25
+	// Equilibration stops when all row and column norms are within this
+	// distance of one.
+	EQUILIBRATE_TOL = 1e-3
+)
+
+// equilibration holds the diagonal scalings of a cone program
+//
+//     Gs = diag(e) * G * diag(d),  As = diag(f) * A * diag(d)
+//
+// The scaling e is constant within each 'q' and 's' block so that the
+// scaled slack diag(e)*s stays in the cone if and only if s does.
+type equilibration struct {
+	d, e, f []float64
+}
+
+// Computes row and column scalings of G and A by Ruiz equilibration.
+// Columns are scaled to unit infinity norm over [G; A]. Rows of A and the
+// rows of the 'l' block of G are scaled individually, 'q' and 's' blocks of
+// G with a single scalar equal to the largest row norm within the block.
+func ruizEquilibrate(G, A *matrix.FloatMatrix, dims *DimensionSet, maxiter int) *equilibration {
+	n := G.Cols()
+	mg := G.Rows()
+	p := A.Rows()
+	eq := &equilibration{ones(n), ones(mg), ones(p)}
+
+	// block boundaries of the rows of G; each 'l' row is its own block.
+	blocks := make([]int, 0, dims.At("l")[0]+len(dims.At("q"))+len(dims.At("s"))+1)
+	for k := 0; k <= dims.At("l")[0]; k++ {
+		blocks = append(blocks, k)
+	}
+	ind := dims.At("l")[0]
+	for _, m := range dims.At("q") {
+		ind += m
+		blocks = append(blocks, ind)
+	}
+	for _, m := range dims.At("s") {
+		ind += m*m
+		blocks = append(blocks, ind)
+	}
+
+	Gs := G.Copy()
+	As := A.Copy()
+	ga := Gs.FloatArray()
+	aa := As.FloatArray()
+	cnrm := make([]float64, n)
+	gnrm := make([]float64, mg)
+	anrm := make([]float64, p)
+
+	for iter := 0; iter < maxiter; iter++ {
+		for i := range gnrm {
+			gnrm[i] = 0.0
+		}
+		for i := range anrm {
+			anrm[i] = 0.0
+		}
+		for j := 0; j < n; j++ {
+			cnrm[j] = 0.0
+			for i := 0; i < mg; i++ {
+				v := math.Abs(ga[j*mg+i])
+				cnrm[j] = math.Max(cnrm[j], v)
+				gnrm[i] = math.Max(gnrm[i], v)
+			}
+			for i := 0; i < p; i++ {
+				v := math.Abs(aa[j*p+i])
+				cnrm[j] = math.Max(cnrm[j], v)
+				anrm[i] = math.Max(anrm[i], v)
+			}
+		}
+		// uniform row norm within cone blocks
+		for k := 0; k < len(blocks)-1; k++ {
+			bmax := 0.0
+			for i := blocks[k]; i < blocks[k+1]; i++ {
+				bmax = math.Max(bmax, gnrm[i])
+			}
+			for i := blocks[k]; i < blocks[k+1]; i++ {
+				gnrm[i] = bmax
+			}
+		}
+
+		dev := 0.0
+		for _, v := range cnrm {
+			if v > 0.0 {
+				dev = math.Max(dev, math.Abs(1.0-v))
+			}
+		}
+		for _, v := range gnrm {
+			if v > 0.0 {
+				dev = math.Max(dev, math.Abs(1.0-v))
+			}
+		}
+		for _, v := range anrm {
+			if v > 0.0 {
+				dev = math.Max(dev, math.Abs(1.0-v))
+			}
+		}
+		if dev <= EQUILIBRATE_TOL {
+			break
+		}
+
+		for j := 0; j < n; j++ {
+			cnrm[j] = ruizFactor(cnrm[j])
+			eq.d[j] *= cnrm[j]
+		}
+		for i := 0; i < mg; i++ {
+			gnrm[i] = ruizFactor(gnrm[i])
+			eq.e[i] *= gnrm[i]
+		}
+		for i := 0; i < p; i++ {
+			anrm[i] = ruizFactor(anrm[i])
+			eq.f[i] *= anrm[i]
+		}
+		for j := 0; j < n; j++ {
+			for i := 0; i < mg; i++ {
+				ga[j*mg+i] *= gnrm[i] * cnrm[j]
+			}
+			for i := 0; i < p; i++ {
+				aa[j*p+i] *= anrm[i] * cnrm[j]
+			}
+		}
+	}
+	return eq
+}
+
+// Scaling factor 1/sqrt(nrm) for a row or column with norm nrm. Empty
+// rows and columns are not scaled.
+func ruizFactor(nrm float64) float64 {
+	if nrm == 0.0 {
+		return 1.0
+	}
+	return 1.0 / math.Sqrt(nrm)
+}
+
+func ones(n int) []float64 {
+	v := make([]float64, n)
+	for i := range v {
+		v[i] = 1.0
+	}
+	return v
+}
+
+// Returns diag(r) * M * diag(c) as a new matrix. r or c may be nil.
+func scaleRowsCols(M *matrix.FloatMatrix, r, c []float64) *matrix.FloatMatrix {
+	S := M.Copy()
+	rows, cols := S.Size()
+	sa := S.FloatArray()
+	for j := 0; j < cols; j++ {
+		for i := 0; i < rows; i++ {
+			if r != nil {
+				sa[j*rows+i] *= r[i]
+			}
+			if c != nil {
+				sa[j*rows+i] *= c[j]
+			}
+		}
+	}
+	return S
+}
+
+// Elementwise x := x .* v (inverse is false) or x := x ./ v (inverse is true).
+func scaleVector(x *matrix.FloatMatrix, v []float64, inverse bool) {
+	if x == nil {
+		return
+	}
+	xa := x.FloatArray()
+	for i := range xa {
+		if inverse {
+			xa[i] /= v[i]
+		} else {
+			xa[i] *= v[i]
+		}
+	}
+}
+
+// Solves the cone program of ConeLp after equilibrating G and A. The
+// scaled problem is
+//
+//     minimize    (D*c)'*xs
+//     subject to  E*G*D*xs + ss = E*h
+//                 F*A*D*xs = F*b
+//
+// with x = D*xs, s = E^{-1}*ss, z = E*zs and y = F*ys. Primal and dual
+// objectives are not changed by the scaling; the residuals reported
+// in the solution are those of the scaled problem.
+func coneLpEquilibrated(c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, solopts *SolverOptions, primalstart, dualstart *FloatMatrixSet) (sol *Solution, err error) {
+
+	opts := *solopts
+	opts.Equilibrate = false
+
+	if c == nil || G == nil || h == nil {
+		// let ConeLp report the argument errors
+		return ConeLp(c, G, h, A, b, dims, &opts, primalstart, dualstart)
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, c.Rows())
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	if dims == nil {
+		dims = DSetNew("l", "q", "s")
+		dims.Set("l", []int{h.Rows()})
+	}
+	cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+	if !G.SizeMatch(cdim, c.Rows()) || A.Cols() != c.Rows() || h.Rows() != cdim ||
+		b.Rows() != A.Rows() {
+		return ConeLp(c, G, h, A, b, dims, &opts, primalstart, dualstart)
+	}
+	if err = checkConeLpStart(primalstart, dualstart, c.Rows(), cdim, b.Rows()); err != nil {
+		return
+	}
+
+	maxiter := solopts.EquilibrateIter
+	if maxiter <= 0 {
+		maxiter = EQUILIBRATE_ITERS
+	}
+	eq := ruizEquilibrate(G, A, dims, maxiter)
+
+	Gs := scaleRowsCols(G, eq.e, eq.d)
+	As := scaleRowsCols(A, eq.f, eq.d)
+	cs := c.Copy()
+	scaleVector(cs, eq.d, false)
+	hs := h.Copy()
+	scaleVector(hs, eq.e, false)
+	bs := b.Copy()
+	scaleVector(bs, eq.f, false)
+
+	var pstart, dstart *FloatMatrixSet = nil, nil
+	if primalstart != nil {
+		pstart = FloatSetNew("x", "s")
+		x := primalstart.At("x")[0].Copy()
+		scaleVector(x, eq.d, true)
+		s := primalstart.At("s")[0].Copy()
+		scaleVector(s, eq.e, false)
+		pstart.Set("x", x)
+		pstart.Set("s", s)
+	}
+	if dualstart != nil {
+		dstart = FloatSetNew("y", "z")
+		if firstOf(dualstart, "y") != nil {
+			y := dualstart.At("y")[0].Copy()
+			scaleVector(y, eq.f, true)
+			dstart.Set("y", y)
+		}
+		z := dualstart.At("z")[0].Copy()
+		scaleVector(z, eq.e, true)
+		dstart.Set("z", z)
+	}
+
+	sol, err = ConeLp(cs, Gs, hs, As, bs, dims, &opts, pstart, dstart)
+	if sol == nil {
+		return
+	}
+	// X, S, Y and Z alias the entries of sol.Result; scale in place.
+	scaleVector(sol.X, eq.d, false)
+	scaleVector(sol.S, eq.e, true)
+	scaleVector(sol.Z, eq.e, false)
+	scaleVector(sol.Y, eq.f, false)
+	if cert := sol.Certificate; cert != nil {
+		// certificates scale like the primal and dual variables and
+		// keep their normalization.
+		scaleVector(cert.X, eq.d, false)
+		scaleVector(cert.S, eq.e, true)
+		scaleVector(cert.Z, eq.e, false)
+		scaleVector(cert.Y, eq.f, false)
+	}
+	return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
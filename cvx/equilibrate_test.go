@@ -0,0 +1,122 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"testing"
+)
+
+// The LP of makeTestLp with the equality x0 - x1 = 0.5, rows of G scaled by
+// rg, the row of A by ra and the columns by d. The optimum of the unscaled
+// problem is x = (7/6, 2/3) with the first row of G active; the solution
+// of the scaled problem is x ./ d.
+func makeScaledLp(rg []float64, ra float64, d []float64) (c, G, h, A, b *matrix.FloatMatrix) {
+	c, G, h = makeTestLp()
+	A = matrix.FloatMatrixStacked([][]float64{[]float64{1.0, -1.0}}, matrix.RowOrder)
+	b = matrix.FloatVector([]float64{0.5})
+	for j := 0; j < 2; j++ {
+		c.SetIndex(j, d[j]*c.GetIndex(j))
+		A.SetAt(0, j, ra*d[j]*A.GetAt(0, j))
+		for i := 0; i < G.Rows(); i++ {
+			G.SetAt(i, j, rg[i]*d[j]*G.GetAt(i, j))
+		}
+	}
+	for i := 0; i < G.Rows(); i++ {
+		h.SetIndex(i, rg[i]*h.GetIndex(i))
+	}
+	b.SetIndex(0, ra*b.GetIndex(0))
+	return
+}
+
+// Relative difference max |x - y| / max(1, |y|).
+func relDiff(x, y *matrix.FloatMatrix) float64 {
+	xa, ya := x.FloatArray(), y.FloatArray()
+	if len(xa) != len(ya) {
+		return math.Inf(1)
+	}
+	d := 0.0
+	for k := range xa {
+		d = math.Max(d, math.Abs(xa[k]-ya[k])/math.Max(1.0, math.Abs(ya[k])))
+	}
+	return d
+}
+
+// Coefficients from 1e-6 to 2e6: the equilibrated solve converges to the
+// optimum, the unequilibrated one stalls (does not reach Optimal, or needs
+// more iterations or ends less accurate).
+func TestEquilibrateBadlyScaled(t *testing.T) {
+	d := []float64{1e3, 1e-3}
+	c, G, h, A, b := makeScaledLp([]float64{1e3, 1e-3, 1e3, 1e-3}, 1e-3, d)
+	xopt := matrix.FloatVector([]float64{7.0 / 6.0 / d[0], 2.0 / 3.0 / d[1]})
+
+	solopts := &SolverOptions{MaxIter: 30, Equilibrate: true}
+	sol, err := ConeLp(c, G, h, A, b, nil, solopts, nil, nil)
+	if err != nil {
+		t.Fatalf("equilibrated: %s\n", err)
+	}
+	if sol.Status != Optimal {
+		t.Fatalf("equilibrated: status %v\n", sol.Status)
+	}
+	if e := relDiff(sol.X, xopt); e > 1e-6 {
+		t.Errorf("equilibrated: x = %v, expected %v\n", sol.X.FloatArray(), xopt.FloatArray())
+	}
+
+	solopts.Equilibrate = false
+	plain, _ := ConeLp(c, G, h, A, b, nil, solopts, nil, nil)
+	if plain != nil && plain.Status == Optimal && plain.Iterations <= sol.Iterations &&
+		relDiff(plain.X, xopt) <= 1e-6 {
+		t.Errorf("no stall without equilibration: %d iterations, %d equilibrated\n",
+			plain.Iterations, sol.Iterations)
+	}
+}
+
+// The unscaled X, S, Y and Z of an equilibrated solve are those of the
+// unequilibrated solve, for an LP with an equality and for a cone LP.
+func TestEquilibrateUnscaled(t *testing.T) {
+	c, G, h, A, b := makeScaledLp([]float64{10.0, 0.1, 1.0, 5.0}, 3.0, []float64{0.5, 4.0})
+	cc, Gc, hc, dims := makeConeLp()
+	for _, tc := range []struct {
+		name          string
+		c, G, h, A, b *matrix.FloatMatrix
+		dims          *DimensionSet
+	}{{"lp", c, G, h, A, b, nil}, {"conelp", cc, Gc, hc, nil, nil, dims}} {
+		ref, err := ConeLp(tc.c, tc.G, tc.h, tc.A, tc.b, tc.dims, &SolverOptions{MaxIter: 30}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: %s\n", tc.name, err)
+		}
+		sol, err := ConeLp(tc.c, tc.G, tc.h, tc.A, tc.b, tc.dims, &SolverOptions{MaxIter: 30, Equilibrate: true}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: equilibrated: %s\n", tc.name, err)
+		}
+		if sol.Status != Optimal {
+			t.Fatalf("%s: status %v\n", tc.name, sol.Status)
+		}
+		for _, v := range []struct {
+			name string
+			x, y *matrix.FloatMatrix
+		}{{"x", sol.X, ref.X}, {"s", sol.S, ref.S}, {"y", sol.Y, ref.Y}, {"z", sol.Z, ref.Z}} {
+			if e := relDiff(v.x, v.y); e > 1e-5 {
+				t.Errorf("%s: %s differs by %.3e\n", tc.name, v.name, e)
+			}
+		}
+		if math.Abs(sol.PrimalObjective-ref.PrimalObjective) > 1e-6 {
+			t.Errorf("%s: objective %v, unequilibrated %v\n", tc.name, sol.PrimalObjective, ref.PrimalObjective)
+		}
+	}
+	// the optimum of the LP
+	xopt := matrix.FloatVector([]float64{7.0 / 6.0 / 0.5, 2.0 / 3.0 / 4.0})
+	sol, _ := ConeLp(c, G, h, A, b, nil, &SolverOptions{MaxIter: 30, Equilibrate: true}, nil, nil)
+	if e := relDiff(sol.X, xopt); e > 1e-6 {
+		t.Errorf("lp: x = %v, expected %v\n", sol.X.FloatArray(), xopt.FloatArray())
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
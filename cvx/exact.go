@@ -0,0 +1,315 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Result of the exact optimality check of ExactVerifyLp.
+type ExactLpReport struct {
+	// True if the basic solution is primal and dual feasible and
+	// complementary in exact arithmetic.
+	Optimal        bool
+	PrimalFeasible bool
+	DualFeasible   bool
+	Complementary  bool
+	// Rows of [G; A] in the basis. Indexes greater or equal to G.Rows()
+	// refer to rows of A.
+	Basis []int
+	// Exact basic solution and multipliers. Z and Y are zero for the rows
+	// not in the basis.
+	X, Z, Y []*big.Rat
+	// Exact objective values c'*x and -h'*z - b'*y.
+	PrimalObjective *big.Rat
+	DualObjective   *big.Rat
+	// Perturbations of the problem data that confirm optimality of the
+	// basis: with h+DeltaH and b+DeltaB the basic solution is feasible and
+	// with c+DeltaC the basic multipliers are nonnegative. DeltaH is the
+	// componentwise smallest such perturbation. DeltaC = B'*min(lambda, 0)
+	// sets the negative multipliers to zero; it is not the smallest cost
+	// perturbation in general, only an upper bound of it. The norms are
+	// infinity norms.
+	DeltaH, DeltaB, DeltaC *matrix.FloatMatrix
+	PrimalPerturbation     float64
+	DualPerturbation       float64
+	// Explanation if Optimal is false.
+	Reason string
+}
+
+//    Verifies optimality of a solution of
+//
+//        minimize    c'*x
+//        subject to  G*x <= h
+//                    A*x = b
+//
+//    in exact rational arithmetic.
+//
+//    The active set is identified from sol as in LpSensitivity with tolerance
+//    tol and a basis of n linearly independent active rows is selected.
+//    The problem data, taken to be exactly the given float64 values, is
+//    converted to big.Rat and the basic solution B*x = h_B and the basic
+//    multipliers B'*lambda = -c are solved exactly. The solution is optimal
+//    if G*x <= h, A*x = b and the multipliers of the inequality rows are
+//    nonnegative; complementary slackness holds by construction and is
+//    checked as equality of the primal and dual objectives.
+//
+//    If optimality cannot be confirmed the report gives perturbations of
+//    h, b and c that would confirm it. The perturbation of c is not minimal,
+//    see ExactLpReport.
+//
+func ExactVerifyLp(c, G, h, A, b *matrix.FloatMatrix, sol *Solution, tol float64) (rep *ExactLpReport, err error) {
+
+	// reuse the argument checks and the active set of the sensitivity analysis
+	sens, err := LpSensitivity(c, G, h, A, b, sol, nil, tol)
+	if err != nil {
+		return
+	}
+	n := c.Rows()
+	m := G.Rows()
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	p := A.Rows()
+
+	rep = &ExactLpReport{Basis: sens.Basis}
+	if sens.Incomplete {
+		rep.Reason = fmt.Sprintf("active rows have rank %d < %d; no basic solution", len(sens.Basis), n)
+		return
+	}
+
+	// exact data
+	Gr := ratMatrix(G)
+	Ar := ratMatrix(A)
+	hr := ratVector(h)
+	br := ratVector(b)
+	cr := ratVector(c)
+	for _, v := range [][]float64{G.FloatArray(), A.FloatArray(), h.FloatArray(),
+		b.FloatArray(), c.FloatArray()} {
+		for _, f := range v {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				rep = nil
+				err = errors.New("problem data is not finite")
+				return
+			}
+		}
+	}
+
+	row := func(r int) []*big.Rat {
+		if r < m {
+			return Gr[r]
+		}
+		return Ar[r-m]
+	}
+	rhs := func(r int) *big.Rat {
+		if r < m {
+			return hr[r]
+		}
+		return br[r-m]
+	}
+
+	// B*x = rhs_B
+	B := make([][]*big.Rat, n)
+	xb := make([]*big.Rat, n)
+	for k, r := range rep.Basis {
+		B[k] = row(r)
+		xb[k] = rhs(r)
+	}
+	rep.X, err = ratSolve(B, xb, false)
+	if err != nil {
+		rep = nil
+		return
+	}
+	// B'*lambda = -c
+	nc := make([]*big.Rat, n)
+	for j := range nc {
+		nc[j] = new(big.Rat).Neg(cr[j])
+	}
+	lambda, err := ratSolve(B, nc, true)
+	if err != nil {
+		rep = nil
+		return
+	}
+	rep.Z = make([]*big.Rat, m)
+	for i := range rep.Z {
+		rep.Z[i] = new(big.Rat)
+	}
+	rep.Y = make([]*big.Rat, p)
+	for i := range rep.Y {
+		rep.Y[i] = new(big.Rat)
+	}
+	for k, r := range rep.Basis {
+		if r < m {
+			rep.Z[r] = lambda[k]
+		} else {
+			rep.Y[r-m] = lambda[k]
+		}
+	}
+
+	// primal feasibility: G*x <= h, A*x = b
+	rep.DeltaH = matrix.FloatZeros(m, 1)
+	rep.DeltaB = matrix.FloatZeros(p, 1)
+	rep.PrimalFeasible = true
+	for i := 0; i < m+p; i++ {
+		r := ratDot(row(i), rep.X)
+		r.Sub(r, rhs(i))
+		// r = row*x - rhs
+		switch {
+		case i < m && r.Sign() > 0:
+			rep.PrimalFeasible = false
+			f, _ := r.Float64()
+			rep.DeltaH.SetIndex(i, f)
+		case i >= m && r.Sign() != 0:
+			rep.PrimalFeasible = false
+			f, _ := r.Float64()
+			rep.DeltaB.SetIndex(i-m, f)
+		}
+	}
+	rep.PrimalPerturbation = math.Max(normInf(rep.DeltaH), normInf(rep.DeltaB))
+
+	// dual feasibility: z >= 0. Replacing the negative multipliers by zero
+	// gives the multipliers of the cost c + B'*min(lambda, 0).
+	rep.DeltaC = matrix.FloatZeros(n, 1)
+	rep.DualFeasible = true
+	dc := make([]*big.Rat, n)
+	for j := range dc {
+		dc[j] = new(big.Rat)
+	}
+	t := new(big.Rat)
+	for k, r := range rep.Basis {
+		if r < m && lambda[k].Sign() < 0 {
+			rep.DualFeasible = false
+			for j := 0; j < n; j++ {
+				dc[j].Add(dc[j], t.Mul(B[k][j], lambda[k]))
+			}
+		}
+	}
+	for j := 0; j < n; j++ {
+		f, _ := dc[j].Float64()
+		rep.DeltaC.SetIndex(j, f)
+	}
+	rep.DualPerturbation = normInf(rep.DeltaC)
+
+	// objectives; equal if and only if z'*(h - G*x) + y'*(b - A*x) = 0
+	rep.PrimalObjective = ratDot(cr, rep.X)
+	rep.DualObjective = ratDot(hr, rep.Z)
+	rep.DualObjective.Add(rep.DualObjective, ratDot(br, rep.Y))
+	rep.DualObjective.Neg(rep.DualObjective)
+	rep.Complementary = rep.PrimalObjective.Cmp(rep.DualObjective) == 0
+
+	rep.Optimal = rep.PrimalFeasible && rep.DualFeasible && rep.Complementary
+	switch {
+	case !rep.PrimalFeasible:
+		rep.Reason = fmt.Sprintf("basic solution infeasible by %.3e", rep.PrimalPerturbation)
+	case !rep.DualFeasible:
+		rep.Reason = fmt.Sprintf("basic multipliers infeasible; cost perturbation %.3e", rep.DualPerturbation)
+	case !rep.Complementary:
+		rep.Reason = "primal and dual objectives differ"
+	}
+	return
+}
+
+// Solves B*x = r (trans is false) or B'*x = r (trans is true) exactly by
+// Gaussian elimination. B is given as a slice of rows and is not modified.
+func ratSolve(B [][]*big.Rat, r []*big.Rat, trans bool) ([]*big.Rat, error) {
+	n := len(r)
+	M := make([][]*big.Rat, n)
+	for i := 0; i < n; i++ {
+		M[i] = make([]*big.Rat, n+1)
+		for j := 0; j < n; j++ {
+			if trans {
+				M[i][j] = new(big.Rat).Set(B[j][i])
+			} else {
+				M[i][j] = new(big.Rat).Set(B[i][j])
+			}
+		}
+		M[i][n] = new(big.Rat).Set(r[i])
+	}
+	t := new(big.Rat)
+	for k := 0; k < n; k++ {
+		piv := -1
+		for i := k; i < n; i++ {
+			if M[i][k].Sign() != 0 {
+				piv = i
+				break
+			}
+		}
+		if piv < 0 {
+			return nil, errors.New("basis matrix is singular in exact arithmetic")
+		}
+		M[k], M[piv] = M[piv], M[k]
+		for i := k + 1; i < n; i++ {
+			if M[i][k].Sign() == 0 {
+				continue
+			}
+			f := new(big.Rat).Quo(M[i][k], M[k][k])
+			for j := k; j <= n; j++ {
+				M[i][j].Sub(M[i][j], t.Mul(f, M[k][j]))
+			}
+		}
+	}
+	x := make([]*big.Rat, n)
+	for i := n - 1; i >= 0; i-- {
+		s := new(big.Rat).Set(M[i][n])
+		for j := i + 1; j < n; j++ {
+			s.Sub(s, t.Mul(M[i][j], x[j]))
+		}
+		x[i] = s.Quo(s, M[i][i])
+	}
+	return x, nil
+}
+
+// Rows of M as exact rationals.
+func ratMatrix(M *matrix.FloatMatrix) [][]*big.Rat {
+	rows, cols := M.Size()
+	R := make([][]*big.Rat, rows)
+	for i := 0; i < rows; i++ {
+		R[i] = make([]*big.Rat, cols)
+		for j := 0; j < cols; j++ {
+			R[i][j] = ratFloat(M.GetAt(i, j))
+		}
+	}
+	return R
+}
+
+func ratVector(x *matrix.FloatMatrix) []*big.Rat {
+	v := make([]*big.Rat, x.NumElements())
+	for i := range v {
+		v[i] = ratFloat(x.GetIndex(i))
+	}
+	return v
+}
+
+// Exact value of a finite float; non-finite values map to zero and are
+// rejected by the caller.
+func ratFloat(f float64) *big.Rat {
+	r := new(big.Rat)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return r
+	}
+	return r.SetFloat64(f)
+}
+
+func ratDot(a, b []*big.Rat) *big.Rat {
+	s := new(big.Rat)
+	t := new(big.Rat)
+	for i := range a {
+		s.Add(s, t.Mul(a[i], b[i]))
+	}
+	return s
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
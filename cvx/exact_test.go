@@ -0,0 +1,109 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// The data of makeTestLp is exactly representable; the basis of the first
+// two rows gives x = (1, 1), z = (1, 2, 0, 0) and objective -9 exactly.
+// With c0 = -2.5 the multiplier of the first row is exactly zero and the
+// basis is still optimal.
+func TestExactVerifyLpOptimal(t *testing.T) {
+	c, G, h := makeTestLp()
+	sol, err := Lp(c, G, h, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := ExactVerifyLp(c, G, h, nil, nil, sol, 0.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Optimal {
+		t.Fatalf("not optimal: %s\n", rep.Reason)
+	}
+	for k, v := range []int64{1, 1} {
+		if rep.X[k].Cmp(big.NewRat(v, 1)) != 0 {
+			t.Errorf("x[%d] = %s, expected %d\n", k, rep.X[k].RatString(), v)
+		}
+	}
+	for k, v := range []int64{1, 2, 0, 0} {
+		if rep.Z[k].Cmp(big.NewRat(v, 1)) != 0 {
+			t.Errorf("z[%d] = %s, expected %d\n", k, rep.Z[k].RatString(), v)
+		}
+	}
+	if rep.PrimalObjective.Cmp(big.NewRat(-9, 1)) != 0 || rep.DualObjective.Cmp(big.NewRat(-9, 1)) != 0 {
+		t.Errorf("objectives %s, %s\n", rep.PrimalObjective.RatString(), rep.DualObjective.RatString())
+	}
+	if rep.PrimalPerturbation != 0.0 || rep.DualPerturbation != 0.0 {
+		t.Errorf("perturbations %v, %v\n", rep.PrimalPerturbation, rep.DualPerturbation)
+	}
+
+	c.SetIndex(0, -2.5)
+	rep, err = ExactVerifyLp(c, G, h, nil, nil, sol, 0.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Optimal || rep.Z[0].Sign() != 0 {
+		t.Errorf("c0 = -2.5: optimal %v, z0 = %s: %s\n", rep.Optimal, rep.Z[0].RatString(), rep.Reason)
+	}
+}
+
+// Perturbations of 2^-30 that floating point tolerances do not see make the
+// basis of the solution of makeTestLp nonoptimal.
+func TestExactVerifyLpPerturbed(t *testing.T) {
+	c, G, h := makeTestLp()
+	sol, err := Lp(c, G, h, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta := math.Ldexp(1.0, -30)
+
+	// c0 just above the cost range [-10, -2.5]: z0 = -2*delta/3 and
+	// DeltaC = (2, 1)*z0.
+	cp := c.Copy()
+	cp.SetIndex(0, -2.5+delta)
+	rep, err := ExactVerifyLp(cp, G, h, nil, nil, sol, 0.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Optimal || rep.DualFeasible || !rep.PrimalFeasible {
+		t.Errorf("cost perturbation: optimal %v, dual feasible %v, primal feasible %v\n",
+			rep.Optimal, rep.DualFeasible, rep.PrimalFeasible)
+	}
+	z0 := new(big.Rat).SetFloat64(delta)
+	z0.Mul(z0, big.NewRat(-2, 3))
+	if rep.Z[0].Cmp(z0) != 0 {
+		t.Errorf("z0 = %s, expected %s\n", rep.Z[0].RatString(), z0.RatString())
+	}
+	if e := maxDiff(rep.DeltaC, matrix.FloatVector([]float64{-4.0 * delta / 3.0, -2.0 * delta / 3.0})); e > 1e-24 {
+		t.Errorf("DeltaC = %v\n", rep.DeltaC.FloatArray())
+	}
+
+	// x0 >= 1 + delta is violated by delta at x = (1, 1)
+	hp := h.Copy()
+	hp.SetIndex(2, -1.0-delta)
+	rep, err = ExactVerifyLp(c, G, hp, nil, nil, sol, 0.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Optimal || rep.PrimalFeasible {
+		t.Errorf("rhs perturbation: optimal %v, primal feasible %v\n", rep.Optimal, rep.PrimalFeasible)
+	}
+	if rep.DeltaH.GetIndex(2) != delta || rep.PrimalPerturbation != delta {
+		t.Errorf("DeltaH = %v, perturbation %v, expected %v\n", rep.DeltaH.FloatArray(),
+			rep.PrimalPerturbation, delta)
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
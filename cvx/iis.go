@@ -0,0 +1,206 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Multipliers of the infeasibility certificate smaller than this relative
+// to the largest one are not taken into the initial IIS candidate set.
+const IIS_SUPPORT_TOL = 1e-6
+
+// Irreducible infeasible subset of the rows of a linear program. The rows
+// together are infeasible but removing any single one of them makes
+// the remaining rows feasible.
+type IIS struct {
+	// Row indexes of G and A in the subset, in increasing order.
+	Inequalities []int
+	Equalities   []int
+	// Names of the rows if names were given, otherwise nil.
+	InequalityNames []string
+	EqualityNames   []string
+	// Number of Lp solves performed.
+	Solves int
+}
+
+//    Computes an irreducible infeasible subset of the constraints
+//
+//        G*x <= h
+//        A*x = b.
+//
+//    The initial candidate set is the support of the Farkas certificate
+//    of the full problem. It is then reduced by deletion filtering: each row
+//    in turn is removed and the remaining rows are solved as a feasibility
+//    problem with Lp. If they are still infeasible the row is dropped for
+//    good, otherwise it is kept.
+//
+//    A row is kept also if the solver fails on the reduced problem (for
+//    example if the remaining equality rows are rank deficient), so the
+//    returned set is always infeasible but may not be irreducible in such
+//    cases.
+//
+//    Arguments A, b, names and solopts may be nil. Returns an error if the
+//    full problem is not found to be primal infeasible.
+//
+func FindIIS(G, h, A, b *matrix.FloatMatrix, names *LpNames, solopts *SolverOptions) (iis *IIS, err error) {
+
+	if G == nil {
+		err = errors.New("'G' must be a dense matrix")
+		return
+	}
+	m, n := G.Size()
+	if n < 1 {
+		err = errors.New("Number of variables must be at least 1")
+		return
+	}
+	if h == nil || !h.SizeMatch(m, 1) {
+		err = errors.New(fmt.Sprintf("'h' must be matrix of size (%d,1)", m))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	p := A.Rows()
+	if A.Cols() != n || !b.SizeMatch(p, 1) {
+		err = errors.New(fmt.Sprintf("'A' must have %d columns and 'b' size (%d,1)", n, p))
+		return
+	}
+	if solopts == nil {
+		solopts = &SolverOptions{MaxIter: MAXITERS}
+	}
+
+	iis = &IIS{}
+	c := matrix.FloatZeros(n, 1)
+
+	// Solves the feasibility problem over the rows in set; indexes below m
+	// refer to G, the others to A. Returns the certificate if infeasible.
+	infeasible := func(set []int) *Certificate {
+		ineq, eq := splitRows(set, m)
+		// a zero row 0*x <= 1 keeps G nonempty; it never enters a certificate.
+		Gs := matrix.FloatZeros(len(ineq)+1, n)
+		hs := matrix.FloatZeros(len(ineq)+1, 1)
+		for k, i := range ineq {
+			for j := 0; j < n; j++ {
+				Gs.SetAt(k, j, G.GetAt(i, j))
+			}
+			hs.SetIndex(k, h.GetIndex(i))
+		}
+		hs.SetIndex(len(ineq), 1.0)
+		As := matrix.FloatZeros(len(eq), n)
+		bs := matrix.FloatZeros(len(eq), 1)
+		for k, i := range eq {
+			for j := 0; j < n; j++ {
+				As.SetAt(k, j, A.GetAt(i, j))
+			}
+			bs.SetIndex(k, b.GetIndex(i))
+		}
+		iis.Solves++
+		sol, _ := Lp(c, Gs, hs, As, bs, solopts, nil, nil)
+		if sol == nil || sol.Status != PrimalInfeasible || sol.Certificate == nil {
+			return nil
+		}
+		// map the certificate back to the rows of G and A
+		z := matrix.FloatZeros(m, 1)
+		y := matrix.FloatZeros(p, 1)
+		for k, i := range ineq {
+			z.SetIndex(i, sol.Certificate.Z.GetIndex(k))
+		}
+		for k, i := range eq {
+			y.SetIndex(i, sol.Certificate.Y.GetIndex(k))
+		}
+		return &Certificate{Status: PrimalInfeasible, Y: y, Z: z}
+	}
+
+	all := make([]int, m+p)
+	for i := range all {
+		all[i] = i
+	}
+	cert := infeasible(all)
+	if cert == nil {
+		iis = nil
+		err = errors.New("problem is not primal infeasible")
+		return
+	}
+
+	// initial candidates from the support of the certificate
+	cmax := 0.0
+	for i := 0; i < m; i++ {
+		cmax = math.Max(cmax, math.Abs(cert.Z.GetIndex(i)))
+	}
+	for i := 0; i < p; i++ {
+		cmax = math.Max(cmax, math.Abs(cert.Y.GetIndex(i)))
+	}
+	set := make([]int, 0, m+p)
+	for i := 0; i < m; i++ {
+		if math.Abs(cert.Z.GetIndex(i)) > IIS_SUPPORT_TOL*cmax {
+			set = append(set, i)
+		}
+	}
+	for i := 0; i < p; i++ {
+		if math.Abs(cert.Y.GetIndex(i)) > IIS_SUPPORT_TOL*cmax {
+			set = append(set, m+i)
+		}
+	}
+	if len(set) < m+p && infeasible(set) == nil {
+		set = all
+	}
+
+	// deletion filter
+	trial := make([]int, 0, len(set))
+	for k := 0; k < len(set); {
+		trial = trial[:0]
+		trial = append(trial, set[:k]...)
+		trial = append(trial, set[k+1:]...)
+		if infeasible(trial) != nil {
+			set = append(set[:k], set[k+1:]...)
+		} else {
+			k++
+		}
+	}
+
+	iis.Inequalities, iis.Equalities = splitRows(set, m)
+	if names != nil {
+		if len(names.Inequalities) > 0 {
+			iis.InequalityNames = make([]string, len(iis.Inequalities))
+			for k, i := range iis.Inequalities {
+				iis.InequalityNames[k] = nameAt(names.Inequalities, i)
+			}
+		}
+		if len(names.Equalities) > 0 {
+			iis.EqualityNames = make([]string, len(iis.Equalities))
+			for k, i := range iis.Equalities {
+				iis.EqualityNames[k] = nameAt(names.Equalities, i)
+			}
+		}
+	}
+	return
+}
+
+// Splits row indexes of [G; A] into rows of G and rows of A.
+func splitRows(set []int, m int) (ineq, eq []int) {
+	ineq = make([]int, 0, len(set))
+	eq = make([]int, 0, len(set))
+	for _, r := range set {
+		if r < m {
+			ineq = append(ineq, r)
+		} else {
+			eq = append(eq, r-m)
+		}
+	}
+	return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,55 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"testing"
+)
+
+// Rows x0 + x1 <= 10, x0 <= 1, x1 >= 0, x0 >= 2, x1 <= 3 and the equality
+// x1 = 1. The only infeasible subset is {x0 <= 1, x0 >= 2}.
+func TestFindIIS(t *testing.T) {
+	G := matrix.FloatMatrixStacked([][]float64{
+		[]float64{1.0, 1.0},
+		[]float64{1.0, 0.0},
+		[]float64{0.0, -1.0},
+		[]float64{-1.0, 0.0},
+		[]float64{0.0, 1.0}}, matrix.RowOrder)
+	h := matrix.FloatVector([]float64{10.0, 1.0, 0.0, -2.0, 3.0})
+	A := matrix.FloatMatrixStacked([][]float64{[]float64{0.0, 1.0}}, matrix.RowOrder)
+	b := matrix.FloatVector([]float64{1.0})
+	names := &LpNames{
+		Inequalities: []string{"sum", "x0_max", "x1_min", "x0_min", "x1_max"},
+		Equalities:   []string{"x1_fix"}}
+
+	iis, err := FindIIS(G, h, A, b, names, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(iis.Inequalities) != 2 || iis.Inequalities[0] != 1 || iis.Inequalities[1] != 3 ||
+		len(iis.Equalities) != 0 {
+		t.Fatalf("IIS %v, %v, expected [1 3], []\n", iis.Inequalities, iis.Equalities)
+	}
+	if len(iis.InequalityNames) != 2 || iis.InequalityNames[0] != "x0_max" ||
+		iis.InequalityNames[1] != "x0_min" {
+		t.Errorf("IIS names %v\n", iis.InequalityNames)
+	}
+	if iis.Solves < 2 {
+		t.Errorf("%d solves\n", iis.Solves)
+	}
+
+	// a feasible problem has no IIS
+	h.SetIndex(3, -0.5)
+	if _, err = FindIIS(G, h, A, b, nil, nil); err == nil {
+		t.Errorf("IIS of a feasible problem\n")
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
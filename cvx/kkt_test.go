@@ -0,0 +1,224 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/linalg/blas"
+	"github.com/hrautila/go.opt/matrix"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// The cone LP of examples/testconelp.go over the cone of makeDSet.
+func makeConeLp() (c, G, h *matrix.FloatMatrix, dims *DimensionSet) {
+	gdata := [][]float64{
+		[]float64{16., 7., 24., -8., 8., -1., 0., -1., 0., 0., 7.,
+			-5., 1., -5., 1., -7., 1., -7., -4.},
+		[]float64{-14., 2., 7., -13., -18., 3., 0., 0., -1., 0., 3.,
+			13., -6., 13., 12., -10., -6., -10., -28.},
+		[]float64{5., 0., -15., 12., -6., 17., 0., 0., 0., -1., 9.,
+			6., -6., 6., -7., -7., -6., -7., -11.}}
+	hdata := []float64{-3., 5., 12., -2., -14., -13., 10., 0., 0., 0., 68.,
+		-30., -19., -30., 99., 23., -19., 23., 10.}
+	c = matrix.FloatVector([]float64{-6., -4., -5.})
+	G = matrix.FloatMatrixStacked(gdata)
+	h = matrix.FloatVector(hdata)
+	dims = makeDSet()
+	return
+}
+
+// ConeLp with KKT solver "cg" and both preconditioners against "ldl".
+func TestKktCg(t *testing.T) {
+	c, G, h, dims := makeConeLp()
+	ref, err := ConeLp(c, G, h, nil, nil, dims, &SolverOptions{MaxIter: 30, KKTSolverName: "ldl"}, nil, nil)
+	if err != nil {
+		t.Fatalf("ldl: %s\n", err)
+	}
+	for _, pc := range []string{"diag", "ichol"} {
+		solopts := &SolverOptions{MaxIter: 30, KKTSolverName: "cg", CGPreconditioner: pc}
+		sol, err := ConeLp(c, G, h, nil, nil, dims, solopts, nil, nil)
+		if err != nil {
+			t.Fatalf("cg/%s: %s\n", pc, err)
+		}
+		if sol.Status != Optimal {
+			t.Errorf("cg/%s: status %v\n", pc, sol.Status)
+		}
+		d := sol.X.Copy()
+		blas.AxpyFloat(ref.X, d, -1.0)
+		if e := blas.Nrm2Float(d); e > 1e-5 {
+			t.Errorf("cg/%s: ||x - x_ldl|| = %.3e\n", pc, e)
+		}
+		iters := 0
+		for _, st := range sol.IterStats {
+			iters += st.CGIterations
+		}
+		if iters == 0 {
+			t.Errorf("cg/%s: no CG iterations reported\n", pc)
+		}
+	}
+}
+
+// Solves stopped by CGMaxIter are reported in the iteration statistics.
+func TestKktCgUnconverged(t *testing.T) {
+	c, G, h, dims := makeConeLp()
+	solopts := &SolverOptions{KKTSolverName: "cg", CGMaxIter: 1, MaxIter: 5}
+	sol, _ := ConeLp(c, G, h, nil, nil, dims, solopts, nil, nil)
+	if sol == nil {
+		t.Fatalf("no solution\n")
+	}
+	unconverged := 0
+	for _, st := range sol.IterStats {
+		unconverged += st.CGUnconverged
+	}
+	if unconverged == 0 {
+		t.Errorf("no unconverged CG solves with CGMaxIter 1\n")
+	}
+	solopts.CGMaxIter = 0
+	sol, err := ConeLp(c, G, h, nil, nil, dims, solopts, nil, nil)
+	if err != nil && sol == nil {
+		t.Fatal(err)
+	}
+	for k, st := range sol.IterStats {
+		if st.CGUnconverged != 0 {
+			t.Errorf("iteration %d: %d unconverged CG solves\n", k, st.CGUnconverged)
+		}
+	}
+}
+
+// Timings and counts of Solution.Stats of ConeLp.
+func TestSolveStats(t *testing.T) {
+	c, G, h, dims := makeConeLp()
+	sol, err := ConeLp(c, G, h, nil, nil, dims, &SolverOptions{MaxIter: 30, Refinement: 1}, nil, nil)
+	if err != nil {
+		t.Fatalf("ConeLp: %s\n", err)
+	}
+	st := sol.Stats
+	// one factorization per iteration and one for the starting point
+	if st.Factorizations != sol.Iterations+1 {
+		t.Errorf("%d factorizations in %d iterations\n", st.Factorizations, sol.Iterations)
+	}
+	if st.Solves < 2*st.Factorizations || st.Refinement == 0 {
+		t.Errorf("%d solves, %d refinement steps\n", st.Solves, st.Refinement)
+	}
+	for _, d := range []time.Duration{st.SetupTime, st.FactorTime, st.SolveTime, st.StepTime, st.ScalingTime} {
+		if d > st.TotalTime {
+			t.Errorf("phase time %v exceeds total time %v\n", d, st.TotalTime)
+		}
+	}
+	if st.Step <= 0.0 || st.Step > 1.0 || st.AffineStep <= 0.0 || st.AffineStep > 1.0 {
+		t.Errorf("step lengths %.3f, %.3f\n", st.AffineStep, st.Step)
+	}
+}
+
+// The iterations of ConeLp reuse their workspace: every iteration makes
+// the same number of allocations, those of the option lists and arguments
+// of the linalg wrappers, so that solves stopped after 2, 4 and 6
+// iterations differ by the same count.
+func TestConeLpIterationAllocs(t *testing.T) {
+	c, G, h, dims := makeConeLp()
+	allocs := func(maxiter int) float64 {
+		solopts := &SolverOptions{MaxIter: maxiter, KKTSolverName: "ldl", Workers: 1,
+			AbsTol: 1e-14, RelTol: 1e-14, FeasTol: 1e-14}
+		return testing.AllocsPerRun(5, func() {
+			sol, _ := ConeLp(c, G, h, nil, nil, dims, solopts, nil, nil)
+			if sol == nil || sol.Iterations != maxiter {
+				t.Fatalf("ConeLp stopped before iteration %d\n", maxiter)
+			}
+		})
+	}
+	a2, a4, a6 := allocs(2), allocs(4), allocs(6)
+	if a4-a2 != a6-a4 {
+		t.Errorf("%.0f allocations in iterations 3-4, %.0f in iterations 5-6\n", a4-a2, a6-a4)
+	}
+}
+
+// Number of heap allocations made by f.
+func countAllocs(f func()) uint64 {
+	var m0, m1 runtime.MemStats
+	runtime.ReadMemStats(&m0)
+	f()
+	runtime.ReadMemStats(&m1)
+	return m1.Mallocs - m0.Mallocs
+}
+
+func BenchmarkKktLdlFactor(b *testing.B) {
+	_, G, _, dims := makeConeLp()
+	A := matrix.FloatZeros(0, G.Cols())
+	W := NewScaling(dims, 0)
+	factor, _ := kktLdl(G, dims, A, 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := factor(W, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKktLdlSolve(b *testing.B) {
+	c, G, h, dims := makeConeLp()
+	A := matrix.FloatZeros(0, G.Cols())
+	W := NewScaling(dims, 0)
+	factor, _ := kktLdl(G, dims, A, 0)
+	f, err := factor(W, nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	x := c.Copy()
+	y := matrix.FloatZeros(0, 1)
+	z := h.Copy()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		blas.Copy(c, x)
+		blas.Copy(h, z)
+		if err = f(x, y, z); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScale(b *testing.B) {
+	_, _, h, dims := makeConeLp()
+	W := NewScaling(dims, 0)
+	x := h.Copy()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Scale(x, W, true, true)
+	}
+}
+
+// Reports the heap allocations of one ConeLp iteration as allocs/iter,
+// the difference of a full solve and a solve stopped after two iterations.
+// Setup and result allocations cancel out.
+func BenchmarkConeLpIteration(b *testing.B) {
+	c, G, h, dims := makeConeLp()
+	var iters int
+	var short, full uint64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		short += countAllocs(func() {
+			ConeLp(c, G, h, nil, nil, dims, &SolverOptions{MaxIter: 2}, nil, nil)
+		})
+		full += countAllocs(func() {
+			sol, err := ConeLp(c, G, h, nil, nil, dims, &SolverOptions{MaxIter: 100}, nil, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			iters = sol.Iterations
+		})
+	}
+	if iters > 2 && full > short {
+		b.ReportMetric(float64(full-short)/float64(b.N*(iters-2)), "allocs/iter")
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,383 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la_ "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/blas"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Parameters of the conjugate gradient KKT solver "cg". The relative
+// tolerance of CG is CG_GAPFACTOR times the duality measure mu of the
+// current iterate, limited to [CG_TOLMIN, CG_TOLMAX].
+const (
+	CG_TOLMIN    = 1e-12
+	CG_TOLMAX    = 1e-4
+	CG_GAPFACTOR = 1e-3
+	// Default maximum number of CG iterations per solve.
+	CG_MAXITER = 1000
+	// Bandwidth of the incomplete Cholesky preconditioner.
+	ICHOL_BAND = 8
+)
+
+// Preconditioned conjugate gradient solution of the KKT equations
+//
+//     [ H     A'   G'    ]   [ ux ]   [ bx ]
+//     [ A     0    0     ] * [ uy ] = [ by ].
+//     [ G     0   -W'*W  ]   [ uz ]   [ bz ]
+//
+// Eliminating uz gives the reduced equations
+//
+//     [ H + G'*W^{-1}*W^{-T}*G  A' ] [ ux ]   [ bx + G'*W^{-1}*W^{-T}*bz ]
+//     [ A                       0  ] [ uy ] = [ by                       ]
+//
+// that are solved by CG in the null space of A. The CG iteration uses only
+// products with H, G, A and their transposes; the entries of G are read
+// once per factorization to build the diagonal ("diag") or banded
+// incomplete Cholesky ("ichol") preconditioner. Neither copies G: the
+// columns of W^{-T}*G are formed one at a time, and "ichol" keeps only the
+// ICHOL_BAND+1 columns in its band.
+//
+// The projection on the null space of A is not matrix-free: A*A' is formed
+// densely from products with A and A' and factored once when the solver is
+// created, which costs p products and O(p^3) work and O(p^2) memory. The
+// solver is meant for problems with few equality constraints.
+//
+// A solve that reaches the maximum number of CG iterations before the
+// tolerance returns the last iterate and is counted in unconverged, which
+// the solvers report in IterationStats.CGUnconverged.
+type kktCgSolver struct {
+	n, p, cdim int
+	G, A, H    *matrix.FloatMatrix
+	dims       *DimensionSet
+	W          *Scaling
+	precond    string
+	maxiter    int
+	tol        float64
+	// CG iterations and the solves stopped at maxiter since the last
+	// call of setGap
+	iters, unconverged int
+	// inverse diagonal or the band of the matrix and its incomplete
+	// factor, M[i][k] = M(i, i-k) and L[i][k] = L(i, i-k)
+	dinv []float64
+	M, L [][]float64
+	// the scaled columns of G in the band of "ichol", column j in
+	// cols[j % len(cols)]
+	cols []*matrix.FloatMatrix
+	// Cholesky factor of A*A'
+	AAt *matrix.FloatMatrix
+	// work vectors of length cdim, n and p
+	t, bzs    *matrix.FloatMatrix
+	rhs, u, r *matrix.FloatMatrix
+	z, d, q   *matrix.FloatMatrix
+	yp        *matrix.FloatMatrix
+	solveFunc kktFunc
+}
+
+// KKT solver "cg" with the default options.
+func kktCg(G *matrix.FloatMatrix, dims *DimensionSet, A *matrix.FloatMatrix, mnl int) (kktFactor, error) {
+	cg, err := createCgSolver(G, dims, A, mnl, &SolverOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return cg.factor, nil
+}
+
+func createCgSolver(G *matrix.FloatMatrix, dims *DimensionSet, A *matrix.FloatMatrix, mnl int, solopts *SolverOptions) (cg *kktCgSolver, err error) {
+	if mnl > 0 {
+		err = errors.New("KKT solver 'cg' does not support nonlinear constraints")
+		return
+	}
+	cg = &kktCgSolver{G: G, A: A, dims: dims}
+	cg.p, cg.n = A.Size()
+	cg.cdim = dims.Sum("l", "q") + dims.SumSquared("s")
+	switch solopts.CGPreconditioner {
+	case "", "diag":
+		cg.precond = "diag"
+	case "ichol":
+		cg.precond = "ichol"
+	default:
+		err = errors.New(fmt.Sprintf("unknown CG preconditioner '%s'", solopts.CGPreconditioner))
+		return
+	}
+	cg.maxiter = CG_MAXITER
+	if solopts.CGMaxIter > 0 {
+		cg.maxiter = solopts.CGMaxIter
+	}
+	cg.tol = CG_TOLMIN
+	n, p := cg.n, cg.p
+	cg.t = matrix.FloatZeros(cg.cdim, 1)
+	cg.bzs = matrix.FloatZeros(cg.cdim, 1)
+	for _, v := range []**matrix.FloatMatrix{&cg.rhs, &cg.u, &cg.r, &cg.z, &cg.d, &cg.q} {
+		*v = matrix.FloatZeros(n, 1)
+	}
+	cg.yp = matrix.FloatZeros(p, 1)
+	cg.dinv = make([]float64, n)
+	if cg.precond == "ichol" {
+		cg.M = make([][]float64, n)
+		cg.L = make([][]float64, n)
+		for i := 0; i < n; i++ {
+			cg.M[i] = make([]float64, int(math.Min(float64(i), ICHOL_BAND))+1)
+			cg.L[i] = make([]float64, len(cg.M[i]))
+		}
+		cg.cols = make([]*matrix.FloatMatrix, ICHOL_BAND+1)
+		for k := range cg.cols {
+			cg.cols[k] = matrix.FloatZeros(cg.cdim, 1)
+		}
+	}
+	if p > 0 {
+		// A*A' column by column from products with A' and A
+		cg.AAt = matrix.FloatZeros(p, p)
+		e := matrix.FloatZeros(p, 1)
+		for j := 0; j < p; j++ {
+			e.SetIndex(j, 1.0)
+			blas.GemvFloat(A, e, cg.u, 1.0, 0.0, la_.OptTrans)
+			blas.GemvFloat(A, cg.u, cg.yp, 1.0, 0.0)
+			for i := 0; i < p; i++ {
+				cg.AAt.SetAt(i, j, cg.yp.GetIndex(i))
+			}
+			e.SetIndex(j, 0.0)
+		}
+		if err = lapack.PotrfFloat(cg.AAt); err != nil {
+			err = errors.New("Rank(A) < p")
+			return
+		}
+	}
+	cg.solveFunc = cg.solve
+	return
+}
+
+// Sets the CG tolerance for the duality gap of an iterate with the given
+// degree and resets the iteration counts.
+func (cg *kktCgSolver) setGap(gap float64, degree int) {
+	mu := gap / float64(degree)
+	cg.tol = math.Min(CG_TOLMAX, math.Max(CG_TOLMIN, CG_GAPFACTOR*mu))
+	cg.iters = 0
+	cg.unconverged = 0
+}
+
+// v := P*v where P is the orthogonal projection on the null space of A.
+func (cg *kktCgSolver) project(v *matrix.FloatMatrix) {
+	if cg.p == 0 {
+		return
+	}
+	blas.GemvFloat(cg.A, v, cg.yp, 1.0, 0.0)
+	lapack.Potrs(cg.AAt, cg.yp)
+	blas.GemvFloat(cg.A, cg.yp, v, -1.0, 1.0, la_.OptTrans)
+}
+
+// y := (H + G'*W^{-1}*W^{-T}*G)*x
+func (cg *kktCgSolver) mult(x, y *matrix.FloatMatrix) {
+	Sgemv(cg.G, x, cg.t, 1.0, 0.0, cg.dims)
+	Scale(cg.t, cg.W, true, true)
+	Scale(cg.t, cg.W, false, true)
+	Sgemv(cg.G, cg.t, y, 1.0, 0.0, cg.dims, la_.OptTrans)
+	if cg.H != nil {
+		blas.SymvFloat(cg.H, x, y, 1.0, 1.0)
+	}
+}
+
+// z := M^{-1}*r for the preconditioner M.
+func (cg *kktCgSolver) psolve(r, z *matrix.FloatMatrix) {
+	za := z.FloatArray()
+	copy(za, r.FloatArray())
+	if cg.precond == "diag" {
+		for i := range za {
+			za[i] *= cg.dinv[i]
+		}
+		return
+	}
+	// L*L'*z = r with the band of L
+	n, L := cg.n, cg.L
+	for i := 0; i < n; i++ {
+		for k := 1; k < len(L[i]); k++ {
+			za[i] -= L[i][k] * za[i-k]
+		}
+		za[i] /= L[i][0]
+	}
+	for i := n-1; i >= 0; i-- {
+		za[i] /= L[i][0]
+		for k := 1; k < len(L[i]); k++ {
+			za[i-k] -= L[i][k] * za[i]
+		}
+	}
+}
+
+// Builds the preconditioner of H + G'*W^{-1}*W^{-T}*G. The columns of
+// W^{-T}*G are formed one at a time.
+func (cg *kktCgSolver) factor(W *Scaling, H, Df *matrix.FloatMatrix) (kktFunc, error) {
+	cg.W, cg.H = W, H
+	n := cg.n
+	diag := func(j int) float64 {
+		if H != nil {
+			return H.GetAt(j, j)
+		}
+		return 0.0
+	}
+	if cg.precond == "diag" {
+		ta := cg.t.FloatArray()
+		for j := 0; j < n; j++ {
+			cg.G.GetColumnArray(j, ta)
+			if err := Scale(cg.t, W, true, true); err != nil {
+				return nil, err
+			}
+			a := diag(j) + Sdot(cg.t, cg.t, cg.dims, 0)
+			if a <= 0.0 {
+				a = 1.0
+			}
+			cg.dinv[j] = 1.0 / a
+		}
+		return cg.solveFunc, nil
+	}
+	// incomplete Cholesky on the band |i-j| <= ICHOL_BAND, shifting the
+	// diagonal until all pivots are positive. Row i of the band needs the
+	// scaled columns i-ICHOL_BAND, ..., i.
+	M, nc := cg.M, len(cg.cols)
+	for i := 0; i < n; i++ {
+		ci := cg.cols[i % nc]
+		cg.G.GetColumnArray(i, ci.FloatArray())
+		if err := Scale(ci, W, true, true); err != nil {
+			return nil, err
+		}
+		for k := range M[i] {
+			M[i][k] = Sdot(ci, cg.cols[(i-k) % nc], cg.dims, 0)
+			if H != nil {
+				M[i][k] += H.GetAt(i, i-k)
+			}
+		}
+	}
+	shift := 0.0
+	for {
+		if bandCholesky(M, cg.L, shift) {
+			break
+		}
+		if shift == 0.0 {
+			shift = 1e-8
+		} else {
+			shift *= 10.0
+		}
+		if shift > 1.0 {
+			return nil, errors.New("incomplete Cholesky preconditioner failed")
+		}
+	}
+	return cg.solveFunc, nil
+}
+
+// Cholesky factor L of the band matrix M, M[i][k] = M(i, i-k), with the
+// diagonal scaled by 1+shift; L has the shape of M. Returns false if a
+// pivot is not positive.
+func bandCholesky(M, L [][]float64, shift float64) bool {
+	n := len(M)
+	for i := 0; i < n; i++ {
+		for k := len(M[i])-1; k >= 0; k-- {
+			// L(i, j) with j = i-k
+			j := i - k
+			a := M[i][k]
+			if k == 0 {
+				a *= 1.0 + shift
+			}
+			for l := k+1; l < len(L[i]); l++ {
+				// L(i, i-l) * L(j, i-l) if i-l is in the band of row j
+				if kj := l - k; kj < len(L[j]) {
+					a -= L[i][l] * L[j][kj]
+				}
+			}
+			if k == 0 {
+				if a <= 0.0 {
+					return false
+				}
+				L[i][0] = math.Sqrt(a)
+			} else {
+				L[i][k] = a / L[j][0]
+			}
+		}
+	}
+	return true
+}
+
+// Solves the KKT equations; on exit x, y, z contain ux, uy and W*uz.
+func (cg *kktCgSolver) solve(x, y, z *matrix.FloatMatrix) error {
+	// bzs = W^{-T}*bz, rhs = bx + G'*W^{-1}*bzs
+	blas.Copy(z, cg.bzs)
+	if err := Scale(cg.bzs, cg.W, true, true); err != nil {
+		return err
+	}
+	blas.Copy(cg.bzs, cg.t)
+	Scale(cg.t, cg.W, false, true)
+	blas.Copy(x, cg.rhs)
+	Sgemv(cg.G, cg.t, cg.rhs, 1.0, 1.0, cg.dims, la_.OptTrans)
+
+	// u = A'*(A*A')^{-1}*by satisfies A*u = by
+	blas.ScalFloat(cg.u, 0.0)
+	if cg.p > 0 {
+		blas.Copy(y, cg.yp)
+		lapack.Potrs(cg.AAt, cg.yp)
+		blas.GemvFloat(cg.A, cg.yp, cg.u, 1.0, 0.0, la_.OptTrans)
+	}
+
+	// projected PCG for the correction in the null space of A
+	cg.mult(cg.u, cg.r)
+	blas.ScalFloat(cg.r, -1.0)
+	blas.AxpyFloat(cg.rhs, cg.r, 1.0)
+	cg.project(cg.r)
+	r0 := math.Max(blas.Nrm2Float(cg.rhs), 1e-300)
+	rz := 0.0
+	for k := 0; k < cg.maxiter; k++ {
+		if blas.Nrm2Float(cg.r) <= cg.tol*r0 {
+			break
+		}
+		cg.psolve(cg.r, cg.z)
+		cg.project(cg.z)
+		rz2 := blas.DotFloat(cg.r, cg.z)
+		if k == 0 {
+			blas.Copy(cg.z, cg.d)
+		} else {
+			blas.ScalFloat(cg.d, rz2/rz)
+			blas.AxpyFloat(cg.z, cg.d, 1.0)
+		}
+		rz = rz2
+		cg.mult(cg.d, cg.q)
+		dq := blas.DotFloat(cg.d, cg.q)
+		if dq <= 0.0 {
+			return errors.New("KKT matrix is not positive definite on the null space of A")
+		}
+		alpha := rz / dq
+		blas.AxpyFloat(cg.d, cg.u, alpha)
+		blas.AxpyFloat(cg.q, cg.r, -alpha)
+		cg.project(cg.r)
+		cg.iters++
+	}
+	if blas.Nrm2Float(cg.r) > cg.tol*r0 {
+		// stopped by maxiter, keep the last iterate
+		cg.unconverged++
+	}
+
+	// uy = (A*A')^{-1}*A*(rhs - M*ux)
+	if cg.p > 0 {
+		cg.mult(cg.u, cg.r)
+		blas.ScalFloat(cg.r, -1.0)
+		blas.AxpyFloat(cg.rhs, cg.r, 1.0)
+		blas.GemvFloat(cg.A, cg.r, y, 1.0, 0.0)
+		lapack.Potrs(cg.AAt, y)
+	}
+	blas.Copy(cg.u, x)
+
+	// W*uz = W^{-T}*(G*ux - bz)
+	Sgemv(cg.G, x, z, 1.0, 0.0, cg.dims)
+	Scale(z, cg.W, true, true)
+	blas.AxpyFloat(cg.bzs, z, -1.0)
+	return nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,447 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	// Default integrality tolerance.
+	MIP_INTTOL = 1e-6
+	// Default absolute optimality tolerance of branch-and-bound.
+	MIP_ABSGAP = 1e-6
+)
+
+// Node selection rule of branch-and-bound.
+type NodeSelection int
+
+const (
+	// Explore the node with the smallest relaxation bound first.
+	BestBound = NodeSelection(1 + iota)
+	// Explore the most recently created node first.
+	DepthFirst
+)
+
+// Options of MixedIntegerLp and MixedIntegerQp.
+type BranchBoundOptions struct {
+	// Node selection rule; BestBound if zero.
+	Selection NodeSelection
+	// Maximum number of nodes to solve; unlimited if zero.
+	MaxNodes int
+	// Number of goroutines solving nodes; one if zero.
+	Workers int
+	// Integrality tolerance; MIP_INTTOL if zero.
+	IntTol float64
+	// Nodes with bound above incumbent - AbsGap are pruned; MIP_ABSGAP
+	// if zero.
+	AbsGap float64
+	// Called with a copy of each new incumbent and its objective value.
+	// Calls are serialized and made with strictly decreasing objective
+	// values; an incumbent superseded before its call is not reported.
+	// Other workers continue during a call. Returning false stops the
+	// search and no further calls are made.
+	Incumbent func(x *matrix.FloatMatrix, objective float64) bool
+	// Options of the relaxation solver; the defaults if nil.
+	Solver *SolverOptions
+}
+
+// Result of branch-and-bound.
+type MipSolution struct {
+	// Optimal if the incumbent is proven optimal, PrimalInfeasible if
+	// no integer feasible point exists and Unknown if the search was stopped
+	// by the node limit or the incumbent callback.
+	Status StatusCode
+	// Best integer feasible point found or nil.
+	X *matrix.FloatMatrix
+	// Objective value of X, +Inf if X is nil.
+	Objective float64
+	// Lower bound of the optimal value.
+	Bound float64
+	// Number of nodes solved.
+	Nodes int
+	// Number of relaxations the solver failed on; such nodes are dropped
+	// and optimality is then not proven.
+	Failed int
+}
+
+// Branch-and-bound node; integer variable k is bounded by lo[k] and up[k].
+type bbNode struct {
+	lo, up []float64
+	bound  float64
+	depth  int
+	seq    int
+}
+
+// Queue of open nodes ordered by the node selection rule.
+type bbQueue struct {
+	nodes     []*bbNode
+	bestBound bool
+}
+
+func (q *bbQueue) Len() int { return len(q.nodes) }
+
+func (q *bbQueue) Less(i, j int) bool {
+	a, b := q.nodes[i], q.nodes[j]
+	if q.bestBound {
+		if a.bound != b.bound {
+			return a.bound < b.bound
+		}
+		return a.seq < b.seq
+	}
+	// depth first; latest first among equal depth
+	if a.depth != b.depth {
+		return a.depth > b.depth
+	}
+	return a.seq > b.seq
+}
+
+func (q *bbQueue) Swap(i, j int) { q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i] }
+
+func (q *bbQueue) Push(x interface{}) { q.nodes = append(q.nodes, x.(*bbNode)) }
+
+func (q *bbQueue) Pop() interface{} {
+	k := len(q.nodes) - 1
+	x := q.nodes[k]
+	q.nodes = q.nodes[:k]
+	return x
+}
+
+// relaxation solves the continuous problem with extra rows G_b*x <= h_b.
+type relaxation func(Gb, hb *matrix.FloatMatrix) (*Solution, error)
+
+//    Solves the mixed integer linear program
+//
+//        minimize    c'*x
+//        subject to  G*x <= h
+//                    A*x = b
+//                    x[k] integer for k in integer
+//
+//    by branch-and-bound with Lp relaxations. Arguments are as for Lp and
+//    integer lists the indexes of the integer variables. See
+//    BranchBoundOptions for the options.
+//
+func MixedIntegerLp(c, G, h, A, b *matrix.FloatMatrix, integer []int, bbopts *BranchBoundOptions) (*MipSolution, error) {
+	if c == nil || c.Cols() != 1 {
+		return nil, errors.New("'c' must a column matrix")
+	}
+	n := c.Rows()
+	if G == nil {
+		G = matrix.FloatZeros(0, n)
+	}
+	if h == nil {
+		h = matrix.FloatZeros(0, 1)
+	}
+	if G.Cols() != n || !h.SizeMatch(G.Rows(), 1) {
+		return nil, errors.New(fmt.Sprintf("'G' must have %d columns and 'h' size (%d,1)", n, G.Rows()))
+	}
+	solopts := solverOptions(bbopts)
+	relax := func(Gb, hb *matrix.FloatMatrix) (*Solution, error) {
+		Gn, _ := matrix.FloatMatrixCombined(matrix.StackDown, G, Gb)
+		hn, _ := matrix.FloatMatrixCombined(matrix.StackDown, h, hb)
+		if Gn.Rows() == 0 {
+			// Lp needs at least one inequality; 0*x <= 1 is always satisfied.
+			Gn = matrix.FloatZeros(1, n)
+			hn = matrix.FloatOnes(1, 1)
+		}
+		return Lp(c, Gn, hn, A, b, solopts, nil, nil)
+	}
+	return branchBound(n, c, integer, relax, bbopts)
+}
+
+//    Solves the mixed integer quadratic program
+//
+//        minimize    (1/2)*x'*P*x + q'*x
+//        subject to  G*x <= h
+//                    A*x = b
+//                    x[k] integer for k in integer
+//
+//    by branch-and-bound with Qp relaxations. P must be positive
+//    semidefinite. Arguments are as for Qp and integer lists the indexes of
+//    the integer variables. See BranchBoundOptions for the options.
+//
+func MixedIntegerQp(P, q, G, h, A, b *matrix.FloatMatrix, integer []int, bbopts *BranchBoundOptions) (*MipSolution, error) {
+	if q == nil || q.Cols() != 1 {
+		return nil, errors.New("'q' must a column matrix")
+	}
+	n := q.Rows()
+	if G == nil {
+		G = matrix.FloatZeros(0, n)
+	}
+	if h == nil {
+		h = matrix.FloatZeros(0, 1)
+	}
+	if G.Cols() != n || !h.SizeMatch(G.Rows(), 1) {
+		return nil, errors.New(fmt.Sprintf("'G' must have %d columns and 'h' size (%d,1)", n, G.Rows()))
+	}
+	solopts := solverOptions(bbopts)
+	relax := func(Gb, hb *matrix.FloatMatrix) (*Solution, error) {
+		Gn, _ := matrix.FloatMatrixCombined(matrix.StackDown, G, Gb)
+		hn, _ := matrix.FloatMatrixCombined(matrix.StackDown, h, hb)
+		return Qp(P, q, Gn, hn, A, b, solopts, nil)
+	}
+	return branchBound(n, nil, integer, relax, bbopts)
+}
+
+func solverOptions(bbopts *BranchBoundOptions) *SolverOptions {
+	if bbopts == nil || bbopts.Solver == nil {
+		return &SolverOptions{MaxIter: MAXITERS}
+	}
+	return bbopts.Solver
+}
+
+//    Branch-and-bound over the integer variables.
+//
+//    Each node carries bounds lo <= x[k] <= up for the integer variables,
+//    added as rows of G to the relaxation. A node is pruned if its relaxation
+//    is infeasible or its bound is not below the incumbent objective minus
+//    AbsGap. Otherwise, if the relaxed solution is integral within IntTol it
+//    becomes the new incumbent, else the most fractional variable x[k] = v is
+//    branched on with x[k] <= floor(v) and x[k] >= ceil(v).
+//
+//    Nodes are taken from a shared queue by Workers goroutines in the order
+//    given by Selection. With several workers the order in which nodes are
+//    solved, and the incumbent among equally good solutions, may vary
+//    between runs.
+//
+//    If c is not nil the objective of a node is c'*x, otherwise the primal
+//    objective of the relaxation solution.
+//
+func branchBound(n int, c *matrix.FloatMatrix, integer []int, relax relaxation, bbopts *BranchBoundOptions) (*MipSolution, error) {
+
+	for _, k := range integer {
+		if k < 0 || k >= n {
+			return nil, errors.New(fmt.Sprintf("integer variable index %d out of range", k))
+		}
+	}
+	opts := BranchBoundOptions{}
+	if bbopts != nil {
+		opts = *bbopts
+	}
+	if opts.Selection == 0 {
+		opts.Selection = BestBound
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.IntTol <= 0.0 {
+		opts.IntTol = MIP_INTTOL
+	}
+	if opts.AbsGap <= 0.0 {
+		opts.AbsGap = MIP_ABSGAP
+	}
+
+	ni := len(integer)
+	mip := &MipSolution{Status: Unknown, Objective: math.Inf(1), Bound: math.Inf(-1)}
+	queue := &bbQueue{bestBound: opts.Selection == BestBound}
+	root := &bbNode{lo: make([]float64, ni), up: make([]float64, ni), bound: math.Inf(-1)}
+	for k := range root.lo {
+		root.lo[k] = math.Inf(-1)
+		root.up[k] = math.Inf(1)
+	}
+	heap.Push(queue, root)
+
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	active := 0
+	seq := 1
+	stopped := false
+	var solveErr error
+	// smallest bound of the nodes not explored
+	openBound := math.Inf(1)
+
+	// the callback is called without holding mu
+	var cbmu sync.Mutex
+	reported := math.Inf(1)
+	halted := false
+	report := func(x *matrix.FloatMatrix, obj float64) bool {
+		cbmu.Lock()
+		defer cbmu.Unlock()
+		if halted || obj >= reported {
+			return !halted
+		}
+		reported = obj
+		halted = !opts.Incumbent(x, obj)
+		return !halted
+	}
+
+	worker := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for {
+			for queue.Len() == 0 && active > 0 && !stopped {
+				cond.Wait()
+			}
+			if stopped || queue.Len() == 0 {
+				cond.Broadcast()
+				return
+			}
+			if opts.MaxNodes > 0 && mip.Nodes >= opts.MaxNodes {
+				stopped = true
+				cond.Broadcast()
+				return
+			}
+			node := heap.Pop(queue).(*bbNode)
+			if node.bound >= mip.Objective-opts.AbsGap {
+				continue
+			}
+			mip.Nodes++
+			active++
+			mu.Unlock()
+
+			sol, obj, err := solveNode(n, c, integer, node, relax)
+
+			mu.Lock()
+			active--
+			switch {
+			case err != nil:
+				solveErr = err
+				stopped = true
+			case sol == nil:
+				// infeasible
+			case sol.Status != Optimal:
+				mip.Failed++
+				openBound = math.Min(openBound, node.bound)
+			case obj >= mip.Objective-opts.AbsGap:
+				// pruned by bound
+			default:
+				k, v := mostFractional(sol.X, integer, opts.IntTol)
+				if k < 0 {
+					mip.X = sol.X.Copy()
+					mip.Objective = obj
+					if opts.Incumbent != nil {
+						x := mip.X.Copy()
+						mu.Unlock()
+						ok := report(x, obj)
+						mu.Lock()
+						if !ok {
+							stopped = true
+						}
+					}
+					break
+				}
+				down := &bbNode{lo: node.lo, up: append([]float64(nil), node.up...),
+					bound: obj, depth: node.depth + 1, seq: seq}
+				down.up[k] = math.Floor(v)
+				up := &bbNode{lo: append([]float64(nil), node.lo...), up: node.up,
+					bound: obj, depth: node.depth + 1, seq: seq + 1}
+				up.lo[k] = math.Ceil(v)
+				seq += 2
+				heap.Push(queue, down)
+				heap.Push(queue, up)
+			}
+			cond.Broadcast()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker()
+		}()
+	}
+	wg.Wait()
+
+	if solveErr != nil {
+		return mip, solveErr
+	}
+	for _, node := range queue.nodes {
+		if node.bound < mip.Objective-opts.AbsGap {
+			openBound = math.Min(openBound, node.bound)
+		}
+	}
+	switch {
+	case !math.IsInf(openBound, 1):
+		mip.Status = Unknown
+		mip.Bound = math.Min(openBound, mip.Objective)
+	case mip.X == nil:
+		mip.Status = PrimalInfeasible
+		mip.Bound = math.Inf(1)
+	default:
+		mip.Status = Optimal
+		mip.Bound = mip.Objective
+	}
+	return mip, nil
+}
+
+// Solves the relaxation of a node. Returns nil solution if the relaxation
+// is infeasible.
+func solveNode(n int, c *matrix.FloatMatrix, integer []int, node *bbNode, relax relaxation) (*Solution, float64, error) {
+	rows := 0
+	for k := range integer {
+		if !math.IsInf(node.lo[k], -1) {
+			rows++
+		}
+		if !math.IsInf(node.up[k], 1) {
+			rows++
+		}
+		if node.lo[k] > node.up[k] {
+			return nil, 0.0, nil
+		}
+	}
+	Gb := matrix.FloatZeros(rows, n)
+	hb := matrix.FloatZeros(rows, 1)
+	i := 0
+	for k, j := range integer {
+		if !math.IsInf(node.up[k], 1) {
+			Gb.SetAt(i, j, 1.0)
+			hb.SetIndex(i, node.up[k])
+			i++
+		}
+		if !math.IsInf(node.lo[k], -1) {
+			Gb.SetAt(i, j, -1.0)
+			hb.SetIndex(i, -node.lo[k])
+			i++
+		}
+	}
+	sol, err := relax(Gb, hb)
+	if sol == nil && err == nil {
+		err = errors.New("relaxation solver failed")
+	}
+	// Lp and Qp return an error with the certificate of an infeasible
+	// node; any other error is an error of the search.
+	if err != nil && (sol == nil || (sol.Status != Optimal && sol.Status != PrimalInfeasible)) {
+		return nil, 0.0, err
+	}
+	switch sol.Status {
+	case PrimalInfeasible:
+		return nil, 0.0, nil
+	case DualInfeasible:
+		return nil, 0.0, errors.New("relaxation is unbounded")
+	case Optimal:
+		if c != nil {
+			return sol, vecDot(c, sol.X), nil
+		}
+		return sol, sol.PrimalObjective, nil
+	}
+	return sol, 0.0, nil
+}
+
+// Returns the integer variable with fractional part closest to 1/2 and its
+// value, or -1 if all are integral within tol.
+func mostFractional(x *matrix.FloatMatrix, integer []int, tol float64) (int, float64) {
+	best, bestk, v := tol, -1, 0.0
+	for k, j := range integer {
+		xj := x.GetIndex(j)
+		f := math.Min(xj-math.Floor(xj), math.Ceil(xj)-xj)
+		if f > best {
+			best, bestk, v = f, k, xj
+		}
+	}
+	return bestk, v
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,162 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"container/heap"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 0-1 knapsack: maximize 10*x0 + 13*x1 + 7*x2 + 8*x3 subject to
+// 3*x0 + 4*x1 + 2*x2 + 3*x3 <= 7. The optimum is x = (1, 1, 0, 0) with value
+// 23; the root relaxation has x1 = 1/2 and value 23.5.
+func makeKnapsack() (c, G, h *matrix.FloatMatrix, integer []int) {
+	n := 4
+	c = matrix.FloatVector([]float64{-10.0, -13.0, -7.0, -8.0})
+	G = matrix.FloatZeros(1+2*n, n)
+	h = matrix.FloatZeros(1+2*n, 1)
+	for j, w := range []float64{3.0, 4.0, 2.0, 3.0} {
+		G.SetAt(0, j, w)
+		G.SetAt(1+j, j, -1.0)
+		G.SetAt(1+n+j, j, 1.0)
+		h.SetIndex(1+n+j, 1.0)
+	}
+	h.SetIndex(0, 7.0)
+	integer = []int{0, 1, 2, 3}
+	return
+}
+
+func checkKnapsack(t *testing.T, name string, mip *MipSolution, err error) {
+	if err != nil {
+		t.Fatalf("%s: %s\n", name, err)
+	}
+	if mip.Status != Optimal || mip.X == nil {
+		t.Fatalf("%s: status %v\n", name, mip.Status)
+	}
+	if math.Abs(mip.Objective+23.0) > 1e-6 || math.Abs(mip.Bound+23.0) > 1e-6 {
+		t.Errorf("%s: objective %v, bound %v\n", name, mip.Objective, mip.Bound)
+	}
+	if e := maxDiff(mip.X, matrix.FloatVector([]float64{1.0, 1.0, 0.0, 0.0})); e > 1e-6 {
+		t.Errorf("%s: x = %v\n", name, mip.X.FloatArray())
+	}
+}
+
+func TestMilpKnapsack(t *testing.T) {
+	c, G, h, integer := makeKnapsack()
+	for _, sel := range []NodeSelection{BestBound, DepthFirst} {
+		for _, workers := range []int{1, 4} {
+			bbopts := &BranchBoundOptions{Selection: sel, Workers: workers}
+			mip, err := MixedIntegerLp(c, G, h, nil, nil, integer, bbopts)
+			checkKnapsack(t, "knapsack", mip, err)
+			if mip.Nodes < 3 {
+				t.Errorf("selection %d, %d workers: %d nodes\n", sel, workers, mip.Nodes)
+			}
+		}
+	}
+}
+
+// Nodes come out of the queue by bound or by depth.
+func TestMilpSelection(t *testing.T) {
+	nodes := []*bbNode{
+		&bbNode{bound: -1.0, depth: 1, seq: 1},
+		&bbNode{bound: -3.0, depth: 1, seq: 2},
+		&bbNode{bound: -2.0, depth: 3, seq: 3},
+		&bbNode{bound: -2.0, depth: 2, seq: 4}}
+	for _, tc := range []struct {
+		bestBound bool
+		order     []int
+	}{{true, []int{2, 3, 4, 1}}, {false, []int{3, 4, 2, 1}}} {
+		q := &bbQueue{bestBound: tc.bestBound}
+		for _, node := range nodes {
+			heap.Push(q, node)
+		}
+		for _, s := range tc.order {
+			if node := heap.Pop(q).(*bbNode); node.seq != s {
+				t.Errorf("best bound %v: node %d, expected %d\n", tc.bestBound, node.seq, s)
+			}
+		}
+	}
+}
+
+// The node limit stops the search with the bound of the open nodes.
+func TestMilpNodeLimit(t *testing.T) {
+	c, G, h, integer := makeKnapsack()
+	mip, err := MixedIntegerLp(c, G, h, nil, nil, integer, &BranchBoundOptions{MaxNodes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mip.Status != Unknown || mip.Nodes != 1 || mip.X != nil {
+		t.Fatalf("status %v, %d nodes\n", mip.Status, mip.Nodes)
+	}
+	if math.Abs(mip.Bound+23.5) > 1e-6 {
+		t.Errorf("bound %v, expected -23.5\n", mip.Bound)
+	}
+}
+
+// The callback sees decreasing objectives ending at the optimum, may run
+// concurrently with the search, and stops the search when it returns false.
+func TestMilpIncumbent(t *testing.T) {
+	c, G, h, integer := makeKnapsack()
+	for _, workers := range []int{1, 4} {
+		var mu sync.Mutex
+		objs := make([]float64, 0)
+		bbopts := &BranchBoundOptions{Workers: workers, Selection: DepthFirst,
+			Incumbent: func(x *matrix.FloatMatrix, obj float64) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				if math.Abs(vecDot(c, x)-obj) > 1e-6 {
+					t.Errorf("objective %v of incumbent %v\n", obj, x.FloatArray())
+				}
+				objs = append(objs, obj)
+				return true
+			}}
+		mip, err := MixedIntegerLp(c, G, h, nil, nil, integer, bbopts)
+		checkKnapsack(t, "incumbent", mip, err)
+		if len(objs) == 0 || math.Abs(objs[len(objs)-1]+23.0) > 1e-6 {
+			t.Errorf("%d workers: incumbents %v\n", workers, objs)
+		}
+		for k := 1; k < len(objs); k++ {
+			if objs[k] >= objs[k-1] {
+				t.Errorf("%d workers: incumbents %v not decreasing\n", workers, objs)
+			}
+		}
+
+		calls := 0
+		bbopts.Incumbent = func(x *matrix.FloatMatrix, obj float64) bool {
+			calls++
+			return false
+		}
+		mip, err = MixedIntegerLp(c, G, h, nil, nil, integer, bbopts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls != 1 || mip.X == nil {
+			t.Errorf("%d workers: %d calls after stop\n", workers, calls)
+		}
+		if mip.Status == Optimal && mip.Objective > -23.0+1e-6 {
+			t.Errorf("%d workers: stopped search claims optimality\n", workers)
+		}
+	}
+}
+
+// Errors of the relaxation solver are returned.
+func TestMilpRelaxError(t *testing.T) {
+	c, G, h, integer := makeKnapsack()
+	_, err := MixedIntegerLp(c, G, h, nil, nil, integer,
+		&BranchBoundOptions{Solver: &SolverOptions{KKTSolverName: "nosuchsolver"}})
+	if err == nil || !strings.Contains(err.Error(), "not known") {
+		t.Errorf("relaxation error not returned: %v\n", err)
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -47,6 +47,34 @@ func minvec(vec []float64) float64 {
 	return res
 }
 
+// Returns the indexes of the unit entries of the identity element e of the
+// cone dims in the 'l' and 'q' blocks and, if sdiag is set, also on the
+// diagonals of the 's' blocks. Computed once for the iteration loops.
+func identityIndexes(dims *DimensionSet, sdiag bool) []int {
+	n := dims.At("l")[0] + len(dims.At("q"))
+	if sdiag {
+		n += dims.Sum("s")
+	}
+	is := make([]int, 0, n)
+	ind := dims.At("l")[0]
+	for k := 0; k < ind; k++ {
+		is = append(is, k)
+	}
+	for _, m := range dims.At("q") {
+		is = append(is, ind)
+		ind += m
+	}
+	if sdiag {
+		for _, m := range dims.At("s") {
+			for k := 0; k < m; k++ {
+				is = append(is, ind+k*(m+1))
+			}
+			ind += m*m
+		}
+	}
+	return is
+}
+
 /*
     Applies Nesterov-Todd scaling or its inverse.
     
@@ -57,26 +85,21 @@ func minvec(vec []float64) float64 {
          x := W^{-1}*x   (trans is false 'N', inverse = true  'T')  
          x := W^{-T}*x   (trans is true  'T', inverse = true  'T'). 
     
-    x is a dense float matrix.
-    
-    W is a MatrixSet with entries:
-    
-    - W['dnl']: positive vector
-    - W['dnli']: componentwise inverse of W['dnl']
-    - W['d']: positive vector
-    - W['di']: componentwise inverse of W['d']
-    - W['v']: lists of 2nd order cone vectors with unit hyperbolic norms
-    - W['beta']: list of positive numbers
-    - W['r']: list of square matrices 
-    - W['rti']: list of square matrices.  rti[k] is the inverse transpose
-      of r[k].
-    
-    The 'dnl' and 'dnli' entries are optional, and only present when the 
-    function is called from the nonlinear solver.
+    x is a dense float matrix; each column of x is scaled. The 's'
+    components of x are in unpacked storage and only their lower
+    triangles are referenced.
+
+    W is the scaling computed by ComputeScaling. The Dnl and Dnli blocks
+    are only present when the function is called from the nonlinear
+    solver. The 'q' and 's' blocks are scaled concurrently if W was
+    computed with more than one worker.
+
+    Scale and UpdateScaling keep their work matrices and the arguments of
+    the call in W. They are not reentrant on a shared W: concurrent calls
+    with the same W must be serialized by the caller.
 */
-func scale(x *matrix.FloatMatrix, W *FloatMatrixSet, trans, inverse bool) (err error) {
+func Scale(x *matrix.FloatMatrix, W *Scaling, trans, inverse bool) (err error) {
 	/*DEBUGGED*/
-	var wl []*matrix.FloatMatrix
 	var w *matrix.FloatMatrix
 	ind := 0
 	err = nil
@@ -85,11 +108,11 @@ func scale(x *matrix.FloatMatrix, W *FloatMatrixSet, trans, inverse bool) (err e
     // scaling is xk ./ dnl = dnli .* xk, where dnl = W['dnl'], 
     // dnli = W['dnli'].
 
-	if wl = W.At("dnl"); wl != nil {
+	if W.Dnl != nil {
 		if inverse {
-			w = W.At("dnli")[0]
+			w = W.Dnli
 		} else {
-			w = W.At("dnl")[0]
+			w = W.Dnl
 		}
 		for k := 0; k < x.Cols(); k++ {
 			err = blas.TbmvFloat(w, x, &la_.IOpt{"n", w.Rows()}, &la_.IOpt{"k", 0},
@@ -103,9 +126,9 @@ func scale(x *matrix.FloatMatrix, W *FloatMatrixSet, trans, inverse bool) (err e
     // scaling is xk ./ d = di .* xk, where d = W['d'], di = W['di'].
 
 	if inverse {
-		w = W.At("di")[0]
+		w = W.Di
 	} else {
-		w = W.At("d")[0]
+		w = W.D
 	}
 	
 	for k := 0; k < x.Cols(); k++ {
@@ -113,120 +136,126 @@ func scale(x *matrix.FloatMatrix, W *FloatMatrixSet, trans, inverse bool) (err e
 			&la_.IOpt{"lda", 1}, &la_.IOpt{"offsetx", k*x.Rows()+ind})
 		if err != nil { return }
 	}
-	ind += w.Rows()
 		
     // Scaling for 'q' component is 
     //
     //    xk := beta * (2*v*v' - J) * xk
     //        = beta * (2*v*(xk'*v)' - J*xk)
     //
-    // where beta = W['beta'][k], v = W['v'][k], J = [1, 0; 0, -I].
+    // where beta = W.Beta[k], v = W.V[k], J = [1, 0; 0, -I].
     //
     //Inverse scaling is
     //
     //    xk := 1/beta * (2*J*v*v'*J - J) * xk
     //        = 1/beta * (-J) * (2*v*((-J*xk)'*v)' + xk). 
-	//wf := matrix.FloatZeros(x.Cols(), 1)
-	w = matrix.FloatZeros(x.Cols(), 1)
-	for k, v := range W.At("v") {
-		m := v.Rows()
-		if inverse {
-			blas.ScalFloat(x, -1.0,	&la_.IOpt{"offset", ind}, &la_.IOpt{"inc", x.Rows()})
-		}
-		err = blas.GemvFloat(x, v, w, 1.0, 0.0, la_.OptTrans, &la_.IOpt{"m", m},
-			&la_.IOpt{"n", x.Cols()}, &la_.IOpt{"offsetA", ind},
-			&la_.IOpt{"lda", x.Rows()})
-		if err != nil { return }
-
-		err = blas.ScalFloat(x, -1.0, &la_.IOpt{"offset", ind}, &la_.IOpt{"inc", x.Rows()})
-		if err != nil { return }
-
-		err = blas.GerFloat(v, w, x, 2.0, &la_.IOpt{"m", m},
-			&la_.IOpt{"n", x.Cols()}, &la_.IOpt{"lda", x.Rows()},
-			&la_.IOpt{"offsetA", ind})
-		if err != nil { return }
-
-		var a float64
-		if inverse {
-			blas.ScalFloat(x, -1.0,
-				&la_.IOpt{"offset", ind}, &la_.IOpt{"inc", x.Rows()})
-			// a[i,j] := 1.0/W[i,j]
-			a = 1.0 / W.At("beta")[0].GetIndex(k)
-		} else {
-			a = W.At("beta")[0].GetIndex(k)
-		}
-		for i := 0; i < x.Cols(); i++ {
-			blas.ScalFloat(x, a, &la_.IOpt{"n", m}, &la_.IOpt{"offset", ind + i*x.Rows()})
-		}
-		ind += m
-	}
+	workers := W.prepareWork()
+	W.x, W.trans, W.inverse = x, trans, inverse
+	err = forBlocks(len(W.V), workers, W.scaleq)
+	if err != nil { return }
 
     // Scaling for 's' component xk is
     //
     //     xk := vec( r' * mat(xk) * r )  if trans = 'N'
     //     xk := vec( r * mat(xk) * r' )  if trans = 'T'.
     //
-    // r is kth element of W['r'].
+    // r is kth element of W.R.
     //
     // Inverse scaling is
     //
     //     xk := vec( rti * mat(xk) * rti' )  if trans = 'N'
     //     xk := vec( rti' * mat(xk) * rti )  if trans = 'T'.
     //
-    // rti is kth element of W['rti'].
-	maxn := 0
-	for _, r := range W.At("r") {
-		if r.Rows() > maxn {
-			maxn = r.Rows()
-		}
+    // rti is kth element of W.Rti.
+	err = forBlocks(len(W.R), workers, W.scales)
+	return 
+}
+
+// Scales the 'q' block k of W.x, see Scale.
+func (W *Scaling) scaleQ(k, wk int) (err error) {
+	x, inverse := W.x, W.inverse
+	v := W.V[k]
+	m := v.Rows()
+	ind := W.indq[k]
+	w := W.vecWork(wk, x.Cols())
+	if inverse {
+		blas.ScalFloat(x, -1.0,	&la_.IOpt{"offset", ind}, &la_.IOpt{"inc", x.Rows()})
 	}
-	a := matrix.FloatZeros(maxn, maxn)
-	for k, v := range W.At("r") {
-		t := trans
-		var r *matrix.FloatMatrix
-		if ! inverse {
-			r = v
-			t = ! trans
-		} else {
-			r = W.At("rti")[k]
-		}
+	err = blas.GemvFloat(x, v, w, 1.0, 0.0, la_.OptTrans, &la_.IOpt{"m", m},
+		&la_.IOpt{"n", x.Cols()}, &la_.IOpt{"offsetA", ind},
+		&la_.IOpt{"lda", x.Rows()})
+	if err != nil { return }
 
-		n := r.Rows()
-		for i := 0; i < x.Cols(); i++ {
-			// scale diagonal of xk by 0.5
-			blas.ScalFloat(x, 0.5, &la_.IOpt{"offset", ind+i*x.Rows()},
-				&la_.IOpt{"inc", n+1}, &la_.IOpt{"n", n})
+	err = blas.ScalFloat(x, -1.0, &la_.IOpt{"offset", ind}, &la_.IOpt{"inc", x.Rows()})
+	if err != nil { return }
+
+	err = blas.GerFloat(v, w, x, 2.0, &la_.IOpt{"m", m},
+		&la_.IOpt{"n", x.Cols()}, &la_.IOpt{"lda", x.Rows()},
+		&la_.IOpt{"offsetA", ind})
+	if err != nil { return }
+
+	var a float64
+	if inverse {
+		blas.ScalFloat(x, -1.0,
+			&la_.IOpt{"offset", ind}, &la_.IOpt{"inc", x.Rows()})
+		// a[i,j] := 1.0/W[i,j]
+		a = 1.0 / W.Beta[k]
+	} else {
+		a = W.Beta[k]
+	}
+	for i := 0; i < x.Cols(); i++ {
+		blas.ScalFloat(x, a, &la_.IOpt{"n", m}, &la_.IOpt{"offset", ind + i*x.Rows()})
+	}
+	return
+}
+
+// Scales the 's' block k of W.x, see Scale.
+func (W *Scaling) scaleS(k, wk int) (err error) {
+	x, trans, inverse := W.x, W.trans, W.inverse
+	a := W.matWork(wk, W.maxr)
+	ind := W.inds[k]
+	t := trans
+	var r *matrix.FloatMatrix
+	if ! inverse {
+		r = W.R[k]
+		t = ! trans
+	} else {
+		r = W.Rti[k]
+	}
+
+	n := r.Rows()
+	for i := 0; i < x.Cols(); i++ {
+		// scale diagonal of xk by 0.5
+		blas.ScalFloat(x, 0.5, &la_.IOpt{"offset", ind+i*x.Rows()},
+			&la_.IOpt{"inc", n+1}, &la_.IOpt{"n", n})
 
             // a = r*tril(x) (t is 'N') or a = tril(x)*r  (t is 'T')
-			blas.Copy(r, a)
-			if ! t {
-				err = blas.TrmmFloat(x, a, 1.0, la_.OptRight, &la_.IOpt{"m", n},
-					&la_.IOpt{"n", n}, &la_.IOpt{"lda", n}, &la_.IOpt{"ldb", n},
-					&la_.IOpt{"offsetA", ind+i*x.Rows()})
-				if err != nil { return }
-
-				// x := (r*a' + a*r')  if t is 'N'
-				err = blas.Syr2kFloat(r, a, x, 1.0, 0.0, la_.OptNoTrans, &la_.IOpt{"n", n},
-					&la_.IOpt{"k", n}, &la_.IOpt{"ldb", n}, &la_.IOpt{"ldc", n},
-					&la_.IOpt{"offsetC", ind+i*x.Rows()})
-				if err != nil { return }
-
-			} else {
-				err = blas.TrmmFloat(x, a, 1.0, la_.OptLeft, &la_.IOpt{"m", n},
-					&la_.IOpt{"n", n}, &la_.IOpt{"lda", n}, &la_.IOpt{"ldb", n},
-					&la_.IOpt{"offsetA", ind+i*x.Rows()})
-				if err != nil { return }
-
-				// x := (r'*a + a'*r)  if t is 'T'
-				err = blas.Syr2kFloat(r, a, x, 1.0, 0.0, la_.OptTrans, &la_.IOpt{"n", n},
-					&la_.IOpt{"k", n}, &la_.IOpt{"ldb", n}, &la_.IOpt{"ldc", n},
-					&la_.IOpt{"offsetC", ind+i*x.Rows()})
-				if err != nil { return }
-			}
+		blas.Copy(r, a)
+		if ! t {
+			err = blas.TrmmFloat(x, a, 1.0, la_.OptRight, &la_.IOpt{"m", n},
+				&la_.IOpt{"n", n}, &la_.IOpt{"lda", n}, &la_.IOpt{"ldb", n},
+				&la_.IOpt{"offsetA", ind+i*x.Rows()})
+			if err != nil { return }
+
+			// x := (r*a' + a*r')  if t is 'N'
+			err = blas.Syr2kFloat(r, a, x, 1.0, 0.0, la_.OptNoTrans, &la_.IOpt{"n", n},
+				&la_.IOpt{"k", n}, &la_.IOpt{"ldb", n}, &la_.IOpt{"ldc", n},
+				&la_.IOpt{"offsetC", ind+i*x.Rows()})
+			if err != nil { return }
+
+		} else {
+			err = blas.TrmmFloat(x, a, 1.0, la_.OptLeft, &la_.IOpt{"m", n},
+				&la_.IOpt{"n", n}, &la_.IOpt{"lda", n}, &la_.IOpt{"ldb", n},
+				&la_.IOpt{"offsetA", ind+i*x.Rows()})
+			if err != nil { return }
+
+			// x := (r'*a + a'*r)  if t is 'T'
+			err = blas.Syr2kFloat(r, a, x, 1.0, 0.0, la_.OptTrans, &la_.IOpt{"n", n},
+				&la_.IOpt{"k", n}, &la_.IOpt{"ldb", n}, &la_.IOpt{"ldc", n},
+				&la_.IOpt{"offsetC", ind+i*x.Rows()})
+			if err != nil { return }
 		}
-		ind += n*n
 	}
-	return 
+	return
 }
 
 /*
@@ -267,7 +296,7 @@ func scale2(lmbda, x *matrix.FloatMatrix, dims *DimensionSet, mnl int, inverse b
     // a = sqrt(lambda_k' * J * lambda_k), l = lambda_k / a.
 	for _, m := range dims.At("q") {
 		var lx, a, c, x0 float64
-		a = jnrm2(lmbda, m, ind) //&la_.IOpt{"n", m}, &la_.IOpt{"offset", ind})
+		a = Jnrm2(lmbda, m, ind) //&la_.IOpt{"n", m}, &la_.IOpt{"offset", ind})
 		if ! inverse {
 			lx = jdot(lmbda, x, m, ind, ind) //&la_.IOpt{"n", m}, &la_.IOpt{"offsetx", ind},
 				//&la_.IOpt{"offsety", ind})
@@ -300,21 +329,17 @@ func scale2(lmbda, x *matrix.FloatMatrix, dims *DimensionSet, mnl int, inverse b
     // We scale upper and lower triangular part of mat(xk) because the
     // inverse operation will be applied to nonsymmetric matrices.
 	ind2 := ind
-	sdims := dims.At("s")
-	for k := 0; k < len(sdims); k++ {
-		m := sdims[k]
-		scaleF := func(v, x float64)float64 {
-			return math.Sqrt(v)*math.Sqrt(x)
-		}
+	xa, la := x.FloatArray(), lmbda.FloatArray()
+	for _, m := range dims.At("s") {
 		for j := 0; j < m; j++ {
-			c := matrix.FloatVector(lmbda.FloatArray()[ind2:ind2+m])
-			c.ApplyConst(c, scaleF, lmbda.GetIndex(ind2+j))
-			if ! inverse {
-				blas.Tbsv(c, x, &la_.IOpt{"n", m}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1}, 
-					&la_.IOpt{"offsetx", ind+j*m})
-			} else {
-				blas.Tbmv(c, x, &la_.IOpt{"n", m}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1}, 
-					&la_.IOpt{"offsetx", ind+j*m})
+			lj := math.Sqrt(la[ind2+j])
+			for i := 0; i < m; i++ {
+				c := math.Sqrt(la[ind2+i]) * lj
+				if ! inverse {
+					xa[ind+j*m+i] /= c
+				} else {
+					xa[ind+j*m+i] *= c
+				}
 			}
 		}
 		ind += m*m
@@ -339,9 +364,8 @@ func scale2(lmbda, x *matrix.FloatMatrix, dims *DimensionSet, mnl int, inverse b
 
  */
 
-func updateScaling(W *FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err error) {
+func UpdateScaling(W *Scaling, lmbda, s, z *matrix.FloatMatrix) (err error) {
 	err = nil
-	var stmp, ztmp *matrix.FloatMatrix
 	/*
      Nonlinear and 'l' blocks
     
@@ -349,37 +373,34 @@ func updateScaling(W *FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err erro
         lmbda := lmbda .* sqrt(s) .* sqrt(z)
 	 */
 	mnl := 0
-	dnlset := W.At("dnl")
-	dnliset := W.At("dnli")
-	dset := W.At("d")
-	diset := W.At("di")
-	beta := W.At("beta")[0]
-	if dnlset != nil && dnlset[0].NumElements() > 0 {
-		mnl = dnlset[0].NumElements()
+	if W.Dnl != nil {
+		mnl = W.Dnl.NumElements()
 	}
-	ml := dset[0].NumElements()
+	ml := W.D.NumElements()
 	m := mnl + ml
 	//fmt.Printf("ml=%d, mnl=%d, m=%d'n", ml, mnl, m)
 
-	stmp = matrix.FloatVector(s.FloatArray()[:m])
-	stmp.Apply(stmp, math.Sqrt)
-	s.SetIndexes(matrix.MakeIndexSet(0, m, 1), stmp.FloatArray())
-
-	ztmp = matrix.FloatVector(z.FloatArray()[:m])
-	ztmp.Apply(ztmp, math.Sqrt)
-	z.SetIndexes(matrix.MakeIndexSet(0, m, 1), ztmp.FloatArray())
+	sa, za := s.FloatArray(), z.FloatArray()
+	for i := 0; i < m; i++ {
+		sa[i] = math.Sqrt(sa[i])
+		za[i] = math.Sqrt(za[i])
+	}
 
     // d := d .* s .* z 
-	if len(dnlset) > 0 {
-		blas.TbmvFloat(s, dnlset[0], &la_.IOpt{"n", mnl}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
-		blas.TbsvFloat(z, dnlset[0], &la_.IOpt{"n", mnl}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
-		dnliset[0].Apply(dnlset[0], func(a float64)float64 { return 1.0/a})
+	if mnl > 0 {
+		blas.TbmvFloat(s, W.Dnl, &la_.IOpt{"n", mnl}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
+		blas.TbsvFloat(z, W.Dnl, &la_.IOpt{"n", mnl}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
+		for i, d := range W.Dnl.FloatArray() {
+			W.Dnli.SetIndex(i, 1.0/d)
+		}
 	}
-	blas.TbmvFloat(s, dset[0], &la_.IOpt{"n", ml},
+	blas.TbmvFloat(s, W.D, &la_.IOpt{"n", ml},
 		&la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1}, &la_.IOpt{"offseta", mnl})
-	blas.TbsvFloat(z, dset[0], &la_.IOpt{"n", ml},
+	blas.TbsvFloat(z, W.D, &la_.IOpt{"n", ml},
 		&la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1}, &la_.IOpt{"offseta", mnl})
-	diset[0].Apply(dset[0], func(a float64)float64 { return 1.0/a})
+	for i, d := range W.D.FloatArray() {
+		W.Di.SetIndex(i, 1.0/d)
+	}
 
     // lmbda := s .* z
 	blas.CopyFloat(s, lmbda, &la_.IOpt{"n", m})
@@ -407,7 +428,7 @@ func updateScaling(W *FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err erro
     //
     //        wk := betak * sqrt(a/b) * (2*v[k]*v[k]' - J) * q 
     //
-    //    with betak = W['beta'][k].
+    //    with betak = W.Beta[k].
     // 
     // 3. The scaled variable:
     //
@@ -425,174 +446,176 @@ func updateScaling(W *FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err erro
     //              = 1 / sqrt(2*(wk0 + 1)) * (wk + e).
     //        beta[k] *=  sqrt(a/b)
 
-	ind := m
-	for k, v := range W.At("v") {
-		m = v.NumElements()
+	workers := W.prepareWork()
+	W.lmbda, W.s, W.z = lmbda, s, z
+	err = forBlocks(len(W.V), workers, W.updateq)
+	if err != nil { return }
+	//fmt.Printf("-- end of q:\nz=\n%v\nlmbda=\n%v\n", z.ConvertToString(), lmbda.ConvertToString())
+	//fmt.Printf("beta=\n%v\n", beta.ConvertToString())
+
+    // 's' blocks
+    // 
+    // Let st, zt be the updated variables in the old scaling:
+    // 
+    //     st = Ls * Ls', zt = Lz * Lz'.
+    //
+    // where Ls and Lz are the 's' components of s, z.
+    //
+    // 1.  SVD Lz'*Ls = Uk * lambda_k^+ * Vk'.
+    //
+    // 2.  New scaling is 
+    //
+    //         r[k] := r[k] * Ls * Vk * diag(lambda_k^+)^{-1/2}
+    //         rti[k] := r[k] * Lz * Uk * diag(lambda_k^+)^{-1/2}.
+    //
+
+	err = forBlocks(len(W.R), workers, W.updates)
+	if err != nil { return }
+
+	//fmt.Printf("-- end of s:\nz=\n%v\nlmbda=\n%v\n", z.ConvertToString(), lmbda.ConvertToString())
+
+	return
+
+}
+
+// Updates the 'q' block k of W, see UpdateScaling.
+func (W *Scaling) updateQ(k, wk int) error {
+	lmbda, s, z := W.lmbda, W.s, W.z
+	v := W.V[k]
+	m := v.NumElements()
+	ind := W.indq[k]
 
         // ln = sqrt( lambda_k' * J * lambda_k ) !! NOT USED!!
-		jnrm2(lmbda, m, ind) // ?? NOT USED ??
+	Jnrm2(lmbda, m, ind) // ?? NOT USED ??
 
         // a = sqrt( sk' * J * sk ) = sqrt( st' * J * st ) 
         // s := s / a = st / a
-		aa := jnrm2(s, m, ind)
-		blas.ScalFloat(s, 1.0/aa, &la_.IOpt{"n", m}, &la_.IOpt{"offset", ind})
+	aa := Jnrm2(s, m, ind)
+	blas.ScalFloat(s, 1.0/aa, &la_.IOpt{"n", m}, &la_.IOpt{"offset", ind})
 
         // b = sqrt( zk' * J * zk ) = sqrt( zt' * J * zt )
         // z := z / a = zt / b
-		bb := jnrm2(z, m, ind)
-		blas.ScalFloat(z, 1.0/bb, &la_.IOpt{"n", m}, &la_.IOpt{"offset", ind})
+	bb := Jnrm2(z, m, ind)
+	blas.ScalFloat(z, 1.0/bb, &la_.IOpt{"n", m}, &la_.IOpt{"offset", ind})
 
         // c = sqrt( ( 1 + (st'*zt) / (a*b) ) / 2 )
-		cc := blas.DotFloat(s, z, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"offsety", ind},
-			&la_.IOpt{"n", m})
-		cc = math.Sqrt((1.0 + cc)/2.0)
+	cc := blas.DotFloat(s, z, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"offsety", ind},
+		&la_.IOpt{"n", m})
+	cc = math.Sqrt((1.0 + cc)/2.0)
 
         // vs = v' * st / a 
-		vs := blas.DotFloat(v, s, &la_.IOpt{"offsety", ind}, &la_.IOpt{"n", m})
+	vs := blas.DotFloat(v, s, &la_.IOpt{"offsety", ind}, &la_.IOpt{"n", m})
 
-		// vz = v' * J *zt / b
-		vz := jdot(v, z, m, 0, ind)
+	// vz = v' * J *zt / b
+	vz := jdot(v, z, m, 0, ind)
 
-		// vq = v' * q where q = (st/a + J * zt/b) / (2 * c)
-		vq := (vs + vz) / 2.0/ cc
+	// vq = v' * q where q = (st/a + J * zt/b) / (2 * c)
+	vq := (vs + vz) / 2.0/ cc
 
         // vq = v' * q where q = (st/a + J * zt/b) / (2 * c)
-		vu := vs - vz
+	vu := vs - vz
         // lambda_k0 = c
-		lmbda.SetIndex(ind, cc)
+	lmbda.SetIndex(ind, cc)
 
         // wk0 = 2 * vk0 * (vk' * q) - q0 
-		wk0 := 2.0 * v.GetIndex(0)*vq - (s.GetIndex(ind) + z.GetIndex(ind))/2.0/cc
+	wk0 := 2.0 * v.GetIndex(0)*vq - (s.GetIndex(ind) + z.GetIndex(ind))/2.0/cc
 
-		// d = (v[0] * (vk' * u) - u0/2) / (wk0 + 1)
-		dd := (v.GetIndex(0)*vu - s.GetIndex(ind)/2.0 + z.GetIndex(ind)/2.0) / (wk0 + 1.0)
+	// d = (v[0] * (vk' * u) - u0/2) / (wk0 + 1)
+	dd := (v.GetIndex(0)*vu - s.GetIndex(ind)/2.0 + z.GetIndex(ind)/2.0) / (wk0 + 1.0)
 
-		// lambda_k1 = 2 * v_k1 * vk' * (-d*q + u/2) - d*q1 + u1/2
-		blas.CopyFloat(v, lmbda, &la_.IOpt{"offsetx", 1}, &la_.IOpt{"offsety", ind+1},
-			&la_.IOpt{"n", m-1})
-		blas.ScalFloat(lmbda, (2.0*(-dd*vq + 0.5*vu)),
-			&la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", ind+1}, &la_.IOpt{"n", m-1})
-		blas.AxpyFloat(s, lmbda, 0.5*(1.0 - dd/cc),
-			&la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", ind+1}, &la_.IOpt{"n", m-1})
-		blas.AxpyFloat(z, lmbda, 0.5*(1.0 + dd/cc),
-			&la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", ind+1}, &la_.IOpt{"n", m-1})
+	// lambda_k1 = 2 * v_k1 * vk' * (-d*q + u/2) - d*q1 + u1/2
+	blas.CopyFloat(v, lmbda, &la_.IOpt{"offsetx", 1}, &la_.IOpt{"offsety", ind+1},
+		&la_.IOpt{"n", m-1})
+	blas.ScalFloat(lmbda, (2.0*(-dd*vq + 0.5*vu)),
+		&la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", ind+1}, &la_.IOpt{"n", m-1})
+	blas.AxpyFloat(s, lmbda, 0.5*(1.0 - dd/cc),
+		&la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", ind+1}, &la_.IOpt{"n", m-1})
+	blas.AxpyFloat(z, lmbda, 0.5*(1.0 + dd/cc),
+		&la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", ind+1}, &la_.IOpt{"n", m-1})
 
         // Scale so that sqrt(lambda_k' * J * lambda_k) = sqrt(aa*bb).
-		blas.ScalFloat(lmbda, math.Sqrt(aa*bb), &la_.IOpt{"offset", ind}, &la_.IOpt{"n", m})
-		
+	blas.ScalFloat(lmbda, math.Sqrt(aa*bb), &la_.IOpt{"offset", ind}, &la_.IOpt{"n", m})
+	
         // v := (2*v*v' - J) * q 
         //    = 2 * (v'*q) * v' - (J* st/a + zt/b) / (2*c)
-		blas.ScalFloat(v, 2.0*vq)
-		v.SetIndex(0, v.GetIndex(0)-(s.GetIndex(ind)/2.0/cc))
-		blas.AxpyFloat(s, v, 0.5/cc, &la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", 1},
-			&la_.IOpt{"n", m-1})
-		blas.AxpyFloat(z, v, -0.5/cc, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m})
+	blas.ScalFloat(v, 2.0*vq)
+	v.SetIndex(0, v.GetIndex(0)-(s.GetIndex(ind)/2.0/cc))
+	blas.AxpyFloat(s, v, 0.5/cc, &la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", 1},
+		&la_.IOpt{"n", m-1})
+	blas.AxpyFloat(z, v, -0.5/cc, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m})
 
         // v := v^{1/2} = 1/sqrt(2 * (v0 + 1)) * (v + e)
-		v0 := v.GetIndex(0) + 1.0
-		v.SetIndex(0, v0)
-		blas.ScalFloat(v, 1.0/math.Sqrt(2.0*v0))
+	v0 := v.GetIndex(0) + 1.0
+	v.SetIndex(0, v0)
+	blas.ScalFloat(v, 1.0/math.Sqrt(2.0*v0))
 
         // beta[k] *= ( aa / bb )**1/2
-		bk := beta.GetIndex(k)
-		beta.SetIndex(k, bk*math.Sqrt(aa/bb))
-
-		ind += m
-	}
-	//fmt.Printf("-- end of q:\nz=\n%v\nlmbda=\n%v\n", z.ConvertToString(), lmbda.ConvertToString())
-	//fmt.Printf("beta=\n%v\n", beta.ConvertToString())
-
-    // 's' blocks
-    // 
-    // Let st, zt be the updated variables in the old scaling:
-    // 
-    //     st = Ls * Ls', zt = Lz * Lz'.
-    //
-    // where Ls and Lz are the 's' components of s, z.
-    //
-    // 1.  SVD Lz'*Ls = Uk * lambda_k^+ * Vk'.
-    //
-    // 2.  New scaling is 
-    //
-    //         r[k] := r[k] * Ls * Vk * diag(lambda_k^+)^{-1/2}
-    //         rti[k] := r[k] * Lz * Uk * diag(lambda_k^+)^{-1/2}.
-    //
+	W.Beta[k] *= math.Sqrt(aa/bb)
+	return nil
+}
 
-	maxr := 0
-	for _, m := range W.At("r") {
-		if m.Rows() > maxr { maxr = m.Rows() }
+// Updates the 's' block k of W, see UpdateScaling.
+func (W *Scaling) updateS(k, wk int) error {
+	lmbda, s, z := W.lmbda, W.s, W.z
+	if W.wupd[wk] == nil {
+		W.wupd[wk] = matrix.FloatZeros(W.maxr*W.maxr, 1)
 	}
-	work := matrix.FloatZeros(maxr*maxr, 1)
-	vlensum := 0
-	for _, m := range W.At("v") {
-		vlensum += m.NumElements()
-	}
-	ind = mnl + ml + vlensum
-	ind2 := ind
-	ind3 := 0
-	rset := W.At("r")
-	rtiset := W.At("rti")
-
-	for k, _ := range rset {
-		r := rset[k]
-		rti := rtiset[k]
-		m = r.Rows()
-		//fmt.Printf("m=%d, r=\n%v\nrti=\n%v\n", m, r.ConvertToString(), rti.ConvertToString())
-
-		// r := r*sk = r*Ls
-		blas.GemmFloat(r, s, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
-			&la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
-			&la_.IOpt{"offsetb", ind2})
-		//fmt.Printf("1 work=\n%v\n", work.ConvertToString())
-		blas.CopyFloat(work, r, &la_.IOpt{"n", m*m})
-		
+	work := W.wupd[wk]
+	ind, ind2 := W.indd[k], W.inds[k]
+	r := W.R[k]
+	rti := W.Rti[k]
+	m := r.Rows()
+	//fmt.Printf("m=%d, r=\n%v\nrti=\n%v\n", m, r.ConvertToString(), rti.ConvertToString())
+
+	// r := r*sk = r*Ls
+	blas.GemmFloat(r, s, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
+		&la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
+		&la_.IOpt{"offsetb", ind2})
+	//fmt.Printf("1 work=\n%v\n", work.ConvertToString())
+	blas.CopyFloat(work, r, &la_.IOpt{"n", m*m})
+	
         // rti := rti*zk = rti*Lz
-		blas.GemmFloat(rti, z, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
-			&la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
-			&la_.IOpt{"offsetb", ind2})
-		//fmt.Printf("2 work=\n%v\n", work.ConvertToString())
-		blas.CopyFloat(work, rti, &la_.IOpt{"n", m*m})
+	blas.GemmFloat(rti, z, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
+		&la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
+		&la_.IOpt{"offsetb", ind2})
+	//fmt.Printf("2 work=\n%v\n", work.ConvertToString())
+	blas.CopyFloat(work, rti, &la_.IOpt{"n", m*m})
 
         // SVD Lz'*Ls = U * lmbds^+ * V'; store U in sk and V' in zk. '
-		blas.GemmFloat(z, s, work, 1.0, 0.0, la_.OptTransA, &la_.IOpt{"m", m},
-			&la_.IOpt{"n", m}, &la_.IOpt{"k", m}, &la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m},
-			&la_.IOpt{"ldc", m}, &la_.IOpt{"offseta", ind2}, &la_.IOpt{"offsetb", ind2})
-		//fmt.Printf("3 work=\n%v\n", work.ConvertToString())
-
-		// U = s, Vt = z
-		lapack.GesvdFloat(work, lmbda, s, z, la_.OptJobuAll, la_.OptJobvtAll,
-			&la_.IOpt{"m", m}, &la_.IOpt{"n", m}, &la_.IOpt{"lda", m}, &la_.IOpt{"ldu", m},
-			&la_.IOpt{"ldvt", m}, &la_.IOpt{"offsets", ind}, &la_.IOpt{"offsetu", ind2},
-			&la_.IOpt{"offsetvt", ind2})
+	blas.GemmFloat(z, s, work, 1.0, 0.0, la_.OptTransA, &la_.IOpt{"m", m},
+		&la_.IOpt{"n", m}, &la_.IOpt{"k", m}, &la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m},
+		&la_.IOpt{"ldc", m}, &la_.IOpt{"offseta", ind2}, &la_.IOpt{"offsetb", ind2})
+	//fmt.Printf("3 work=\n%v\n", work.ConvertToString())
+
+	// U = s, Vt = z
+	err := lapack.GesvdFloat(work, lmbda, s, z, la_.OptJobuAll, la_.OptJobvtAll,
+		&la_.IOpt{"m", m}, &la_.IOpt{"n", m}, &la_.IOpt{"lda", m}, &la_.IOpt{"ldu", m},
+		&la_.IOpt{"ldvt", m}, &la_.IOpt{"offsets", ind}, &la_.IOpt{"offsetu", ind2},
+		&la_.IOpt{"offsetvt", ind2})
+	if err != nil {
+		return err
+	}
 
         // r := r*V
-		blas.GemmFloat(r, z, work, 1.0, 0.0, la_.OptTransB, &la_.IOpt{"m", m},
-			&la_.IOpt{"n", m}, &la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
-			&la_.IOpt{"offsetb", ind2})
-		//fmt.Printf("4 work=\n%v\n", work.ConvertToString())
-		blas.CopyFloat(work, r, &la_.IOpt{"n", m*m})
+	blas.GemmFloat(r, z, work, 1.0, 0.0, la_.OptTransB, &la_.IOpt{"m", m},
+		&la_.IOpt{"n", m}, &la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
+		&la_.IOpt{"offsetb", ind2})
+	//fmt.Printf("4 work=\n%v\n", work.ConvertToString())
+	blas.CopyFloat(work, r, &la_.IOpt{"n", m*m})
 
         // rti := rti*U
-		blas.GemmFloat(rti, s, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
-			&la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
-			&la_.IOpt{"offsetb", ind2})
-		//fmt.Printf("5 work=\n%v\n", work.ConvertToString())
-		blas.CopyFloat(work, rti, &la_.IOpt{"n", m*m})
-
-		for i := 0; i < m; i++ {
-			a := 1.0 / math.Sqrt(lmbda.GetIndex(ind+i))
-			blas.ScalFloat(r, a, &la_.IOpt{"n", m}, &la_.IOpt{"offset", m*i})
-			blas.ScalFloat(rti, a, &la_.IOpt{"n", m}, &la_.IOpt{"offset", m*i})
-		}
-		ind += m
-		ind2 += m*m
-		ind3 += m // !!NOT USED: ind3!!
+	blas.GemmFloat(rti, s, work, 1.0, 0.0, &la_.IOpt{"m", m}, &la_.IOpt{"n", m},
+		&la_.IOpt{"k", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
+		&la_.IOpt{"offsetb", ind2})
+	//fmt.Printf("5 work=\n%v\n", work.ConvertToString())
+	blas.CopyFloat(work, rti, &la_.IOpt{"n", m*m})
+
+	for i := 0; i < m; i++ {
+		a := 1.0 / math.Sqrt(lmbda.GetIndex(ind+i))
+		blas.ScalFloat(r, a, &la_.IOpt{"n", m}, &la_.IOpt{"offset", m*i})
+		blas.ScalFloat(rti, a, &la_.IOpt{"n", m}, &la_.IOpt{"offset", m*i})
 	}
-
-	//fmt.Printf("-- end of s:\nz=\n%v\nlmbda=\n%v\n", z.ConvertToString(), lmbda.ConvertToString())
-
-	return
-
+	return nil
 }
 
 /*
@@ -601,28 +624,29 @@ func updateScaling(W *FloatMatrixSet, lmbda, s, z *matrix.FloatMatrix) (err erro
     
         W * z = W^{-T} * s = lmbda. 
 
-    W is a MatrixSet with entries:
-    
-    - W['dnl']: positive vector
-    - W['dnli']: componentwise inverse of W['dnl']
-    - W['d']: positive vector
-    - W['di']: componentwise inverse of W['d']
-    - W['v']: lists of 2nd order cone vectors with unit hyperbolic norms
-    - W['beta']: list of positive numbers
-    - W['r']: list of square matrices 
-    - W['rti']: list of square matrices.  rti[k] is the inverse transpose
-      of r[k].
+    s and z are strictly inside the cone described by dims with mnl
+    nonlinear components first; the 's' components are in unpacked
+    storage. lmbda has length mnl + dims['l'] + sum(dims['q']) +
+    sum(dims['s']); the 's' components of the scaled variable are
+    diagonal and only the diagonals are stored.
+
+    The blocks of W are described in Scaling.
+
+    Option "workers" (la_.IOpt) sets the number of goroutines for the 'q'
+    and 's' blocks here and in UpdateScaling and Scale of the returned W.
+    The result is the same for any number of workers.
 
  */
-func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int) (W *FloatMatrixSet, err error) {
+func ComputeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int, opts ...la_.Option) (W *Scaling, err error) {
 	/*DEBUGGED*/
 	err = nil
-	W = FloatSetNew("dnl", "dnli", "d", "di", "v", "beta", "r", "rti")
+	W = &Scaling{}
+	W.workers = workerCount(opts...)
 
     // For the nonlinear block:
     //
-    //     W['dnl'] = sqrt( s[:mnl] ./ z[:mnl] )
-    //     W['dnli'] = sqrt( z[:mnl] ./ s[:mnl] )
+    //     W.Dnl = sqrt( s[:mnl] ./ z[:mnl] )
+    //     W.Dnli = sqrt( z[:mnl] ./ s[:mnl] )
     //     lambda[:mnl] = sqrt( s[:mnl] .* z[:mnl] )
 
 	var stmp, ztmp, lmd *matrix.FloatMatrix
@@ -633,8 +657,8 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
 		dnl.Apply(dnl, math.Sqrt)
 		dnli := dnl.Copy()
 		dnli.Apply(dnli, func(a float64)float64 { return 1.0/a })
-		W.Set("dnl", dnl)
-		W.Set("dnli", dnli)
+		W.Dnl = dnl
+		W.Dnli = dnli
 		lmd = stmp.Mul(ztmp)
 		lmd.Apply(lmd, math.Sqrt)
 		lmbda.SetIndexes(matrix.MakeIndexSet(0, mnl, 1), lmd.FloatArray())
@@ -646,8 +670,8 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
 
     // For the 'l' block: 
     //
-    //     W['d'] = sqrt( sk ./ zk )
-    //     W['di'] = sqrt( zk ./ sk )
+    //     W.D = sqrt( sk ./ zk )
+    //     W.Di = sqrt( zk ./ sk )
     //     lambdak = sqrt( sk .* zk )
     //
     // where sk and zk are the first dims['l'] entries of s and z.
@@ -665,8 +689,8 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
 	di.Apply(di, func(a float64)float64 { return 1.0/a })
 	//fmt.Printf("d:\n%v\n", d)
 	//fmt.Printf("di:\n%v\n", di)
-	W.Set("d", d)
-	W.Set("di", di)
+	W.D = d
+	W.Di = di
 	lmd = stmp.Mul(ztmp)
 	lmd.Apply(lmd, math.Sqrt)
 	// lmd has indexes mnl:mnl+m and length of m
@@ -674,7 +698,7 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
 	//fmt.Printf("after l:\n%v\n", lmbda)
 
 	/*
-     For the 'q' blocks, compute lists 'v', 'beta'.
+     For the 'q' blocks, compute lists V and Beta.
     
      The vector v[k] has unit hyperbolic norm: 
      
@@ -691,23 +715,22 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
     
      lambda_k is stored in lmbda[indq[k]:indq[k+1]].
 	 */
-	ind := mnl + dims.At("l")[0]
-	var beta *matrix.FloatMatrix
-
+	indq, inds, indd := blockOffsets(dims, mnl)
+	W.V = make([]*matrix.FloatMatrix, 0, len(dims.At("q")))
 	for _, k := range dims.At("q") {
-		W.Append("v", matrix.FloatZeros(k, 1))
+		W.V = append(W.V, matrix.FloatZeros(k, 1))
 	}
-	beta = matrix.FloatZeros(len(dims.At("q")), 1)
-	W.Set("beta", beta)
-	vset := W.At("v")
-	for k, m := range dims.At("q") {
-		v := vset[k]
+	W.Beta = make([]float64, len(dims.At("q")))
+	err = forBlocks(len(W.V), W.workers, func(k, wk int) error {
+		v := W.V[k]
+		m := v.NumElements()
+		ind := indq[k]
         // a = sqrt( sk' * J * sk )  where J = [1, 0; 0, -I]
-		aa := jnrm2(s, m, ind)
+		aa := Jnrm2(s, m, ind)
 		// b = sqrt( zk' * J * zk )
-		bb := jnrm2(z, m, ind)
+		bb := Jnrm2(z, m, ind)
         // beta[k] = ( a / b )**1/2
-		beta.SetIndex(k, math.Sqrt(aa/bb))
+		W.Beta[k] = math.Sqrt(aa/bb)
         // c = sqrt( (sk/a)' * (zk/b) + 1 ) / sqrt(2)    
 		c0 := blas.DotFloat(s, z, &la_.IOpt{"n", m},
 			&la_.IOpt{"offsetx", ind}, &la_.IOpt{"offsety", ind})
@@ -741,12 +764,13 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
 		blas.AxpyFloat(z, lmbda, ss, &la_.IOpt{"offsetx", ind+1},
 			&la_.IOpt{"offsety", ind+1}, &la_.IOpt{"n", m-1})
 		blas.ScalFloat(lmbda, math.Sqrt(aa*bb), &la_.IOpt{"offset", ind}, &la_.IOpt{"n", m})
-		
-		ind += m
-		//fmt.Printf("after q[%d]:\n%v\n", k, lmbda)
+		return nil
+	})
+	if err != nil {
+		return
 	}
 	/*
-     For the 's' blocks: compute two lists 'r' and 'rti'.
+     For the 's' blocks: compute two lists R and Rti.
     
          r[k]' * sk^{-1} * r[k] = diag(lambda_k)^{-1}
          r[k]' * zk * r[k] = diag(lambda_k)
@@ -763,26 +787,38 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
      
          lmbda[ dims['l'] + sum(dims['q']) : -1 ]
 	 */
+	W.R = make([]*matrix.FloatMatrix, 0, len(dims.At("s")))
+	W.Rti = make([]*matrix.FloatMatrix, 0, len(dims.At("s")))
 	for _, k := range dims.At("s") {
-		W.Append("r", matrix.FloatZeros(k, k))
-		W.Append("rti", matrix.FloatZeros(k, k))
+		W.R = append(W.R, matrix.FloatZeros(k, k))
+		W.Rti = append(W.Rti, matrix.FloatZeros(k, k))
 	}
+	// work, Ls and Lz for each worker
 	maxs := maxdim(dims.At("s"))
-	work := matrix.FloatZeros(maxs*maxs, 1)
-	Ls := matrix.FloatZeros(maxs*maxs, 1)
-	Lz := matrix.FloatZeros(maxs*maxs, 1)
-	ind2 := ind
-	for k, m := range dims.At("s") {
-		r := W.At("r")[k]
-		rti := W.At("rti")[k]
+	works := make([][3]*matrix.FloatMatrix, W.workers)
+	err = forBlocks(len(W.R), W.workers, func(k, wk int) error {
+		if works[wk][0] == nil {
+			for i := range works[wk] {
+				works[wk][i] = matrix.FloatZeros(maxs*maxs, 1)
+			}
+		}
+		work, Ls, Lz := works[wk][0], works[wk][1], works[wk][2]
+		ind, ind2 := indd[k], inds[k]
+		r := W.R[k]
+		rti := W.Rti[k]
+		m := r.Rows()
 
 		// Factor sk = Ls*Ls'; store Ls in ds[inds[k]:inds[k+1]].
 		blas.CopyFloat(s, Ls, &la_.IOpt{"offsetx", ind2}, &la_.IOpt{"n", m*m})
-		lapack.PotrfFloat(Ls, &la_.IOpt{"n", m}, &la_.IOpt{"lda", m})
+		if err := lapack.PotrfFloat(Ls, &la_.IOpt{"n", m}, &la_.IOpt{"lda", m}); err != nil {
+			return err
+		}
 
         // Factor zs[k] = Lz*Lz'; store Lz in dz[inds[k]:inds[k+1]].
 		blas.CopyFloat(z, Lz, &la_.IOpt{"offsetx", ind2}, &la_.IOpt{"n", m*m})
-		lapack.PotrfFloat(Lz, &la_.IOpt{"n", m}, &la_.IOpt{"lda", m})
+		if err := lapack.PotrfFloat(Lz, &la_.IOpt{"n", m}, &la_.IOpt{"lda", m}); err != nil {
+			return err
+		}
 
         // SVD Lz'*Ls = U*diag(lambda_k)*V'.  Keep U in work. 
 		for i := 0; i < m; i++ {
@@ -791,9 +827,12 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
 		blas.CopyFloat(Ls, work, &la_.IOpt{"n", m*m})
 		blas.TrmmFloat(Lz, work, 1.0, la_.OptTransA, &la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m},
 			&la_.IOpt{"n", m}, &la_.IOpt{"m", m})
-		lapack.GesvdFloat(work, lmbda, nil, nil,
+		err := lapack.GesvdFloat(work, lmbda, nil, nil,
 			la_.OptJobuO, &la_.IOpt{"lda", m}, &la_.IOpt{"offsetS", ind},
 			&la_.IOpt{"n", m}, &la_.IOpt{"m", m})
+		if err != nil {
+			return err
+		}
 		
 		// r = Lz^{-T} * U 
 		blas.CopyFloat(work, r, &la_.IOpt{"n", m*m})
@@ -812,14 +851,13 @@ func computeScaling(s, z, lmbda *matrix.FloatMatrix, dims *DimensionSet, mnl int
 			blas.ScalFloat(r, a, &la_.IOpt{"offset", m*i}, &la_.IOpt{"n", m})
 			blas.ScalFloat(rti, 1.0/a, &la_.IOpt{"offset", m*i}, &la_.IOpt{"n", m})
 		}
-		ind += m
-		ind2 += m*m
-	}
+		return nil
+	})
 	return 
 }
 
 // Inner product of two vectors in S.
-func sdot(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) float64 {
+func Sdot(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) float64 {
 	/*DEBUGGED*/
 	ind := mnl + dims.At("l")[0] + dims.Sum("q")
 	a := blas.DotFloat(x, y, &la_.IOpt{"n", ind})
@@ -836,15 +874,15 @@ func sdot(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) float64 {
 }
 
 // Returns the norm of a vector in S
-func snrm2(x *matrix.FloatMatrix, dims *DimensionSet, mnl int) float64 {
+func Snrm2(x *matrix.FloatMatrix, dims *DimensionSet, mnl int) float64 {
 	/*DEBUGGED*/
-	return math.Sqrt(sdot(x, x, dims, mnl))
+	return math.Sqrt(Sdot(x, x, dims, mnl))
 }
 
 // Converts lower triangular matrix to symmetric.  
 // Fills in the upper triangular part of the symmetric matrix stored in 
 // x[offset : offset+n*n] using 'L' storage.
-func symm(x *matrix.FloatMatrix, n, offset int) (err error) {
+func Symm(x *matrix.FloatMatrix, n, offset int) (err error) {
 	/*DEBUGGED*/
 	err = nil
 	if n <= 1 {
@@ -882,7 +920,7 @@ func symm(x *matrix.FloatMatrix, n, offset int) (err error) {
     
     The 's' components in S are stored in unpacked 'L' storage.
 */
-func sgemv(A, x, y *matrix.FloatMatrix, alpha, beta float64, dims *DimensionSet, opts ...la_.Option) error {
+func Sgemv(A, x, y *matrix.FloatMatrix, alpha, beta float64, dims *DimensionSet, opts ...la_.Option) error {
 
 	m := dims.Sum("l", "q") + dims.SumSquared("s")
 	n := la_.GetIntOpt("n", -1, opts...)
@@ -916,7 +954,7 @@ func sgemv(A, x, y *matrix.FloatMatrix, alpha, beta float64, dims *DimensionSet,
  diagonal.
 */
 
-func sinv(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) (err error) {
+func Sinv(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) (err error) {
 	/*DEBUGGED*/
 
 	err = nil
@@ -958,13 +996,12 @@ func sinv(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) (err error) {
     // where gammaij = .5 * (yk_i + yk_j).
 
 	ind2 := ind
+	xa, ya := x.FloatArray(), y.FloatArray()
 	for _, m := range dims.At("s") {
 		for j := 0; j < m; j++ {
-			u := matrix.FloatVector(y.FloatArray()[ind2+j:ind2+m])
-			u.Add(y.GetIndex(ind2+j))
-			u.Scale(0.5)
-			blas.Tbsv(u, x, &la_.IOpt{"n", m-j}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1},
-				&la_.IOpt{"offsetx", ind+j*(m+1)})
+			for i := j; i < m; i++ {
+				xa[ind+j*m+i] /= 0.5 * (ya[ind2+i] + ya[ind2+j])
+			}
 		}
 		ind += m*m
 		ind2 += m
@@ -1015,92 +1052,17 @@ func triusc(x *matrix.FloatMatrix, dims *DimensionSet, offset int) error {
 
 
 // The product x := (y o x).  If diag is 'D', the 's' part of y is 
-// diagonal and only the diagonal is stored.
-func sprod(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int, opts ...la_.Option) (err error){
-
-	err = nil
+// diagonal and only the diagonal is stored. Option "workers" sets the
+// number of goroutines for the 'q' and 's' blocks.
+func Sprod(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int, opts ...la_.Option) (err error){
 	diag := la_.GetStringOpt("diag", "N", opts...)
-    // For the nonlinear and 'l' blocks:  
-    //
-    //     yk o xk = yk .* xk.
-	ind := mnl + dims.At("l")[0]
-	err = blas.Tbmv(y, x, &la_.IOpt{"n", ind}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
-	if err != nil { return }
-	//fmt.Printf("Sprod l:x=\n%v\n", x)
-
-    // For 'q' blocks: 
-    //
-    //               [ l0   l1'  ]
-    //     yk o xk = [           ] * xk
-    //               [ l1   l0*I ] 
-    //
-    // where yk = (l0, l1).
-	for _, m := range dims.At("q") {
-		dd := blas.DotFloat(x, y, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"offsety", ind},
-			&la_.IOpt{"n", m})
-		//fmt.Printf("dd=%v\n", dd)
-		alpha := y.GetIndex(ind)
-		//fmt.Printf("scal=%v\n", alpha)
-		blas.ScalFloat(x, alpha, &la_.IOpt{"offset", ind+1}, &la_.IOpt{"n", m-1})
-		alpha = x.GetIndex(ind)
-		//fmt.Printf("axpy=%v\n", alpha)
-		blas.AxpyFloat(y, x, alpha, &la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", ind+1},
-			&la_.IOpt{"n", m-1})
-		x.SetIndex(ind, dd)
-		ind += m
-	}
-	//fmt.Printf("Sprod q :x=\n%v\n", x)
-	
-    // For the 's' blocks:
-    //
-    //    yk o sk = .5 * ( Yk * mat(xk) + mat(xk) * Yk )
-    // 
-    // where Yk = mat(yk) if diag is 'N' and Yk = diag(yk) if diag is 'D'.
-
-	if diag[0] == 'N' {
-		// DEBUGGED
-		maxm := maxdim(dims.At("s"))
-		A := matrix.FloatZeros(maxm, maxm)
-		for _, m := range dims.At("s") {
-			blas.Copy(x, A, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m*m})
-			for i := 0; i < m-1; i++ { // i < m-1 --> i < m
-				symm(A, m, 0)
-				symm(y, m, ind)
-			}
-			err = blas.Syr2kFloat(A, y, x, 0.5, 0.0, &la_.IOpt{"n", m}, &la_.IOpt{"k", m},
-				&la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
-				&la_.IOpt{"offsetb", ind}, &la_.IOpt{"offsetc", ind})
-			if err != nil { return }
-			ind += m*m
-		}
-		//fmt.Printf("Sprod diag=N s:x=\n%v\n", x)
-
-	} else {
-		ind2 := ind
-		for _, m := range dims.At("s") {
-			for i := 0; i < m; i++ {
-				// original: u = 0.5 * ( y[ind2+i:ind2+m] + y[ind2+i] )
-				// creates matrix of elements: [ind2+i ... ind2+m] then
-				// element wisely adds y[ind2+i] and scales by 0.5
-				iset := matrix.MakeIndexSet(ind2+i, ind2+m, 1)
-				u := matrix.FloatVector(y.GetIndexes(iset))
-				u.Add(y.GetIndex(ind2+i))
-				u.Scale(0.5)
-				err = blas.Tbmv(u, x, &la_.IOpt{"n", m-i}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1},
-					&la_.IOpt{"offsetx", ind+i*(m+1)})
-				if err != nil { return }
-			}
-			ind += m*m
-			ind2 += m
-		}
-		//fmt.Printf("Sprod diag=T s:x=\n%v\n", x)
-	}
-	return
+	cw := newConeWork(dims, mnl, workerCount(opts...))
+	return cw.sprod(x, y, diag[0] == 'D')
 }
 
 // The product x := y o y.   The 's' components of y are diagonal and
 // only the diagonals of x and y are stored.     
-func ssqr(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) (err error) {
+func Ssqr(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) (err error) {
 	/*DEBUGGED*/
 	blas.Copy(y, x)
 	ind := mnl+dims.At("l")[0]
@@ -1126,56 +1088,13 @@ func ssqr(x, y *matrix.FloatMatrix, dims *DimensionSet, mnl int) (err error) {
 //    
 // When called with the argument sigma, also returns the eigenvalues 
 // (in sigma) and the eigenvectors (in x) of the 's' components of x.
-func maxStep(x *matrix.FloatMatrix, dims *DimensionSet, mnl int, sigma *matrix.FloatMatrix) (rval float64, err error) {
+// Option "workers" sets the number of goroutines for the 'q' and 's'
+// blocks. Returns the error of the first 's' block whose eigenvalues
+// could not be computed.
+func MaxStep(x *matrix.FloatMatrix, dims *DimensionSet, mnl int, sigma *matrix.FloatMatrix, opts ...la_.Option) (rval float64, err error) {
 	/*DEBUGGED*/
-
-	rval = 0.0
-	err = nil
-	t := make([]float64, 0, 10)
-	ind := mnl + dims.Sum("l")
-	if ind > 0 {
-		t = append(t, -minvec(x.FloatArray()[:ind]))
-	}
-	for _, m := range dims.At("q") {
-		if m > 0 {
-			v := blas.Nrm2Float(x, &la_.IOpt{"offset", ind+1}, &la_.IOpt{"n", m-1})
-			v -= x.GetIndex(ind)
-			t = append(t, v)
-		}
-		ind += m
-	}
-
-	var Q *matrix.FloatMatrix
-	var w *matrix.FloatMatrix
-	ind2 := 0
-	if sigma == nil && len(dims.At("s")) > 0 {
-		mx := dims.Max("s")
-		Q = matrix.FloatZeros(mx, mx)
-		w = matrix.FloatZeros(mx, 1)
-	}
-	for _, m := range dims.At("s") {
-		if sigma == nil {
-			blas.Copy(x, Q, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m*m})
-			err = lapack.SyevrFloat(Q, w, nil, 0.0, nil, []int{1,1}, la_.OptRangeInt,
-				&la_.IOpt{"n", m}, &la_.IOpt{"lda", m})
-			if m > 0 {
-				t = append(t, -w.GetIndex(0))
-			}
-		} else {
-			err = lapack.SyevdFloat(x, sigma, la_.OptJobZValue, &la_.IOpt{"n", m},
-				&la_.IOpt{"lda", m}, &la_.IOpt{"offseta", ind}, &la_.IOpt{"offsetw", ind2})
-			if m > 0 {
-				t = append(t, -sigma.GetIndex(ind2))
-			}
-		}
-		ind += m*m
-		ind2 += m
-	}
-
-	if len(t) > 0 {
-		rval = maxvec(t)
-	}
-	return
+	cw := newConeWork(dims, mnl, workerCount(opts...))
+	return cw.maxStep(x, sigma)
 }
 
 /*
@@ -1186,7 +1105,7 @@ func maxStep(x *matrix.FloatMatrix, dims *DimensionSet, mnl int, sigma *matrix.F
      stored in packed storage and the off-diagonal entries scaled by 
      sqrt(2).
  */
-func pack(x, y *matrix.FloatMatrix, dims *DimensionSet, opts ...la_.Option) (err error) {
+func Pack(x, y *matrix.FloatMatrix, dims *DimensionSet, opts ...la_.Option) (err error) {
 	/*DEBUGGED*/
 	err = nil
 	mnl := la_.GetIntOpt("mnl", 0, opts...)
@@ -1219,7 +1138,7 @@ func pack(x, y *matrix.FloatMatrix, dims *DimensionSet, opts ...la_.Option) (err
      unpacked storage.
 
  */
-func unpack(x, y *matrix.FloatMatrix, dims *DimensionSet, opts ...la_.Option) (err error) {
+func Unpack(x, y *matrix.FloatMatrix, dims *DimensionSet, opts ...la_.Option) (err error) {
 	/*DEBUGGED*/
 	err = nil
 	mnl := la_.GetIntOpt("mnl", 0, opts...)
@@ -1269,7 +1188,7 @@ func jdot(x, y *matrix.FloatMatrix, n, offsetx, offsety int) float64 {
     Returns sqrt(x' * J * x) where J = [1, 0; 0, -I], for a vector
     x in a second order cone. 
  */
-func jnrm2(x *matrix.FloatMatrix, n, offset int) float64 {
+func Jnrm2(x *matrix.FloatMatrix, n, offset int) float64 {
 	/*DEBUGGED*/
 	if n <= 0 {
 		n = x.NumElements()
@@ -1282,6 +1201,29 @@ func jnrm2(x *matrix.FloatMatrix, n, offset int) float64 {
 	return math.Sqrt(fst - a) * math.Sqrt(fst + a)
 }
 
+// Returns sum a[i]*b[i] over the length of a.
+func dotArray(a, b []float64) float64 {
+	s := 0.0
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// Returns the inner product of the elements of a and b.
+func vecDot(a, b *matrix.FloatMatrix) float64 {
+	return dotArray(a.FloatArray(), b.FloatArray())
+}
+
+// Returns max |x[i]|, zero for an empty x.
+func normInf(x *matrix.FloatMatrix) float64 {
+	nrm := 0.0
+	for _, v := range x.FloatArray() {
+		nrm = math.Max(nrm, math.Abs(v))
+	}
+	return nrm
+}
+
 // Local Variables:
 // tab-width: 4
 // End:
@@ -0,0 +1,527 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la_ "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/blas"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"testing"
+)
+
+// Reference values computed with CVXOPT, see examples/testcvx.go.
+const (
+	sS2 =
+		"[ 4.58e+01]"+
+		"[ 4.35e+01]"+
+		"[ 2.83e+01]"+
+		"[ 1.97e+01]"+
+		"[-1.84e+01]"+
+		"[ 2.69e+00]"+
+		"[ 5.09e+01]"+
+		"[ 7.07e-02]"+
+		"[ 4.09e-01]"+
+		"[-1.27e+00]"+
+		"[ 1.75e+02]"+
+		"[ 0.00e+00]"+
+		"[ 0.00e+00]"+
+		"[ 0.00e+00]"+
+		"[ 1.04e+02]"+
+		"[ 0.00e+00]"+
+		"[ 0.00e+00]"+
+		"[ 0.00e+00]"+
+		"[ 3.89e+01]"
+
+	lmbdaS2 =
+		"[ 6.77e+00]"+
+		"[ 6.59e+00]"+
+		"[ 4.27e+00]"+
+		"[ 2.30e+00]"+
+		"[-2.16e+00]"+
+		"[ 3.15e-01]"+
+		"[ 7.14e+00]"+
+		"[ 4.95e-03]"+
+		"[ 2.86e-02]"+
+		"[-8.90e-02]"+
+		"[ 1.32e+01]"+
+		"[ 1.02e+01]"+
+		"[ 6.24e+00]"+
+		"[ 1.00e+00]"
+
+	sinvRes =
+		"[-6.77e+00]"+
+		"[-6.59e+00]"+
+		"[-4.27e+00]"+
+		"[-2.30e+00]"+
+		"[ 2.16e+00]"+
+		"[-3.15e-01]"+
+		"[-7.14e+00]"+
+		"[-4.95e-03]"+
+		"[-2.86e-02]"+
+		"[ 8.90e-02]"+
+		"[-1.32e+01]"+
+		"[-0.00e+00]"+
+		"[-0.00e+00]"+
+		"[-0.00e+00]"+
+		"[-1.02e+01]"+
+		"[-0.00e+00]"+
+		"[-0.00e+00]"+
+		"[-0.00e+00]"+
+		"[-6.24e+00]"
+
+	sS =
+		"[ 3.57e+01]"+
+		"[ 3.61e+01]"+
+		"[ 2.29e+01]"+
+		"[ 1.58e+01]"+
+		"[-1.50e+01]"+
+		"[ 1.22e+00]"+
+		"[ 4.39e+01]"+
+		"[ 2.99e-01]"+
+		"[ 3.71e-01]"+
+		"[-8.84e-01]"+
+		"[ 1.07e+02]"+
+		"[-2.80e+01]"+
+		"[-2.24e+01]"+
+		"[ 3.00e+01]"+
+		"[ 1.22e+02]"+
+		"[ 2.26e+01]"+
+		"[ 1.90e+01]"+
+		"[-2.30e+01]"+
+		"[ 4.57e+01]"
+
+	zS =
+		"[ 1.28e+00]"+
+		"[ 1.21e+00]"+
+		"[ 1.23e+00]"+
+		"[ 1.00e-02]"+
+		"[ 7.99e-03]"+
+		"[ 9.00e-02]"+
+		"[ 1.16e+00]"+
+		"[-6.30e-03]"+
+		"[-4.97e-04]"+
+		"[-5.57e-03]"+
+		"[ 1.26e+00]"+
+		"[ 8.42e-03]"+
+		"[-3.01e-02]"+
+		"[ 0.00e+00]"+
+		"[ 1.13e+00]"+
+		"[-8.81e-02]"+
+		"[ 0.00e+00]"+
+		"[ 0.00e+00]"+
+		"[ 1.06e+00]"
+
+	sSinv = "{19 1 [45.80966169071921001 43.47630265946575179 28.29761547160174473 19.66003536745345315 -18.42630342523398568 2.69314851601064342 50.94833945136289799 0.07072948843782226 0.40880031765775354 -1.27102371744456244 174.71568098235402999 0.00000000000000000 0.00000000000000000 0.00000000000000000 104.02951094158350998 0.00000000000000000 0.00000000000000000 0.00000000000000000 38.93289962917572211]}"
+
+	lmbdaSinv = "{14 1 [6.76828351140222306 6.59365624365311831 4.27263364786096478 2.30069284986509182 -2.15631679941233223 0.31516258331192648 7.13719596228404818 0.00495499134475126 0.02863872029141604 -0.08904223200267916 13.21800593820240444 10.19948581750979777 6.23962335635539134 1.00000000000000000]}"
+
+	ws3Sprod = "{19 1 [-6.76828351140222395 -6.59365624365311831 -4.27263364786096744 -2.30069284986509093 2.15631679941233045 -0.31516258331192648 -7.13719596228404818 -0.00495499134475128 -0.02863872029141602 0.08904223200267919 -13.21800593820240444 0.00000000000000000 0.00000000000000000 0.00000000000000000 -10.19948581750979777 0.00000000000000000 0.00000000000000000 0.00000000000000000 -6.23962335635539223]}"
+
+	// W'*ws3Scale for W of makeRefScaling as printed by CVXOPT in
+	// examples/testcvx.go.
+	ws3String =
+		"[-1.01e+01]"+
+		"[-2.42e+01]"+
+		"[-7.07e+00]"+
+		"[-1.83e+00]"+
+		"[ 8.14e+00]"+
+		"[-4.77e+00]"+
+		"[-2.89e+01]"+
+		"[-1.14e+00]"+
+		"[ 4.14e-01]"+
+		"[-2.41e-01]"+
+		"[ 8.94e+00]"+
+		"[-2.46e+01]"+
+		"[-7.30e+00]"+
+		"[ 7.30e+01]"+
+		"[ 9.98e+00]"+
+		"[ 5.95e+00]"+
+		"[ 5.09e+01]"+
+		"[-5.71e+01]"+
+		"[-2.45e+01]"
+
+	// ws3String evaluated in double precision from the CVXOPT definition of W.
+	ws3Scaled = "{19 1 [-10.144334183629399 -24.247518649010861 -7.069180906854436 -1.834398610955819 8.144845143051125 -4.767268724559512 -28.902912939763240 -1.139806820328421 0.414366521668855 -0.241258277771711 8.940396472699433 -24.605371665961073 -7.300721267483091 72.989181468426182 9.978150942258139 5.951003407748944 50.857929053976775 -57.088147353372193 -24.513718669838262]}"
+
+	// ds and dz of TestUpdateScaling in examples/testcvx.go, which prints
+	// no CVXOPT output; lmbdaUpdate is the updated lmbda evaluated in
+	// double precision with the formulas of CVXOPT's update_scaling.
+	dsUpdate = "{19 1 [5.85597012340924117 4.24746698613998053 6.66652548029122194 0.58902278472678926 1.03308171512083025 -1.94414436400428547 4.60978114520661020 -0.08983717465618864 0.08317983679679065 -0.11015599534516843 -0.26824322970973236 -0.30242159737138297 -2.03756136168495861 -0.13360736529691733 -3.00562533088611916 0.28121160147417951 -3.90046688480349379 0.16852198213982270 0.22709525427810559]}"
+
+	lmbdaUpdate = "{13 1 [5.855970123409241 4.247466986139981 6.666525480291221 0.589022784726789 1.033081715120830 -1.944144364004285 4.609781145206609 -0.089837174656189 0.083179836796791 -0.110155995345168 15.320893150534088 9.156966988905609 4.261537770991731]}"
+
+	ws3Scale = "{19 1 [-1.922024455099050 -4.434132478780063 -1.157755840639486 0.548208874526766 2.216497006279832 -1.981625448557906 -4.700418701695003 -0.156608707563295 0.088266810165157 -0.075309106376174 3.093576871124169 -0.088929363667257 -0.690404195783791 72.989181468426182 -1.792662782081562 -0.151842328275522 50.857929053976775 -57.088147353372193 -4.338862257011282]}"
+)
+
+// Scaling W computed by CVXOPT for dims makeDSet().
+func makeRefScaling(t *testing.T) *Scaling {
+	specs := []string{
+		"{2 1 [5.277942305425358 5.468379387636596]}",
+		"{2 1 [0.189467777806527 0.182869535764269]}",
+		"{3 3 [-2.249267570008489 2.361205104290289 0.913567958765254 -1.925574750121168 -2.172410611043159 0.173946992908080 0.559540271653776 -0.139584324205900 2.348303661399984]}",
+		"{3 3 [-0.214383299026403 0.195042904770822 0.062675463930892 -0.239513204273735 -0.244613737625478 0.042529866033816 0.101143782396739 -0.057758767117156 0.398306137924685]}",
+		"{4 1 [1.114132558182145 0.355030258425681 -0.339080260926309 0.016414915590130]}",
+		"{4 1 [1.000014508170784 0.003059892342288 0.002220573368109 -0.003837012486999]}",
+		"{2 1 [2.33309421747982 6.1486663985292]}"}
+	m := make([]*matrix.FloatMatrix, len(specs))
+	for k, s := range specs {
+		var err error
+		if m[k], err = matrix.FloatParseSpe(s); err != nil {
+			t.Fatalf("parse error: %s\n", err)
+		}
+	}
+	return &Scaling{D: m[0], Di: m[1],
+		R: []*matrix.FloatMatrix{m[2]}, Rti: []*matrix.FloatMatrix{m[3]},
+		V: []*matrix.FloatMatrix{m[4], m[5]}, Beta: m[6].FloatArray()}
+}
+
+func parseSpe(t *testing.T, s string) *matrix.FloatMatrix {
+	m, err := matrix.FloatParseSpe(s)
+	if err != nil {
+		t.Fatalf("parse error: %s\n", err)
+	}
+	return m
+}
+
+func parsePy(t *testing.T, s string) *matrix.FloatMatrix {
+	m, err := matrix.FloatParsePy(s)
+	if err != nil {
+		t.Fatalf("parse error: %s\n", err)
+	}
+	return m
+}
+
+// Expands lmbda with diagonal 's' components to unpacked storage.
+func expandDiag(lmbda *matrix.FloatMatrix, dims *DimensionSet) *matrix.FloatMatrix {
+	x := matrix.FloatZeros(dims.Sum("l", "q")+dims.SumSquared("s"), 1)
+	ind := dims.Sum("l", "q")
+	for k := 0; k < ind; k++ {
+		x.SetIndex(k, lmbda.GetIndex(k))
+	}
+	ind2 := ind
+	for _, m := range dims.At("s") {
+		for i := 0; i < m; i++ {
+			x.SetIndex(ind2+i*(m+1), lmbda.GetIndex(ind+i))
+		}
+		ind += m
+		ind2 += m*m
+	}
+	return x
+}
+
+// Zeros the strictly upper triangular parts of the 's' components of x.
+func lowerOnly(x *matrix.FloatMatrix, dims *DimensionSet) *matrix.FloatMatrix {
+	x = x.Copy()
+	ind := dims.Sum("l", "q")
+	for _, m := range dims.At("s") {
+		for j := 1; j < m; j++ {
+			for i := 0; i < j; i++ {
+				x.SetIndex(ind+j*m+i, 0.0)
+			}
+		}
+		ind += m*m
+	}
+	return x
+}
+
+// Checks |x[k] - y[k]| <= tol*max(1, |y[k]|) for the first n elements.
+func checkClose(t *testing.T, name string, x, y *matrix.FloatMatrix, n int, tol float64) {
+	for k := 0; k < n; k++ {
+		a, b := x.GetIndex(k), y.GetIndex(k)
+		if math.Abs(a-b) > tol*math.Max(1.0, math.Abs(b)) {
+			t.Errorf("%s: element %d is %.12e, expected %.12e\n", name, k, a, b)
+			return
+		}
+	}
+}
+
+func TestSgemv(t *testing.T) {
+	G := parseSpe(t, "{19 3 [16.0 7.0 24.0 -8.0 8.0 -1.0 0.0 -1.0 0.0 0.0 7.0 -5.0 1.0 -5.0 1.0 -7.0 1.0 -7.0 -4.0 -14.0 2.0 7.0 -13.0 -18.0 3.0 0.0 0.0 -1.0 0.0 3.0 13.0 -6.0 13.0 12.0 -10.0 -6.0 -10.0 -28.0 5.0 0.0 -15.0 12.0 -6.0 17.0 0.0 0.0 0.0 -1.0 9.0 6.0 -6.0 6.0 -7.0 -7.0 -6.0 -7.0 -11.0]}")
+	x := parseSpe(t, "{19 1 [1.28237163646993602 1.20577885626601700 1.23170989498268124 0.01004618744349980 0.00798511180410652 0.08995485424980643 1.16070198911381661 -0.00629749112134051 -0.00049721465140839 -0.00557415890687774 1.25644350107932512 0.00842128830287307 -0.03013075022837631 0.00000000000000000 1.13394694370391358 -0.08807369671161171 0.00000000000000000 0.00000000000000000 1.06027426641336442]}")
+	r := parseSpe(t, "{3 1 [-65.19580144480464412 16.89263580404870524 17.05333779316251253]}")
+	y := matrix.FloatZeros(3, 1)
+	dims := makeDSet()
+	Sgemv(G, x, y, -1.0, 1.0, dims, la_.OptTrans)
+	checkClose(t, "Sgemv", y, r, 3, 1e-12)
+}
+
+func TestSymm(t *testing.T) {
+	x := matrix.FloatNew(3, 3, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9})
+	r := matrix.FloatNew(3, 3, []float64{1, 2, 3, 2, 5, 6, 3, 6, 9})
+	Symm(x, 3, 0)
+	if !x.Equal(r) {
+		t.Errorf("Symm: got\n%v\nexpected\n%v\n", x, r)
+	}
+}
+
+func TestSinv(t *testing.T) {
+	dims := makeDSet()
+	// s = lmbda o lmbda, so lmbda o\ s = lmbda
+	s := parseSpe(t, sSinv)
+	lmbda := parseSpe(t, lmbdaSinv)
+	Sinv(s, lmbda, dims, 0)
+	r := parseSpe(t, ws3Sprod)
+	r.Scale(-1.0)
+	checkClose(t, "Sinv", s, r, s.NumElements(), 1e-10)
+
+	// sS2 and lmbdaS2 are sSinv and lmbdaSinv printed with three
+	// significant digits.
+	s = parsePy(t, sS2)
+	lmbda = parsePy(t, lmbdaS2)
+	checkClose(t, "sS2", s, parseSpe(t, sSinv), s.NumElements(), 5e-3)
+	checkClose(t, "lmbdaS2", lmbda, parseSpe(t, lmbdaSinv), lmbda.NumElements(), 5e-3)
+	Sinv(s, lmbda, dims, 0)
+	s.Scale(-1.0)
+	checkClose(t, "Sinv", s, parsePy(t, sinvRes), s.NumElements(), 1e-2)
+}
+
+func TestSprod(t *testing.T) {
+	dims := makeDSet()
+	x := parseSpe(t, ws3Sprod)
+	lmbda := parseSpe(t, lmbdaSinv)
+	Sprod(x, lmbda, dims, 0, &la_.SOpt{"diag", "D"})
+	r := parseSpe(t, sSinv)
+	r.Scale(-1.0)
+	checkClose(t, "Sprod", x, r, x.NumElements(), 1e-10)
+}
+
+func TestSsqr(t *testing.T) {
+	dims := makeDSet()
+	lmbda := parseSpe(t, lmbdaSinv)
+	x := matrix.FloatZeros(lmbda.NumElements(), 1)
+	Ssqr(x, lmbda, dims, 0)
+	r := parseSpe(t, sSinv)
+	checkClose(t, "Ssqr", expandDiag(x, dims), r, r.NumElements(), 1e-10)
+}
+
+func TestComputeScaling(t *testing.T) {
+	dims := makeDSet()
+	s := parsePy(t, sS)
+	z := parsePy(t, zS)
+	lmbda := matrix.FloatZeros(dims.Sum("l", "q", "s"), 1)
+	W, err := ComputeScaling(s, z, lmbda, dims, 0)
+	if err != nil {
+		t.Fatalf("ComputeScaling: %s\n", err)
+	}
+	// W*z = W^{-T}*s = lmbda
+	r := lowerOnly(expandDiag(lmbda, dims), dims)
+	Scale(z, W, false, false)
+	checkClose(t, "W*z", lowerOnly(z, dims), r, r.NumElements(), 1e-8)
+	Scale(s, W, true, true)
+	checkClose(t, "W^{-T}*s", lowerOnly(s, dims), r, r.NumElements(), 1e-8)
+}
+
+func TestScale(t *testing.T) {
+	dims := makeDSet()
+	W := makeRefScaling(t)
+	x0 := parseSpe(t, ws3Scale)
+	x := x0.Copy()
+	Scale(x, W, true, false)
+	// reference values printed with three significant digits
+	checkClose(t, "W'*x", lowerOnly(x, dims), lowerOnly(parsePy(t, ws3String), dims),
+		x.NumElements(), 5e-3)
+	checkClose(t, "W'*x", lowerOnly(x, dims), lowerOnly(parseSpe(t, ws3Scaled), dims),
+		x.NumElements(), 1e-8)
+
+	Symm(x0, 3, dims.Sum("l", "q"))
+	for _, trans := range []bool{false, true} {
+		x := x0.Copy()
+		Scale(x, W, trans, false)
+		Scale(x, W, trans, true)
+		checkClose(t, "Scale", lowerOnly(x, dims), lowerOnly(x0, dims), x.NumElements(), 1e-8)
+	}
+}
+
+// Returns s with the 's' components, stored as factors L, replaced by L*L'.
+func factorProduct(s *matrix.FloatMatrix, dims *DimensionSet) *matrix.FloatMatrix {
+	s = s.Copy()
+	ind := dims.Sum("l", "q")
+	for _, m := range dims.At("s") {
+		L := matrix.FloatZeros(m, m)
+		blas.CopyFloat(s, L, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m*m})
+		S := matrix.FloatZeros(m, m)
+		blas.GemmFloat(L, L, S, 1.0, 0.0, la_.OptTransB)
+		blas.CopyFloat(S, s, &la_.IOpt{"offsety", ind}, &la_.IOpt{"n", m*m})
+		ind += m*m
+	}
+	return s
+}
+
+// The updated scaling maps the new iterates s+ = W'*st and z+ = W^{-1}*zt
+// to lmbda: W+^{-T}*s+ = W+*z+ = lmbda.
+func TestUpdateScaling(t *testing.T) {
+	dims := makeDSet()
+	ds := parseSpe(t, dsUpdate)
+	for _, tc := range []struct {
+		name  string
+		dz    *matrix.FloatMatrix
+		lmbda string
+	}{{"testcvx", ds, lmbdaUpdate}, {"s != z", parsePy(t, zS), ""}} {
+		W := makeRefScaling(t)
+		sp := factorProduct(ds, dims)
+		zp := factorProduct(tc.dz, dims)
+		Scale(sp, W, true, false)
+		Scale(zp, W, false, true)
+
+		s, z := ds.Copy(), tc.dz.Copy()
+		lmbda := matrix.FloatZeros(dims.Sum("l", "q", "s"), 1)
+		if err := UpdateScaling(W, lmbda, s, z); err != nil {
+			t.Fatalf("%s: UpdateScaling: %s\n", tc.name, err)
+		}
+		if tc.lmbda != "" {
+			checkClose(t, tc.name+": lmbda", lmbda, parseSpe(t, tc.lmbda), lmbda.NumElements(), 1e-8)
+		}
+		r := lowerOnly(expandDiag(lmbda, dims), dims)
+		Scale(sp, W, true, true)
+		checkClose(t, tc.name+": W^{-T}*s", lowerOnly(sp, dims), r, r.NumElements(), 1e-8)
+		Scale(zp, W, false, false)
+		checkClose(t, tc.name+": W*z", lowerOnly(zp, dims), r, r.NumElements(), 1e-8)
+	}
+}
+
+func TestPack(t *testing.T) {
+	dims := makeDSet()
+	x := parseSpe(t, ws3Scale)
+	y := matrix.FloatZeros(dims.Sum("l", "q")+dims.SumPacked("s"), 1)
+	Pack(x, y, dims)
+	if nrm := Snrm2(x, dims, 0); math.Abs(nrm-blas.Nrm2Float(y)) > 1e-10*nrm {
+		t.Errorf("Pack: norm %.12e, expected %.12e\n", blas.Nrm2Float(y), nrm)
+	}
+	x2 := matrix.FloatZeros(x.NumElements(), 1)
+	Unpack(y, x2, dims)
+	checkClose(t, "Unpack", lowerOnly(x2, dims), lowerOnly(x, dims), x.NumElements(), 1e-12)
+}
+
+func TestJnrm2(t *testing.T) {
+	s := parsePy(t, sS)
+	a := s.GetIndex(2)*s.GetIndex(2)
+	for k := 3; k < 6; k++ {
+		a -= s.GetIndex(k)*s.GetIndex(k)
+	}
+	if nrm := Jnrm2(s, 4, 2); math.Abs(nrm-math.Sqrt(a)) > 1e-10*nrm {
+		t.Errorf("Jnrm2: got %.12e, expected %.12e\n", nrm, math.Sqrt(a))
+	}
+}
+
+func TestMaxStep(t *testing.T) {
+	dims := DSetNew("l", "q", "s")
+	dims.Set("l", []int{2})
+	dims.Set("q", []int{3})
+	x := matrix.FloatVector([]float64{1.0, -0.5, 1.0, 3.0, 4.0})
+	// max{-1.0, 0.5, ||(3, 4)|| - 1} = 4
+	step, err := MaxStep(x, dims, 0, nil)
+	if err != nil {
+		t.Fatalf("MaxStep: %s\n", err)
+	}
+	if math.Abs(step-4.0) > 1e-12 {
+		t.Errorf("MaxStep: got %.12e, expected 4.0\n", step)
+	}
+}
+
+// Returns a point strictly inside the cone dims; a varies the point.
+func interiorPoint(dims *DimensionSet, a float64) *matrix.FloatMatrix {
+	x := matrix.FloatZeros(dims.Sum("l", "q")+dims.SumSquared("s"), 1)
+	ind := 0
+	for k := 0; k < dims.At("l")[0]; k++ {
+		x.SetIndex(ind, 1.0+a*float64(k+1))
+		ind++
+	}
+	for _, m := range dims.At("q") {
+		x.SetIndex(ind, 2.0+a)
+		for k := 1; k < m; k++ {
+			x.SetIndex(ind+k, a*math.Sin(float64(k+m)))
+		}
+		ind += m
+	}
+	for _, m := range dims.At("s") {
+		for i := 0; i < m; i++ {
+			for j := 0; j < m; j++ {
+				v := 0.1 * a * math.Cos(float64(i+j+m))
+				if i == j {
+					v += 2.0
+				}
+				x.SetIndex(ind+i+j*m, v)
+			}
+		}
+		ind += m*m
+	}
+	return x
+}
+
+func checkEqual(t *testing.T, name string, x, y *matrix.FloatMatrix) {
+	xa, ya := x.FloatArray(), y.FloatArray()
+	if len(xa) != len(ya) {
+		t.Errorf("%s: lengths %d and %d differ\n", name, len(xa), len(ya))
+		return
+	}
+	for k := range xa {
+		if xa[k] != ya[k] {
+			t.Errorf("%s[%d]: %v != %v\n", name, k, xa[k], ya[k])
+			return
+		}
+	}
+}
+
+// The cone operations give the same bits with one and several workers.
+func TestWorkers(t *testing.T) {
+	dims := DSetNew("l", "q", "s")
+	dims.Set("l", []int{2})
+	dims.Set("q", []int{4, 4, 3})
+	dims.Set("s", []int{3, 2, 3})
+	cdim_diag := dims.Sum("l", "q", "s")
+
+	var Ws [2]*Scaling
+	var xs, ys, ls [2]*matrix.FloatMatrix
+	var steps [2]float64
+	for i, n := range []int{1, 4} {
+		opt := &la_.IOpt{"workers", n}
+		s := interiorPoint(dims, 0.5)
+		z := interiorPoint(dims, 0.3)
+		ls[i] = matrix.FloatZeros(cdim_diag, 1)
+		W, err := ComputeScaling(s, z, ls[i], dims, 0, opt)
+		if err != nil {
+			t.Fatalf("ComputeScaling: %s\n", err)
+		}
+		// new iterates in the current scaling
+		s = interiorPoint(dims, 0.2)
+		z = interiorPoint(dims, 0.4)
+		ind := dims.Sum("l", "q")
+		for _, m := range dims.At("s") {
+			lapack.PotrfFloat(s, &la_.IOpt{"n", m}, &la_.IOpt{"lda", m}, &la_.IOpt{"offseta", ind})
+			lapack.PotrfFloat(z, &la_.IOpt{"n", m}, &la_.IOpt{"lda", m}, &la_.IOpt{"offseta", ind})
+			ind += m*m
+		}
+		if err = UpdateScaling(W, ls[i], s, z); err != nil {
+			t.Fatalf("UpdateScaling: %s\n", err)
+		}
+		Ws[i] = W
+		xs[i] = interiorPoint(dims, 0.7)
+		Scale(xs[i], W, true, false)
+		ys[i] = interiorPoint(dims, 0.9)
+		Sprod(ys[i], xs[i], dims, 0, opt)
+		steps[i], _ = MaxStep(ys[i], dims, 0, nil, opt)
+	}
+	checkEqual(t, "lmbda", ls[0], ls[1])
+	checkEqual(t, "Scale", xs[0], xs[1])
+	checkEqual(t, "Sprod", ys[0], ys[1])
+	for k := range Ws[0].V {
+		checkEqual(t, "V", Ws[0].V[k], Ws[1].V[k])
+	}
+	for k := range Ws[0].R {
+		checkEqual(t, "R", Ws[0].R[k], Ws[1].R[k])
+		checkEqual(t, "Rti", Ws[0].Rti[k], Ws[1].Rti[k])
+	}
+	if steps[0] != steps[1] {
+		t.Errorf("MaxStep: %v != %v\n", steps[0], steps[1])
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
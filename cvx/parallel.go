@@ -0,0 +1,278 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la_ "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/blas"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Returns the worker count of option "workers", at least one.
+func workerCount(opts ...la_.Option) int {
+	n := la_.GetIntOpt("workers", 1, opts...)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// Calls f(k, w) for the blocks k = 0, ..., n-1 on at most workers
+// goroutines. w identifies the goroutine, 0 <= w < workers, and selects
+// its workspace. The blocks must not share any output; then the result
+// does not depend on the number of workers. With one worker the blocks
+// are processed in order in the calling goroutine.
+//
+// Returns the error of the first failing block.
+func forBlocks(n, workers int, f func(k, w int) error) error {
+	if workers > n {
+		workers = n
+	}
+	if workers < 2 {
+		for k := 0; k < n; k++ {
+			if err := f(k, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	errs := make([]error, n)
+	next := int64(-1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for {
+				k := int(atomic.AddInt64(&next, 1))
+				if k >= n {
+					return
+				}
+				errs[k] = f(k, w)
+			}
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the offsets of the 'q' and 's' blocks in a vector of S with mnl
+// nonlinear components and the offsets of the 's' blocks in a vector that
+// stores only their diagonals.
+func blockOffsets(dims *DimensionSet, mnl int) (indq, inds, indd []int) {
+	indq = make([]int, len(dims.At("q")))
+	inds = make([]int, len(dims.At("s")))
+	indd = make([]int, len(dims.At("s")))
+	ind := mnl + dims.At("l")[0]
+	for k, m := range dims.At("q") {
+		indq[k] = ind
+		ind += m
+	}
+	ind2 := ind
+	for k, m := range dims.At("s") {
+		inds[k] = ind
+		indd[k] = ind2
+		ind += m*m
+		ind2 += m
+	}
+	return
+}
+
+// Workspace of MaxStep and Sprod for the cone dims with mnl nonlinear
+// components: the block offsets, the steps of the 'q' and 's' blocks, the
+// work matrices of the workers and the block functions, all set up once.
+// The solvers create one per solve so that the iterations do not allocate.
+// A workspace serves one call at a time.
+type coneWork struct {
+	dims         *DimensionSet
+	mnl, workers int
+	indq, inds, indd []int
+	tq, ts       []float64
+	// eigenvectors and eigenvalues of maxStep and the matrix of sprod
+	// per worker, allocated on first use
+	Qs, ws, As   []*matrix.FloatMatrix
+	irange       []int
+	// arguments of the current call
+	x, y, sigma  *matrix.FloatMatrix
+	qstep, sstep, qprod, sprodN, sprodD func(k, wk int) error
+}
+
+func newConeWork(dims *DimensionSet, mnl, workers int) *coneWork {
+	if workers < 1 {
+		workers = 1
+	}
+	cw := &coneWork{dims: dims, mnl: mnl, workers: workers}
+	cw.indq, cw.inds, cw.indd = blockOffsets(dims, mnl)
+	cw.tq = make([]float64, len(cw.indq))
+	cw.ts = make([]float64, len(cw.inds))
+	cw.Qs = make([]*matrix.FloatMatrix, workers)
+	cw.ws = make([]*matrix.FloatMatrix, workers)
+	cw.As = make([]*matrix.FloatMatrix, workers)
+	cw.irange = []int{1, 1}
+	cw.qstep = cw.qblockStep
+	cw.sstep = cw.sblockStep
+	cw.qprod = cw.qblockProd
+	cw.sprodN = cw.sblockProd
+	cw.sprodD = cw.sblockProdDiag
+	return cw
+}
+
+// MaxStep with the workspace cw, see MaxStep.
+func (cw *coneWork) maxStep(x, sigma *matrix.FloatMatrix) (rval float64, err error) {
+	cw.x, cw.sigma = x, sigma
+	if err = forBlocks(len(cw.indq), cw.workers, cw.qstep); err != nil {
+		return
+	}
+	if err = forBlocks(len(cw.inds), cw.workers, cw.sstep); err != nil {
+		return
+	}
+	// maximum of the block steps; blocks of size zero do not contribute
+	rval = math.Inf(-1)
+	if ind := cw.mnl + cw.dims.Sum("l"); ind > 0 {
+		rval = -minvec(x.FloatArray()[:ind])
+	}
+	for k, m := range cw.dims.At("q") {
+		if m > 0 && cw.tq[k] > rval {
+			rval = cw.tq[k]
+		}
+	}
+	for k, m := range cw.dims.At("s") {
+		if m > 0 && cw.ts[k] > rval {
+			rval = cw.ts[k]
+		}
+	}
+	if math.IsInf(rval, -1) {
+		rval = 0.0
+	}
+	return
+}
+
+func (cw *coneWork) qblockStep(k, wk int) error {
+	m, ind := cw.dims.At("q")[k], cw.indq[k]
+	if m > 0 {
+		v := blas.Nrm2Float(cw.x, &la_.IOpt{"offset", ind+1}, &la_.IOpt{"n", m-1})
+		cw.tq[k] = v - cw.x.GetIndex(ind)
+	}
+	return nil
+}
+
+func (cw *coneWork) sblockStep(k, wk int) (err error) {
+	x := cw.x
+	m, ind, ind2 := cw.dims.At("s")[k], cw.inds[k], cw.indd[k]-cw.indd[0]
+	if cw.sigma == nil {
+		if cw.Qs[wk] == nil {
+			mx := cw.dims.Max("s")
+			cw.Qs[wk] = matrix.FloatZeros(mx, mx)
+			cw.ws[wk] = matrix.FloatZeros(mx, 1)
+		}
+		Q, w := cw.Qs[wk], cw.ws[wk]
+		blas.Copy(x, Q, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m*m})
+		err = lapack.SyevrFloat(Q, w, nil, 0.0, nil, cw.irange, la_.OptRangeInt,
+			&la_.IOpt{"n", m}, &la_.IOpt{"lda", m})
+		if m > 0 {
+			cw.ts[k] = -w.GetIndex(0)
+		}
+	} else {
+		err = lapack.SyevdFloat(x, cw.sigma, la_.OptJobZValue, &la_.IOpt{"n", m},
+			&la_.IOpt{"lda", m}, &la_.IOpt{"offseta", ind}, &la_.IOpt{"offsetw", ind2})
+		if m > 0 {
+			cw.ts[k] = -cw.sigma.GetIndex(ind2)
+		}
+	}
+	return
+}
+
+// Sprod with the workspace cw, see Sprod. If diag is set, the 's' part of y
+// is diagonal and only the diagonal is stored.
+func (cw *coneWork) sprod(x, y *matrix.FloatMatrix, diag bool) (err error) {
+    // For the nonlinear and 'l' blocks:  
+    //
+    //     yk o xk = yk .* xk.
+	ind := cw.mnl + cw.dims.At("l")[0]
+	err = blas.Tbmv(y, x, &la_.IOpt{"n", ind}, &la_.IOpt{"k", 0}, &la_.IOpt{"lda", 1})
+	if err != nil { return }
+
+	cw.x, cw.y = x, y
+	err = forBlocks(len(cw.indq), cw.workers, cw.qprod)
+	if err != nil { return }
+	if diag {
+		err = forBlocks(len(cw.inds), cw.workers, cw.sprodD)
+	} else {
+		err = forBlocks(len(cw.inds), cw.workers, cw.sprodN)
+	}
+	return
+}
+
+// For 'q' blocks: 
+//
+//               [ l0   l1'  ]
+//     yk o xk = [           ] * xk
+//               [ l1   l0*I ] 
+//
+// where yk = (l0, l1).
+func (cw *coneWork) qblockProd(k, wk int) error {
+	x, y := cw.x, cw.y
+	m, ind := cw.dims.At("q")[k], cw.indq[k]
+	dd := blas.DotFloat(x, y, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"offsety", ind},
+		&la_.IOpt{"n", m})
+	alpha := y.GetIndex(ind)
+	blas.ScalFloat(x, alpha, &la_.IOpt{"offset", ind+1}, &la_.IOpt{"n", m-1})
+	alpha = x.GetIndex(ind)
+	blas.AxpyFloat(y, x, alpha, &la_.IOpt{"offsetx", ind+1}, &la_.IOpt{"offsety", ind+1},
+		&la_.IOpt{"n", m-1})
+	x.SetIndex(ind, dd)
+	return nil
+}
+
+// For the 's' blocks:
+//
+//    yk o sk = .5 * ( Yk * mat(xk) + mat(xk) * Yk )
+// 
+// where Yk = mat(yk).
+func (cw *coneWork) sblockProd(k, wk int) error {
+	x, y := cw.x, cw.y
+	if cw.As[wk] == nil {
+		maxm := cw.dims.Max("s")
+		cw.As[wk] = matrix.FloatZeros(maxm, maxm)
+	}
+	A := cw.As[wk]
+	m, ind := cw.dims.At("s")[k], cw.inds[k]
+	blas.Copy(x, A, &la_.IOpt{"offsetx", ind}, &la_.IOpt{"n", m*m})
+	for i := 0; i < m-1; i++ { // i < m-1 --> i < m
+		Symm(A, m, 0)
+		Symm(y, m, ind)
+	}
+	return blas.Syr2kFloat(A, y, x, 0.5, 0.0, &la_.IOpt{"n", m}, &la_.IOpt{"k", m},
+		&la_.IOpt{"lda", m}, &la_.IOpt{"ldb", m}, &la_.IOpt{"ldc", m},
+		&la_.IOpt{"offsetb", ind}, &la_.IOpt{"offsetc", ind})
+}
+
+// For the 's' blocks with Yk = diag(yk), only the diagonal of yk stored:
+// xk[i,j] := 0.5 * (yk[i] + yk[j]) * xk[i,j] in the lower triangle.
+func (cw *coneWork) sblockProdDiag(k, wk int) error {
+	xa, ya := cw.x.FloatArray(), cw.y.FloatArray()
+	m, ind, ind2 := cw.dims.At("s")[k], cw.inds[k], cw.indd[k]
+	for j := 0; j < m; j++ {
+		for i := j; i < m; i++ {
+			xa[ind+j*m+i] *= 0.5 * (ya[ind2+i] + ya[ind2+j])
+		}
+	}
+	return nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,207 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Relative tolerance for the zero eigenvalues of semidefinite P in Qcqp.
+const QCQP_EIGTOL = 1e-12
+
+// Convex quadratic constraint x'*P*x + q'*x <= r of Qcqp. P is symmetric
+// positive semidefinite with the lower triangle referenced. P or Q may be
+// nil.
+type QuadConstraint struct {
+	P, Q *matrix.FloatMatrix
+	R    float64
+}
+
+// Returns L with P = L*L' for the lower triangle of the positive semidefinite
+// matrix P. L is the Cholesky factor if P is definite, otherwise
+// L = V*diag(sqrt(w)) over the nonzero eigenvalues w of P.
+func quadFactor(P *matrix.FloatMatrix) (L *matrix.FloatMatrix, err error) {
+	n := P.Rows()
+	L = matrix.FloatZeros(n, n)
+	for j := 0; j < n; j++ {
+		for i := j; i < n; i++ {
+			L.SetAt(i, j, P.GetAt(i, j))
+		}
+	}
+	if lapack.PotrfFloat(L) == nil {
+		return
+	}
+	// semidefinite or indefinite; factor by eigenvalues
+	A := matrix.FloatZeros(n, n)
+	for j := 0; j < n; j++ {
+		for i := j; i < n; i++ {
+			A.SetAt(i, j, P.GetAt(i, j))
+		}
+	}
+	w := matrix.FloatZeros(n, 1)
+	V := matrix.FloatZeros(n, n)
+	if err = lapack.SyevrFloat(A, w, V, 0.0, nil, nil, la.OptJobZValue); err != nil {
+		return nil, err
+	}
+	wmax := 0.0
+	for _, a := range w.FloatArray() {
+		wmax = math.Max(wmax, math.Abs(a))
+	}
+	cols := make([]int, 0, n)
+	for k, a := range w.FloatArray() {
+		if a < -QCQP_EIGTOL*math.Max(wmax, 1.0) {
+			return nil, errors.New("matrix is not positive semidefinite")
+		}
+		if a > QCQP_EIGTOL*wmax {
+			cols = append(cols, k)
+		}
+	}
+	L = matrix.FloatZeros(n, len(cols))
+	for j, k := range cols {
+		sw := math.Sqrt(w.GetIndex(k))
+		for i := 0; i < n; i++ {
+			L.SetAt(i, j, sw*V.GetAt(i, k))
+		}
+	}
+	return
+}
+
+//    Solves a convex quadratically constrained quadratic program
+//
+//        minimize    (1/2)*x'*P0*x + q0'*x
+//        subject to  x'*P[k]*x + q[k]'*x <= r[k],  k = 0, ..., N-1
+//                    G*x <= h
+//                    A*x = b.
+//
+//    The quadratic constraints are given in qc. With P = L*L', by Cholesky
+//    or eigenvalue factorization, each is the second order cone constraint
+//
+//        || [ 2*L'*x; r - q'*x - 1 ] ||_2 <= r - q'*x + 1
+//
+//    and the objective is replaced by t + q0'*x with (1/2)*||L0'*x||^2 <= t.
+//    The problem is solved by SolveSocp. P0 may be nil for a linear objective,
+//    G, h, A and b may be nil.
+//
+//    Returns the solution with sol.X of length n and sol.S, sol.Z for the
+//    rows of G, and the multipliers of the quadratic constraints in lambda.
+//
+func Qcqp(P0, q0 *matrix.FloatMatrix, qc []QuadConstraint, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions) (sol *Solution, lambda *matrix.FloatMatrix, err error) {
+	if q0 == nil || q0.Cols() != 1 {
+		err = errors.New("'q0' must be a column matrix")
+		return
+	}
+	n := q0.Rows()
+	if P0 != nil && ! P0.SizeMatch(n, n) {
+		err = errors.New(fmt.Sprintf("'P0' must be matrix of size (%d,%d)", n, n))
+		return
+	}
+	G, h, A, b, err = checkBoundedArgs(G, h, A, b, n)
+	if err != nil {
+		return
+	}
+	// extra epigraph variable t for a quadratic objective
+	nx := n
+	if P0 != nil {
+		nx = n + 1
+	}
+	extend := func(M *matrix.FloatMatrix) *matrix.FloatMatrix {
+		if nx == n {
+			return M
+		}
+		E := matrix.FloatZeros(M.Rows(), nx)
+		E.SetSubMatrix(0, 0, M)
+		return E
+	}
+	blocks := make([]SocpBlock, 0, len(qc)+1)
+	// adds the block || [2*L'*x; v - 1] || <= v + 1 with v = r - q'*x - a*t
+	addBlock := func(P, q *matrix.FloatMatrix, r, a float64) error {
+		var L *matrix.FloatMatrix
+		if P != nil {
+			var ferr error
+			if L, ferr = quadFactor(P); ferr != nil {
+				return ferr
+			}
+		} else {
+			L = matrix.FloatZeros(n, 0)
+		}
+		m := L.Cols()
+		Gq := matrix.FloatZeros(m+2, nx)
+		for j := 0; j < n; j++ {
+			if q != nil {
+				Gq.SetAt(0, j, q.GetIndex(j))
+				Gq.SetAt(1, j, q.GetIndex(j))
+			}
+			for i := 0; i < m; i++ {
+				Gq.SetAt(i+2, j, -2.0*L.GetAt(j, i))
+			}
+		}
+		if nx > n {
+			Gq.SetAt(0, n, a)
+			Gq.SetAt(1, n, a)
+		}
+		hq := matrix.FloatZeros(m+2, 1)
+		hq.SetIndex(0, r+1.0)
+		hq.SetIndex(1, r-1.0)
+		blocks = append(blocks, SocpBlock{Gq, hq})
+		return nil
+	}
+	for k, c := range qc {
+		if c.P != nil && ! c.P.SizeMatch(n, n) {
+			err = errors.New(fmt.Sprintf("'P[%d]' must be matrix of size (%d,%d)", k, n, n))
+			return
+		}
+		if c.Q != nil && ! c.Q.SizeMatch(n, 1) {
+			err = errors.New(fmt.Sprintf("'q[%d]' must be matrix of size (%d,1)", k, n))
+			return
+		}
+		if e := addBlock(c.P, c.Q, c.R, 0.0); e != nil {
+			err = errors.New(fmt.Sprintf("'P[%d]': %s", k, e))
+			return
+		}
+	}
+	c := q0
+	if nx > n {
+		// (1/2)*||L0'*x||^2 <= t  <=>  ||L0'*x||^2 <= 2*t
+		if e := addBlock(P0, nil, 0.0, -2.0); e != nil {
+			err = errors.New(fmt.Sprintf("'P0': %s", e))
+			return
+		}
+		c = matrix.FloatZeros(nx, 1)
+		c.SetSubMatrix(0, 0, q0)
+		c.SetIndex(n, 1.0)
+	}
+	sol, res, err := SolveSocp(c, extend(G), h, extend(A), b, blocks, solopts, nil)
+	if sol == nil || res == nil {
+		return
+	}
+	if res.X != nil {
+		sol.X = matrix.FloatVector(res.X.FloatArray()[:n])
+	}
+	sol.S, sol.Z = res.Sl, res.Zl
+	if res.Zq != nil {
+		// d(optimum)/dr[k] = -(z0 + z1) of the kth cone
+		lambda = matrix.FloatZeros(len(qc), 1)
+		for k := range qc {
+			lambda.SetIndex(k, res.Zq[k].GetIndex(0)+res.Zq[k].GetIndex(1))
+		}
+	}
+	sol.Result = FloatSetNew("x", "y", "s", "z")
+	sol.Result.Set("x", sol.X)
+	sol.Result.Set("y", sol.Y)
+	sol.Result.Set("s", sol.S)
+	sol.Result.Set("z", sol.Z)
+	return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,88 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"testing"
+)
+
+// minimize -x0 - x1 and (1/2)*||x||^2 - 2*x0 - 2*x1 subject to x'*x <= 2.
+// Both have the optimum x = (1, 1) with multiplier 1/2. The reference is
+// the SOCP with ||x||_2 <= sqrt(2), and (1/2)*||x||^2 <= t written as
+// ||(2*x, 2*t - 1)||_2 <= 2*t + 1; its cone multiplier mu is the derivative
+// of the optimum with respect to the radius sqrt(r), so lambda =
+// mu/(2*sqrt(r)).
+func TestQcqp(t *testing.T) {
+	r := 2.0
+	qc := []QuadConstraint{{P: matrix.FloatIdentity(2), R: r}}
+	ball := matrix.FloatMatrixStacked([][]float64{
+		[]float64{0.0, 0.0},
+		[]float64{-1.0, 0.0},
+		[]float64{0.0, -1.0}}, matrix.RowOrder)
+	hball := matrix.FloatVector([]float64{math.Sqrt(r), 0.0, 0.0})
+
+	for _, tc := range []struct {
+		name string
+		P0   *matrix.FloatMatrix
+		q0   *matrix.FloatMatrix
+		obj  float64
+	}{{"linear", nil, matrix.FloatVector([]float64{-1.0, -1.0}), -2.0},
+		{"quadratic", matrix.FloatIdentity(2), matrix.FloatVector([]float64{-2.0, -2.0}), -3.0}} {
+
+		sol, lambda, err := Qcqp(tc.P0, tc.q0, qc, nil, nil, nil, nil, &SolverOptions{MaxIter: 30})
+		if err != nil {
+			t.Fatalf("%s: %s\n", tc.name, err)
+		}
+		if e := maxDiff(sol.X, matrix.FloatVector([]float64{1.0, 1.0})); e > 1e-6 {
+			t.Errorf("%s: x = %v\n", tc.name, sol.X.FloatArray())
+		}
+		if lambda == nil || math.Abs(lambda.GetIndex(0)-0.5) > 1e-6 {
+			t.Errorf("%s: lambda = %v, expected 0.5\n", tc.name, lambda)
+		}
+
+		// the reference SOCP
+		Ghq := FloatSetNew("Gq", "hq")
+		c := tc.q0
+		if tc.P0 == nil {
+			Ghq.Append("Gq", ball)
+			Ghq.Append("hq", hball)
+		} else {
+			c = matrix.FloatVector([]float64{tc.q0.GetIndex(0), tc.q0.GetIndex(1), 1.0})
+			Gb := matrix.FloatZeros(3, 3)
+			Gb.SetSubMatrix(0, 0, ball)
+			Ge := matrix.FloatMatrixStacked([][]float64{
+				[]float64{0.0, 0.0, -2.0},
+				[]float64{0.0, 0.0, -2.0},
+				[]float64{-2.0, 0.0, 0.0},
+				[]float64{0.0, -2.0, 0.0}}, matrix.RowOrder)
+			Ghq.Append("Gq", Gb, Ge)
+			Ghq.Append("hq", hball, matrix.FloatVector([]float64{1.0, -1.0, 0.0, 0.0}))
+		}
+		ref, err := Socp(c, nil, nil, nil, nil, Ghq, &SolverOptions{MaxIter: 30}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: Socp: %s\n", tc.name, err)
+		}
+		xref := matrix.FloatVector(ref.Result.At("x")[0].FloatArray()[:2])
+		if e := maxDiff(sol.X, xref); e > 1e-6 {
+			t.Errorf("%s: x = %v, Socp %v\n", tc.name, sol.X.FloatArray(), xref.FloatArray())
+		}
+		mu := ref.Result.At("zq")[0].GetIndex(0)
+		if lambda != nil && math.Abs(lambda.GetIndex(0)-mu/(2.0*math.Sqrt(r))) > 1e-6 {
+			t.Errorf("%s: lambda = %v, Socp multiplier %v\n", tc.name, lambda.GetIndex(0), mu)
+		}
+		if math.Abs(sol.PrimalObjective-tc.obj) > 1e-6 || math.Abs(ref.PrimalObjective-tc.obj) > 1e-6 {
+			t.Errorf("%s: objective %v, Socp %v, expected %v\n", tc.name, sol.PrimalObjective,
+				ref.PrimalObjective, tc.obj)
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
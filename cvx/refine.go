@@ -0,0 +1,112 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/linalg/blas"
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+)
+
+// Defaults of adaptive iterative refinement, see SolverOptions.
+const (
+	REFINEMENT_TOL = 1e-12
+	MAX_REFINEMENT = 8
+)
+
+// Statistics of one iteration of ConeLp or ConeQp.
+type IterationStats struct {
+	// Relative residual ||b - K*u|| / max(1, ||b||) of the last KKT solve
+	// of the iteration after refinement; zero unless refinement is
+	// adaptive.
+	KKTResidual float64
+	// Refinement steps of all KKT solves of the iteration.
+	Refinement int
+	// Conjugate gradient iterations of all KKT solves of the iteration
+	// with KKT solver "cg", and the number of its solves that stopped at
+	// CGMaxIter iterations without reaching the CG tolerance.
+	CGIterations  int
+	CGUnconverged int
+}
+
+// Returns the tolerance and the maximum number of steps of adaptive
+// refinement of solopts.
+func refinementLimits(solopts *SolverOptions) (tol float64, maxsteps int) {
+	tol, maxsteps = REFINEMENT_TOL, MAX_REFINEMENT
+	if solopts.RefinementTol > 0.0 {
+		tol = solopts.RefinementTol
+	}
+	if solopts.MaxRefinement > 0 {
+		maxsteps = solopts.MaxRefinement
+	}
+	return
+}
+
+// Euclidean norm of the vectors of vs stacked into one vector.
+func stackedNrm2(vs []*matrix.FloatMatrix) float64 {
+	s := 0.0
+	for _, v := range vs {
+		a := blas.Nrm2Float(v)
+		s += a*a
+	}
+	return math.Sqrt(s)
+}
+
+// Adaptive iterative refinement of the solution u of the KKT system with
+// right hand side b. On entry u contains the solution of the first solve.
+// resid(u, r) adds the residual of u to r, so that r = b on entry gives
+// the residual; solve(r) overwrites r with the correction. r and p are work
+// vectors of the shapes of u.
+//
+// The corrections are applied while the relative residual is above tol and
+// decreases, for at most maxsteps steps. A correction that does not decrease
+// the residual is undone. Returns the relative residual of u and the number
+// of steps taken.
+func refineAdaptive(u, b, r, p []*matrix.FloatMatrix, tol float64, maxsteps int,
+	resid func(u, r []*matrix.FloatMatrix) error, solve func(r []*matrix.FloatMatrix) error) (rnrm float64, steps int, err error) {
+
+	bnrm := math.Max(1.0, stackedNrm2(b))
+	residual := func() (float64, error) {
+		for k := range r {
+			blas.Copy(b[k], r[k])
+		}
+		if err := resid(u, r); err != nil {
+			return 0.0, err
+		}
+		return stackedNrm2(r) / bnrm, nil
+	}
+	if rnrm, err = residual(); err != nil {
+		return
+	}
+	for steps < maxsteps && rnrm > tol {
+		if err = solve(r); err != nil {
+			return
+		}
+		for k := range u {
+			blas.Copy(u[k], p[k])
+			blas.AxpyFloat(r[k], u[k], 1.0)
+		}
+		steps++
+		var rn float64
+		if rn, err = residual(); err != nil {
+			return
+		}
+		if ! (rn < rnrm) {
+			// no progress, keep the previous solution
+			for k := range u {
+				blas.Copy(p[k], u[k])
+			}
+			break
+		}
+		rnrm = rn
+	}
+	return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,150 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"testing"
+)
+
+// Refinement of a diagonal system solved with a 1% error in the inverse.
+func TestRefineAdaptive(t *testing.T) {
+	d := []float64{1.0, 2.0, 4.0, 8.0}
+	b := matrix.FloatVector([]float64{1.0, -1.0, 2.0, 3.0})
+	inexact := func(r *matrix.FloatMatrix) {
+		for k, a := range d {
+			r.SetIndex(k, 1.01*r.GetIndex(k)/a)
+		}
+	}
+	u := b.Copy()
+	inexact(u)
+	vec := func(m *matrix.FloatMatrix) []*matrix.FloatMatrix {
+		return []*matrix.FloatMatrix{m}
+	}
+	rnrm, steps, err := refineAdaptive(vec(u), vec(b), vec(matrix.FloatZeros(4, 1)),
+		vec(matrix.FloatZeros(4, 1)), 1e-12, 20,
+		func(u, r []*matrix.FloatMatrix) error {
+			// r := r - K*u
+			for k, a := range d {
+				r[0].SetIndex(k, r[0].GetIndex(k)-a*u[0].GetIndex(k))
+			}
+			return nil
+		},
+		func(r []*matrix.FloatMatrix) error {
+			inexact(r[0])
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("refineAdaptive: %s\n", err)
+	}
+	if rnrm > 1e-12 || steps == 0 || steps == 20 {
+		t.Errorf("residual %.3e after %d steps\n", rnrm, steps)
+	}
+	for k, a := range d {
+		if e := u.GetIndex(k) - b.GetIndex(k)/a; e > 1e-12 || e < -1e-12 {
+			t.Errorf("u[%d] has error %.3e\n", k, e)
+		}
+	}
+}
+
+// The SDP of examples/testsdp.go.
+func makeTestSdp() (c *matrix.FloatMatrix, Ghs *FloatMatrixSet) {
+	g0 := matrix.FloatMatrixStacked([][]float64{
+		[]float64{-7., -11., -11., 3.},
+		[]float64{7., -18., -18., 8.},
+		[]float64{-2., -8., -8., 1.}}, matrix.ColumnOrder)
+	g1 := matrix.FloatMatrixStacked([][]float64{
+		[]float64{-21., -11., 0., -11., 10., 8., 0., 8., 5.},
+		[]float64{0., 10., 16., 10., -10., -10., 16., -10., 3.},
+		[]float64{-5., 2., -17., 2., -6., 8., -17., -7., 6.}}, matrix.ColumnOrder)
+	h0 := matrix.FloatMatrixStacked([][]float64{
+		[]float64{33., -9.},
+		[]float64{-9., 26.}}, matrix.ColumnOrder)
+	h1 := matrix.FloatMatrixStacked([][]float64{
+		[]float64{14., 9., 40.},
+		[]float64{9., 91., 10.},
+		[]float64{40., 10., 15.}}, matrix.ColumnOrder)
+	Ghs = FloatSetNew("Gs", "hs")
+	Ghs.Append("Gs", g0, g1)
+	Ghs.Append("hs", h0, h1)
+	c = matrix.FloatVector([]float64{1.0, -1.0, 1.0})
+	return
+}
+
+// With adaptive refinement the near-optimal iterates of an SDP solved with
+// "ldl" and tight tolerances keep converging: the KKT residuals stay small
+// and the solve ends Optimal instead of Unknown.
+func TestAdaptiveRefinementSdp(t *testing.T) {
+	c, Ghs := makeTestSdp()
+	ref, err := Sdp(c, nil, nil, nil, nil, Ghs, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatalf("Sdp: %s\n", err)
+	}
+	tol := 1e-10
+	solopts := &SolverOptions{KKTSolverName: "ldl", MaxIter: 50,
+		AbsTol: tol, RelTol: tol, FeasTol: tol}
+	fixed, _ := Sdp(c, nil, nil, nil, nil, Ghs, solopts, nil, nil)
+	if fixed != nil {
+		t.Logf("fixed refinement: status %v after %d iterations\n", fixed.Status, fixed.Iterations)
+	}
+	solopts.AdaptiveRefinement = true
+	sol, err := Sdp(c, nil, nil, nil, nil, Ghs, solopts, nil, nil)
+	if err != nil {
+		t.Fatalf("adaptive refinement: %s\n", err)
+	}
+	if sol.Status != Optimal {
+		t.Fatalf("adaptive refinement: status %v\n", sol.Status)
+	}
+	if sol.Gap > tol && sol.RelativeGap > tol {
+		t.Errorf("gap %.3e, relative %.3e\n", sol.Gap, sol.RelativeGap)
+	}
+	for k, st := range sol.IterStats {
+		if st.KKTResidual > 1e-8 {
+			t.Errorf("iteration %d: KKT residual %.3e after %d steps\n", k, st.KKTResidual, st.Refinement)
+		}
+	}
+	x, xref := sol.Result.At("x")[0], ref.Result.At("x")[0]
+	if e := maxDiff(x, xref); e > 1e-6 {
+		t.Errorf("||x - x_ref|| = %.3e\n", e)
+	}
+}
+
+// ConeQp applies a fixed Refinement: two steps for each of the two KKT
+// solves of an iteration, none by default, and the same solution.
+func TestConeQpRefinement(t *testing.T) {
+	P, q, G, h, A, b := makeActiveSetQp()
+	plain, err := ConeQp(P, q, G, h, A, b, nil, &SolverOptions{MaxIter: 30}, nil)
+	if err != nil {
+		t.Fatalf("ConeQp: %s\n", err)
+	}
+	if plain.Stats.Refinement != 0 {
+		t.Errorf("%d refinement steps by default\n", plain.Stats.Refinement)
+	}
+	sol, err := ConeQp(P, q, G, h, A, b, nil, &SolverOptions{MaxIter: 30, Refinement: 2}, nil)
+	if err != nil {
+		t.Fatalf("ConeQp with refinement: %s\n", err)
+	}
+	for k, st := range sol.IterStats {
+		if st.Refinement != 4 {
+			t.Errorf("iteration %d: %d refinement steps\n", k, st.Refinement)
+		}
+	}
+	if len(sol.IterStats) == 0 || sol.Stats.Refinement != 4*len(sol.IterStats) {
+		t.Errorf("%d refinement steps in %d iterations\n", sol.Stats.Refinement, len(sol.IterStats))
+	}
+	if e := maxDiff(sol.X, plain.X); e > 1e-8 {
+		t.Errorf("||x - x_plain|| = %.3e\n", e)
+	}
+	if math.Abs(sol.PrimalObjective-plain.PrimalObjective) > 1e-8 {
+		t.Errorf("objective %v, without refinement %v\n", sol.PrimalObjective, plain.PrimalObjective)
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,201 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"fmt"
+)
+
+// Nesterov-Todd scaling matrix W of the interior-point solvers. W is block
+// diagonal with one block for the nonlinear constraints of Cp and Cpl, the
+// 'l' block, and one block per 'q' and 's' cone:
+//
+//   - nonlinear block:  W = diag(Dnl), W^{-1} = diag(Dnli)
+//   - 'l' block:        W = diag(D),   W^{-1} = diag(Di)
+//   - 'q' block k:      W = Beta[k]*(2*V[k]*V[k]' - J), J = [1, 0; 0, -I],
+//                       V[k] has unit hyperbolic norm
+//   - 's' block k:      W*x = vec(R[k]'*mat(x)*R[k]), Rti[k] = R[k]^{-T}
+//
+// Dnl and Dnli are nil if there are no nonlinear constraints.
+//
+// A Scaling is computed by ComputeScaling, updated in place by
+// UpdateScaling and applied to vectors by Scale. Custom KKT solvers receive
+// the current scaling as their first argument.
+type Scaling struct {
+	Dnl, Dnli *matrix.FloatMatrix
+	D, Di     *matrix.FloatMatrix
+	V         []*matrix.FloatMatrix
+	Beta      []float64
+	R, Rti    []*matrix.FloatMatrix
+	// goroutines for the per-cone work of Scale and UpdateScaling
+	workers int
+	// workspace of Scale and UpdateScaling, set up on first use and not
+	// copied: the offsets of the 'q' and 's' blocks in x and of the 's'
+	// blocks in lmbda, the work matrices per worker, the arguments of the
+	// current call and the block functions bound to W
+	indq, inds, indd []int
+	maxr int
+	wvec, wmat, wupd []*matrix.FloatMatrix
+	x, lmbda, s, z *matrix.FloatMatrix
+	trans, inverse bool
+	scaleq, scales, updateq, updates func(k, wk int) error
+}
+
+// Returns the number of workers of W and sets up the workspace for them.
+func (W *Scaling) prepareWork() int {
+	n := W.workers
+	if n < 1 {
+		n = 1
+	}
+	for len(W.wvec) < n {
+		W.wvec = append(W.wvec, nil)
+		W.wmat = append(W.wmat, nil)
+		W.wupd = append(W.wupd, nil)
+	}
+	if W.scaleq == nil {
+		ind := W.D.NumElements()
+		if W.Dnl != nil {
+			ind += W.Dnl.NumElements()
+		}
+		W.indq = make([]int, len(W.V))
+		for k, v := range W.V {
+			W.indq[k] = ind
+			ind += v.NumElements()
+		}
+		W.inds = make([]int, len(W.R))
+		W.indd = make([]int, len(W.R))
+		ind2 := ind
+		for k, r := range W.R {
+			W.inds[k] = ind
+			W.indd[k] = ind2
+			ind += r.Rows()*r.Rows()
+			ind2 += r.Rows()
+			if r.Rows() > W.maxr {
+				W.maxr = r.Rows()
+			}
+		}
+		W.scaleq = W.scaleQ
+		W.scales = W.scaleS
+		W.updateq = W.updateQ
+		W.updates = W.updateS
+	}
+	return n
+}
+
+// Returns the workspace vector of worker wk of length at least n. The
+// vector only grows so that calls with different column counts of x do not
+// reallocate it; the callers pass n explicitly to the BLAS routines.
+func (W *Scaling) vecWork(wk, n int) *matrix.FloatMatrix {
+	if W.wvec[wk] == nil || W.wvec[wk].Rows() < n {
+		W.wvec[wk] = matrix.FloatZeros(n, 1)
+	}
+	return W.wvec[wk]
+}
+
+// Returns the n x n workspace matrix of worker wk.
+func (W *Scaling) matWork(wk, n int) *matrix.FloatMatrix {
+	if W.wmat[wk] == nil || W.wmat[wk].Rows() != n {
+		W.wmat[wk] = matrix.FloatZeros(n, n)
+	}
+	return W.wmat[wk]
+}
+
+// Returns the identity scaling for the cone dims with mnl nonlinear
+// constraints.
+func NewScaling(dims *DimensionSet, mnl int) *Scaling {
+	W := &Scaling{}
+	if mnl > 0 {
+		W.Dnl = matrix.FloatOnes(mnl, 1)
+		W.Dnli = matrix.FloatOnes(mnl, 1)
+	}
+	W.D = matrix.FloatOnes(dims.Sum("l"), 1)
+	W.Di = matrix.FloatOnes(dims.Sum("l"), 1)
+	W.Beta = make([]float64, len(dims.At("q")))
+	W.V = make([]*matrix.FloatMatrix, len(dims.At("q")))
+	for k, n := range dims.At("q") {
+		W.Beta[k] = 1.0
+		W.V[k] = matrix.FloatZeros(n, 1)
+		W.V[k].SetIndex(0, 1.0)
+	}
+	W.R = make([]*matrix.FloatMatrix, len(dims.At("s")))
+	W.Rti = make([]*matrix.FloatMatrix, len(dims.At("s")))
+	for k, n := range dims.At("s") {
+		W.R[k] = matrix.FloatIdentity(n)
+		W.Rti[k] = matrix.FloatIdentity(n)
+	}
+	return W
+}
+
+// Returns a copy of W.
+func (W *Scaling) Copy() *Scaling {
+	W2 := &Scaling{}
+	if W.Dnl != nil {
+		W2.Dnl = W.Dnl.Copy()
+		W2.Dnli = W.Dnli.Copy()
+	}
+	W2.D = W.D.Copy()
+	W2.Di = W.Di.Copy()
+	W2.Beta = make([]float64, len(W.Beta))
+	copy(W2.Beta, W.Beta)
+	W2.V = make([]*matrix.FloatMatrix, len(W.V))
+	for k, v := range W.V {
+		W2.V[k] = v.Copy()
+	}
+	W2.R = make([]*matrix.FloatMatrix, len(W.R))
+	W2.Rti = make([]*matrix.FloatMatrix, len(W.Rti))
+	for k := range W.R {
+		W2.R[k] = W.R[k].Copy()
+		W2.Rti[k] = W.Rti[k].Copy()
+	}
+	W2.workers = W.workers
+	return W2
+}
+
+// Copies the contents of W to W2 of the same structure, keeping the
+// matrices of W2.
+func (W *Scaling) CopyTo(W2 *Scaling) {
+	cp := func(src, dst *matrix.FloatMatrix) {
+		if src != nil && dst != nil {
+			copy(dst.FloatArray(), src.FloatArray())
+		}
+	}
+	cp(W.Dnl, W2.Dnl)
+	cp(W.Dnli, W2.Dnli)
+	cp(W.D, W2.D)
+	cp(W.Di, W2.Di)
+	copy(W2.Beta, W.Beta)
+	for k := range W.V {
+		cp(W.V[k], W2.V[k])
+	}
+	for k := range W.R {
+		cp(W.R[k], W2.R[k])
+		cp(W.Rti[k], W2.Rti[k])
+	}
+}
+
+// Prints the blocks of W.
+func (W *Scaling) Print() {
+	if W.Dnl != nil {
+		fmt.Printf("** dnl **\n%v\n", W.Dnl)
+		fmt.Printf("** dnli **\n%v\n", W.Dnli)
+	}
+	fmt.Printf("** d **\n%v\n", W.D)
+	fmt.Printf("** di **\n%v\n", W.Di)
+	fmt.Printf("** beta **\n%v\n", W.Beta)
+	for k, v := range W.V {
+		fmt.Printf("** v[%d] **\n%v\n", k, v)
+	}
+	for k := range W.R {
+		fmt.Printf("** r[%d] **\n%v\n", k, W.R[k])
+		fmt.Printf("** rti[%d] **\n%v\n", k, W.Rti[k])
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
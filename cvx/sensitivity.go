@@ -0,0 +1,434 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Default tolerance for deciding if an inequality is active.
+const SENSITIVITY_TOL = 1e-6
+
+// Binding status of a constraint row.
+type ConstraintStatus int
+
+const (
+	// Constraint has positive slack.
+	NonBinding = ConstraintStatus(1 + iota)
+	// Constraint is active with a nonzero multiplier.
+	Binding
+	// Constraint is active but its multiplier is zero.
+	Degenerate
+)
+
+func (s ConstraintStatus) String() string {
+	switch s {
+	case NonBinding:
+		return "nonbinding"
+	case Binding:
+		return "binding"
+	case Degenerate:
+		return "degenerate"
+	}
+	return "unknown"
+}
+
+// Optional names of the variables and constraint rows of a linear program.
+// Any of the slices may be nil or shorter than the dimension; missing names
+// are reported as empty strings.
+type LpNames struct {
+	Variables    []string
+	Inequalities []string
+	Equalities   []string
+}
+
+// Sensitivity information of a single variable.
+type VariableSensitivity struct {
+	Name  string
+	Index int
+	Value float64
+	// Reduced cost c_j + a_j'*y + g_j'*z computed over the equality rows and
+	// the inequality rows that are not simple bounds on x_j.
+	ReducedCost float64
+	// Interval of c_j over which the optimal basis stays optimal.
+	CostLower, CostUpper float64
+}
+
+// Sensitivity information of a single row of G or A.
+type ConstraintSensitivity struct {
+	Name  string
+	Index int
+	// Slack h_i - G_i*x for inequalities, residual b_i - A_i*x for equalities.
+	Slack float64
+	// Multiplier z_i or y_i from the solution.
+	Dual float64
+	// Rate of change of the optimal value per unit increase of the
+	// right-hand side. Equal to -Dual.
+	ShadowPrice float64
+	Status      ConstraintStatus
+	// Interval of h_i (or b_i) over which the optimal basis stays feasible.
+	RhsLower, RhsUpper float64
+}
+
+// Sensitivity report of an optimal solution of a linear program.
+type SensitivityReport struct {
+	Objective    float64
+	Variables    []VariableSensitivity
+	Inequalities []ConstraintSensitivity
+	Equalities   []ConstraintSensitivity
+	// Rows of [G; A] that define the optimal vertex. Indexes greater or
+	// equal to G.Rows() refer to rows of A.
+	Basis []int
+	// True if the active rows do not determine a unique vertex. Ranging
+	// intervals are then set to NaN.
+	Incomplete bool
+}
+
+//    Computes sensitivity information for an optimal solution of
+//
+//        minimize    c'*x
+//        subject to  G*x <= h
+//                    A*x = b
+//
+//    as returned by Lp.
+//
+//    Inequalities with slack at most tol*(1+|h_i|) are considered active.
+//    A basis of n linearly independent rows is chosen from the equality rows
+//    and the active inequality rows, preferring rows with large multipliers.
+//    Ranging intervals are computed with respect to this basis. If the active
+//    rows do not span R^n the report is marked incomplete and the intervals
+//    are NaN.
+//
+//    Arguments names and A, b may be nil. If tol is not positive
+//    SENSITIVITY_TOL is used.
+//
+func LpSensitivity(c, G, h, A, b *matrix.FloatMatrix, sol *Solution, names *LpNames, tol float64) (rep *SensitivityReport, err error) {
+
+	if sol == nil || sol.Status != Optimal {
+		err = errors.New("sensitivity analysis requires an optimal solution")
+		return
+	}
+	if c == nil || c.Cols() != 1 {
+		err = errors.New("'c' must be a dense column matrix")
+		return
+	}
+	n := c.Rows()
+	if G == nil || G.Cols() != n {
+		err = errors.New(fmt.Sprintf("'G' must be a dense matrix with %d columns", n))
+		return
+	}
+	m := G.Rows()
+	if h == nil || !h.SizeMatch(m, 1) {
+		err = errors.New(fmt.Sprintf("'h' must be a dense matrix of size (%d,1)", m))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	p := A.Rows()
+	if A.Cols() != n || !b.SizeMatch(p, 1) {
+		err = errors.New(fmt.Sprintf("'A' must have %d columns and 'b' size (%d,1)", n, p))
+		return
+	}
+	x, z := sol.X, sol.Z
+	if x == nil || z == nil || !x.SizeMatch(n, 1) || !z.SizeMatch(m, 1) {
+		err = errors.New("solution does not match problem dimensions")
+		return
+	}
+	y := sol.Y
+	if y == nil {
+		y = matrix.FloatZeros(p, 1)
+	} else if !y.SizeMatch(p, 1) {
+		err = errors.New("solution does not match problem dimensions")
+		return
+	}
+	if names == nil {
+		names = &LpNames{}
+	}
+	if tol <= 0.0 {
+		tol = SENSITIVITY_TOL
+	}
+
+	rep = &SensitivityReport{}
+	rep.Objective = vecDot(c, x)
+
+	// inequality rows
+	active := make([]bool, m)
+	slack := make([]float64, m)
+	grow := make([]float64, n)
+	rep.Inequalities = make([]ConstraintSensitivity, m)
+	for i := 0; i < m; i++ {
+		G.GetRowArray(i, grow)
+		slack[i] = h.GetIndex(i) - dotArray(grow, x.FloatArray())
+		zi := z.GetIndex(i)
+		cs := &rep.Inequalities[i]
+		cs.Name = nameAt(names.Inequalities, i)
+		cs.Index = i
+		cs.Slack = slack[i]
+		cs.Dual = zi
+		cs.ShadowPrice = -zi
+		active[i] = slack[i] <= tol*(1.0+math.Abs(h.GetIndex(i)))
+		switch {
+		case !active[i]:
+			cs.Status = NonBinding
+		case zi > tol:
+			cs.Status = Binding
+		default:
+			cs.Status = Degenerate
+		}
+	}
+	// equality rows
+	arow := make([]float64, n)
+	rep.Equalities = make([]ConstraintSensitivity, p)
+	for i := 0; i < p; i++ {
+		A.GetRowArray(i, arow)
+		yi := y.GetIndex(i)
+		cs := &rep.Equalities[i]
+		cs.Name = nameAt(names.Equalities, i)
+		cs.Index = i
+		cs.Slack = b.GetIndex(i) - dotArray(arow, x.FloatArray())
+		cs.Dual = yi
+		cs.ShadowPrice = -yi
+		if math.Abs(yi) > tol {
+			cs.Status = Binding
+		} else {
+			cs.Status = Degenerate
+		}
+	}
+
+	// reduced costs: c + A'*y + G'*z over rows that are not bounds on x_j
+	rep.Variables = make([]VariableSensitivity, n)
+	for j := 0; j < n; j++ {
+		vs := &rep.Variables[j]
+		vs.Name = nameAt(names.Variables, j)
+		vs.Index = j
+		vs.Value = x.GetIndex(j)
+		vs.ReducedCost = c.GetIndex(j)
+		for i := 0; i < p; i++ {
+			vs.ReducedCost += A.GetAt(i, j) * y.GetIndex(i)
+		}
+	}
+	for i := 0; i < m; i++ {
+		G.GetRowArray(i, grow)
+		if isBoundRow(grow) < 0 {
+			for j := 0; j < n; j++ {
+				rep.Variables[j].ReducedCost += grow[j] * z.GetIndex(i)
+			}
+		}
+	}
+
+	rep.Basis = selectBasis(G, A, active, z)
+	if len(rep.Basis) < n {
+		rep.Incomplete = true
+		nan := math.NaN()
+		for j := range rep.Variables {
+			rep.Variables[j].CostLower = nan
+			rep.Variables[j].CostUpper = nan
+		}
+		for i := range rep.Inequalities {
+			rep.Inequalities[i].RhsLower = nan
+			rep.Inequalities[i].RhsUpper = nan
+		}
+		for i := range rep.Equalities {
+			rep.Equalities[i].RhsLower = nan
+			rep.Equalities[i].RhsUpper = nan
+		}
+		return
+	}
+
+	// basis matrix B with the selected rows of [G; A]
+	B := matrix.FloatZeros(n, n)
+	inBasis := make([]int, m)
+	for i := range inBasis {
+		inBasis[i] = -1
+	}
+	for k, r := range rep.Basis {
+		if r < m {
+			G.GetRowArray(r, grow)
+			inBasis[r] = k
+		} else {
+			A.GetRowArray(r-m, grow)
+		}
+		for j := 0; j < n; j++ {
+			B.SetAt(k, j, grow[j])
+		}
+	}
+	ipiv := make([]int32, n)
+	if err = lapack.Getrf(B, ipiv); err != nil {
+		rep = nil
+		err = errors.New("singular basis matrix")
+		return
+	}
+
+	// basis multipliers: B'*lambda = -c
+	lambda := c.Copy()
+	lambda.Scale(-1.0)
+	if err = lapack.Getrs(B, lambda, ipiv, la.OptTrans); err != nil {
+		rep = nil
+		return
+	}
+
+	// cost ranging; c_j + t changes lambda by -t*w where B'*w = e_j.
+	// Multipliers of basic inequality rows must stay nonnegative.
+	w := matrix.FloatZeros(n, 1)
+	for j := 0; j < n; j++ {
+		w.Scale(0.0)
+		w.SetIndex(j, 1.0)
+		lapack.Getrs(B, w, ipiv, la.OptTrans)
+		tlo, thi := math.Inf(-1), math.Inf(1)
+		for k, r := range rep.Basis {
+			if r >= m {
+				continue
+			}
+			wk := w.GetIndex(k)
+			lk := math.Max(lambda.GetIndex(k), 0.0)
+			if wk > tol {
+				thi = math.Min(thi, lk/wk)
+			} else if wk < -tol {
+				tlo = math.Max(tlo, lk/wk)
+			}
+		}
+		cj := c.GetIndex(j)
+		rep.Variables[j].CostLower = cj + tlo
+		rep.Variables[j].CostUpper = cj + thi
+	}
+
+	// right-hand side ranging of basic rows; rhs_k + t moves x by t*v
+	// where B*v = e_k. Nonbasic inequality slacks must stay nonnegative.
+	v := matrix.FloatZeros(n, 1)
+	for k, r := range rep.Basis {
+		v.Scale(0.0)
+		v.SetIndex(k, 1.0)
+		lapack.Getrs(B, v, ipiv)
+		tlo, thi := math.Inf(-1), math.Inf(1)
+		for i := 0; i < m; i++ {
+			if inBasis[i] >= 0 {
+				continue
+			}
+			G.GetRowArray(i, grow)
+			t := dotArray(grow, v.FloatArray())
+			si := math.Max(slack[i], 0.0)
+			if t > tol {
+				thi = math.Min(thi, si/t)
+			} else if t < -tol {
+				tlo = math.Max(tlo, si/t)
+			}
+		}
+		if r < m {
+			hi := h.GetIndex(r)
+			rep.Inequalities[r].RhsLower = hi + tlo
+			rep.Inequalities[r].RhsUpper = hi + thi
+		} else {
+			bi := b.GetIndex(r - m)
+			rep.Equalities[r-m].RhsLower = bi + tlo
+			rep.Equalities[r-m].RhsUpper = bi + thi
+		}
+	}
+	// nonbasic inequalities may move down until they become active
+	for i := 0; i < m; i++ {
+		if inBasis[i] >= 0 {
+			continue
+		}
+		hi := h.GetIndex(i)
+		rep.Inequalities[i].RhsLower = hi - math.Max(slack[i], 0.0)
+		rep.Inequalities[i].RhsUpper = math.Inf(1)
+	}
+	return
+}
+
+// Selects up to n linearly independent rows of [G; A]. Equality rows are
+// taken first, then active inequality rows in order of decreasing
+// multiplier. Independence is tested with modified Gram-Schmidt.
+func selectBasis(G, A *matrix.FloatMatrix, active []bool, z *matrix.FloatMatrix) []int {
+	n := G.Cols()
+	m := G.Rows()
+	cand := make([]int, 0, m+A.Rows())
+	for i := 0; i < A.Rows(); i++ {
+		cand = append(cand, m+i)
+	}
+	ineq := make([]int, 0, m)
+	for i := 0; i < m; i++ {
+		if active[i] {
+			ineq = append(ineq, i)
+		}
+	}
+	// insertion sort by decreasing z
+	for k := 1; k < len(ineq); k++ {
+		for l := k; l > 0 && z.GetIndex(ineq[l]) > z.GetIndex(ineq[l-1]); l-- {
+			ineq[l], ineq[l-1] = ineq[l-1], ineq[l]
+		}
+	}
+	cand = append(cand, ineq...)
+
+	basis := make([]int, 0, n)
+	Q := make([][]float64, 0, n)
+	for _, r := range cand {
+		if len(basis) == n {
+			break
+		}
+		q := make([]float64, n)
+		if r < m {
+			G.GetRowArray(r, q)
+		} else {
+			A.GetRowArray(r-m, q)
+		}
+		nrm0 := math.Sqrt(dotArray(q, q))
+		if nrm0 == 0.0 {
+			continue
+		}
+		for _, u := range Q {
+			t := dotArray(u, q)
+			for j := range q {
+				q[j] -= t * u[j]
+			}
+		}
+		nrm := math.Sqrt(dotArray(q, q))
+		if nrm <= 1e-9*nrm0 {
+			continue
+		}
+		for j := range q {
+			q[j] /= nrm
+		}
+		Q = append(Q, q)
+		basis = append(basis, r)
+	}
+	return basis
+}
+
+// Returns the index of the only nonzero of row g, or -1 if the row has
+// more or less than one nonzero.
+func isBoundRow(g []float64) int {
+	k := -1
+	for j, v := range g {
+		if v != 0.0 {
+			if k >= 0 {
+				return -1
+			}
+			k = j
+		}
+	}
+	return k
+}
+
+func nameAt(names []string, i int) string {
+	if i < len(names) {
+		return names[i]
+	}
+	return ""
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,76 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"math"
+	"testing"
+)
+
+// The LP of makeTestLp: minimize -4*x0 - 5*x1 subject to 2*x0 + x1 <= 3,
+// x0 + 2*x1 <= 3, x >= 0. The optimum x = (1, 1) is defined by the first
+// two rows with z = (1, 2, 0, 0). It stays optimal while -c is in the cone
+// of (2, 1) and (1, 2), ie. for c0 in [-10, -2.5] and c1 in [-8, -2], and
+// the basis stays feasible for h0 and h1 in [1.5, 6].
+func TestLpSensitivityRanging(t *testing.T) {
+	c, G, h := makeTestLp()
+	sol, err := Lp(c, G, h, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := &LpNames{Variables: []string{"x0", "x1"}, Inequalities: []string{"r0", "r1"}}
+	rep, err := LpSensitivity(c, G, h, nil, nil, sol, names, 0.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Incomplete || len(rep.Basis) != 2 {
+		t.Fatalf("basis %v, incomplete %v\n", rep.Basis, rep.Incomplete)
+	}
+	if math.Abs(rep.Objective+9.0) > 1e-6 {
+		t.Errorf("objective %v, expected -9\n", rep.Objective)
+	}
+	near := func(a, b float64) bool {
+		if math.IsInf(b, 0) {
+			return a == b
+		}
+		return math.Abs(a-b) <= 1e-5*math.Max(1.0, math.Abs(b))
+	}
+
+	costs := [][2]float64{{-10.0, -2.5}, {-8.0, -2.0}}
+	for j, vs := range rep.Variables {
+		if !near(vs.Value, 1.0) || !near(vs.ReducedCost, 0.0) {
+			t.Errorf("%s: value %v, reduced cost %v\n", vs.Name, vs.Value, vs.ReducedCost)
+		}
+		if !near(vs.CostLower, costs[j][0]) || !near(vs.CostUpper, costs[j][1]) {
+			t.Errorf("%s: cost range [%v, %v], expected %v\n", vs.Name, vs.CostLower, vs.CostUpper, costs[j])
+		}
+	}
+
+	inf := math.Inf(1)
+	for i, tc := range []struct {
+		name   string
+		status ConstraintStatus
+		dual   float64
+		lo, hi float64
+	}{{"r0", Binding, 1.0, 1.5, 6.0}, {"r1", Binding, 2.0, 1.5, 6.0},
+		{"", NonBinding, 0.0, -1.0, inf}, {"", NonBinding, 0.0, -1.0, inf}} {
+		cs := rep.Inequalities[i]
+		if cs.Name != tc.name || cs.Status != tc.status {
+			t.Errorf("row %d: name %q status %v, expected %q %v\n", i, cs.Name, cs.Status, tc.name, tc.status)
+		}
+		if !near(cs.Dual, tc.dual) || !near(cs.ShadowPrice, -tc.dual) {
+			t.Errorf("row %d: dual %v, shadow price %v, expected %v\n", i, cs.Dual, cs.ShadowPrice, tc.dual)
+		}
+		if !near(cs.RhsLower, tc.lo) || !near(cs.RhsUpper, tc.hi) {
+			t.Errorf("row %d: rhs range [%v, %v], expected [%v, %v]\n", i, cs.RhsLower, cs.RhsUpper, tc.lo, tc.hi)
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,968 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// Default iteration limit of the simplex method.
+	SIMPLEX_MAXITERS = 10000
+	// Bound violation accepted as feasible.
+	SIMPLEX_FEASTOL = 1e-9
+	// Reduced cost accepted as optimal.
+	SIMPLEX_OPTTOL = 1e-9
+	// Smallest accepted pivot element.
+	SIMPLEX_PIVTOL = 1e-9
+	// Number of basis updates between refactorizations.
+	SIMPLEX_REFACTOR = 64
+	// Number of consecutive degenerate steps before switching to Bland's rule.
+	SIMPLEX_DEGENERATE = 50
+)
+
+// Status of a variable with respect to a simplex basis.
+type VarStatus int
+
+const (
+	// Variable is basic.
+	Basic = VarStatus(1 + iota)
+	// Nonbasic at its lower bound.
+	AtLower
+	// Nonbasic at its upper bound.
+	AtUpper
+	// Nonbasic free variable at zero.
+	AtZero
+)
+
+func (s VarStatus) String() string {
+	switch s {
+	case Basic:
+		return "basic"
+	case AtLower:
+		return "lower"
+	case AtUpper:
+		return "upper"
+	case AtZero:
+		return "zero"
+	}
+	return "unknown"
+}
+
+// Simplex basis of the problem
+//
+//     minimize    c'*x
+//     subject to  G*x + s = h
+//                 A*x + r = b
+//                 s >= 0, r = 0
+//
+// with free variables x. The number of basic entries equals the number of
+// rows of G and A.
+type Basis struct {
+	// Status of the variables x.
+	Variables []VarStatus
+	// Status of the slacks s of the rows of G.
+	Inequalities []VarStatus
+	// Status of the logical variables r of the rows of A.
+	Equalities []VarStatus
+}
+
+// Returns a copy of the basis.
+func (b *Basis) Copy() *Basis {
+	nb := &Basis{}
+	nb.Variables = append([]VarStatus(nil), b.Variables...)
+	nb.Inequalities = append([]VarStatus(nil), b.Inequalities...)
+	nb.Equalities = append([]VarStatus(nil), b.Equalities...)
+	return nb
+}
+
+// Eta vector of a basis update: column r of the basis replaced by
+// B*alpha.
+type simplexEta struct {
+	r     int
+	alpha []float64
+}
+
+// Bounded variable form of an Lp. Columns 0..n-1 are x, n..n+m-1 the slacks
+// of G and n+m..n+m+p the logicals of A.
+type simplexLp struct {
+	n, m, p    int
+	rows, cols int
+	G, A       *matrix.FloatMatrix
+	cost, rhs  []float64
+	lo, up     []float64
+	status     []VarStatus
+	head       []int
+	val        []float64
+	// LU factors of the basis at the last refactorization and the updates
+	// since then.
+	B0   *matrix.FloatMatrix
+	ipiv []int32
+	etas []simplexEta
+	work *matrix.FloatMatrix
+	// direction of unboundedness and certificate multipliers
+	ray []float64
+	pi  []float64
+	// nonbasic free variables away from zero during crossover
+	super []bool
+	// infeasibility at the end of phase 1
+	infeas  float64
+	iter    int
+	maxiter int
+	show    bool
+	// start of the solve and the statistics of the basis factorizations
+	start time.Time
+	stats SolveStats
+}
+
+func newSimplexLp(c, G, h, A, b *matrix.FloatMatrix) *simplexLp {
+	lp := &simplexLp{G: G, A: A, start: time.Now()}
+	lp.n = c.Rows()
+	lp.m = G.Rows()
+	lp.p = A.Rows()
+	lp.rows = lp.m + lp.p
+	lp.cols = lp.n + lp.m + lp.p
+	lp.cost = make([]float64, lp.cols)
+	copy(lp.cost, c.FloatArray())
+	lp.rhs = make([]float64, lp.rows)
+	copy(lp.rhs, h.FloatArray())
+	copy(lp.rhs[lp.m:], b.FloatArray())
+	lp.lo = make([]float64, lp.cols)
+	lp.up = make([]float64, lp.cols)
+	for j := 0; j < lp.n; j++ {
+		lp.lo[j] = math.Inf(-1)
+		lp.up[j] = math.Inf(1)
+	}
+	for j := lp.n; j < lp.n+lp.m; j++ {
+		lp.up[j] = math.Inf(1)
+	}
+	lp.status = make([]VarStatus, lp.cols)
+	lp.head = make([]int, lp.rows)
+	lp.val = make([]float64, lp.cols)
+	lp.ipiv = make([]int32, lp.rows)
+	lp.work = matrix.FloatZeros(lp.rows, 1)
+	return lp
+}
+
+// Sets the basis of all logical variables.
+func (lp *simplexLp) slackBasis() {
+	for j := 0; j < lp.n; j++ {
+		lp.status[j] = AtZero
+	}
+	for k := 0; k < lp.rows; k++ {
+		lp.status[lp.n+k] = Basic
+		lp.head[k] = lp.n + k
+	}
+}
+
+// Sets the basis from b. Statuses inconsistent with the bounds are
+// corrected.
+func (lp *simplexLp) setBasis(b *Basis) error {
+	if len(b.Variables) != lp.n || len(b.Inequalities) != lp.m || len(b.Equalities) != lp.p {
+		return errors.New("basis does not match problem dimensions")
+	}
+	copy(lp.status, b.Variables)
+	copy(lp.status[lp.n:], b.Inequalities)
+	copy(lp.status[lp.n+lp.m:], b.Equalities)
+	k := 0
+	for j := 0; j < lp.cols; j++ {
+		switch {
+		case lp.status[j] == Basic:
+			if k == lp.rows {
+				return errors.New("basis has too many basic variables")
+			}
+			lp.head[k] = j
+			k++
+		case j < lp.n:
+			lp.status[j] = AtZero
+		case lp.status[j] != AtUpper || math.IsInf(lp.up[j], 1):
+			lp.status[j] = AtLower
+		}
+	}
+	if k != lp.rows {
+		return errors.New("basis has too few basic variables")
+	}
+	return nil
+}
+
+// Returns the current basis.
+func (lp *simplexLp) basis() *Basis {
+	b := &Basis{}
+	b.Variables = append([]VarStatus(nil), lp.status[:lp.n]...)
+	b.Inequalities = append([]VarStatus(nil), lp.status[lp.n:lp.n+lp.m]...)
+	b.Equalities = append([]VarStatus(nil), lp.status[lp.n+lp.m:]...)
+	return b
+}
+
+// Copies column j of [G, I, 0; A, 0, I] to v.
+func (lp *simplexLp) column(j int, v []float64) {
+	for i := range v {
+		v[i] = 0.0
+	}
+	switch {
+	case j < lp.n:
+		copy(v, lp.G.FloatArray()[j*lp.m:(j+1)*lp.m])
+		copy(v[lp.m:], lp.A.FloatArray()[j*lp.p:(j+1)*lp.p])
+	default:
+		v[j-lp.n] = 1.0
+	}
+}
+
+// Returns column j times v.
+func (lp *simplexLp) colDot(j int, v []float64) float64 {
+	if j >= lp.n {
+		return v[j-lp.n]
+	}
+	s := 0.0
+	g := lp.G.FloatArray()[j*lp.m : (j+1)*lp.m]
+	for i, gi := range g {
+		s += gi * v[i]
+	}
+	a := lp.A.FloatArray()[j*lp.p : (j+1)*lp.p]
+	for i, ai := range a {
+		s += ai * v[lp.m+i]
+	}
+	return s
+}
+
+// v := v + t*(column j)
+func (lp *simplexLp) colAxpy(j int, t float64, v []float64) {
+	if j >= lp.n {
+		v[j-lp.n] += t
+		return
+	}
+	g := lp.G.FloatArray()[j*lp.m : (j+1)*lp.m]
+	for i, gi := range g {
+		v[i] += t * gi
+	}
+	a := lp.A.FloatArray()[j*lp.p : (j+1)*lp.p]
+	for i, ai := range a {
+		v[lp.m+i] += t * ai
+	}
+}
+
+// Computes the LU factorization of the current basis.
+func (lp *simplexLp) refactor() error {
+	lp.etas = lp.etas[:0]
+	if lp.rows == 0 {
+		return nil
+	}
+	defer addSince(&lp.stats.FactorTime, time.Now())
+	lp.stats.Factorizations++
+	R := lp.rows
+	B := matrix.FloatZeros(R, R)
+	ba := B.FloatArray()
+	for k, j := range lp.head {
+		lp.column(j, ba[k*R:(k+1)*R])
+	}
+	if err := lapack.Getrf(B, lp.ipiv); err != nil {
+		return errors.New("singular basis matrix")
+	}
+	lp.B0 = B
+	return nil
+}
+
+// Solves B*x = v in place.
+func (lp *simplexLp) ftran(v []float64) error {
+	if lp.rows == 0 {
+		return nil
+	}
+	defer addSince(&lp.stats.SolveTime, time.Now())
+	lp.stats.Solves++
+	w := lp.work.FloatArray()
+	copy(w, v)
+	if err := lapack.Getrs(lp.B0, lp.work, lp.ipiv); err != nil {
+		return err
+	}
+	copy(v, w)
+	for _, e := range lp.etas {
+		xr := v[e.r] / e.alpha[e.r]
+		for i, a := range e.alpha {
+			v[i] -= a * xr
+		}
+		v[e.r] = xr
+	}
+	return nil
+}
+
+// Solves B'*x = v in place.
+func (lp *simplexLp) btran(v []float64) error {
+	if lp.rows == 0 {
+		return nil
+	}
+	defer addSince(&lp.stats.SolveTime, time.Now())
+	lp.stats.Solves++
+	for k := len(lp.etas) - 1; k >= 0; k-- {
+		e := lp.etas[k]
+		s := v[e.r]
+		for i, a := range e.alpha {
+			if i != e.r {
+				s -= a * v[i]
+			}
+		}
+		v[e.r] = s / e.alpha[e.r]
+	}
+	w := lp.work.FloatArray()
+	copy(w, v)
+	if err := lapack.Getrs(lp.B0, lp.work, lp.ipiv, la.OptTrans); err != nil {
+		return err
+	}
+	copy(v, w)
+	return nil
+}
+
+// Replaces the basic variable at position r by column q with
+// alpha = B^{-1}*a_q.
+func (lp *simplexLp) pivot(r, q int, alpha []float64) error {
+	lp.head[r] = q
+	lp.status[q] = Basic
+	lp.etas = append(lp.etas, simplexEta{r, append([]float64(nil), alpha...)})
+	if len(lp.etas) >= SIMPLEX_REFACTOR {
+		if err := lp.refactor(); err != nil {
+			return err
+		}
+		return lp.computeBasic()
+	}
+	return nil
+}
+
+// Sets the values of the nonbasic variables from their status and
+// computes the basic variables. Superbasic variables keep their values.
+func (lp *simplexLp) computeBasic() error {
+	v := make([]float64, lp.rows)
+	copy(v, lp.rhs)
+	for j := 0; j < lp.cols; j++ {
+		switch lp.status[j] {
+		case Basic:
+			continue
+		case AtLower:
+			lp.val[j] = lp.lo[j]
+		case AtUpper:
+			lp.val[j] = lp.up[j]
+		default:
+			if lp.super == nil || !lp.super[j] {
+				lp.val[j] = 0.0
+			}
+		}
+		if lp.val[j] != 0.0 {
+			lp.colAxpy(j, -lp.val[j], v)
+		}
+	}
+	if err := lp.ftran(v); err != nil {
+		return err
+	}
+	for k, j := range lp.head {
+		lp.val[j] = v[k]
+	}
+	return nil
+}
+
+// Returns the largest bound violation of the basic variables.
+func (lp *simplexLp) primalInfeasibility() float64 {
+	inf := 0.0
+	for _, j := range lp.head {
+		inf = math.Max(inf, lp.lo[j]-lp.val[j])
+		inf = math.Max(inf, lp.val[j]-lp.up[j])
+	}
+	return inf
+}
+
+// Computes simplex multipliers for the basic costs cB and returns them.
+func (lp *simplexLp) multipliers(cB []float64) ([]float64, error) {
+	pi := append([]float64(nil), cB...)
+	if err := lp.btran(pi); err != nil {
+		return nil, err
+	}
+	return pi, nil
+}
+
+// Returns the largest violation of the optimality conditions of the reduced
+// costs of the nonbasic variables.
+func (lp *simplexLp) dualInfeasibility(pi []float64) float64 {
+	inf := 0.0
+	for j := 0; j < lp.cols; j++ {
+		if lp.status[j] == Basic || lp.lo[j] == lp.up[j] {
+			continue
+		}
+		d := lp.cost[j] - lp.colDot(j, pi)
+		switch lp.status[j] {
+		case AtLower:
+			inf = math.Max(inf, -d)
+		case AtUpper:
+			inf = math.Max(inf, d)
+		default:
+			inf = math.Max(inf, math.Abs(d))
+		}
+	}
+	return inf
+}
+
+// Ratio test for moving a nonbasic variable in direction dir with
+// alpha = B^{-1}*a_q; basic variable k changes by t*delta[k] with
+// delta = -dir*alpha. Basic variables outside their bounds are allowed to
+// move until they reach the violated bound. Returns the step, the position
+// of the blocking basic variable, or -1 if the step is limited by tmax, and
+// the bound it reaches.
+func (lp *simplexLp) ratioTest(alpha []float64, dir, tmax float64) (float64, int, float64) {
+	r := -1
+	var bound, rpiv float64
+	for k, j := range lp.head {
+		dk := -dir * alpha[k]
+		if math.Abs(dk) <= SIMPLEX_PIVTOL {
+			continue
+		}
+		x := lp.val[j]
+		t, bnd := math.Inf(1), 0.0
+		switch {
+		case x < lp.lo[j]-SIMPLEX_FEASTOL:
+			if dk > 0.0 {
+				t, bnd = (lp.lo[j]-x)/dk, lp.lo[j]
+			}
+		case x > lp.up[j]+SIMPLEX_FEASTOL:
+			if dk < 0.0 {
+				t, bnd = (lp.up[j]-x)/dk, lp.up[j]
+			}
+		case dk < 0.0:
+			if !math.IsInf(lp.lo[j], -1) {
+				t, bnd = math.Max((lp.lo[j]-x)/dk, 0.0), lp.lo[j]
+			}
+		default:
+			if !math.IsInf(lp.up[j], 1) {
+				t, bnd = math.Max((lp.up[j]-x)/dk, 0.0), lp.up[j]
+			}
+		}
+		if t < tmax-1e-12 || (t <= tmax+1e-12 && r >= 0 && math.Abs(dk) > rpiv) {
+			tmax, r, bound, rpiv = t, k, bnd, math.Abs(dk)
+		}
+	}
+	return tmax, r, bound
+}
+
+// Runs the primal simplex method from the current basis. Phase 1 minimizes
+// the sum of the bound violations of the basic variables and phase 2 the
+// objective. Returns PrimalInfeasible with multipliers in lp.pi, or
+// DualInfeasible with a direction in lp.ray, or Optimal. Unknown is
+// returned when the iteration limit is reached.
+func (lp *simplexLp) primal() (StatusCode, error) {
+	cB := make([]float64, lp.rows)
+	alpha := make([]float64, lp.rows)
+	degenerate := 0
+
+	for ; lp.iter < lp.maxiter; lp.iter++ {
+		// phase 1 costs of the infeasible basic variables
+		phase1 := false
+		infeas := 0.0
+		for k, j := range lp.head {
+			switch {
+			case lp.val[j] < lp.lo[j]-SIMPLEX_FEASTOL:
+				cB[k] = -1.0
+				infeas += lp.lo[j] - lp.val[j]
+				phase1 = true
+			case lp.val[j] > lp.up[j]+SIMPLEX_FEASTOL:
+				cB[k] = 1.0
+				infeas += lp.val[j] - lp.up[j]
+				phase1 = true
+			default:
+				cB[k] = 0.0
+			}
+		}
+		if !phase1 {
+			for k, j := range lp.head {
+				cB[k] = lp.cost[j]
+			}
+		}
+		pi, err := lp.multipliers(cB)
+		if err != nil {
+			return Unknown, err
+		}
+
+		// pricing; Dantzig's rule or Bland's rule after a run of
+		// degenerate steps.
+		q, dir, dq := -1, 0.0, 0.0
+		for j := 0; j < lp.cols; j++ {
+			if lp.status[j] == Basic || lp.lo[j] == lp.up[j] {
+				continue
+			}
+			d := -lp.colDot(j, pi)
+			if !phase1 {
+				d += lp.cost[j]
+			}
+			var jdir float64
+			switch {
+			case lp.status[j] == AtLower && d < -SIMPLEX_OPTTOL:
+				jdir = 1.0
+			case lp.status[j] == AtUpper && d > SIMPLEX_OPTTOL:
+				jdir = -1.0
+			case lp.status[j] == AtZero && math.Abs(d) > SIMPLEX_OPTTOL:
+				jdir = -math.Copysign(1.0, d)
+			default:
+				continue
+			}
+			if q < 0 || (degenerate < SIMPLEX_DEGENERATE && math.Abs(d) > math.Abs(dq)) {
+				q, dir, dq = j, jdir, d
+			}
+		}
+		if lp.show {
+			obj := 0.0
+			for j := 0; j < lp.n; j++ {
+				obj += lp.cost[j] * lp.val[j]
+			}
+			fmt.Printf("%4d: % 14.7e  %10.3e  %10.3e\n", lp.iter, obj, infeas, math.Abs(dq))
+		}
+		if q < 0 {
+			if phase1 {
+				lp.pi = pi
+				lp.infeas = infeas
+				return PrimalInfeasible, nil
+			}
+			lp.pi = pi
+			return Optimal, nil
+		}
+
+		lp.column(q, alpha)
+		if err := lp.ftran(alpha); err != nil {
+			return Unknown, err
+		}
+		tmax, r, bound := lp.ratioTest(alpha, dir, lp.up[q]-lp.lo[q])
+
+		if math.IsInf(tmax, 1) {
+			if phase1 {
+				return Unknown, errors.New("unbounded phase 1 step")
+			}
+			lp.ray = make([]float64, lp.cols)
+			lp.ray[q] = dir
+			for k, j := range lp.head {
+				lp.ray[j] = -dir * alpha[k]
+			}
+			return DualInfeasible, nil
+		}
+		if tmax <= SIMPLEX_FEASTOL {
+			degenerate++
+		} else {
+			degenerate = 0
+		}
+
+		lp.val[q] += dir * tmax
+		for k, j := range lp.head {
+			lp.val[j] -= tmax * dir * alpha[k]
+		}
+		if r < 0 {
+			// bound flip of the entering variable
+			if dir > 0.0 {
+				lp.status[q] = AtUpper
+				lp.val[q] = lp.up[q]
+			} else {
+				lp.status[q] = AtLower
+				lp.val[q] = lp.lo[q]
+			}
+			continue
+		}
+		leave := lp.head[r]
+		lp.val[leave] = bound
+		if bound == lp.lo[leave] {
+			lp.status[leave] = AtLower
+		} else {
+			lp.status[leave] = AtUpper
+		}
+		if err := lp.pivot(r, q, alpha); err != nil {
+			return Unknown, err
+		}
+	}
+	return Unknown, errors.New("Terminated (maximum iterations reached)")
+}
+
+// Moves the nonbasic free variables into the basis so that the solution is
+// a vertex. At an optimal basis their reduced costs are zero and the
+// objective does not change. A free variable that can move without limit
+// in both directions is left at zero; the feasible set then contains a line
+// and has no vertices.
+func (lp *simplexLp) pivotFree() error {
+	alpha := make([]float64, lp.rows)
+	for j := 0; j < lp.n; j++ {
+		if lp.status[j] != AtZero {
+			continue
+		}
+		lp.column(j, alpha)
+		if err := lp.ftran(alpha); err != nil {
+			return err
+		}
+		for _, dir := range []float64{1.0, -1.0} {
+			t, r, bound := lp.ratioTest(alpha, dir, math.Inf(1))
+			if r < 0 {
+				continue
+			}
+			lp.val[j] += dir * t
+			for k, jb := range lp.head {
+				lp.val[jb] -= t * dir * alpha[k]
+			}
+			leave := lp.head[r]
+			lp.val[leave] = bound
+			if bound == lp.lo[leave] {
+				lp.status[leave] = AtLower
+			} else {
+				lp.status[leave] = AtUpper
+			}
+			if err := lp.pivot(r, j, alpha); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// Runs the dual simplex method from a dual feasible basis. Returns Optimal
+// or Unknown if the basis does not allow progress; the primal method is
+// then used to finish.
+func (lp *simplexLp) dual() (StatusCode, error) {
+	for ; lp.iter < lp.maxiter; lp.iter++ {
+		// leaving variable with the largest bound violation
+		r, viol, target := -1, SIMPLEX_FEASTOL, 0.0
+		for k, j := range lp.head {
+			if v := lp.lo[j] - lp.val[j]; v > viol {
+				r, viol, target = k, v, lp.lo[j]
+			}
+			if v := lp.val[j] - lp.up[j]; v > viol {
+				r, viol, target = k, v, lp.up[j]
+			}
+		}
+		if r < 0 {
+			return Optimal, nil
+		}
+		if lp.show {
+			obj := 0.0
+			for j := 0; j < lp.n; j++ {
+				obj += lp.cost[j] * lp.val[j]
+			}
+			fmt.Printf("%4d: % 14.7e  %10.3e  (dual)\n", lp.iter, obj, viol)
+		}
+		ok, err := lp.dualStep(r, target)
+		if err != nil {
+			return Unknown, err
+		}
+		if !ok {
+			// primal infeasible; the primal method computes the certificate.
+			return Unknown, nil
+		}
+	}
+	return Unknown, errors.New("Terminated (maximum iterations reached)")
+}
+
+// Moves the basic variable at position r to target and pivots it out of
+// the basis. The entering variable is chosen by the dual ratio test so that
+// a dual feasible basis stays dual feasible. Returns false if there is no
+// eligible entering variable.
+func (lp *simplexLp) dualStep(r int, target float64) (bool, error) {
+	cB := make([]float64, lp.rows)
+	for k, j := range lp.head {
+		cB[k] = lp.cost[j]
+	}
+	pi, err := lp.multipliers(cB)
+	if err != nil {
+		return false, err
+	}
+	rho := make([]float64, lp.rows)
+	rho[r] = 1.0
+	if err = lp.btran(rho); err != nil {
+		return false, err
+	}
+
+	// x_Br changes by -a_rj*dx_j; it must move towards target.
+	sgn := 1.0
+	if target < lp.val[lp.head[r]] {
+		sgn = -1.0
+	}
+	q, ratio, qpiv := -1, math.Inf(1), 0.0
+	for j := 0; j < lp.cols; j++ {
+		if lp.status[j] == Basic || lp.lo[j] == lp.up[j] {
+			continue
+		}
+		arj := lp.colDot(j, rho)
+		if math.Abs(arj) <= SIMPLEX_PIVTOL {
+			continue
+		}
+		switch lp.status[j] {
+		case AtLower:
+			if sgn*arj >= 0.0 {
+				continue
+			}
+		case AtUpper:
+			if sgn*arj <= 0.0 {
+				continue
+			}
+		}
+		d := lp.cost[j] - lp.colDot(j, pi)
+		t := math.Abs(d) / math.Abs(arj)
+		if t < ratio-1e-12 || (t <= ratio+1e-12 && math.Abs(arj) > qpiv) {
+			q, ratio, qpiv = j, t, math.Abs(arj)
+		}
+	}
+	if q < 0 {
+		return false, nil
+	}
+
+	alpha := make([]float64, lp.rows)
+	lp.column(q, alpha)
+	if err = lp.ftran(alpha); err != nil {
+		return false, err
+	}
+	if math.Abs(alpha[r]) <= SIMPLEX_PIVTOL {
+		return false, nil
+	}
+	leave := lp.head[r]
+	dx := (lp.val[leave] - target) / alpha[r]
+	lp.val[q] += dx
+	for k, j := range lp.head {
+		lp.val[j] -= dx * alpha[k]
+	}
+	lp.val[leave] = target
+	if target == lp.lo[leave] {
+		lp.status[leave] = AtLower
+	} else {
+		lp.status[leave] = AtUpper
+	}
+	return true, lp.pivot(r, q, alpha)
+}
+
+//    Solves a pair of primal and dual LPs
+//
+//        minimize    c'*x
+//        subject to  G*x + s = h
+//                    A*x = b
+//                    s >= 0
+//
+//        maximize    -h'*z - b'*y
+//        subject to  G'*z + A'*y + c = 0
+//                    z >= 0
+//
+//    with the bounded variable revised simplex method. The arguments are as
+//    for Lp. G may be nil if there are no inequalities.
+//
+//    The basis is kept as an LU factorization computed with lapack.Getrf and
+//    updated in product form; it is refactored every SIMPLEX_REFACTOR
+//    updates. If basis is nil the method starts from the basis of the
+//    slack and logical variables and runs a composite phase 1 followed by
+//    phase 2 of the primal simplex method. A basis returned by an earlier
+//    call with the same dimensions can be given to warm start the method;
+//    if it is dual feasible but not primal feasible (after a change of h or
+//    b) the dual simplex method is used.
+//
+//    Returns the solution and the final basis. The solution is a vertex of
+//    the feasible set. If the problem is infeasible or unbounded the status
+//    is PrimalInfeasible or DualInfeasible and sol.Certificate holds the
+//    normalized certificate.
+//
+//    Only ShowProgress and MaxIter (default SIMPLEX_MAXITERS) of solopts are
+//    used.
+//
+func Simplex(c, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions, basis *Basis) (sol *Solution, final *Basis, err error) {
+
+	if c == nil || c.Cols() != 1 {
+		err = errors.New("'c' must a column matrix")
+		return
+	}
+	n := c.Rows()
+	if n < 1 {
+		err = errors.New("Number of variables must be at least 1")
+		return
+	}
+	if G == nil {
+		G = matrix.FloatZeros(0, n)
+	}
+	if G.Cols() != n {
+		err = errors.New(fmt.Sprintf("'G' must be matrix with %d columns", n))
+		return
+	}
+	m := G.Rows()
+	if h == nil {
+		h = matrix.FloatZeros(0, 1)
+	}
+	if !h.SizeMatch(m, 1) {
+		err = errors.New(fmt.Sprintf("'h' must be matrix of size (%d,1)", m))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if A.Cols() != n {
+		err = errors.New(fmt.Sprintf("'A' must be matrix with %d columns", n))
+		return
+	}
+	p := A.Rows()
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	if !b.SizeMatch(p, 1) {
+		err = errors.New(fmt.Sprintf("'b' must be matrix of size (%d,1)", p))
+		return
+	}
+	if solopts == nil {
+		solopts = &SolverOptions{}
+	}
+
+	lp := newSimplexLp(c, G, h, A, b)
+	lp.maxiter = SIMPLEX_MAXITERS
+	if solopts.MaxIter > 0 {
+		lp.maxiter = solopts.MaxIter
+	}
+	lp.show = solopts.ShowProgress
+
+	warm := false
+	if basis != nil {
+		if err = lp.setBasis(basis); err != nil {
+			return
+		}
+		if lp.refactor() == nil {
+			warm = true
+		} else if solopts.ShowProgress {
+			fmt.Printf("Singular start basis; starting from slack basis.\n")
+		}
+	}
+	if !warm {
+		lp.slackBasis()
+		if err = lp.refactor(); err != nil {
+			return
+		}
+	}
+	if err = lp.computeBasic(); err != nil {
+		return
+	}
+	addSince(&lp.stats.SetupTime, lp.start)
+
+	status := Unknown
+	if warm && lp.primalInfeasibility() > SIMPLEX_FEASTOL {
+		cB := make([]float64, lp.rows)
+		for k, j := range lp.head {
+			cB[k] = lp.cost[j]
+		}
+		var pi []float64
+		if pi, err = lp.multipliers(cB); err != nil {
+			return
+		}
+		if lp.dualInfeasibility(pi) <= SIMPLEX_OPTTOL {
+			if status, err = lp.dual(); err != nil {
+				sol, _ = lp.solution(status)
+				final = lp.basis()
+				return
+			}
+		}
+	}
+	// finishes after the dual method and handles all other cases
+	status, err = lp.primal()
+	if status == Optimal {
+		err = lp.pivotFree()
+	}
+	var serr error
+	sol, serr = lp.solution(status)
+	final = lp.basis()
+	if err == nil {
+		err = serr
+	}
+	if err == nil {
+		switch status {
+		case PrimalInfeasible:
+			err = errors.New("Primal infeasible")
+		case DualInfeasible:
+			err = errors.New("Dual infeasible")
+		}
+	}
+	return
+}
+
+// Builds the solution for the current basis.
+func (lp *simplexLp) solution(status StatusCode) (*Solution, error) {
+	sol := &Solution{Status: status, Iterations: lp.iter, Stats: lp.stats}
+	sol.Stats.TotalTime = time.Since(lp.start)
+	n, m, p := lp.n, lp.m, lp.p
+
+	if status == DualInfeasible {
+		x := matrix.FloatVector(lp.ray[:n])
+		s := matrix.FloatVector(lp.ray[n : n+m])
+		cx := 0.0
+		for j := 0; j < n; j++ {
+			cx += lp.cost[j] * lp.ray[j]
+		}
+		x.Scale(-1.0 / cx)
+		s.Scale(-1.0 / cx)
+		sol.Certificate = &Certificate{Status: DualInfeasible, X: x, S: s}
+		sol.PrimalObjective = math.Inf(-1)
+		sol.DualObjective = math.NaN()
+		return sol, nil
+	}
+
+	// G*x + s = h and A*x + r = b with simplex multipliers pi give
+	// z = -pi[:m] and y = -pi[m:].
+	var pi []float64
+	if status == PrimalInfeasible {
+		pi = lp.pi
+	} else {
+		cB := make([]float64, lp.rows)
+		for k, j := range lp.head {
+			cB[k] = lp.cost[j]
+		}
+		var err error
+		if pi, err = lp.multipliers(cB); err != nil {
+			return sol, err
+		}
+	}
+	z := matrix.FloatZeros(m, 1)
+	y := matrix.FloatZeros(p, 1)
+	for i := 0; i < m; i++ {
+		z.SetIndex(i, -pi[i])
+	}
+	for i := 0; i < p; i++ {
+		y.SetIndex(i, -pi[m+i])
+	}
+
+	if status == PrimalInfeasible {
+		// phase 1 multipliers give h'*z + b'*y = -(sum of infeasibilities)
+		z.Scale(1.0 / lp.infeas)
+		y.Scale(1.0 / lp.infeas)
+		sol.Certificate = &Certificate{Status: PrimalInfeasible, Y: y, Z: z}
+		sol.PrimalObjective = math.NaN()
+		sol.DualObjective = math.Inf(1)
+		return sol, nil
+	}
+
+	x := matrix.FloatVector(lp.val[:n])
+	s := matrix.FloatVector(lp.val[n : n+m])
+	sol.X, sol.S, sol.Y, sol.Z = x, s, y, z
+	sol.Result = FloatSetNew("x", "y", "s", "z")
+	sol.Result.Set("x", x)
+	sol.Result.Set("y", y)
+	sol.Result.Set("s", s)
+	sol.Result.Set("z", z)
+	for j := 0; j < n; j++ {
+		sol.PrimalObjective += lp.cost[j] * lp.val[j]
+	}
+	for i := 0; i < lp.rows; i++ {
+		sol.DualObjective += lp.rhs[i] * pi[i]
+	}
+	sol.Gap = vecDot(s, z)
+	if math.Abs(sol.PrimalObjective) > 0.0 {
+		sol.RelativeGap = sol.Gap / math.Abs(sol.PrimalObjective)
+	} else {
+		sol.RelativeGap = math.NaN()
+	}
+	sol.PrimalInfeasibility = lp.primalInfeasibility()
+	sol.DualInfeasibility = lp.dualInfeasibility(pi)
+	sol.PrimalSlack = minvec(s.FloatArray())
+	sol.DualSlack = minvec(z.FloatArray())
+	sol.PrimalResidualCert = math.NaN()
+	sol.DualResidualCert = math.NaN()
+	return sol, nil
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
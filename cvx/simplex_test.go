@@ -0,0 +1,140 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"testing"
+)
+
+// Largest absolute difference of the entries of x and y.
+func maxDiff(x, y *matrix.FloatMatrix) float64 {
+	xa, ya := x.FloatArray(), y.FloatArray()
+	if len(xa) != len(ya) {
+		return math.Inf(1)
+	}
+	d := 0.0
+	for k := range xa {
+		d = math.Max(d, math.Abs(xa[k]-ya[k]))
+	}
+	return d
+}
+
+// The LP of examples/testlp.go; the optimum is x = (1, 1).
+func makeTestLp() (c, G, h *matrix.FloatMatrix) {
+	c = matrix.FloatVector([]float64{-4.0, -5.0})
+	G = matrix.FloatMatrixStacked([][]float64{
+		[]float64{2.0, 1.0, -1.0, 0.0},
+		[]float64{1.0, 2.0, 0.0, -1.0}}, matrix.ColumnOrder)
+	h = matrix.FloatVector([]float64{3.0, 3.0, 0.0, 0.0})
+	return
+}
+
+func TestSimplexOptimum(t *testing.T) {
+	c, G, h := makeTestLp()
+	ref, err := Lp(c, G, h, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatalf("Lp: %s\n", err)
+	}
+	sol, basis, err := Simplex(c, G, h, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Simplex: %s\n", err)
+	}
+	if sol.Status != Optimal || basis == nil {
+		t.Fatalf("status %v\n", sol.Status)
+	}
+	if e := maxDiff(sol.X, ref.X); e > 1e-6 {
+		t.Errorf("||x - x_lp|| = %.3e\n", e)
+	}
+	if e := maxDiff(sol.Z, ref.Z); e > 1e-6 {
+		t.Errorf("||z - z_lp|| = %.3e\n", e)
+	}
+	if math.Abs(sol.PrimalObjective+9.0) > 1e-12 || math.Abs(sol.DualObjective+9.0) > 1e-12 {
+		t.Errorf("objectives %v, %v\n", sol.PrimalObjective, sol.DualObjective)
+	}
+}
+
+// After h[1] = 0.5 the optimal basis of the original problem is dual but
+// not primal feasible and the warm start runs the dual method.
+func TestSimplexWarmDual(t *testing.T) {
+	c, G, h := makeTestLp()
+	_, basis, err := Simplex(c, G, h, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Simplex: %s\n", err)
+	}
+	h.SetIndex(1, 0.5)
+
+	lp := newSimplexLp(c, G, h, matrix.FloatZeros(0, 2), matrix.FloatZeros(0, 1))
+	lp.maxiter = SIMPLEX_MAXITERS
+	if err = lp.setBasis(basis); err != nil {
+		t.Fatal(err)
+	}
+	if err = lp.refactor(); err != nil {
+		t.Fatal(err)
+	}
+	if err = lp.computeBasic(); err != nil {
+		t.Fatal(err)
+	}
+	cB := make([]float64, lp.rows)
+	for k, j := range lp.head {
+		cB[k] = lp.cost[j]
+	}
+	pi, err := lp.multipliers(cB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lp.primalInfeasibility() <= SIMPLEX_FEASTOL || lp.dualInfeasibility(pi) > SIMPLEX_OPTTOL {
+		t.Fatalf("start basis is not dual feasible and primal infeasible\n")
+	}
+	if status, err := lp.dual(); err != nil || status != Optimal {
+		t.Errorf("dual method: status %v, error %v\n", status, err)
+	}
+
+	sol, _, err := Simplex(c, G, h, nil, nil, nil, basis)
+	if err != nil {
+		t.Fatalf("warm Simplex: %s\n", err)
+	}
+	if e := maxDiff(sol.X, matrix.FloatVector([]float64{0.5, 0.0})); e > 1e-12 {
+		t.Errorf("x = %v\n", sol.X.FloatArray())
+	}
+	if math.Abs(sol.PrimalObjective+2.0) > 1e-12 {
+		t.Errorf("objective %v\n", sol.PrimalObjective)
+	}
+}
+
+// x >= 1, x <= 0.
+func TestSimplexPrimalInfeasible(t *testing.T) {
+	c := matrix.FloatVector([]float64{1.0})
+	G := matrix.FloatVector([]float64{-1.0, 1.0})
+	h := matrix.FloatVector([]float64{-1.0, 0.0})
+	sol, _, err := Simplex(c, G, h, nil, nil, nil, nil)
+	if err == nil || sol == nil || sol.Status != PrimalInfeasible || sol.Certificate == nil {
+		t.Fatalf("primal infeasibility not detected: %v\n", err)
+	}
+	if res, err := sol.Certificate.Verify(c, G, h, nil, nil, nil, 1e-10); err != nil {
+		t.Errorf("certificate residual %.3e: %s\n", res, err)
+	}
+}
+
+// minimize -x, x >= 0.
+func TestSimplexDualInfeasible(t *testing.T) {
+	c := matrix.FloatVector([]float64{-1.0})
+	G := matrix.FloatVector([]float64{-1.0})
+	h := matrix.FloatVector([]float64{0.0})
+	sol, _, err := Simplex(c, G, h, nil, nil, nil, nil)
+	if err == nil || sol == nil || sol.Status != DualInfeasible || sol.Certificate == nil {
+		t.Fatalf("dual infeasibility not detected: %v\n", err)
+	}
+	if res, err := sol.Certificate.Verify(c, G, h, nil, nil, nil, 1e-10); err != nil {
+		t.Errorf("certificate residual %.3e: %s\n", res, err)
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
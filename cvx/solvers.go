@@ -12,7 +12,6 @@ import (
 	"github.com/hrautila/go.opt/matrix"
 	"errors"
 	"fmt"
-	"math"
 )
 
 
@@ -160,7 +159,34 @@ func Qp(P, q, G, h, A, b *matrix.FloatMatrix, solopts *SolverOptions, initvals *
 //    
 //        sq[k][0] >= || sq[k][1:] ||_2,  zq[k][0] >= || zq[k][1:] ||_2.
 //
+//    Ghq has the lists "Gq" and "hq". The primal start has the entries "x",
+//    "s" (for sl) and the list "sl" (for sq), the dual start the entries
+//    "y", "z" (for zl) and the list "zl" (for zq). The solution set
+//    sol.Result has the entries "x", "y", "sl", "zl" and the lists "sq"
+//    and "zq". See SolveSocp for the typed interface.
+//
 func Socp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *FloatMatrixSet, solopts *SolverOptions, primalstart, dualstart *FloatMatrixSet) (sol *Solution, err error) {
+	blocks, err := SocpBlocksFromSet(Ghq)
+	if err != nil {
+		return
+	}
+	var start *SocpStart
+	if primalstart != nil || dualstart != nil {
+		start = SocpStartFromSets(primalstart, dualstart)
+	}
+	sol, res, err := SolveSocp(c, Gl, hl, A, b, blocks, solopts, start)
+	if sol != nil && res != nil {
+		sol.Result = res.FloatMatrixSet()
+	}
+	return
+}
+
+//    Solves the SOCP of Socp with the cone constraints Gq[k]*x + sq[k] = hq[k]
+//    given as blocks. The start point is optional. Returns the solution and
+//    its blocks; the blocks are nil if the solver returned an error before
+//    the solution was found.
+//
+func SolveSocp(c, Gl, hl, A, b *matrix.FloatMatrix, blocks []SocpBlock, solopts *SolverOptions, start *SocpStart) (sol *Solution, res *SocpResult, err error) {
 	if c == nil {
 		err = errors.New("'c' must a column matrix")
 		return
@@ -185,32 +211,10 @@ func Socp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *FloatMatrixSet, solopts *Sol
 		err = errors.New(fmt.Sprintf("'hl' must be matrix of size (%d,1)", ml))
 		return
 	}
-	Gqset := Ghq.At("Gq")
-	mq := make([]int, 0)
-	for i, Gq := range Gqset {
-		if Gq.Cols() != n {
-			err = errors.New(fmt.Sprintf("'Gq' must be list of matrices with %d columns", n))
-			return
-		}
-		if Gq.Rows() == 0 {
-			err = errors.New(fmt.Sprintf("the number of rows of 'Gq[%d]' is zero", i))
-			return
-		}
-		mq = append(mq, Gq.Rows())
-	}
-	hqset := Ghq.At("hq")
-	if len(Gqset) != len(hqset) {
-		err = errors.New(fmt.Sprintf("'hq' must be a list of %d matrices", len(Gqset)))
+	mq, err := checkSocpBlocks(blocks, n)
+	if err != nil {
 		return
 	}
-	for i, hq := range hqset {
-		if ! hq.SizeMatch(Gqset[i].Rows(), 1) {
-			s := fmt.Sprintf("hq[%d] has size (%d,%d). Expected size is (%d,1)",
-				i, hq.Rows(), hq.Cols(), Gqset[i].Rows())
-			err = errors.New(s)
-			return
-		}
-	}
 	if A == nil {
 		A = matrix.FloatZeros(0, n)
 	}
@@ -226,70 +230,49 @@ func Socp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *FloatMatrixSet, solopts *Sol
 		err = errors.New(fmt.Sprintf("'b' must be matrix of size (%d,1)", p))
 		return
 	}
+	pstart, dstart, err := start.coneStart(n, ml, p, mq)
+	if err != nil {
+		return
+	}
 	dims := DSetNew("l", "q", "s")
 	dims.Set("l", []int{ml})
 	dims.Set("q", mq)
-	//N := dims.Sum("l", "q")
 
-	hargs := make([]*matrix.FloatMatrix, 0, len(hqset)+1)
+	hargs := make([]*matrix.FloatMatrix, 0, len(blocks)+1)
 	hargs = append(hargs, hl)
-	hargs = append(hargs, hqset...)
-	h, indh:= matrix.FloatMatrixCombined(matrix.StackDown, hargs...)
-
-	Gargs := make([]*matrix.FloatMatrix, 0, len(Gqset)+1)
+	Gargs := make([]*matrix.FloatMatrix, 0, len(blocks)+1)
 	Gargs = append(Gargs, Gl)
-	Gargs = append(Gargs, Gqset...)
-	G, indg := matrix.FloatMatrixCombined(matrix.StackDown, Gargs...)
-
-	var pstart, dstart *FloatMatrixSet = nil, nil
-	if primalstart != nil {
-		pstart = FloatSetNew("x", "s")
-		pstart.Set("x", primalstart.At("x")[0])
-		slset := primalstart.At("sl")
-		margs := make([]*matrix.FloatMatrix, 0, len(slset)+1)
-		margs = append(margs, primalstart.At("s")[0])
-		margs = append(margs, slset...)
-		sl, _ := matrix.FloatMatrixCombined(matrix.StackDown,	margs...)
-		pstart.Set("s", sl)
+	for _, blk := range blocks {
+		hargs = append(hargs, blk.H)
+		Gargs = append(Gargs, blk.G)
 	}
+	h, _ := matrix.FloatMatrixCombined(matrix.StackDown, hargs...)
+	G, _ := matrix.FloatMatrixCombined(matrix.StackDown, Gargs...)
 
-	if dualstart != nil {
-		dstart = FloatSetNew("y", "z")
-		dstart.Set("y", dualstart.At("y")[0])
-		zlset := primalstart.At("zl")
-		margs := make([]*matrix.FloatMatrix, 0, len(zlset)+1)
-		margs = append(margs, dualstart.At("z")[0])
-		margs = append(margs, zlset...)
-		zl, _ := matrix.FloatMatrixCombined(matrix.StackDown,	margs...)
-		dstart.Set("z", zl)
-	}
-		
 	sol, err = ConeLp(c, G, h, A, b, dims, solopts, pstart, dstart)
-	// unpack sol.Result
-	if err == nil {
-		s := sol.Result.At("s")[0]
-		sl := matrix.FloatVector(s.FloatArray()[:ml])
-		sol.Result.Append("sl", sl)
+	if sol == nil {
+		return
+	}
+	// unpack the cone variables
+	res = &SocpResult{X: sol.X, Y: sol.Y}
+	if sol.S != nil {
+		sa := sol.S.FloatArray()
+		res.Sl = matrix.FloatVector(sa[:ml])
 		ind := ml
-		for _, k := range indh[1:] {
-			sk := matrix.FloatVector(s.FloatArray()[ind:ind+k])
-			sol.Result.Append("sq", sk)
-			ind += k
+		for _, m := range mq {
+			res.Sq = append(res.Sq, matrix.FloatVector(sa[ind:ind+m]))
+			ind += m
 		}
-
-		z := sol.Result.At("z")[0]
-		zl := matrix.FloatVector(z.FloatArray()[:ml])
-		sol.Result.Append("zl", zl)
-		ind = ml
-		for _, k := range indg[1:] {
-			zk := matrix.FloatVector(z.FloatArray()[ind:ind+k])
-			sol.Result.Append("zq", zk)
-			ind += k
+	}
+	if sol.Z != nil {
+		za := sol.Z.FloatArray()
+		res.Zl = matrix.FloatVector(za[:ml])
+		ind := ml
+		for _, m := range mq {
+			res.Zq = append(res.Zq, matrix.FloatVector(za[ind:ind+m]))
+			ind += m
 		}
 	}
-	sol.Result.Remove("s")
-	sol.Result.Remove("z")
-	
 	return
 }
 
@@ -312,7 +295,34 @@ func Socp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghq *FloatMatrixSet, solopts *Sol
 //    X[:] = Gs[k]*x.  For a symmetric matrix, zs[k], vec(zs[k]) is the 
 //    vector zs[k][:].
 //    
+//    Ghs has the lists "Gs" and "hs". The primal start has the entries "x",
+//    "s" (for sl) and the list "ss", the dual start the entries "y", "z"
+//    (for zl) and the list "zs". The solution set sol.Result has the
+//    entries "x", "y", "sl", "zl" and the lists "ss" and "zs". See SolveSdp
+//    for the typed interface.
+//
 func Sdp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghs *FloatMatrixSet, solopts *SolverOptions, primalstart, dualstart *FloatMatrixSet) (sol *Solution, err error) {
+	blocks, err := SdpBlocksFromSet(Ghs)
+	if err != nil {
+		return
+	}
+	var start *SdpStart
+	if primalstart != nil || dualstart != nil {
+		start = SdpStartFromSets(primalstart, dualstart)
+	}
+	sol, res, err := SolveSdp(c, Gl, hl, A, b, blocks, solopts, start)
+	if sol != nil && res != nil {
+		sol.Result = res.FloatMatrixSet()
+	}
+	return
+}
+
+//    Solves the SDP of Sdp with the matrix inequalities
+//    mat(Gs[k]*x) + ss[k] = hs[k] given as blocks. The start point is
+//    optional. Returns the solution and its blocks; the blocks are nil if
+//    the solver returned an error before the solution was found.
+//
+func SolveSdp(c, Gl, hl, A, b *matrix.FloatMatrix, blocks []SdpBlock, solopts *SolverOptions, start *SdpStart) (sol *Solution, res *SdpResult, err error) {
 	if c == nil {
 		err = errors.New("'c' must a column matrix")
 		return
@@ -337,34 +347,10 @@ func Sdp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghs *FloatMatrixSet, solopts *Solv
 		err = errors.New(fmt.Sprintf("'hl' must be matrix of size (%d,1)", ml))
 		return
 	}
-	Gsset := Ghs.At("Gs")
-	ms := make([]int, 0)
-	for i, Gs := range Gsset {
-		if Gs.Cols() != n {
-			err = errors.New(fmt.Sprintf("'Gs' must be list of matrices with %d columns", n))
-			return
-		}
-		sz := int(math.Sqrt(float64(Gs.Rows())))
-		if Gs.Rows() != sz*sz {
-			err = errors.New(fmt.Sprintf("the squareroot of the number of rows of 'Gq[%d]' is not an integer", i))
-			return
-		}
-		ms = append(ms, sz)
-	}
-
-	hsset := Ghs.At("hs")
-	if len(Gsset) != len(hsset) {
-		err = errors.New(fmt.Sprintf("'hs' must be a list of %d matrices", len(Gsset)))
+	ms, err := checkSdpBlocks(blocks, n)
+	if err != nil {
 		return
 	}
-	for i, hs := range hsset {
-		if ! hs.SizeMatch(ms[i], ms[i]) {
-			s := fmt.Sprintf("hq[%d] has size (%d,%d). Expected size is (%d,%d)",
-				i, hs.Rows(), hs.Cols(), ms[i], ms[i])
-			err = errors.New(s)
-			return
-		}
-	}
 	if A == nil {
 		A = matrix.FloatZeros(0, n)
 	}
@@ -380,76 +366,51 @@ func Sdp(c, Gl, hl, A, b *matrix.FloatMatrix, Ghs *FloatMatrixSet, solopts *Solv
 		err = errors.New(fmt.Sprintf("'b' must be matrix of size (%d,1)", p))
 		return
 	}
+	pstart, dstart, err := start.coneStart(n, ml, p, ms)
+	if err != nil {
+		return
+	}
 	dims := DSetNew("l", "q", "s")
 	dims.Set("l", []int{ml})
 	dims.Set("s", ms)
-	N := dims.Sum("l") + dims.SumSquared("s")
 
 	// Map hs matrices to h vector
-	h := matrix.FloatZeros(N, 1)
-	h.SetIndexes(matrix.MakeIndexSet(0, ml, 1), hl.FloatArray()[:ml])
-	ind := ml
-	for k, hs := range hsset {
-		h.SetIndexes(matrix.MakeIndexSet(ind, ind+ms[k]*ms[k], 1), hs.FloatArray())
-		ind += ms[k]*ms[k]
-	}
-
-	Gargs := make([]*matrix.FloatMatrix, 0)
+	hargs := make([]*matrix.FloatMatrix, 0, len(blocks)+1)
+	hargs = append(hargs, hl)
+	Gargs := make([]*matrix.FloatMatrix, 0, len(blocks)+1)
 	Gargs = append(Gargs, Gl)
-	Gargs = append(Gargs, Gsset...)
-	G, sizeg := matrix.FloatMatrixCombined(matrix.StackDown, Gargs...)
-
-	var pstart, dstart *FloatMatrixSet = nil, nil
-	if primalstart != nil {
-		pstart = FloatSetNew("x", "s")
-		pstart.Set("x", primalstart.At("x")[0])
-		slset := primalstart.At("sl")
-		margs := make([]*matrix.FloatMatrix, 0, len(slset)+1)
-		margs = append(margs, primalstart.At("s")[0])
-		margs = append(margs, slset...)
-		sl, _ := matrix.FloatMatrixCombined(matrix.StackDown,	margs...)
-		pstart.Set("s", sl)
-	}
-
-	if dualstart != nil {
-		dstart = FloatSetNew("y", "z")
-		dstart.Set("y", dualstart.At("y")[0])
-		zlset := primalstart.At("zl")
-		margs := make([]*matrix.FloatMatrix, 0, len(zlset)+1)
-		margs = append(margs, dualstart.At("z")[0])
-		margs = append(margs, zlset...)
-		zl, _ := matrix.FloatMatrixCombined(matrix.StackDown,	margs...)
-		dstart.Set("z", zl)
+	for _, blk := range blocks {
+		hargs = append(hargs, blk.H)
+		Gargs = append(Gargs, blk.G)
 	}
+	h := stackVectors(hargs...)
+	G, _ := matrix.FloatMatrixCombined(matrix.StackDown, Gargs...)
 
 	sol, err = ConeLp(c, G, h, A, b, dims, solopts, pstart, dstart)
-	// unpack sol.Result
-	if err == nil {
-		s := sol.Result.At("s")[0]
-		sl := matrix.FloatVector(s.FloatArray()[:ml])
-		sol.Result.Append("sl", sl)
+	if sol == nil {
+		return
+	}
+	// unpack the matrix variables
+	res = &SdpResult{X: sol.X, Y: sol.Y}
+	if sol.S != nil {
+		sa := sol.S.FloatArray()
+		res.Sl = matrix.FloatVector(sa[:ml])
 		ind := ml
 		for _, m := range ms {
-			sk := matrix.FloatNew(m, m, s.FloatArray()[ind:ind+m*m])
-			sol.Result.Append("ss", sk)
+			res.Ss = append(res.Ss, matrix.FloatNew(m, m, sa[ind:ind+m*m]))
 			ind += m*m
 		}
-
-		z := sol.Result.At("z")[0]
-		zl := matrix.FloatVector(s.FloatArray()[:ml])
-		sol.Result.Append("zl", zl)
-		ind = ml
-		for i, k := range sizeg[1:] {
-			zk := matrix.FloatNew(ms[i], ms[i], z.FloatArray()[ind:ind+k])
-			sol.Result.Append("zs", zk)
-			ind += k
+	}
+	if sol.Z != nil {
+		za := sol.Z.FloatArray()
+		res.Zl = matrix.FloatVector(za[:ml])
+		ind := ml
+		for _, m := range ms {
+			res.Zs = append(res.Zs, matrix.FloatNew(m, m, za[ind:ind+m*m]))
+			ind += m*m
 		}
 	}
-	sol.Result.Remove("s")
-	sol.Result.Remove("z")
-	
 	return
-
 }
 
 
@@ -0,0 +1,153 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+)
+
+// Initial point of ConeLp and ConeQp. Fields that are nil are not used;
+// ConeLp needs both X and S for a primal start and Z for a dual start.
+type StartPoint struct {
+	X, S, Y, Z *matrix.FloatMatrix
+}
+
+// Returns the first matrix of the named set of ms or nil if ms is nil or
+// the set is empty.
+func firstOf(ms *FloatMatrixSet, key string) *matrix.FloatMatrix {
+	if ms == nil {
+		return nil
+	}
+	if mset := ms.At(key); len(mset) > 0 {
+		return mset[0]
+	}
+	return nil
+}
+
+// Returns the start point with entries "x", "s", "y" and "z" of the sets.
+// Later sets override earlier ones; nil sets are skipped.
+func StartPointFromSets(sets ...*FloatMatrixSet) *StartPoint {
+	sp := &StartPoint{}
+	for _, ms := range sets {
+		if m := firstOf(ms, "x"); m != nil {
+			sp.X = m
+		}
+		if m := firstOf(ms, "s"); m != nil {
+			sp.S = m
+		}
+		if m := firstOf(ms, "y"); m != nil {
+			sp.Y = m
+		}
+		if m := firstOf(ms, "z"); m != nil {
+			sp.Z = m
+		}
+	}
+	return sp
+}
+
+// Returns the primal start set with entries "x" and "s" for ConeLp, or nil
+// if either of X or S is not set.
+func (sp *StartPoint) PrimalSet() *FloatMatrixSet {
+	if sp == nil || sp.X == nil || sp.S == nil {
+		return nil
+	}
+	ms := FloatSetNew("x", "s")
+	ms.Set("x", sp.X)
+	ms.Set("s", sp.S)
+	return ms
+}
+
+// Returns the dual start set with entries "y" and "z" for ConeLp, or nil
+// if Z is not set.
+func (sp *StartPoint) DualSet() *FloatMatrixSet {
+	if sp == nil || sp.Z == nil {
+		return nil
+	}
+	ms := FloatSetNew("y", "z")
+	if sp.Y != nil {
+		ms.Set("y", sp.Y)
+	}
+	ms.Set("z", sp.Z)
+	return ms
+}
+
+// Returns the initial value set with entries "x", "s", "y" and "z" for
+// ConeQp, or nil if no field is set.
+func (sp *StartPoint) InitVals() *FloatMatrixSet {
+	if sp == nil || (sp.X == nil && sp.S == nil && sp.Y == nil && sp.Z == nil) {
+		return nil
+	}
+	ms := FloatSetNew("x", "s", "y", "z")
+	for _, e := range []struct{key string; m *matrix.FloatMatrix}{
+		{"x", sp.X}, {"s", sp.S}, {"y", sp.Y}, {"z", sp.Z}} {
+		if e.m != nil {
+			ms.Set(e.key, e.m)
+		}
+	}
+	return ms
+}
+
+// Checks that the fields set are column vectors with n, cdim, p and cdim
+// rows, respectively.
+func (sp *StartPoint) Validate(n, cdim, p int) error {
+	if sp == nil {
+		return nil
+	}
+	if err := checkVector(sp.X, "x", n, true); err != nil {
+		return err
+	}
+	if err := checkVector(sp.S, "s", cdim, true); err != nil {
+		return err
+	}
+	if err := checkVector(sp.Y, "y", p, true); err != nil {
+		return err
+	}
+	return checkVector(sp.Z, "z", cdim, true)
+}
+
+// Checks that v is a column vector of length m. A nil v is accepted if
+// optional is set or m is zero.
+func checkVector(v *matrix.FloatMatrix, name string, m int, optional bool) error {
+	if v == nil {
+		if optional || m == 0 {
+			return nil
+		}
+		return errors.New(fmt.Sprintf("'%s' is missing", name))
+	}
+	if ! v.SizeMatch(m, 1) {
+		return errors.New(fmt.Sprintf("'%s' has size (%d,%d), expected (%d,1)",
+			name, v.Rows(), v.Cols(), m))
+	}
+	return nil
+}
+
+// Checks the primal and dual start sets of ConeLp.
+func checkConeLpStart(primalstart, dualstart *FloatMatrixSet, n, cdim, p int) (err error) {
+	if primalstart != nil {
+		if err = checkVector(firstOf(primalstart, "x"), "primalstart x", n, false); err != nil {
+			return
+		}
+		if err = checkVector(firstOf(primalstart, "s"), "primalstart s", cdim, false); err != nil {
+			return
+		}
+	}
+	if dualstart != nil {
+		if err = checkVector(firstOf(dualstart, "y"), "dualstart y", p, true); err != nil {
+			return
+		}
+		if err = checkVector(firstOf(dualstart, "z"), "dualstart z", cdim, false); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
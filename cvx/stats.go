@@ -0,0 +1,86 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"time"
+)
+
+// Wall times and work counts of a solve, see Solution.Stats. The times of
+// the phases do not add up to TotalTime; the rest of an iteration (residuals,
+// search direction updates, progress output) is not attributed to a phase.
+type SolveStats struct {
+	// Wall time of the solve, and of the checks, KKT solver creation and
+	// starting point before the first iteration.
+	TotalTime time.Duration
+	SetupTime time.Duration
+	// Wall time of KKT (or basis) factorizations and of the solves with
+	// the factors, refinement included.
+	FactorTime time.Duration
+	SolveTime  time.Duration
+	// Wall time of step length computation (maximum step to the boundary
+	// of the cone, and the line search of Cpl) and of scaling updates.
+	StepTime    time.Duration
+	ScalingTime time.Duration
+	// Number of factorizations and of solves with the factors.
+	Factorizations int
+	Solves         int
+	// Refinement steps of all KKT solves.
+	Refinement int
+	// Step lengths of the predictor (affine scaling) and the corrector
+	// step of the last iteration of the interior point solvers.
+	AffineStep float64
+	Step       float64
+}
+
+// Adds the wall time since t0 to a time of stats.
+func addSince(d *time.Duration, t0 time.Time) {
+	*d += time.Since(t0)
+}
+
+// Times the solves with the factors of a solver. The timing solver is bound
+// once per solve and calls the factors of the latest factorization, so the
+// factorizations of the iterations do not allocate. Like the factor
+// functions of the KKT solvers, a factorization invalidates the solvers
+// returned for the previous one.
+type kktTimer struct {
+	st    *SolveStats
+	f     kktFunc
+	solve kktFunc
+}
+
+// Returns a timer that accounts the factorizations and solves to st.
+func (st *SolveStats) kktTimer() *kktTimer {
+	tm := &kktTimer{st: st}
+	tm.solve = tm.timedSolve
+	return tm
+}
+
+// Counts the factorization started at t0 and returns a solver that times
+// the solves with the factors of f.
+func (tm *kktTimer) timed(t0 time.Time, f kktFunc, err error) (kktFunc, error) {
+	addSince(&tm.st.FactorTime, t0)
+	tm.st.Factorizations++
+	if err != nil {
+		return f, err
+	}
+	tm.f = f
+	return tm.solve, nil
+}
+
+func (tm *kktTimer) timedSolve(x, y, z *matrix.FloatMatrix) error {
+	t0 := time.Now()
+	err := tm.f(x, y, z)
+	addSince(&tm.st.SolveTime, t0)
+	tm.st.Solves++
+	return err
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
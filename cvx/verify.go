@@ -0,0 +1,242 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Default relative tolerance of VerifySolution.
+const VERIFY_TOL = 1e-6
+
+// Outcome of a single check of VerifySolution.
+type CheckResult struct {
+	// Name of the check, for example "primal residual" or "z in q[0]".
+	Name string
+	// Measured violation.
+	Value float64
+	// Largest accepted violation.
+	Tolerance float64
+	Passed    bool
+}
+
+// Result of VerifySolution.
+type VerificationReport struct {
+	// Infinity norms of G*x + s - h and A*x - b.
+	PrimalInequality CheckResult
+	PrimalEquality   CheckResult
+	// Infinity norm of P*x + c + G'*z + A'*y.
+	DualResidual CheckResult
+	// Distance of s and z from the cone, one entry per 'l', 'q' and 's'
+	// block in this order.
+	SlackCone []CheckResult
+	DualCone  []CheckResult
+	// Absolute value of s_k'*z_k for each block.
+	Complementarity []CheckResult
+	// True if all checks passed.
+	Passed bool
+}
+
+// Returns the checks that did not pass.
+func (r *VerificationReport) Failed() []CheckResult {
+	failed := make([]CheckResult, 0)
+	all := []CheckResult{r.PrimalInequality, r.PrimalEquality, r.DualResidual}
+	all = append(all, r.SlackCone...)
+	all = append(all, r.DualCone...)
+	all = append(all, r.Complementarity...)
+	for _, c := range all {
+		if !c.Passed {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+//    Verifies the optimality conditions of a solution of
+//
+//        minimize    (1/2)*x'*P*x + c'*x
+//        subject to  G*x + s = h
+//                    A*x = b
+//                    s >= 0
+//
+//    independently of the solver. The checks are
+//
+//        ||G*x + s - h||_inf         <= tol*(1 + ||h||_inf)
+//        ||A*x - b||_inf             <= tol*(1 + ||b||_inf)
+//        ||P*x + c + G'*z + A'*y||_inf <= tol*(1 + ||c||_inf)
+//
+//    and for each cone block k of dims the distance of s_k and z_k from
+//    the cone (see MaxStep) and |s_k'*z_k|, relative to 1 + ||s_k|| and
+//    1 + |c'*x| respectively. Only the lower triangles of the 's' blocks
+//    are referenced in the cone checks.
+//
+//    P is nil for Lp and ConeLp problems. For Qp only the lower triangular
+//    part of P is referenced. A, b may be nil if there are no equality
+//    constraints and dims is nil for componentwise inequalities. If tol is
+//    not positive VERIFY_TOL is used.
+//
+func VerifySolution(P, c, G, h, A, b *matrix.FloatMatrix, dims *DimensionSet, sol *Solution, tol float64) (rep *VerificationReport, err error) {
+
+	if c == nil || c.Cols() != 1 {
+		err = errors.New("'c' must be matrix with 1 column")
+		return
+	}
+	n := c.Rows()
+	if G == nil {
+		G = matrix.FloatZeros(0, n)
+	}
+	if h == nil {
+		h = matrix.FloatZeros(0, 1)
+	}
+	m := G.Rows()
+	if G.Cols() != n || !h.SizeMatch(m, 1) {
+		err = errors.New(fmt.Sprintf("'G' must have %d columns and 'h' size (%d,1)", n, m))
+		return
+	}
+	if dims == nil {
+		dims = DSetNew("l", "q", "s")
+		dims.Set("l", []int{m})
+	}
+	if cdim := dims.Sum("l", "q") + dims.SumSquared("s"); cdim != m {
+		err = errors.New(fmt.Sprintf("'G' must have %d rows", cdim))
+		return
+	}
+	if A == nil {
+		A = matrix.FloatZeros(0, n)
+	}
+	if b == nil {
+		b = matrix.FloatZeros(0, 1)
+	}
+	p := A.Rows()
+	if A.Cols() != n || !b.SizeMatch(p, 1) {
+		err = errors.New(fmt.Sprintf("'A' must have %d columns and 'b' size (%d,1)", n, p))
+		return
+	}
+	if P != nil && !P.SizeMatch(n, n) {
+		err = errors.New(fmt.Sprintf("'P' must be matrix of size (%d,%d)", n, n))
+		return
+	}
+	if sol == nil || sol.X == nil || !sol.X.SizeMatch(n, 1) {
+		err = errors.New("solution has no primal variable of matching size")
+		return
+	}
+	x := sol.X
+	s, z, y := sol.S, sol.Z, sol.Y
+	// ConeQp returns empty s and z for problems without inequalities.
+	if s == nil || s.Rows() == 0 {
+		s = matrix.FloatZeros(m, 1)
+	}
+	if z == nil || z.Rows() == 0 {
+		z = matrix.FloatZeros(m, 1)
+	}
+	if y == nil {
+		y = matrix.FloatZeros(p, 1)
+	}
+	if !s.SizeMatch(m, 1) || !z.SizeMatch(m, 1) || !y.SizeMatch(p, 1) {
+		err = errors.New("solution does not match problem dimensions")
+		return
+	}
+	if tol <= 0.0 {
+		tol = VERIFY_TOL
+	}
+
+	rep = &VerificationReport{}
+
+	// G*x + s - h
+	res := 0.0
+	for i := 0; i < m; i++ {
+		r := s.GetIndex(i) - h.GetIndex(i)
+		for j := 0; j < n; j++ {
+			r += G.GetAt(i, j) * x.GetIndex(j)
+		}
+		res = math.Max(res, math.Abs(r))
+	}
+	rep.PrimalInequality = newCheck("primal residual G*x+s-h", res, tol*(1.0+normInf(h)))
+
+	// A*x - b
+	res = 0.0
+	for i := 0; i < p; i++ {
+		r := -b.GetIndex(i)
+		for j := 0; j < n; j++ {
+			r += A.GetAt(i, j) * x.GetIndex(j)
+		}
+		res = math.Max(res, math.Abs(r))
+	}
+	rep.PrimalEquality = newCheck("primal residual A*x-b", res, tol*(1.0+normInf(b)))
+
+	// P*x + c + G'*z + A'*y
+	res = 0.0
+	for j := 0; j < n; j++ {
+		r := c.GetIndex(j)
+		if P != nil {
+			for k := 0; k < n; k++ {
+				// lower triangle only
+				if k >= j {
+					r += P.GetAt(k, j) * x.GetIndex(k)
+				} else {
+					r += P.GetAt(j, k) * x.GetIndex(k)
+				}
+			}
+		}
+		for i := 0; i < m; i++ {
+			r += G.GetAt(i, j) * z.GetIndex(i)
+		}
+		for i := 0; i < p; i++ {
+			r += A.GetAt(i, j) * y.GetIndex(i)
+		}
+		res = math.Max(res, math.Abs(r))
+	}
+	rep.DualResidual = newCheck("dual residual P*x+c+G'*z+A'*y", res, tol*(1.0+normInf(c)))
+
+	// cone membership and complementarity per block
+	ctol := tol * (1.0 + math.Abs(vecDot(c, x)))
+	checkBlock := func(name string, offset, size int, bdims *DimensionSet) {
+		sk := matrix.FloatVector(s.FloatArray()[offset : offset+size])
+		zk := matrix.FloatVector(z.FloatArray()[offset : offset+size])
+		rep.SlackCone = append(rep.SlackCone,
+			newCheck("s in "+name, coneViolation(sk, bdims), tol*(1.0+normInf(sk))))
+		rep.DualCone = append(rep.DualCone,
+			newCheck("z in "+name, coneViolation(zk, bdims), tol*(1.0+normInf(zk))))
+		rep.Complementarity = append(rep.Complementarity,
+			newCheck("s'*z in "+name, math.Abs(Sdot(sk, zk, bdims, 0)), ctol))
+	}
+	ind := 0
+	if ml := dims.Sum("l"); ml > 0 {
+		bdims := DSetNew("l", "q", "s")
+		bdims.Set("l", []int{ml})
+		checkBlock("l", ind, ml, bdims)
+		ind += ml
+	}
+	for k, mk := range dims.At("q") {
+		bdims := DSetNew("l", "q", "s")
+		bdims.Set("l", []int{0})
+		bdims.Set("q", []int{mk})
+		checkBlock(fmt.Sprintf("q[%d]", k), ind, mk, bdims)
+		ind += mk
+	}
+	for k, mk := range dims.At("s") {
+		bdims := DSetNew("l", "q", "s")
+		bdims.Set("l", []int{0})
+		bdims.Set("s", []int{mk})
+		checkBlock(fmt.Sprintf("s[%d]", k), ind, mk*mk, bdims)
+		ind += mk * mk
+	}
+
+	rep.Passed = len(rep.Failed()) == 0
+	return
+}
+
+func newCheck(name string, value, tol float64) CheckResult {
+	return CheckResult{name, value, tol, value <= tol}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,100 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/cvx package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package cvx
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"testing"
+)
+
+// Solutions of Lp, Qp and ConeLp pass VerifySolution. Adding one to a
+// single component of X or S fails the primal residual, of Z or Y the dual
+// residual; a negative component of Z of the 'l' block also fails its cone
+// check.
+func TestVerifySolution(t *testing.T) {
+	c, G, h := makeTestLp()
+	lpsol, err := Lp(c, G, h, nil, nil, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatalf("Lp: %s\n", err)
+	}
+	P, q, Gq, hq, Aq, bq := makeActiveSetQp()
+	qpsol, err := Qp(P, q, Gq, hq, Aq, bq, &SolverOptions{MaxIter: 30}, nil)
+	if err != nil {
+		t.Fatalf("Qp: %s\n", err)
+	}
+	cc, Gc, hc, dims := makeConeLp()
+	conesol, err := ConeLp(cc, Gc, hc, nil, nil, dims, &SolverOptions{MaxIter: 30}, nil, nil)
+	if err != nil {
+		t.Fatalf("ConeLp: %s\n", err)
+	}
+
+	for _, tc := range []struct {
+		name             string
+		P, c, G, h, A, b *matrix.FloatMatrix
+		dims             *DimensionSet
+		sol              *Solution
+	}{
+		{"lp", nil, c, G, h, nil, nil, nil, lpsol},
+		{"qp", P, q, Gq, hq, Aq, bq, nil, qpsol},
+		{"conelp", nil, cc, Gc, hc, nil, nil, dims, conesol},
+	} {
+		rep, err := VerifySolution(tc.P, tc.c, tc.G, tc.h, tc.A, tc.b, tc.dims, tc.sol, 0.0)
+		if err != nil {
+			t.Fatalf("%s: %s\n", tc.name, err)
+		}
+		if !rep.Passed {
+			t.Errorf("%s: solution failed %v\n", tc.name, rep.Failed())
+		}
+
+		for _, field := range []string{"x", "s", "z", "y"} {
+			bad := *tc.sol
+			var v *matrix.FloatMatrix
+			switch field {
+			case "x":
+				bad.X = tc.sol.X.Copy()
+				v = bad.X
+			case "s":
+				bad.S = tc.sol.S.Copy()
+				v = bad.S
+			case "z":
+				bad.Z = tc.sol.Z.Copy()
+				v = bad.Z
+			case "y":
+				if tc.A == nil {
+					continue
+				}
+				bad.Y = tc.sol.Y.Copy()
+				v = bad.Y
+			}
+			v.SetIndex(0, v.GetIndex(0)+1.0)
+			rep, err := VerifySolution(tc.P, tc.c, tc.G, tc.h, tc.A, tc.b, tc.dims, &bad, 0.0)
+			if err != nil {
+				t.Fatalf("%s: corrupted %s: %s\n", tc.name, field, err)
+			}
+			check := rep.PrimalInequality
+			if field == "z" || field == "y" {
+				check = rep.DualResidual
+			}
+			if rep.Passed || check.Passed {
+				t.Errorf("%s: corrupted %s not detected by %q\n", tc.name, field, check.Name)
+			}
+		}
+
+		// a negative multiplier of the first 'l' row
+		bad := *tc.sol
+		bad.Z = tc.sol.Z.Copy()
+		bad.Z.SetIndex(0, -1.0)
+		rep, _ = VerifySolution(tc.P, tc.c, tc.G, tc.h, tc.A, tc.b, tc.dims, &bad, 0.0)
+		if rep == nil || rep.DualCone[0].Passed {
+			t.Errorf("%s: negative z not detected\n", tc.name)
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -86,7 +86,7 @@ const (
 
 )
 
-func makeW() (W *cvx.FloatMatrixSet, err error) {
+func makeW() (W *cvx.Scaling, err error) {
 	var m *matrix.FloatMatrix
 
 	d0_s    := "{2 1 [5.277942305425358 5.468379387636596]}"
@@ -100,34 +100,32 @@ func makeW() (W *cvx.FloatMatrixSet, err error) {
 	beta_s := "{2 1 [2.33309421747982 6.1486663985292]}"
 
 
-	W = cvx.FloatSetNew("d", "di", "r", "rti", "beta", "v", "dnl", "dnli")
-	m, err = matrix.FloatParseSpe(d0_s)
+	W = &cvx.Scaling{}
+	W.D, err = matrix.FloatParseSpe(d0_s)
 	if err != nil { return }
-	W.Append("d", m)
 
-	m, err = matrix.FloatParseSpe(di0_s)
+	W.Di, err = matrix.FloatParseSpe(di0_s)
 	if err != nil { return }
-	W.Append("di", m)
 
 	m, err = matrix.FloatParseSpe(r0_s)
 	if err != nil { return }
-	W.Append("r", m)
+	W.R = append(W.R, m)
 
 	m, err = matrix.FloatParseSpe(rti0_s)
 	if err != nil { return }
-	W.Append("rti", m)
+	W.Rti = append(W.Rti, m)
 
 	m, err = matrix.FloatParseSpe(beta_s)
 	if err != nil { return }
-	W.Append("beta", m)
+	W.Beta = m.FloatArray()
 
 	m, err = matrix.FloatParseSpe(v0_s)
 	if err != nil { return }
-	W.Append("v", m)
+	W.V = append(W.V, m)
 
 	m, err = matrix.FloatParseSpe(v1_s)
 	if err != nil { return }
-	W.Append("v", m)
+	W.V = append(W.V, m)
 
 	return
 }
@@ -210,7 +208,7 @@ func TestUpdateScaling() {
 	cvx.UpdateScaling(W, lmbda, ds, dz)
 	fmt.Printf("** done **\n")
 	fmt.Printf("dz=\n%v\n", dz.ConvertToString())
-	W.PrintMatrix()
+	W.Print()
 }
 
 func TestSinv() {
@@ -429,7 +427,7 @@ func TestJnrm2() {
 }
 
 
-func doScale(G *matrix.FloatMatrix, W *cvx.FloatMatrixSet) {
+func doScale(G *matrix.FloatMatrix, W *cvx.Scaling) {
 	g := matrix.FloatZeros(G.Rows(), 1)
 	g.SetIndexes(matrix.MakeIndexSet(0, g.Rows(), 1),G.GetColumn(0, nil))
 	fmt.Printf("** scaling g:\n%v\n", g)
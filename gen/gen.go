@@ -0,0 +1,511 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/gen package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+// Package gen generates random LP, QP, SOCP and SDP instances for testing
+// the solvers of package cvx. The instances have a known primal-dual
+// optimal pair by construction, or a known certificate of primal or dual
+// infeasibility.
+package gen
+
+import (
+	"github.com/hrautila/go.opt/cvx"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Random cone program
+//
+//     minimize    (1/2)*x'*P*x + c'*x
+//     subject to  G*x + s = h
+//                 A*x = b
+//                 s >= 0
+//
+// with the cone of Dims. The rows of G and h are ordered as in cvx.ConeLp;
+// the 's' components are stored unpacked with both triangles filled in.
+//
+// If Status is cvx.Optimal, X, S, Y and Z satisfy the optimality conditions
+//
+//     P*x + G'*z + A'*y + c = 0,  G*x + s = h,  A*x = b,
+//     s >= 0,  z >= 0,  s'*z = 0
+//
+// with strict complementarity, and Objective is the optimal value. For an
+// instance of Lp with at least n-p inequalities the optimal pair is unique
+// with probability one; in general x is unique if P is positive definite or
+// there are at least n-p linear inequalities.
+//
+// If Status is cvx.PrimalInfeasible or cvx.DualInfeasible, Certificate proves
+// it and the other problem is strictly feasible. A dual infeasible QP also
+// has P*x = 0 for the certificate x.
+type Instance struct {
+	// Problem data; P is nil for linear objectives.
+	P, C, G, H, A, B *matrix.FloatMatrix
+	Dims             *cvx.DimensionSet
+	Status           cvx.StatusCode
+	// Optimal pair and the optimal value if Status is cvx.Optimal.
+	X, S, Y, Z *matrix.FloatMatrix
+	Objective  float64
+	// Certificate of infeasibility, nil if Status is cvx.Optimal.
+	Certificate *cvx.Certificate
+}
+
+// Generator of random instances. The instances depend only on the seed and
+// the sequence of calls.
+type Generator struct {
+	rnd *rand.Rand
+}
+
+// Returns a new generator with the given seed.
+func New(seed int64) *Generator {
+	return &Generator{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Returns an LP with n variables, m inequalities and p equalities.
+func (g *Generator) Lp(n, m, p int, status cvx.StatusCode) (*Instance, error) {
+	dims := cvx.DSetNew("l", "q", "s")
+	dims.Set("l", []int{m})
+	return g.generate(n, p, dims, status, false)
+}
+
+// Returns a QP with n variables, m inequalities and p equalities.
+func (g *Generator) Qp(n, m, p int, status cvx.StatusCode) (*Instance, error) {
+	dims := cvx.DSetNew("l", "q", "s")
+	dims.Set("l", []int{m})
+	return g.generate(n, p, dims, status, true)
+}
+
+// Returns an SOCP with n variables, ml linear inequalities, second order
+// cones of dimensions mq and p equalities. See Instance.SocpData.
+func (g *Generator) Socp(n, ml int, mq []int, p int, status cvx.StatusCode) (*Instance, error) {
+	dims := cvx.DSetNew("l", "q", "s")
+	dims.Set("l", []int{ml})
+	dims.Set("q", mq)
+	return g.generate(n, p, dims, status, false)
+}
+
+// Returns an SDP with n variables, ml linear inequalities, linear matrix
+// inequalities of orders ms and p equalities. See Instance.SdpData.
+func (g *Generator) Sdp(n, ml int, ms []int, p int, status cvx.StatusCode) (*Instance, error) {
+	dims := cvx.DSetNew("l", "q", "s")
+	dims.Set("l", []int{ml})
+	dims.Set("s", ms)
+	return g.generate(n, p, dims, status, false)
+}
+
+// Returns a cone LP with n variables, p equalities and the cone of dims.
+func (g *Generator) ConeLp(n, p int, dims *cvx.DimensionSet, status cvx.StatusCode) (*Instance, error) {
+	return g.generate(n, p, dims, status, false)
+}
+
+func (g *Generator) generate(n, p int, dims *cvx.DimensionSet, status cvx.StatusCode, quadratic bool) (*Instance, error) {
+	if n < 1 {
+		return nil, errors.New("Number of variables must be at least 1")
+	}
+	if p < 0 || p > n {
+		return nil, errors.New(fmt.Sprintf("Number of equalities must be in [0, %d]", n))
+	}
+	if dims == nil || len(dims.At("l")) != 1 || dims.At("l")[0] < 0 {
+		return nil, errors.New("dims['l'] must be a list with one nonnegative integer")
+	}
+	for _, key := range []string{"q", "s"} {
+		for _, m := range dims.At(key) {
+			if m < 1 {
+				return nil, errors.New(fmt.Sprintf("dims['%s'] must be list of positive integers", key))
+			}
+		}
+	}
+	cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+	switch status {
+	case cvx.Optimal:
+	case cvx.PrimalInfeasible:
+		if cdim == 0 {
+			return nil, errors.New("Primal infeasible instance needs inequalities")
+		}
+	case cvx.DualInfeasible:
+		if p == n {
+			return nil, errors.New("Dual infeasible instance needs p < n")
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown status %d", status))
+	}
+
+	inst := &Instance{Dims: dims, Status: status}
+	inst.G = g.gaussian(cdim, n)
+	g.symmetrize(inst.G, dims)
+	inst.A = g.gaussian(p, n)
+	var B *matrix.FloatMatrix
+	if quadratic {
+		B = g.gaussian(n, n)
+	}
+
+	switch status {
+	case cvx.Optimal:
+		x := g.gaussian(n, 1)
+		y := g.gaussian(p, 1)
+		na := dims.At("l")[0]
+		if n-p < na {
+			na = n - p
+		}
+		s, z := g.complementary(dims, na)
+		if quadratic {
+			inst.P = gram(B)
+		}
+		inst.H = plus(times(inst.G, x, false), s)
+		inst.B = times(inst.A, x, false)
+		// c = -(P*x + G'*z + A'*y)
+		inst.C = plus(times(inst.G, z, true), times(inst.A, y, true))
+		if inst.P != nil {
+			inst.C = plus(inst.C, times(inst.P, x, false))
+		}
+		inst.C.Scale(-1.0)
+		inst.Objective = dot(inst.C, x)
+		if inst.P != nil {
+			inst.Objective += 0.5 * dot(x, times(inst.P, x, false))
+		}
+		inst.X, inst.S, inst.Y, inst.Z = x, s, y, z
+
+	case cvx.PrimalInfeasible:
+		// G'*z + A'*y = 0 by a rank one change of G, then h'*z + b'*y = -1
+		z := g.interior(dims)
+		y := g.gaussian(p, 1)
+		w := plus(times(inst.G, z, true), times(inst.A, y, true))
+		rankOne(inst.G, z, w, -1.0/dot(z, z))
+		inst.B = g.gaussian(p, 1)
+		inst.H = g.gaussian(cdim, 1)
+		t := dot(inst.H, z) + dot(inst.B, y)
+		axpy(z, inst.H, (-1.0-t)/dot(z, z))
+		if quadratic {
+			inst.P = gram(B)
+		}
+		// strictly feasible dual: c = -(G'*z0 + A'*y0) with z0 > 0
+		z0 := g.interior(dims)
+		inst.C = plus(times(inst.G, z0, true), times(inst.A, g.gaussian(p, 1), true))
+		inst.C.Scale(-1.0)
+		inst.Certificate = &cvx.Certificate{Status: cvx.PrimalInfeasible, Y: y, Z: z}
+
+	case cvx.DualInfeasible:
+		// A*x = 0 and G*x = -s by rank one changes of A and G, c'*x = -1
+		x := g.gaussian(n, 1)
+		s := g.interior(dims)
+		xx := dot(x, x)
+		rankOne(inst.A, times(inst.A, x, false), x, -1.0/xx)
+		rankOne(inst.G, plus(times(inst.G, x, false), s), x, -1.0/xx)
+		inst.C = g.gaussian(n, 1)
+		axpy(x, inst.C, (-1.0-dot(inst.C, x))/xx)
+		if quadratic {
+			rankOne(B, times(B, x, false), x, -1.0/xx)
+			inst.P = gram(B)
+		}
+		// strictly feasible primal
+		x0 := g.gaussian(n, 1)
+		inst.H = plus(times(inst.G, x0, false), g.interior(dims))
+		inst.B = times(inst.A, x0, false)
+		inst.Certificate = &cvx.Certificate{Status: cvx.DualInfeasible, X: x, S: s}
+	}
+	return inst, nil
+}
+
+// Returns the data of cvx.Socp: the linear inequalities Gl, hl and the
+// set with keys "Gq" and "hq" of the second order cone constraints.
+func (inst *Instance) SocpData() (Gl, hl *matrix.FloatMatrix, Ghq *cvx.FloatMatrixSet) {
+	ml := inst.Dims.At("l")[0]
+	Gl, hl = rows(inst.G, 0, ml), rows(inst.H, 0, ml)
+	Ghq = cvx.FloatSetNew("Gq", "hq")
+	ind := ml
+	for _, m := range inst.Dims.At("q") {
+		Ghq.Append("Gq", rows(inst.G, ind, ind+m))
+		Ghq.Append("hq", rows(inst.H, ind, ind+m))
+		ind += m
+	}
+	return
+}
+
+// Returns the data of cvx.Sdp: the linear inequalities Gl, hl and the set
+// with keys "Gs" and "hs" of the linear matrix inequalities.
+func (inst *Instance) SdpData() (Gl, hl *matrix.FloatMatrix, Ghs *cvx.FloatMatrixSet) {
+	ml := inst.Dims.At("l")[0]
+	Gl, hl = rows(inst.G, 0, ml), rows(inst.H, 0, ml)
+	Ghs = cvx.FloatSetNew("Gs", "hs")
+	ind := inst.Dims.Sum("l", "q")
+	for _, m := range inst.Dims.At("s") {
+		Ghs.Append("Gs", rows(inst.G, ind, ind+m*m))
+		h := rows(inst.H, ind, ind+m*m)
+		Ghs.Append("hs", matrix.FloatNew(m, m, h.FloatArray()))
+		ind += m * m
+	}
+	return
+}
+
+// Returns a rows by cols matrix of standard normal entries.
+func (g *Generator) gaussian(rows, cols int) *matrix.FloatMatrix {
+	M := matrix.FloatZeros(rows, cols)
+	a := M.FloatArray()
+	for k := range a {
+		a[k] = g.rnd.NormFloat64()
+	}
+	return M
+}
+
+// Returns a number in [0.5, 1.5).
+func (g *Generator) positive() float64 {
+	return 0.5 + g.rnd.Float64()
+}
+
+// Returns a random m by m orthogonal matrix, Gram-Schmidt orthogonalized
+// columns of a gaussian matrix.
+func (g *Generator) orthogonal(m int) *matrix.FloatMatrix {
+	for {
+		Q := g.gaussian(m, m)
+		q := Q.FloatArray()
+		ok := true
+		for j := 0; j < m && ok; j++ {
+			cj := q[j*m : (j+1)*m]
+			for k := 0; k < j; k++ {
+				ck := q[k*m : (k+1)*m]
+				t := 0.0
+				for i := range cj {
+					t += ck[i] * cj[i]
+				}
+				for i := range cj {
+					cj[i] -= t * ck[i]
+				}
+			}
+			nrm := 0.0
+			for _, v := range cj {
+				nrm += v * v
+			}
+			nrm = math.Sqrt(nrm)
+			if nrm < 1e-8 {
+				ok = false
+				break
+			}
+			for i := range cj {
+				cj[i] /= nrm
+			}
+		}
+		if ok {
+			return Q
+		}
+	}
+}
+
+// Stores Q*diag(d)*Q' in x at offset.
+func setSpectral(x *matrix.FloatMatrix, offset int, Q *matrix.FloatMatrix, d []float64) {
+	m := len(d)
+	xa, q := x.FloatArray(), Q.FloatArray()
+	for j := 0; j < m; j++ {
+		for i := 0; i < m; i++ {
+			v := 0.0
+			for k := 0; k < m; k++ {
+				v += q[k*m+i] * d[k] * q[k*m+j]
+			}
+			xa[offset+j*m+i] = v
+		}
+	}
+}
+
+// Returns s, z in the cone of dims with s'*z = 0 and strict complementarity.
+// na of the 'l' components have z > 0 and s = 0.
+func (g *Generator) complementary(dims *cvx.DimensionSet, na int) (s, z *matrix.FloatMatrix) {
+	cdim := dims.Sum("l", "q") + dims.SumSquared("s")
+	s = matrix.FloatZeros(cdim, 1)
+	z = matrix.FloatZeros(cdim, 1)
+	ml := dims.At("l")[0]
+	for k, i := range g.rnd.Perm(ml) {
+		if k < na {
+			z.SetIndex(i, g.positive())
+		} else {
+			s.SetIndex(i, g.positive())
+		}
+	}
+	ind := ml
+	for _, m := range dims.At("q") {
+		if m == 1 {
+			if g.rnd.Intn(2) == 0 {
+				s.SetIndex(ind, g.positive())
+			} else {
+				z.SetIndex(ind, g.positive())
+			}
+			ind += m
+			continue
+		}
+		switch g.rnd.Intn(3) {
+		case 0:
+			g.socInterior(s, ind, m)
+		case 1:
+			g.socInterior(z, ind, m)
+		default:
+			// s = a*(1, v), z = b*(1, -v) with ||v|| = 1
+			v := g.gaussian(m-1, 1)
+			v.Scale(1.0 / math.Sqrt(dot(v, v)))
+			a, b := g.positive(), g.positive()
+			s.SetIndex(ind, a)
+			z.SetIndex(ind, b)
+			for i := 0; i < m-1; i++ {
+				s.SetIndex(ind+1+i, a*v.GetIndex(i))
+				z.SetIndex(ind+1+i, -b*v.GetIndex(i))
+			}
+		}
+		ind += m
+	}
+	for _, m := range dims.At("s") {
+		// common eigenvectors, complementary eigenvalues
+		Q := g.orthogonal(m)
+		ds, dz := make([]float64, m), make([]float64, m)
+		k := g.rnd.Intn(m + 1)
+		for i := 0; i < m; i++ {
+			if i < k {
+				ds[i] = g.positive()
+			} else {
+				dz[i] = g.positive()
+			}
+		}
+		setSpectral(s, ind, Q, ds)
+		setSpectral(z, ind, Q, dz)
+		ind += m * m
+	}
+	return
+}
+
+// Stores a point in the interior of the second order cone of dimension m in
+// x at offset.
+func (g *Generator) socInterior(x *matrix.FloatMatrix, offset, m int) {
+	nrm := 0.0
+	for i := 1; i < m; i++ {
+		v := g.rnd.NormFloat64()
+		x.SetIndex(offset+i, v)
+		nrm += v * v
+	}
+	x.SetIndex(offset, math.Sqrt(nrm)+g.positive())
+}
+
+// Returns a point in the interior of the cone of dims.
+func (g *Generator) interior(dims *cvx.DimensionSet) *matrix.FloatMatrix {
+	x := matrix.FloatZeros(dims.Sum("l", "q")+dims.SumSquared("s"), 1)
+	ind := 0
+	for ; ind < dims.At("l")[0]; ind++ {
+		x.SetIndex(ind, g.positive())
+	}
+	for _, m := range dims.At("q") {
+		g.socInterior(x, ind, m)
+		ind += m
+	}
+	for _, m := range dims.At("s") {
+		d := make([]float64, m)
+		for i := range d {
+			d[i] = g.positive()
+		}
+		setSpectral(x, ind, g.orthogonal(m), d)
+		ind += m * m
+	}
+	return x
+}
+
+// Makes the columns of G symmetric in the 's' blocks.
+func (g *Generator) symmetrize(G *matrix.FloatMatrix, dims *cvx.DimensionSet) {
+	ind := dims.Sum("l", "q")
+	for _, m := range dims.At("s") {
+		for j := 0; j < G.Cols(); j++ {
+			for c := 0; c < m; c++ {
+				for r := c + 1; r < m; r++ {
+					v := 0.5 * (G.GetAt(ind+c*m+r, j) + G.GetAt(ind+r*m+c, j))
+					G.SetAt(ind+c*m+r, j, v)
+					G.SetAt(ind+r*m+c, j, v)
+				}
+			}
+		}
+		ind += m * m
+	}
+}
+
+// Returns rows [start, end) of M.
+func rows(M *matrix.FloatMatrix, start, end int) *matrix.FloatMatrix {
+	R := matrix.FloatZeros(end-start, M.Cols())
+	for j := 0; j < M.Cols(); j++ {
+		for i := start; i < end; i++ {
+			R.SetAt(i-start, j, M.GetAt(i, j))
+		}
+	}
+	return R
+}
+
+// Returns M*x, or M'*x if trans.
+func times(M, x *matrix.FloatMatrix, trans bool) *matrix.FloatMatrix {
+	m, n := M.Size()
+	if trans {
+		y := matrix.FloatZeros(n, 1)
+		for j := 0; j < n; j++ {
+			v := 0.0
+			for i := 0; i < m; i++ {
+				v += M.GetAt(i, j) * x.GetIndex(i)
+			}
+			y.SetIndex(j, v)
+		}
+		return y
+	}
+	y := matrix.FloatZeros(m, 1)
+	for j := 0; j < n; j++ {
+		for i := 0; i < m; i++ {
+			y.SetIndex(i, y.GetIndex(i)+M.GetAt(i, j)*x.GetIndex(j))
+		}
+	}
+	return y
+}
+
+// Returns x + y.
+func plus(x, y *matrix.FloatMatrix) *matrix.FloatMatrix {
+	z := x.Copy()
+	axpy(y, z, 1.0)
+	return z
+}
+
+// y := y + alpha*x
+func axpy(x, y *matrix.FloatMatrix, alpha float64) {
+	ya, xa := y.FloatArray(), x.FloatArray()
+	for k := range ya {
+		ya[k] += alpha * xa[k]
+	}
+}
+
+func dot(x, y *matrix.FloatMatrix) float64 {
+	xa, ya := x.FloatArray(), y.FloatArray()
+	v := 0.0
+	for k := range xa {
+		v += xa[k] * ya[k]
+	}
+	return v
+}
+
+// M := M + alpha*u*v'
+func rankOne(M, u, v *matrix.FloatMatrix, alpha float64) {
+	for j := 0; j < M.Cols(); j++ {
+		for i := 0; i < M.Rows(); i++ {
+			M.SetAt(i, j, M.GetAt(i, j)+alpha*u.GetIndex(i)*v.GetIndex(j))
+		}
+	}
+}
+
+// Returns B'*B.
+func gram(B *matrix.FloatMatrix) *matrix.FloatMatrix {
+	n := B.Cols()
+	P := matrix.FloatZeros(n, n)
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			v := 0.0
+			for k := 0; k < B.Rows(); k++ {
+				v += B.GetAt(k, i) * B.GetAt(k, j)
+			}
+			P.SetAt(i, j, v)
+		}
+	}
+	return P
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
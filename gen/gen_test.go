@@ -0,0 +1,117 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/gen package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package gen
+
+import (
+	"github.com/hrautila/go.opt/cvx"
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"testing"
+)
+
+func maxAbsDiff(x, y *matrix.FloatMatrix) float64 {
+	xa, ya := x.FloatArray(), y.FloatArray()
+	d := 0.0
+	for k := range xa {
+		d = math.Max(d, math.Abs(xa[k]-ya[k]))
+	}
+	return d
+}
+
+// The generated pair satisfies the optimality conditions.
+func TestOptimality(t *testing.T) {
+	g := New(1)
+	dims := cvx.DSetNew("l", "q", "s")
+	dims.Set("l", []int{6})
+	dims.Set("q", []int{1, 3, 4})
+	dims.Set("s", []int{2, 3})
+	inst, err := g.ConeLp(5, 2, dims, cvx.Optimal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// dual residual, primal residuals and complementarity
+	rd := plus(plus(times(inst.G, inst.Z, true), times(inst.A, inst.Y, true)), inst.C)
+	rp := plus(times(inst.G, inst.X, false), inst.S)
+	if e := math.Max(dot(rd, rd), maxAbsDiff(rp, inst.H)); e > 1e-12 {
+		t.Errorf("residual %.3e\n", e)
+	}
+	if e := maxAbsDiff(times(inst.A, inst.X, false), inst.B); e > 1e-12 {
+		t.Errorf("equality residual %.3e\n", e)
+	}
+	if e := math.Abs(dot(inst.S, inst.Z)); e > 1e-12 {
+		t.Errorf("s'*z = %.3e\n", e)
+	}
+	if d := inst.Objective + dot(inst.H, inst.Z) + dot(inst.B, inst.Y); math.Abs(d) > 1e-10 {
+		t.Errorf("duality gap %.3e\n", d)
+	}
+}
+
+// The solvers find the known optimum.
+func TestSolve(t *testing.T) {
+	g := New(2)
+	solopts := &cvx.SolverOptions{MaxIter: 30}
+	check := func(name string, inst *Instance, sol *cvx.Solution, err error) {
+		if err != nil {
+			t.Errorf("%s: %s\n", name, err)
+			return
+		}
+		if e := maxAbsDiff(sol.X, inst.X); e > 1e-5 {
+			t.Errorf("%s: ||x - x*|| = %.3e\n", name, e)
+		}
+		if e := math.Abs(sol.PrimalObjective - inst.Objective); e > 1e-5*math.Max(1.0, math.Abs(inst.Objective)) {
+			t.Errorf("%s: objective %.9f, expected %.9f\n", name, sol.PrimalObjective, inst.Objective)
+		}
+	}
+
+	lp, _ := g.Lp(8, 12, 2, cvx.Optimal)
+	sol, err := cvx.Lp(lp.C, lp.G, lp.H, lp.A, lp.B, solopts, nil, nil)
+	check("lp", lp, sol, err)
+
+	qp, _ := g.Qp(6, 8, 1, cvx.Optimal)
+	sol, err = cvx.Qp(qp.P, qp.C, qp.G, qp.H, qp.A, qp.B, solopts, nil)
+	check("qp", qp, sol, err)
+
+	socp, _ := g.Socp(5, 4, []int{3, 4}, 1, cvx.Optimal)
+	Gl, hl, Ghq := socp.SocpData()
+	sol, err = cvx.Socp(socp.C, Gl, hl, socp.A, socp.B, Ghq, solopts, nil, nil)
+	check("socp", socp, sol, err)
+
+	sdp, _ := g.Sdp(4, 4, []int{2, 3}, 0, cvx.Optimal)
+	Gl, hl, Ghs := sdp.SdpData()
+	sol, err = cvx.Sdp(sdp.C, Gl, hl, sdp.A, sdp.B, Ghs, solopts, nil, nil)
+	check("sdp", sdp, sol, err)
+}
+
+// The certificates of the infeasible variants are valid and the solver
+// detects the infeasibility.
+func TestInfeasible(t *testing.T) {
+	g := New(3)
+	dims := cvx.DSetNew("l", "q", "s")
+	dims.Set("l", []int{4})
+	dims.Set("q", []int{3})
+	dims.Set("s", []int{2})
+	for _, status := range []cvx.StatusCode{cvx.PrimalInfeasible, cvx.DualInfeasible} {
+		inst, err := g.ConeLp(4, 1, dims, status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := inst.Certificate.Verify(inst.C, inst.G, inst.H, inst.A, inst.B, dims, 1e-10); err != nil {
+			t.Errorf("status %d: certificate residual %.3e: %s\n", status, res, err)
+		}
+		sol, _ := cvx.ConeLp(inst.C, inst.G, inst.H, inst.A, inst.B, dims, &cvx.SolverOptions{MaxIter: 30}, nil, nil)
+		if sol == nil || sol.Status != status {
+			t.Errorf("status %d: solver did not detect infeasibility\n", status)
+		}
+	}
+	if _, err := g.Lp(3, 0, 1, cvx.PrimalInfeasible); err == nil {
+		t.Errorf("primal infeasible instance without inequalities\n")
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
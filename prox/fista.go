@@ -0,0 +1,224 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/prox package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+// Package prox implements the accelerated proximal gradient method FISTA
+// for composite objectives f(x) + g(x) with smooth f and nonsmooth g, and
+// a library of proximal operators.
+package prox
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Default parameters of Fista.
+const (
+	MAXITERS = 1000
+	TOL      = 1e-8
+	// Step size reduction factor of the backtracking line search.
+	BACKTRACK = 0.5
+)
+
+// Smooth part f of the objective.
+type Smooth interface {
+	// Returns f(x) and, if grad is not nil, stores the gradient of f at x
+	// in grad. If x is not in dom f, returns non-nil error.
+	F(x, grad *matrix.FloatMatrix) (f float64, err error)
+}
+
+// Nonsmooth part g of the objective.
+type Operator interface {
+	// Returns g(x). Indicator functions return 0 or math.Inf(1).
+	Value(x *matrix.FloatMatrix) float64
+	// Replaces x with the proximal point
+	//
+	//     prox_{t*g}(x) = argmin_u g(u) + (1/(2*t))*||u - x||_2^2.
+	//
+	Prox(x *matrix.FloatMatrix, t float64) error
+}
+
+// Parameters of Fista. Zero values select the defaults.
+type Options struct {
+	MaxIter int
+	// Stop when ||x_k - x_{k-1}||_2 <= Tol*max(1, ||x_k||_2).
+	Tol float64
+	// Initial step size 1/L; 1.0 if zero.
+	Step float64
+	// Step size reduction factor in (0,1); BACKTRACK if zero.
+	Backtrack float64
+	// Disable adaptive restart of the momentum.
+	NoRestart    bool
+	ShowProgress bool
+}
+
+// Result of Fista.
+type Result struct {
+	X *matrix.FloatMatrix
+	// f(X) + g(X)
+	Objective  float64
+	Iterations int
+	// Number of momentum restarts.
+	Restarts int
+	// Final step size.
+	Step      float64
+	Converged bool
+}
+
+//    Minimizes f(x) + g(x) with the fast iterative shrinkage-thresholding
+//    algorithm of Beck and Teboulle.
+//
+//    The step size is found by backtracking: starting from the previous step
+//    t it is reduced by the factor Backtrack until
+//
+//        f(z) <= f(y) + grad f(y)'*(z - y) + (1/(2*t))*||z - y||^2
+//
+//    with z = prox_{t*g}(y - t*grad f(y)). Unless NoRestart is set the
+//    momentum is reset when (y - z)'*(z - x) > 0 (gradient restart of
+//    O'Donoghue and Candes), which restores linear convergence on strongly
+//    convex problems.
+//
+//    x0 is the starting point and is not modified; it may be any matrix
+//    shape that f and g accept. opts may be nil.
+//
+func Fista(f Smooth, g Operator, x0 *matrix.FloatMatrix, opts *Options) (res *Result, err error) {
+
+	if f == nil || g == nil || x0 == nil {
+		err = errors.New("f, g and x0 must be non-nil")
+		return
+	}
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxIter <= 0 {
+		o.MaxIter = MAXITERS
+	}
+	if o.Tol <= 0.0 {
+		o.Tol = TOL
+	}
+	if o.Step <= 0.0 {
+		o.Step = 1.0
+	}
+	if o.Backtrack <= 0.0 || o.Backtrack >= 1.0 {
+		o.Backtrack = BACKTRACK
+	}
+
+	x := x0.Copy()
+	if err = g.Prox(x, o.Step); err != nil {
+		return
+	}
+	y := x.Copy()
+	z := x.Copy()
+	grad := matrix.FloatZeros(x.Rows(), x.Cols())
+	xa, ya, za, ga := x.FloatArray(), y.FloatArray(), z.FloatArray(), grad.FloatArray()
+
+	res = &Result{}
+	t := o.Step
+	theta := 1.0
+	if o.ShowProgress {
+		fmt.Printf("% 6s% 16s% 12s% 12s\n", "iter", "objective", "step", "change")
+	}
+	for res.Iterations = 0; res.Iterations < o.MaxIter; res.Iterations++ {
+		fy, ferr := f.F(y, grad)
+		if ferr != nil {
+			err = ferr
+			res = nil
+			return
+		}
+		if math.IsNaN(fy) || math.IsInf(fy, 0) {
+			err = errors.New(fmt.Sprintf("f not finite at iteration %d", res.Iterations))
+			res = nil
+			return
+		}
+		// backtracking line search
+		for {
+			for k := range za {
+				za[k] = ya[k] - t*ga[k]
+			}
+			if err = g.Prox(z, t); err != nil {
+				res = nil
+				return
+			}
+			fz, ferr := f.F(z, nil)
+			if ferr == nil && !math.IsNaN(fz) {
+				// quadratic upper bound at y
+				q := fy
+				for k := range za {
+					d := za[k] - ya[k]
+					q += ga[k]*d + d*d/(2.0*t)
+				}
+				if fz <= q+1e-12*math.Abs(fy) {
+					break
+				}
+			}
+			t *= o.Backtrack
+			if t < 1e-20 {
+				err = errors.New("step size underflow in line search")
+				res = nil
+				return
+			}
+		}
+
+		// change ||z - x|| and restart test (y - z)'*(z - x)
+		change, restart := 0.0, 0.0
+		for k := range za {
+			d := za[k] - xa[k]
+			change += d * d
+			restart += (ya[k] - za[k]) * d
+		}
+		change = math.Sqrt(change)
+		nrmz := math.Sqrt(dot(za, za))
+
+		thetaNew := 0.5 * (1.0 + math.Sqrt(1.0+4.0*theta*theta))
+		beta := (theta - 1.0) / thetaNew
+		if !o.NoRestart && restart > 0.0 {
+			beta, thetaNew = 0.0, 1.0
+			res.Restarts++
+		}
+		for k := range za {
+			ya[k] = za[k] + beta*(za[k]-xa[k])
+			xa[k] = za[k]
+		}
+		theta = thetaNew
+
+		if o.ShowProgress {
+			fz, _ := f.F(x, nil)
+			fmt.Printf("% 6d% 16.8e% 12.4e% 12.4e\n", res.Iterations, fz+g.Value(x), t, change)
+		}
+		if change <= o.Tol*math.Max(1.0, nrmz) {
+			res.Converged = true
+			res.Iterations++
+			break
+		}
+	}
+	fx, ferr := f.F(x, nil)
+	if ferr != nil {
+		err = ferr
+		res = nil
+		return
+	}
+	res.X = x
+	res.Objective = fx + g.Value(x)
+	res.Step = t
+	if !res.Converged {
+		err = errors.New("Terminated (maximum iterations reached)")
+	}
+	return
+}
+
+func dot(a, b []float64) float64 {
+	s := 0.0
+	for k := range a {
+		s += a[k] * b[k]
+	}
+	return s
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
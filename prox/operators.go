@@ -0,0 +1,223 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/prox package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package prox
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Tolerance of the set membership tests of the indicator functions.
+const FEASTOL = 1e-9
+
+// g(x) = 0.
+type Zero struct{}
+
+func (g *Zero) Value(x *matrix.FloatMatrix) float64 {
+	return 0.0
+}
+
+func (g *Zero) Prox(x *matrix.FloatMatrix, t float64) error {
+	return nil
+}
+
+// g(x) = Lambda*||x||_1. The proximal operator is soft thresholding.
+type L1 struct {
+	Lambda float64
+}
+
+func (g *L1) Value(x *matrix.FloatMatrix) float64 {
+	s := 0.0
+	for _, v := range x.FloatArray() {
+		s += math.Abs(v)
+	}
+	return g.Lambda * s
+}
+
+func (g *L1) Prox(x *matrix.FloatMatrix, t float64) error {
+	softThreshold(x.FloatArray(), t*g.Lambda)
+	return nil
+}
+
+// g(x) = Lambda*sum_k w_k*||x[Groups[k]]||_2 with w_k = sqrt(len(Groups[k]))
+// if Weighted is set and 1 otherwise. Groups must not overlap; elements not
+// in any group are not penalized.
+type GroupLasso struct {
+	Lambda   float64
+	Groups   [][]int
+	Weighted bool
+}
+
+func (g *GroupLasso) weight(k int) float64 {
+	if g.Weighted {
+		return math.Sqrt(float64(len(g.Groups[k])))
+	}
+	return 1.0
+}
+
+func (g *GroupLasso) Value(x *matrix.FloatMatrix) float64 {
+	xa := x.FloatArray()
+	s := 0.0
+	for k, grp := range g.Groups {
+		nrm := 0.0
+		for _, i := range grp {
+			nrm += xa[i] * xa[i]
+		}
+		s += g.weight(k) * math.Sqrt(nrm)
+	}
+	return g.Lambda * s
+}
+
+func (g *GroupLasso) Prox(x *matrix.FloatMatrix, t float64) error {
+	xa := x.FloatArray()
+	for k, grp := range g.Groups {
+		nrm := 0.0
+		for _, i := range grp {
+			if i < 0 || i >= len(xa) {
+				return errors.New(fmt.Sprintf("group %d index %d out of range", k, i))
+			}
+			nrm += xa[i] * xa[i]
+		}
+		nrm = math.Sqrt(nrm)
+		// block soft thresholding
+		scale := 0.0
+		if tl := t * g.Lambda * g.weight(k); nrm > tl {
+			scale = 1.0 - tl/nrm
+		}
+		for _, i := range grp {
+			xa[i] *= scale
+		}
+	}
+	return nil
+}
+
+// Indicator of the nonnegative orthant x >= 0.
+type NonNegative struct{}
+
+func (g *NonNegative) Value(x *matrix.FloatMatrix) float64 {
+	for _, v := range x.FloatArray() {
+		if v < -FEASTOL {
+			return math.Inf(1)
+		}
+	}
+	return 0.0
+}
+
+func (g *NonNegative) Prox(x *matrix.FloatMatrix, t float64) error {
+	xa := x.FloatArray()
+	for k := range xa {
+		xa[k] = math.Max(xa[k], 0.0)
+	}
+	return nil
+}
+
+// Indicator of the box Lower <= x <= Upper. Lower and Upper are matrices of
+// the size of x or nil for unbounded.
+type Box struct {
+	Lower, Upper *matrix.FloatMatrix
+}
+
+func (g *Box) Value(x *matrix.FloatMatrix) float64 {
+	for k, v := range x.FloatArray() {
+		if g.Lower != nil && v < g.Lower.GetIndex(k)-FEASTOL {
+			return math.Inf(1)
+		}
+		if g.Upper != nil && v > g.Upper.GetIndex(k)+FEASTOL {
+			return math.Inf(1)
+		}
+	}
+	return 0.0
+}
+
+func (g *Box) Prox(x *matrix.FloatMatrix, t float64) error {
+	xa := x.FloatArray()
+	n := len(xa)
+	if (g.Lower != nil && g.Lower.NumElements() != n) ||
+		(g.Upper != nil && g.Upper.NumElements() != n) {
+		return errors.New(fmt.Sprintf("box bounds must have %d elements", n))
+	}
+	for k := range xa {
+		if g.Lower != nil {
+			xa[k] = math.Max(xa[k], g.Lower.GetIndex(k))
+		}
+		if g.Upper != nil {
+			xa[k] = math.Min(xa[k], g.Upper.GetIndex(k))
+		}
+	}
+	return nil
+}
+
+// Indicator of the simplex {x | x >= 0, sum(x) = Radius}. Radius 0 means 1.
+type Simplex struct {
+	Radius float64
+}
+
+func (g *Simplex) radius() float64 {
+	if g.Radius <= 0.0 {
+		return 1.0
+	}
+	return g.Radius
+}
+
+func (g *Simplex) Value(x *matrix.FloatMatrix) float64 {
+	s := 0.0
+	for _, v := range x.FloatArray() {
+		if v < -FEASTOL {
+			return math.Inf(1)
+		}
+		s += v
+	}
+	if math.Abs(s-g.radius()) > FEASTOL*float64(x.NumElements()) {
+		return math.Inf(1)
+	}
+	return 0.0
+}
+
+// Euclidean projection onto the simplex by sorting (Held, Wolfe and
+// Crowder; Duchi et al.).
+func (g *Simplex) Prox(x *matrix.FloatMatrix, t float64) error {
+	xa := x.FloatArray()
+	if len(xa) == 0 {
+		return errors.New("projection onto empty simplex")
+	}
+	u := make([]float64, len(xa))
+	copy(u, xa)
+	sort.Sort(sort.Reverse(sort.Float64Slice(u)))
+	r := g.radius()
+	s, tau := 0.0, 0.0
+	for k, v := range u {
+		s += v
+		if tk := (s - r) / float64(k+1); v > tk {
+			tau = tk
+		}
+	}
+	for k := range xa {
+		xa[k] = math.Max(xa[k]-tau, 0.0)
+	}
+	return nil
+}
+
+// Replaces each x[k] with sign(x[k])*max(|x[k]| - tau, 0).
+func softThreshold(x []float64, tau float64) {
+	for k, v := range x {
+		switch {
+		case v > tau:
+			x[k] = v - tau
+		case v < -tau:
+			x[k] = v + tau
+		default:
+			x[k] = 0.0
+		}
+	}
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,220 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/prox package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package prox
+
+import (
+	"github.com/hrautila/go.opt/matrix"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// f(x) = (1/2)*||A*x - b||_2^2
+type leastSquares struct {
+	A, b *matrix.FloatMatrix
+}
+
+func (ls *leastSquares) F(x, grad *matrix.FloatMatrix) (float64, error) {
+	m, n := ls.A.Size()
+	r := make([]float64, m)
+	f := 0.0
+	for i := 0; i < m; i++ {
+		r[i] = -ls.b.GetIndex(i)
+		for j := 0; j < n; j++ {
+			r[i] += ls.A.GetAt(i, j) * x.GetIndex(j)
+		}
+		f += 0.5 * r[i] * r[i]
+	}
+	if grad != nil {
+		for j := 0; j < n; j++ {
+			g := 0.0
+			for i := 0; i < m; i++ {
+				g += ls.A.GetAt(i, j) * r[i]
+			}
+			grad.SetIndex(j, g)
+		}
+	}
+	return f, nil
+}
+
+func checkClose(t *testing.T, name string, x *matrix.FloatMatrix, ref []float64, tol float64) {
+	for k, v := range ref {
+		if math.Abs(x.GetIndex(k)-v) > tol {
+			t.Fatalf("%s: got %v, want %v", name, x.FloatArray(), ref)
+		}
+	}
+}
+
+func TestProxOperators(t *testing.T) {
+	x := matrix.FloatVector([]float64{3.0, -0.5, -2.0, 1.0})
+	(&L1{2.0}).Prox(x, 0.5)
+	checkClose(t, "l1", x, []float64{2.0, 0.0, -1.0, 0.0}, 1e-12)
+
+	x = matrix.FloatVector([]float64{3.0, 4.0, 0.1, 0.1})
+	(&GroupLasso{Lambda: 1.0, Groups: [][]int{{0, 1}, {2, 3}}}).Prox(x, 1.0)
+	checkClose(t, "group lasso", x, []float64{2.4, 3.2, 0.0, 0.0}, 1e-12)
+
+	x = matrix.FloatVector([]float64{0.5, 1.2, -0.3})
+	(&Simplex{}).Prox(x, 1.0)
+	checkClose(t, "simplex", x, []float64{0.15, 0.85, 0.0}, 1e-12)
+
+	x = matrix.FloatVector([]float64{-1.0, 0.5, 2.0})
+	lo := matrix.FloatVector([]float64{0.0, 0.0, 0.0})
+	up := matrix.FloatVector([]float64{1.0, 1.0, 1.0})
+	(&Box{lo, up}).Prox(x, 1.0)
+	checkClose(t, "box", x, []float64{0.0, 0.5, 1.0}, 1e-12)
+}
+
+func TestFistaLasso(t *testing.T) {
+	// orthogonal design: the solution is soft thresholding of b
+	A := matrix.FloatIdentity(4)
+	b := matrix.FloatVector([]float64{3.0, -0.2, -1.5, 0.7})
+	res, err := Fista(&leastSquares{A, b}, &L1{0.5}, matrix.FloatZeros(4, 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClose(t, "lasso", res.X, []float64{2.5, 0.0, -1.0, 0.2}, 1e-6)
+
+	// nonnegative least squares
+	A = matrix.FloatNew(3, 2, []float64{1.0, 1.0, 0.0, 0.0, 1.0, 1.0})
+	b = matrix.FloatVector([]float64{1.0, -2.0, -1.0})
+	res, err = Fista(&leastSquares{A, b}, &NonNegative{}, matrix.FloatZeros(2, 1),
+		&Options{MaxIter: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClose(t, "nnls", res.X, []float64{0.0, 0.0}, 1e-6)
+}
+
+// With f(x) = (1/2)*||3*x - b||^2, L = 9 and the line search halves the
+// initial step 1 to 1/16, the first step below 1/L.
+func TestFistaBacktracking(t *testing.T) {
+	A := matrix.FloatDiagonal(2, 3.0)
+	b := matrix.FloatVector([]float64{3.0, -6.0})
+	res, err := Fista(&leastSquares{A, b}, &Zero{}, matrix.FloatZeros(2, 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Step != 0.0625 {
+		t.Errorf("step %v, expected 0.0625\n", res.Step)
+	}
+	checkClose(t, "backtracking", res.X, []float64{1.0, -2.0}, 1e-8)
+
+	res, err = Fista(&leastSquares{A, b}, &Zero{}, matrix.FloatZeros(2, 1),
+		&Options{Step: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Step != 0.1 {
+		t.Errorf("step %v reduced from 0.1 < 1/L\n", res.Step)
+	}
+}
+
+// On an ill-conditioned strongly convex problem the gradient restart
+// resets the momentum and converges in fewer iterations than plain FISTA.
+func TestFistaRestart(t *testing.T) {
+	A := matrix.FloatNew(2, 2, []float64{1.0, 0.0, 0.0, 0.05})
+	b := matrix.FloatVector([]float64{1.0, 1.0})
+	f := &leastSquares{A, b}
+	res, err := Fista(f, &Zero{}, matrix.FloatZeros(2, 1), &Options{MaxIter: 20000, Tol: 1e-10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Restarts == 0 {
+		t.Errorf("no restarts in %d iterations\n", res.Iterations)
+	}
+	checkClose(t, "restart", res.X, []float64{1.0, 20.0}, 1e-4)
+
+	plain, _ := Fista(f, &Zero{}, matrix.FloatZeros(2, 1), &Options{MaxIter: 20000, Tol: 1e-10, NoRestart: true})
+	if plain.Restarts != 0 {
+		t.Errorf("%d restarts with NoRestart\n", plain.Restarts)
+	}
+	if res.Iterations >= plain.Iterations {
+		t.Errorf("%d iterations with restart, %d without\n", res.Iterations, plain.Iterations)
+	}
+}
+
+func TestSpectral(t *testing.T) {
+	X := matrix.FloatNew(2, 2, []float64{2.0, 0.0, 0.0, -1.0})
+	(&PSD{}).Prox(X, 1.0)
+	checkClose(t, "psd", X, []float64{2.0, 0.0, 0.0, 0.0}, 1e-10)
+
+	X = matrix.FloatNew(2, 2, []float64{3.0, 0.0, 0.0, 0.5})
+	(&Nuclear{1.0}).Prox(X, 1.0)
+	checkClose(t, "nuclear", X, []float64{2.0, 0.0, 0.0, 0.0}, 1e-10)
+	if v := (&Nuclear{1.0}).Value(X); math.Abs(v-2.0) > 1e-10 {
+		t.Fatalf("nuclear norm %v", v)
+	}
+
+	// [1 2; 2 1] has eigenvalues 3 and -1, the projection is (3/2)*[1 1; 1 1]
+	X = matrix.FloatNew(2, 2, []float64{1.0, 2.0, 2.0, 1.0})
+	(&PSD{}).Prox(X, 1.0)
+	checkClose(t, "psd", X, []float64{1.5, 1.5, 1.5, 1.5}, 1e-10)
+	// only the symmetric part of a nonsymmetric matrix is projected
+	X = matrix.FloatNew(2, 2, []float64{1.0, 3.0, 1.0, 1.0})
+	(&PSD{}).Prox(X, 1.0)
+	checkClose(t, "psd, nonsymmetric", X, []float64{1.5, 1.5, 1.5, 1.5}, 1e-10)
+
+	// U*diag(3, 0.5)*V' with rotations U and V thresholds to U*diag(2, 0)*V'
+	U := rotation(0.3)
+	V := rotation(-1.1)
+	X = U.Times(matrix.FloatDiagonal(2, 3.0, 0.5)).Times(V.Transpose())
+	(&Nuclear{1.0}).Prox(X, 1.0)
+	R := U.Times(matrix.FloatDiagonal(2, 2.0, 0.0)).Times(V.Transpose())
+	checkClose(t, "nuclear, rotated", X, R.FloatArray(), 1e-10)
+	if v := (&Nuclear{0.5}).Value(X); math.Abs(v-1.0) > 1e-10 {
+		t.Fatalf("nuclear norm %v, expected 1.0", v)
+	}
+	// rank one 2x3 matrix 3*u*v' with unit u and v
+	X = matrix.FloatNew(2, 3, []float64{1.8, 2.4, 1.8, 2.4, 1.8, 2.4}).Scale(1.0 / math.Sqrt(3.0))
+	(&Nuclear{1.0}).Prox(X, 0.5)
+	R = matrix.FloatNew(2, 3, []float64{1.5, 2.0, 1.5, 2.0, 1.5, 2.0}).Scale(1.0 / math.Sqrt(3.0))
+	checkClose(t, "nuclear, 2x3", X, R.FloatArray(), 1e-10)
+}
+
+// The projection P onto the PSD cone of a random symmetric 4x4 matrix X is
+// PSD and idempotent, and P - X is orthogonal to P.
+func TestPSDRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(11))
+	n := 4
+	X := matrix.FloatZeros(n, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			v := rnd.NormFloat64()
+			X.SetAt(i, j, v)
+			X.SetAt(j, i, v)
+		}
+	}
+	P := X.Copy()
+	if err := (&PSD{}).Prox(P, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	if v := (&PSD{}).Value(P); v != 0.0 {
+		t.Errorf("projection not PSD\n")
+	}
+	PP := P.Copy()
+	(&PSD{}).Prox(PP, 1.0)
+	checkClose(t, "psd, idempotent", PP, P.FloatArray(), 1e-10)
+	ip := 0.0
+	pa, xa := P.FloatArray(), X.FloatArray()
+	for k := range pa {
+		ip += pa[k] * (pa[k] - xa[k])
+	}
+	if math.Abs(ip) > 1e-10 {
+		t.Errorf("<P, P - X> = %.3e\n", ip)
+	}
+}
+
+// Rotation by angle a.
+func rotation(a float64) *matrix.FloatMatrix {
+	c, s := math.Cos(a), math.Sin(a)
+	return matrix.FloatNew(2, 2, []float64{c, s, -s, c})
+}
+
+// Local Variables:
+// tab-width: 4
+// End:
@@ -0,0 +1,144 @@
+// Copyright (c) Harri Rautila, 2012
+
+// This file is part of go.opt/prox package. It is free software, distributed
+// under the terms of GNU Lesser General Public License Version 3, or any later
+// version. See the COPYING tile included in this archive.
+
+package prox
+
+import (
+	la "github.com/hrautila/go.opt/linalg"
+	"github.com/hrautila/go.opt/linalg/blas"
+	"github.com/hrautila/go.opt/linalg/lapack"
+	"github.com/hrautila/go.opt/matrix"
+	"errors"
+	"math"
+)
+
+// g(X) = Lambda*||X||_*, the sum of the singular values of the matrix X.
+// The proximal operator is singular value soft thresholding.
+type Nuclear struct {
+	Lambda float64
+}
+
+func (g *Nuclear) Value(x *matrix.FloatMatrix) float64 {
+	m, n := x.Size()
+	k := min(m, n)
+	if k == 0 {
+		return 0.0
+	}
+	s := matrix.FloatZeros(k, 1)
+	if err := lapack.GesvdFloat(x.Copy(), s, nil, nil); err != nil {
+		return math.NaN()
+	}
+	nrm := 0.0
+	for _, v := range s.FloatArray() {
+		nrm += v
+	}
+	return g.Lambda * nrm
+}
+
+func (g *Nuclear) Prox(x *matrix.FloatMatrix, t float64) error {
+	m, n := x.Size()
+	k := min(m, n)
+	if k == 0 {
+		return nil
+	}
+	s := matrix.FloatZeros(k, 1)
+	U := matrix.FloatZeros(m, k)
+	Vt := matrix.FloatZeros(k, n)
+	err := lapack.GesvdFloat(x.Copy(), s, U, Vt, la.OptJobuS, la.OptJobvtS)
+	if err != nil {
+		return err
+	}
+	// X = U*diag(max(s - t*lambda, 0))*Vt
+	tl := t * g.Lambda
+	for j := 0; j < k; j++ {
+		sj := math.Max(s.GetIndex(j)-tl, 0.0)
+		blas.ScalFloat(U, sj, &la.IOpt{"offset", j * m}, &la.IOpt{"n", m})
+	}
+	return blas.GemmFloat(U, Vt, x, 1.0, 0.0)
+}
+
+// Indicator of the cone of positive semidefinite matrices. The argument is
+// a square matrix; its symmetric part is projected.
+type PSD struct{}
+
+func (g *PSD) Value(x *matrix.FloatMatrix) float64 {
+	n := x.Rows()
+	if n != x.Cols() {
+		return math.Inf(1)
+	}
+	if n == 0 {
+		return 0.0
+	}
+	nrm := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			if math.Abs(x.GetAt(i, j)-x.GetAt(j, i)) > FEASTOL*(1.0+math.Abs(x.GetAt(i, j))) {
+				return math.Inf(1)
+			}
+		}
+		nrm = math.Max(nrm, math.Abs(x.GetAt(i, i)))
+	}
+	// smallest eigenvalue
+	w := matrix.FloatZeros(n, 1)
+	err := lapack.SyevrFloat(x.Copy(), w, nil, 0.0, nil, []int{1, 1}, la.OptRangeInt)
+	if err != nil {
+		return math.NaN()
+	}
+	if w.GetIndex(0) < -FEASTOL*(1.0+nrm) {
+		return math.Inf(1)
+	}
+	return 0.0
+}
+
+func (g *PSD) Prox(x *matrix.FloatMatrix, t float64) error {
+	n := x.Rows()
+	if n != x.Cols() {
+		return errors.New("PSD projection of non-square matrix")
+	}
+	if n == 0 {
+		return nil
+	}
+	A := matrix.FloatZeros(n, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			A.SetAt(i, j, 0.5*(x.GetAt(i, j)+x.GetAt(j, i)))
+		}
+	}
+	w := matrix.FloatZeros(n, 1)
+	Z := matrix.FloatZeros(n, n)
+	err := lapack.SyevrFloat(A, w, Z, 0.0, nil, nil, la.OptJobZValue)
+	if err != nil {
+		return err
+	}
+	// X = Z*diag(max(w, 0))*Z' = sum_j w_j*z_j*z_j'
+	blas.ScalFloat(x, 0.0)
+	for j := 0; j < n; j++ {
+		wj := w.GetIndex(j)
+		if wj <= 0.0 {
+			continue
+		}
+		blas.ScalFloat(Z, math.Sqrt(wj), &la.IOpt{"offset", j * n}, &la.IOpt{"n", n})
+		blas.SyrkFloat(Z, x, 1.0, 1.0, &la.IOpt{"k", 1}, &la.IOpt{"offseta", j * n})
+	}
+	// symmetric result from the lower triangle
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			x.SetAt(j, i, x.GetAt(i, j))
+		}
+	}
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Local Variables:
+// tab-width: 4
+// End: